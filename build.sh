@@ -0,0 +1,5 @@
+#! /bin/sh
+# sqlite_fts5 compiles SQLite with the FTS5 module searchRequests needs and
+# body filters use; a plain go build has no searchRequests and filters bodies
+# by scanning them
+go build -tags sqlite_fts5 "$@" .
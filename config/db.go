@@ -7,6 +7,7 @@ import (
 	"os"
 	"time"
 
+	"github.com/linn221/bane/models"
 	"gorm.io/driver/mysql"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
@@ -35,11 +36,18 @@ func ConnectMySQL() *gorm.DB {
 	return db
 }
 
+// SQLite opens the database at path with models.SQLiteDriver, so that
+// writers, such as the background scanner, queue for the lock instead of
+// failing with "database is locked"
+func SQLite(path string) gorm.Dialector {
+	return sqlite.New(sqlite.Config{DriverName: models.SQLiteDriver, DSN: path + "?_busy_timeout=10000&_txlock=immediate"})
+}
+
 func ConnectSQLite() *gorm.DB {
 	// Use app.db as the SQLite database file
 	dbPath := "app.db"
 	var err error
-	db, err := gorm.Open(sqlite.Open(dbPath), initConfig())
+	db, err := gorm.Open(SQLite(dbPath), initConfig())
 	if err != nil {
 		panic("Fail To Connect SQLite Database")
 	}
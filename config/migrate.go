@@ -1,7 +1,11 @@
 package config
 
 import (
+	"log"
+	"time"
+
 	"github.com/linn221/bane/models"
+	"github.com/linn221/bane/mystructs"
 	"gorm.io/gorm"
 )
 
@@ -15,10 +19,194 @@ func migrate(db *gorm.DB) {
 		&models.Word{},
 		&models.Project{},
 		&models.MyRequest{},
+		&models.RedirectHop{},
+		&models.TlsCertificate{},
 		&models.Alias{},
+		&models.Sequence{},
+		&models.SequenceStep{},
+		&models.Variable{},
+		&models.Environment{},
+		&models.Cookie{},
+		&models.ResponseBlob{},
+		&models.Issue{},
+		&models.IssueRequest{},
 		// &models.Taggable{},
 	)
 	if err != nil {
 		panic("Error migrating tables: " + err.Error())
 	}
+	for _, model := range []any{&models.MyRequest{}, &models.Request{}} {
+		if err := migrateResponseBodies(db, model); err != nil {
+			panic("Error migrating response bodies: " + err.Error())
+		}
+	}
+	if err := migrateRequestHosts(db); err != nil {
+		panic("Error migrating request hosts: " + err.Error())
+	}
+	if err := runOnce(db, "escape_kv_groups", migrateKVGroups); err != nil {
+		panic("Error migrating key-value groups: " + err.Error())
+	}
+	if err := createBlobCleanupTriggers(db); err != nil {
+		panic("Error creating response blob triggers: " + err.Error())
+	}
+	// left behind by requests deleted before the triggers existed
+	if _, err := models.DeleteOrphanBlobs(db); err != nil {
+		log.Printf("deleting unused response blobs: %v", err)
+	}
+	if err := SetupRequestSearch(db); err != nil {
+		log.Printf("searchRequests is disabled: %v", err)
+	}
+}
+
+// createBlobCleanupTriggers makes deleting the last request with a response
+// body delete its blob too. Requests are deleted with raw statements, which
+// skip GORM callbacks.
+func createBlobCleanupTriggers(db *gorm.DB) error {
+	for _, table := range []string{"my_requests", "requests"} {
+		statement := "DELETE FROM response_blobs WHERE hash = old.response_body_hash" +
+			" AND NOT EXISTS (SELECT 1 FROM my_requests WHERE response_body_hash = old.response_body_hash)" +
+			" AND NOT EXISTS (SELECT 1 FROM requests WHERE response_body_hash = old.response_body_hash)"
+		if db.Dialector.Name() == "sqlite" {
+			statement = "BEGIN " + statement + "; END"
+		}
+		err := db.Exec("CREATE TRIGGER IF NOT EXISTS " + table + "_blob_cleanup AFTER DELETE ON " + table + " FOR EACH ROW " + statement).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateResponseBodies moves the bodies databases from before ResponseBlob
+// keep inline in the model's response_body column into blobs, then drops the
+// column
+func migrateResponseBodies(db *gorm.DB, model any) error {
+	if !db.Migrator().HasColumn(model, "response_body") {
+		return nil
+	}
+	type row struct {
+		Id           int
+		ResponseBody string
+	}
+	for {
+		var rows []row
+		err := db.Model(model).Select("id, response_body").
+			Where("response_body_hash IS NULL AND response_body IS NOT NULL AND response_body <> ''").
+			Order("id").Limit(500).Find(&rows).Error
+		if err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			break
+		}
+		err = db.Transaction(func(tx *gorm.DB) error {
+			for _, r := range rows {
+				// stored whole; MAX_BODY_SIZE applies to new requests
+				hash, err := models.SaveResponseBlob(tx, r.ResponseBody)
+				if err != nil {
+					return err
+				}
+				if err := tx.Model(model).Where("id = ?", r.Id).UpdateColumn("response_body_hash", hash).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return db.Migrator().DropColumn(model, "response_body")
+}
+
+// migrateRequestHosts fills my_requests.request_host for the requests
+// recorded before it was added
+func migrateRequestHosts(db *gorm.DB) error {
+	type row struct {
+		Id         int
+		RequestUrl string
+	}
+	for {
+		var rows []row
+		err := db.Table("my_requests").Select("id, request_url").Where("request_host IS NULL").
+			Order("id").Limit(500).Find(&rows).Error
+		if err != nil || len(rows) == 0 {
+			return err
+		}
+		err = db.Transaction(func(tx *gorm.DB) error {
+			for _, r := range rows {
+				err := tx.Table("my_requests").Where("id = ?", r.Id).UpdateColumn("request_host", models.RequestHost(r.RequestUrl)).Error
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// migration records a one-off migration that has run, for those that cannot
+// tell old rows from new ones
+type migration struct {
+	Name      string `gorm:"primaryKey;size:64"`
+	AppliedAt time.Time
+}
+
+// runOnce runs fn in a transaction unless the migration called name has
+// already run
+func runOnce(db *gorm.DB, name string, fn func(tx *gorm.DB) error) error {
+	if err := db.AutoMigrate(&migration{}); err != nil {
+		return err
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&migration{}).Where("name = ?", name).Count(&count).Error; err != nil || count > 0 {
+			return err
+		}
+		if err := fn(tx); err != nil {
+			return err
+		}
+		return tx.Create(&migration{Name: name, AppliedAt: time.Now()}).Error
+	})
+}
+
+// kvGroupColumns are the columns that store a KVGroup or VarKVGroup
+var kvGroupColumns = []struct {
+	table   string
+	columns []string
+}{
+	{"endpoints", []string{"http_queries", "http_headers", "extractors"}},
+	{"environments", []string{"variables"}},
+	{"requests", []string{"http_queries", "http_headers", "http_cookies", "response_headers", "response_cookies"}},
+	{"sequence_steps", []string{"variables", "mappings"}},
+}
+
+// migrateKVGroups escapes the backslashes of groups stored before keys and
+// values could hold escaped whitespace, which would otherwise read back as
+// escapes
+func migrateKVGroups(tx *gorm.DB) error {
+	type row struct {
+		Id    int
+		Value string
+	}
+	for _, group := range kvGroupColumns {
+		for _, column := range group.columns {
+			var rows []row
+			err := tx.Table(group.table).Select("id, "+column+" AS value").
+				Where("INSTR("+column+", ?) > 0", `\`).Find(&rows).Error
+			if err != nil {
+				return err
+			}
+			for _, r := range rows {
+				err := tx.Table(group.table).Where("id = ?", r.Id).UpdateColumn(column, mystructs.EscapeLegacyFields(r.Value)).Error
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
 }
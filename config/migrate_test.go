@@ -0,0 +1,151 @@
+package config
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/linn221/bane/models"
+	"github.com/linn221/bane/mystructs"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type legacyMyRequest struct {
+	Id             int    `gorm:"primaryKey"`
+	EndpointId     int    `gorm:"not null;index"`
+	RequestMethod  string `gorm:"size:10;not null"`
+	RequestUrl     string `gorm:"not null"`
+	ResponseStatus int    `gorm:"not null"`
+	ResponseBody   string `gorm:"type:longtext"`
+	Latency        int64  `gorm:"not null"`
+}
+
+func (legacyMyRequest) TableName() string { return "my_requests" }
+
+func TestMigrate_MovesInlineResponseBodies(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the table as it was when bodies were stored inline
+	if err := db.AutoMigrate(&legacyMyRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	for _, body := range []string{"legacy", "legacy", "other", ""} {
+		if err := db.Create(&legacyMyRequest{RequestMethod: "GET", RequestUrl: "http://example.com/", ResponseStatus: 200, ResponseBody: body}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	migrate(db)
+	if db.Migrator().HasColumn(&models.MyRequest{}, "response_body") {
+		t.Error("response_body was not dropped")
+	}
+	var blobs int64
+	db.Model(&models.ResponseBlob{}).Count(&blobs)
+	if blobs != 2 {
+		t.Errorf("stored %d blobs for 2 distinct bodies", blobs)
+	}
+	var requests []*models.MyRequest
+	if err := db.Order("id").Find(&requests).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := models.LoadResponseBodies(db, requests...); err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []string{"legacy", "legacy", "other", ""} {
+		if requests[i].ResponseBody != want {
+			t.Errorf("request %d body = %q, want %q", requests[i].Id, requests[i].ResponseBody, want)
+		}
+		if requests[i].RequestHost != "example.com" {
+			t.Errorf("request %d host = %q", requests[i].Id, requests[i].RequestHost)
+		}
+	}
+	// migrating again is a no-op
+	migrate(db)
+}
+
+func TestMigrate_EscapesLegacyKVGroups(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.Environment{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&models.Environment{ProjectId: 1, Name: "legacy"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	// stored before escaping, when every backslash was literal
+	if err := db.Exec("UPDATE environments SET variables = ?", `dir:C:\\share\ plain:x`).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	want := []mystructs.KVPair{{Key: "dir", Value: `C:\\share\`}, {Key: "plain", Value: "x"}}
+	for range 2 { // migrating again is a no-op
+		migrate(db)
+		var env models.Environment
+		if err := db.First(&env).Error; err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(env.Variables.KVPairs, want) {
+			t.Errorf("variables = %+v, want %+v", env.Variables.KVPairs, want)
+		}
+	}
+}
+
+func TestMigrate_DeletesBlobsWithTheirRequests(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrate(db)
+	for _, body := range []string{"shared", "shared", "alone"} {
+		request := &models.MyRequest{RequestMethod: "GET", RequestUrl: "http://example.com/", ResponseBody: body}
+		if err := db.Create(request).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.Create(&models.Request{JobId: 1, ResponseBodyHash: mustSaveBlob(t, db, "step")}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	blobs := func() int64 {
+		var count int64
+		db.Model(&models.ResponseBlob{}).Count(&count)
+		return count
+	}
+	for _, c := range []struct {
+		delete string
+		want   int64
+	}{
+		{"DELETE FROM my_requests WHERE id = 1", 3}, // the other request still has it
+		{"DELETE FROM my_requests WHERE id = 3", 2},
+		{"DELETE FROM my_requests WHERE id = 2", 1},
+		{"DELETE FROM requests", 0},
+	} {
+		if err := db.Exec(c.delete).Error; err != nil {
+			t.Fatal(err)
+		}
+		if got := blobs(); got != c.want {
+			t.Errorf("after %s: %d blobs, want %d", c.delete, got, c.want)
+		}
+	}
+}
+
+func mustSaveBlob(t *testing.T, db *gorm.DB, body string) string {
+	t.Helper()
+	hash, err := models.SaveResponseBlob(db, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
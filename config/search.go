@@ -0,0 +1,102 @@
+package config
+
+import (
+	"errors"
+	"reflect"
+
+	"github.com/linn221/bane/models"
+	"gorm.io/gorm"
+)
+
+// SetupRequestSearch creates the FTS5 index searchRequests uses, brings it
+// up to date with the requests recorded and deleted since it last ran, and
+// keeps it in sync as requests are recorded. SQLite only has FTS5 when bane
+// is built with -tags sqlite_fts5, as build.sh does.
+func SetupRequestSearch(db *gorm.DB) error {
+	if db.Dialector.Name() != "sqlite" {
+		return errors.New("full-text search needs SQLite")
+	}
+	if !models.HasFts5(db) {
+		// deleting requests would fail on an index this build cannot open
+		if err := db.Exec("DROP TRIGGER IF EXISTS " + requestSearchDeleteTrigger).Error; err != nil {
+			return err
+		}
+		return errors.New("SQLite has no FTS5, build bane with -tags sqlite_fts5; body filters scan the stored bodies meanwhile")
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec("CREATE VIRTUAL TABLE IF NOT EXISTS " + models.RequestSearchTable +
+			" USING fts5(request_url, request_headers, request_body, response_headers, response_body, content='', contentless_delete=1)").Error
+		if err != nil {
+			return err
+		}
+		// requests are deleted with raw statements, which skip GORM callbacks
+		err = tx.Exec("CREATE TRIGGER IF NOT EXISTS " + requestSearchDeleteTrigger + " AFTER DELETE ON my_requests BEGIN " +
+			"DELETE FROM " + models.RequestSearchTable + " WHERE rowid = old.id; END").Error
+		if err != nil {
+			return err
+		}
+		// left behind while a build without FTS5 ran
+		err = tx.Exec("DELETE FROM " + models.RequestSearchTable + " WHERE rowid NOT IN (SELECT id FROM my_requests)").Error
+		if err != nil {
+			return err
+		}
+		return indexRecordedRequests(tx)
+	})
+	if err != nil {
+		return err
+	}
+	return db.Callback().Create().After("gorm:create").Register("bane:index_requests", indexCreatedRequests)
+}
+
+// requestSearchDeleteTrigger removes deleted requests from the index
+const requestSearchDeleteTrigger = "my_request_search_delete"
+
+// indexRecordedRequests indexes the requests missing from the index
+func indexRecordedRequests(db *gorm.DB) error {
+	last := 0
+	for {
+		var requests []*models.MyRequest
+		err := db.Where("id > ? AND id NOT IN (SELECT rowid FROM "+models.RequestSearchTable+")", last).
+			Order("id").Limit(200).Find(&requests).Error
+		if err != nil {
+			return err
+		}
+		if len(requests) == 0 {
+			return nil
+		}
+		if err := models.LoadResponseBodies(db, requests...); err != nil {
+			return err
+		}
+		for _, r := range requests {
+			if err := models.IndexRequest(db, r); err != nil {
+				return err
+			}
+		}
+		last = requests[len(requests)-1].Id
+	}
+}
+
+// indexCreatedRequests indexes the requests a create statement saved,
+// within its transaction
+func indexCreatedRequests(tx *gorm.DB) {
+	if tx.Error != nil || tx.Statement.Schema == nil || tx.Statement.Schema.Table != "my_requests" {
+		return
+	}
+	db := tx.Session(&gorm.Session{NewDB: true})
+	index := func(value reflect.Value) {
+		if value.Kind() != reflect.Pointer {
+			value = value.Addr()
+		}
+		if r, ok := value.Interface().(*models.MyRequest); ok {
+			tx.AddError(models.IndexRequest(db, r))
+		}
+	}
+	switch value := reflect.Indirect(tx.Statement.ReflectValue); value.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			index(value.Index(i))
+		}
+	case reflect.Struct:
+		index(value)
+	}
+}
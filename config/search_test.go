@@ -0,0 +1,79 @@
+package config
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/linn221/bane/models"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestSetupRequestSearch_FollowsRequests(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	open := func() *gorm.DB {
+		t.Helper()
+		db, err := gorm.Open(sqlite.Open(path), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return db
+	}
+	db := open()
+	if !models.HasFts5(db) {
+		t.Skip("SQLite was built without FTS5")
+	}
+	if err := db.AutoMigrate(&models.MyRequest{}, &models.ResponseBlob{}); err != nil {
+		t.Fatal(err)
+	}
+	record := func(db *gorm.DB, body string) int {
+		t.Helper()
+		request := models.MyRequest{RequestMethod: "GET", RequestUrl: "http://example.com/", ResponseBody: body}
+		if err := db.Create(&request).Error; err != nil {
+			t.Fatal(err)
+		}
+		return request.Id
+	}
+	indexed := func(db *gorm.DB) []int {
+		t.Helper()
+		var ids []int
+		if err := db.Raw("SELECT rowid FROM " + models.RequestSearchTable + " ORDER BY rowid").Scan(&ids).Error; err != nil {
+			t.Fatal(err)
+		}
+		return ids
+	}
+
+	first := record(db, "alpha")
+	if err := SetupRequestSearch(db); err != nil {
+		t.Fatal(err)
+	}
+	second := record(db, "beta")
+	if got := indexed(db); !slices.Equal(got, []int{first, second}) {
+		t.Errorf("indexed %v, want %v", got, []int{first, second})
+	}
+	// destroy deletes with a raw statement
+	if err := db.Exec("DELETE FROM my_requests WHERE id = ?", first).Error; err != nil {
+		t.Fatal(err)
+	}
+	if got := indexed(db); !slices.Equal(got, []int{second}) {
+		t.Errorf("indexed %v after deleting %d", got, first)
+	}
+
+	// changes made while the index was not kept in sync
+	other := open()
+	if err := other.Exec("DROP TRIGGER " + requestSearchDeleteTrigger).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := other.Exec("DELETE FROM my_requests WHERE id = ?", second).Error; err != nil {
+		t.Fatal(err)
+	}
+	third := record(other, "gamma")
+	if err := SetupRequestSearch(other); err != nil {
+		t.Fatal(err)
+	}
+	if got := indexed(other); !slices.Equal(got, []int{third}) {
+		t.Errorf("indexed %v after catching up, want %v", got, []int{third})
+	}
+}
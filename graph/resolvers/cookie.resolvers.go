@@ -0,0 +1,77 @@
+package resolvers
+
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+// Code generated by github.com/99designs/gqlgen version v0.17.81
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/linn221/bane/graph"
+	"github.com/linn221/bane/models"
+)
+
+// Expires is the resolver for the expires field.
+func (r *cookieResolver) Expires(ctx context.Context, obj *models.Cookie) (*string, error) {
+	if obj.Expires == nil {
+		return nil, nil
+	}
+	expires := obj.Expires.Format(time.RFC3339)
+	return &expires, nil
+}
+
+// UpdatedAt is the resolver for the updatedAt field.
+func (r *cookieResolver) UpdatedAt(ctx context.Context, obj *models.Cookie) (string, error) {
+	return obj.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"), nil
+}
+
+// SetCookie is the resolver for the setCookie field.
+func (r *mutationResolver) SetCookie(ctx context.Context, input models.CookieInput) (*models.Cookie, error) {
+	return r.app.Services.CookieService.Set(ctx, &input)
+}
+
+// DeleteCookie is the resolver for the deleteCookie field.
+func (r *mutationResolver) DeleteCookie(ctx context.Context, id int) (bool, error) {
+	return r.app.Services.CookieService.Delete(ctx, id)
+}
+
+// ClearCookies is the resolver for the clearCookies field.
+func (r *mutationResolver) ClearCookies(ctx context.Context, projectID int, domain *string) (int, error) {
+	return r.app.Services.CookieService.Clear(ctx, projectID, domain)
+}
+
+// ImportCookies is the resolver for the importCookies field.
+func (r *mutationResolver) ImportCookies(ctx context.Context, file graphql.Upload, projectID int) (int, error) {
+	return r.app.Services.CookieService.ImportNetscape(ctx, file.File, projectID)
+}
+
+// Cookies is the resolver for the cookies field.
+func (r *queryResolver) Cookies(ctx context.Context, projectID int, domain *string, url *string) ([]*models.Cookie, error) {
+	return r.app.Services.CookieService.List(ctx, projectID, domain, url)
+}
+
+// Expires is the resolver for the expires field.
+func (r *cookieInputResolver) Expires(ctx context.Context, obj *models.CookieInput, data *string) error {
+	if data == nil {
+		obj.Expires = nil
+		return nil
+	}
+	expires, err := time.Parse(time.RFC3339, *data)
+	if err != nil {
+		return fmt.Errorf("expires must be an RFC 3339 time: %v", err)
+	}
+	obj.Expires = &expires
+	return nil
+}
+
+// Cookie returns graph.CookieResolver implementation.
+func (r *Resolver) Cookie() graph.CookieResolver { return &cookieResolver{r} }
+
+// CookieInput returns graph.CookieInputResolver implementation.
+func (r *Resolver) CookieInput() graph.CookieInputResolver { return &cookieInputResolver{r} }
+
+type cookieResolver struct{ *Resolver }
+type cookieInputResolver struct{ *Resolver }
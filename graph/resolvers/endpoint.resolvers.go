@@ -11,7 +11,9 @@ import (
 	"github.com/linn221/bane/graph/model"
 	"github.com/linn221/bane/loaders"
 	"github.com/linn221/bane/models"
+	"github.com/linn221/bane/mystructs"
 	"github.com/linn221/bane/services"
+	"github.com/linn221/bane/utils"
 )
 
 // Alias is the resolver for the alias field.
@@ -24,6 +26,11 @@ func (r *endpointResolver) Match(ctx context.Context, obj *models.Endpoint, rege
 	return services.MatchRegex(obj, regex)
 }
 
+// Export is the resolver for the export field.
+func (r *endpointResolver) Export(ctx context.Context, obj *models.Endpoint, format models.ExportFormat, variables *mystructs.KVGroup, fuzz *string) (string, error) {
+	return r.app.Services.EndpointService.Export(obj, format, variables, fuzz)
+}
+
 // Notes is the resolver for the notes field.
 func (r *endpointResolver) Notes(ctx context.Context, obj *models.Endpoint) ([]*models.Note, error) {
 	var notes []*models.Note
@@ -36,6 +43,21 @@ func (r *mutationResolver) NewEndpoint(ctx context.Context, input models.Endpoin
 	return r.app.Services.EndpointService.Create(ctx, &input)
 }
 
+// PatchEndpoint is the resolver for the patchEndpoint field.
+func (r *mutationResolver) PatchEndpoint(ctx context.Context, id *int, alias *string, input models.PatchEndpoint) (*models.Endpoint, error) {
+	return r.app.Services.EndpointService.Patch(ctx, id, alias, &input)
+}
+
+// ImportCurl is the resolver for the importCurl field.
+func (r *mutationResolver) ImportCurl(ctx context.Context, curl string, create *bool) (*models.ImportedEndpoint, error) {
+	return r.app.Services.EndpointService.ImportCurl(ctx, curl, utils.SafeDeref(create, false))
+}
+
+// ImportRequest is the resolver for the importRequest field.
+func (r *mutationResolver) ImportRequest(ctx context.Context, text string, https *bool, create *bool) (*models.ImportedEndpoint, error) {
+	return r.app.Services.EndpointService.ImportRequest(ctx, text, utils.SafeDeref(https, true), utils.SafeDeref(create, false))
+}
+
 // Endpoint is the resolver for the endpoint field.
 func (r *queryResolver) Endpoint(ctx context.Context, id *int, alias *string) (*models.Endpoint, error) {
 	return r.app.Services.EndpointService.Get(ctx, id, alias)
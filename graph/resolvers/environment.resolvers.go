@@ -0,0 +1,38 @@
+package resolvers
+
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+// Code generated by github.com/99designs/gqlgen version v0.17.81
+
+import (
+	"context"
+
+	"github.com/linn221/bane/graph"
+	"github.com/linn221/bane/loaders"
+	"github.com/linn221/bane/models"
+)
+
+// Alias is the resolver for the alias field.
+func (r *environmentResolver) Alias(ctx context.Context, obj *models.Environment) (string, error) {
+	return loaders.GetEnvironmentAlias(ctx, obj.Id)
+}
+
+// NewEnvironment is the resolver for the newEnvironment field.
+func (r *mutationResolver) NewEnvironment(ctx context.Context, input models.EnvironmentInput) (*models.Environment, error) {
+	return r.app.Services.EnvironmentService.Create(ctx, &input)
+}
+
+// Environment is the resolver for the environment field.
+func (r *queryResolver) Environment(ctx context.Context, id *int, alias *string) (*models.Environment, error) {
+	return r.app.Services.EnvironmentService.Get(ctx, id, alias)
+}
+
+// Environments is the resolver for the environments field.
+func (r *queryResolver) Environments(ctx context.Context, projectID *int) ([]*models.Environment, error) {
+	return r.app.Services.EnvironmentService.List(ctx, projectID)
+}
+
+// Environment returns graph.EnvironmentResolver implementation.
+func (r *Resolver) Environment() graph.EnvironmentResolver { return &environmentResolver{r} }
+
+type environmentResolver struct{ *Resolver }
@@ -0,0 +1,48 @@
+package resolvers
+
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+// Code generated by github.com/99designs/gqlgen version v0.17.81
+
+import (
+	"context"
+	"io"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/linn221/bane/models"
+	"github.com/linn221/bane/utils"
+)
+
+// ImportHar is the resolver for the importHar field.
+func (r *mutationResolver) ImportHar(ctx context.Context, file graphql.Upload, projectID *int) (*models.ImportResult, error) {
+	return r.app.Services.ImportService.ImportHar(ctx, file.File, projectID)
+}
+
+// ImportBurp is the resolver for the importBurp field.
+func (r *mutationResolver) ImportBurp(ctx context.Context, file graphql.Upload, projectID *int) (*models.ImportResult, error) {
+	return r.app.Services.ImportService.ImportBurp(ctx, file.File, projectID)
+}
+
+// ImportOpenAPI is the resolver for the importOpenApi field.
+func (r *mutationResolver) ImportOpenAPI(ctx context.Context, spec string, projectID *int, baseURL *string) (*models.ImportResult, error) {
+	return r.app.Services.ImportService.ImportOpenApi(ctx, spec, projectID, utils.SafeDeref(baseURL))
+}
+
+// ImportPostman is the resolver for the importPostman field.
+func (r *mutationResolver) ImportPostman(ctx context.Context, file graphql.Upload, environment *graphql.Upload, projectID *int) (*models.ImportResult, error) {
+	var env io.Reader
+	if environment != nil {
+		env = environment.File
+	}
+	return r.app.Services.ImportService.ImportPostman(ctx, file.File, env, projectID)
+}
+
+// ExportBurp is the resolver for the exportBurp field.
+func (r *queryResolver) ExportBurp(ctx context.Context, ids []int) (string, error) {
+	return r.app.Services.MyRequestService.ExportBurp(ctx, ids)
+}
+
+// ExportPostman is the resolver for the exportPostman field.
+func (r *queryResolver) ExportPostman(ctx context.Context, projectID int) (string, error) {
+	return r.app.Services.EndpointService.ExportPostman(ctx, projectID)
+}
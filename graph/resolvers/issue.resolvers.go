@@ -0,0 +1,59 @@
+package resolvers
+
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+// Code generated by github.com/99designs/gqlgen version v0.17.81
+
+import (
+	"context"
+
+	"github.com/linn221/bane/graph"
+	"github.com/linn221/bane/models"
+)
+
+// Endpoint is the resolver for the endpoint field.
+func (r *issueResolver) Endpoint(ctx context.Context, obj *models.Issue) (*models.Endpoint, error) {
+	var endpoint models.Endpoint
+	err := r.app.DB.WithContext(ctx).First(&endpoint, obj.EndpointId).Error
+	return &endpoint, err
+}
+
+// Request is the resolver for the request field.
+func (r *issueResolver) Request(ctx context.Context, obj *models.Issue) (*models.MyRequest, error) {
+	return r.app.Services.MyRequestService.Get(ctx, &obj.MyRequestId)
+}
+
+// Requests is the resolver for the requests field.
+func (r *issueResolver) Requests(ctx context.Context, obj *models.Issue) ([]*models.MyRequest, error) {
+	return r.app.Services.IssueService.Requests(ctx, obj.Id)
+}
+
+// RequestCount is the resolver for the requestCount field.
+func (r *issueResolver) RequestCount(ctx context.Context, obj *models.Issue) (int, error) {
+	return obj.Requests, nil
+}
+
+// FirstSeen is the resolver for the firstSeen field.
+func (r *issueResolver) FirstSeen(ctx context.Context, obj *models.Issue) (string, error) {
+	return obj.CreatedAt.Format("2006-01-02T15:04:05Z07:00"), nil
+}
+
+// LastSeen is the resolver for the lastSeen field.
+func (r *issueResolver) LastSeen(ctx context.Context, obj *models.Issue) (string, error) {
+	return obj.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"), nil
+}
+
+// ScanRequests is the resolver for the scanRequests field.
+func (r *mutationResolver) ScanRequests(ctx context.Context, filter *models.MyRequestFilter) (int, error) {
+	return r.app.Services.IssueService.ScanHistory(ctx, filter)
+}
+
+// Issues is the resolver for the issues field.
+func (r *queryResolver) Issues(ctx context.Context, filter *models.IssueFilter) ([]*models.Issue, error) {
+	return r.app.Services.IssueService.List(ctx, filter)
+}
+
+// Issue returns graph.IssueResolver implementation.
+func (r *Resolver) Issue() graph.IssueResolver { return &issueResolver{r} }
+
+type issueResolver struct{ *Resolver }
@@ -0,0 +1,76 @@
+package resolvers
+
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+// Code generated by github.com/99designs/gqlgen version v0.17.81
+
+import (
+	"context"
+
+	"github.com/linn221/bane/graph"
+	"github.com/linn221/bane/models"
+	"github.com/linn221/bane/utils"
+)
+
+// StartedAt is the resolver for the startedAt field.
+func (r *jobResolver) StartedAt(ctx context.Context, obj *models.Job) (*string, error) {
+	if obj.StartedAt == nil {
+		return nil, nil
+	}
+	formatted := obj.StartedAt.Format("2006-01-02T15:04:05Z07:00")
+	return &formatted, nil
+}
+
+// FinishedAt is the resolver for the finishedAt field.
+func (r *jobResolver) FinishedAt(ctx context.Context, obj *models.Job) (*string, error) {
+	if obj.FinishedAt == nil {
+		return nil, nil
+	}
+	formatted := obj.FinishedAt.Format("2006-01-02T15:04:05Z07:00")
+	return &formatted, nil
+}
+
+// Requests is the resolver for the requests field.
+func (r *jobResolver) Requests(ctx context.Context, obj *models.Job) ([]*models.MyRequest, error) {
+	return r.app.Services.MyRequestService.List(ctx, &models.MyRequestFilter{JobId: obj.Id})
+}
+
+// Steps is the resolver for the steps field.
+func (r *jobResolver) Steps(ctx context.Context, obj *models.Job) ([]*models.Request, error) {
+	return r.app.Services.SequenceService.Steps(ctx, obj.Id)
+}
+
+// Fuzz is the resolver for the fuzz field.
+func (r *mutationResolver) Fuzz(ctx context.Context, endpointAlias string, variable string, wordListAlias string, concurrency *int, rateLimit *int, env *string, ignoreScope *bool) (*models.Job, error) {
+	return r.app.Services.JobService.Fuzz(ctx, endpointAlias, variable, wordListAlias, utils.SafeDeref(concurrency, 1), utils.SafeDeref(rateLimit, 0), env, utils.SafeDeref(ignoreScope))
+}
+
+// Attack is the resolver for the attack field.
+func (r *mutationResolver) Attack(ctx context.Context, endpointAlias string, mode models.AttackMode, payloads []*models.AttackPayload, concurrency *int, rateLimit *int, env *string, ignoreScope *bool) (*models.Job, error) {
+	return r.app.Services.JobService.Attack(ctx, endpointAlias, mode, payloads, utils.SafeDeref(concurrency, 1), utils.SafeDeref(rateLimit, 0), env, utils.SafeDeref(ignoreScope))
+}
+
+// CancelJob is the resolver for the cancelJob field.
+func (r *mutationResolver) CancelJob(ctx context.Context, id int) (*models.Job, error) {
+	return r.app.Services.JobService.Cancel(ctx, id)
+}
+
+// Job is the resolver for the job field.
+func (r *queryResolver) Job(ctx context.Context, id int) (*models.Job, error) {
+	return r.app.Services.JobService.Get(ctx, id)
+}
+
+// Jobs is the resolver for the jobs field.
+func (r *queryResolver) Jobs(ctx context.Context, filter *models.JobFilter) ([]*models.Job, error) {
+	return r.app.Services.JobService.List(ctx, filter)
+}
+
+// AttackCount is the resolver for the attackCount field.
+func (r *queryResolver) AttackCount(ctx context.Context, endpointAlias string, mode models.AttackMode, payloads []*models.AttackPayload) (int, error) {
+	return r.app.Services.JobService.AttackCount(ctx, endpointAlias, mode, payloads)
+}
+
+// Job returns graph.JobResolver implementation.
+func (r *Resolver) Job() graph.JobResolver { return &jobResolver{r} }
+
+type jobResolver struct{ *Resolver }
@@ -6,25 +6,19 @@ package resolvers
 
 import (
 	"context"
+	"encoding/base64"
+	"time"
 
 	"github.com/linn221/bane/graph"
+	"github.com/linn221/bane/loaders"
 	"github.com/linn221/bane/models"
 	"github.com/linn221/bane/mystructs"
+	"github.com/linn221/bane/utils"
 )
 
 // RunCurl is the resolver for the runCurl field.
-func (r *mutationResolver) RunCurl(ctx context.Context, endpointAlias string, variables string) (*models.MyRequest, error) {
-	var kv mystructs.KVGroup
-	if err := kv.UnmarshalGQL(variables); err != nil {
-		return nil, err
-	}
-	vkg := mystructs.VarKVGroup{VarKVs: make([]mystructs.VarKV, 0, len(kv.KVPairs))}
-	for _, p := range kv.KVPairs {
-		keyVS, _ := mystructs.NewVarString(p.Key)
-		valVS, _ := mystructs.NewVarString(p.Value)
-		vkg.VarKVs = append(vkg.VarKVs, mystructs.VarKV{Key: *keyVS, Value: *valVS})
-	}
-	return r.app.Services.MyRequestService.ExecuteCurl(ctx, endpointAlias, vkg)
+func (r *mutationResolver) RunCurl(ctx context.Context, endpointAlias string, variables mystructs.KVGroup, env *string, ignoreScope *bool, transport *models.TransportOptions) (*models.MyRequest, error) {
+	return r.app.Services.MyRequestService.ExecuteCurl(ctx, endpointAlias, variables, env, utils.SafeDeref(ignoreScope), transport)
 }
 
 // Endpoint is the resolver for the endpoint field.
@@ -34,11 +28,45 @@ func (r *myRequestResolver) Endpoint(ctx context.Context, obj *models.MyRequest)
 	return &endpoint, err
 }
 
+// ResponseBody is the resolver for the responseBody field.
+func (r *myRequestResolver) ResponseBody(ctx context.Context, obj *models.MyRequest) (*string, error) {
+	body, err := loaders.GetResponseBody(ctx, obj)
+	if err != nil {
+		return nil, err
+	}
+	return &body, nil
+}
+
+// ResponseBodyBase64 is the resolver for the responseBodyBase64 field.
+func (r *myRequestResolver) ResponseBodyBase64(ctx context.Context, obj *models.MyRequest) (*string, error) {
+	body, err := loaders.GetResponseBody(ctx, obj)
+	if err != nil {
+		return nil, err
+	}
+	encoded := base64.StdEncoding.EncodeToString([]byte(body))
+	return &encoded, nil
+}
+
+// Redirects is the resolver for the redirects field.
+func (r *myRequestResolver) Redirects(ctx context.Context, obj *models.MyRequest) ([]*models.RedirectHop, error) {
+	return r.app.Services.MyRequestService.Redirects(ctx, obj.Id)
+}
+
+// Certificates is the resolver for the certificates field.
+func (r *myRequestResolver) Certificates(ctx context.Context, obj *models.MyRequest) ([]*models.TlsCertificate, error) {
+	return r.app.Services.MyRequestService.Certificates(ctx, obj.Id)
+}
+
 // ExecutedAt is the resolver for the executedAt field.
 func (r *myRequestResolver) ExecutedAt(ctx context.Context, obj *models.MyRequest) (string, error) {
 	return obj.ExecutedAt.Format("2006-01-02T15:04:05Z07:00"), nil
 }
 
+// Export is the resolver for the export field.
+func (r *myRequestResolver) Export(ctx context.Context, obj *models.MyRequest, format models.ExportFormat, variables *mystructs.KVGroup, fuzz *string) (string, error) {
+	return r.app.Services.MyRequestService.Export(ctx, obj, format, variables, fuzz)
+}
+
 // MyRequests is the resolver for the myRequests field.
 func (r *queryResolver) MyRequests(ctx context.Context, filter *models.MyRequestFilter) ([]*models.MyRequest, error) {
 	return r.app.Services.MyRequestService.List(ctx, filter)
@@ -49,7 +77,45 @@ func (r *queryResolver) MyRequest(ctx context.Context, id int) (*models.MyReques
 	return r.app.Services.MyRequestService.Get(ctx, &id)
 }
 
+// DiffRequests is the resolver for the diffRequests field.
+func (r *queryResolver) DiffRequests(ctx context.Context, a int, b int, mode *models.DiffMode) (*models.RequestDiff, error) {
+	return r.app.Services.MyRequestService.Diff(ctx, a, b, utils.SafeDeref(mode, models.DiffModeLine))
+}
+
+// SearchRequests is the resolver for the searchRequests field.
+func (r *queryResolver) SearchRequests(ctx context.Context, query string, projectID *int) ([]*models.RequestSearchHit, error) {
+	return r.app.Services.MyRequestService.Search(ctx, query, projectID)
+}
+
+// SetCookies is the resolver for the setCookies field.
+func (r *redirectHopResolver) SetCookies(ctx context.Context, obj *models.RedirectHop) ([]string, error) {
+	return obj.SetCookieValues(), nil
+}
+
+// Sans is the resolver for the sans field.
+func (r *tlsCertificateResolver) Sans(ctx context.Context, obj *models.TlsCertificate) ([]string, error) {
+	return obj.SanList(), nil
+}
+
+// NotBefore is the resolver for the notBefore field.
+func (r *tlsCertificateResolver) NotBefore(ctx context.Context, obj *models.TlsCertificate) (string, error) {
+	return obj.NotBefore.Format(time.RFC3339), nil
+}
+
+// NotAfter is the resolver for the notAfter field.
+func (r *tlsCertificateResolver) NotAfter(ctx context.Context, obj *models.TlsCertificate) (string, error) {
+	return obj.NotAfter.Format(time.RFC3339), nil
+}
+
 // MyRequest returns graph.MyRequestResolver implementation.
 func (r *Resolver) MyRequest() graph.MyRequestResolver { return &myRequestResolver{r} }
 
+// RedirectHop returns graph.RedirectHopResolver implementation.
+func (r *Resolver) RedirectHop() graph.RedirectHopResolver { return &redirectHopResolver{r} }
+
+// TlsCertificate returns graph.TlsCertificateResolver implementation.
+func (r *Resolver) TlsCertificate() graph.TlsCertificateResolver { return &tlsCertificateResolver{r} }
+
 type myRequestResolver struct{ *Resolver }
+type redirectHopResolver struct{ *Resolver }
+type tlsCertificateResolver struct{ *Resolver }
@@ -17,11 +17,26 @@ func (r *mutationResolver) NewProject(ctx context.Context, input models.ProjectI
 	return r.app.Services.ProjectService.Create(ctx, &input)
 }
 
+// InScope is the resolver for the inScope field.
+func (r *projectResolver) InScope(ctx context.Context, obj *models.Project) ([]*models.ScopeRule, error) {
+	return obj.InScope, nil
+}
+
+// OutOfScope is the resolver for the outOfScope field.
+func (r *projectResolver) OutOfScope(ctx context.Context, obj *models.Project) ([]*models.ScopeRule, error) {
+	return obj.OutOfScope, nil
+}
+
 // Alias is the resolver for the alias field.
 func (r *projectResolver) Alias(ctx context.Context, obj *models.Project) (string, error) {
 	return loaders.GetProjectAlias(ctx, obj.Id)
 }
 
+// Environments is the resolver for the environments field.
+func (r *projectResolver) Environments(ctx context.Context, obj *models.Project) ([]*models.Environment, error) {
+	return r.app.Services.EnvironmentService.List(ctx, &obj.Id)
+}
+
 // Project is the resolver for the project field.
 func (r *queryResolver) Project(ctx context.Context, id *int, alias *string) (*models.Project, error) {
 	return r.app.Services.ProjectService.Get(ctx, id, alias)
@@ -32,7 +47,23 @@ func (r *queryResolver) Projects(ctx context.Context, filter *models.ProjectFilt
 	return r.app.Services.ProjectService.List(ctx, filter)
 }
 
+// InScope is the resolver for the inScope field.
+func (r *projectInputResolver) InScope(ctx context.Context, obj *models.ProjectInput, data []*models.ScopeRule) error {
+	obj.InScope = data
+	return nil
+}
+
+// OutOfScope is the resolver for the outOfScope field.
+func (r *projectInputResolver) OutOfScope(ctx context.Context, obj *models.ProjectInput, data []*models.ScopeRule) error {
+	obj.OutOfScope = data
+	return nil
+}
+
 // Project returns graph.ProjectResolver implementation.
 func (r *Resolver) Project() graph.ProjectResolver { return &projectResolver{r} }
 
+// ProjectInput returns graph.ProjectInputResolver implementation.
+func (r *Resolver) ProjectInput() graph.ProjectInputResolver { return &projectInputResolver{r} }
+
 type projectResolver struct{ *Resolver }
+type projectInputResolver struct{ *Resolver }
@@ -0,0 +1,50 @@
+package resolvers
+
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+// Code generated by github.com/99designs/gqlgen version v0.17.81
+
+import (
+	"context"
+	"time"
+
+	"github.com/linn221/bane/graph"
+	"github.com/linn221/bane/models"
+)
+
+// StartRecorder is the resolver for the startRecorder field.
+func (r *mutationResolver) StartRecorder(ctx context.Context, input *models.RecorderInput) (*models.Recorder, error) {
+	if input == nil {
+		input = &models.RecorderInput{}
+	}
+	return r.app.Services.RecorderService.Start(ctx, input)
+}
+
+// StopRecorder is the resolver for the stopRecorder field.
+func (r *mutationResolver) StopRecorder(ctx context.Context) (*models.Recorder, error) {
+	return r.app.Services.RecorderService.Stop(ctx)
+}
+
+// Recorder is the resolver for the recorder field.
+func (r *queryResolver) Recorder(ctx context.Context) (*models.Recorder, error) {
+	return r.app.Services.RecorderService.Status(ctx), nil
+}
+
+// StartedAt is the resolver for the startedAt field.
+func (r *recorderResolver) StartedAt(ctx context.Context, obj *models.Recorder) (*string, error) {
+	if obj.StartedAt == nil {
+		return nil, nil
+	}
+	startedAt := obj.StartedAt.Format(time.RFC3339)
+	return &startedAt, nil
+}
+
+// CaCertificate is the resolver for the caCertificate field.
+func (r *recorderResolver) CaCertificate(ctx context.Context, obj *models.Recorder) (string, error) {
+	return r.app.Services.RecorderService.CACertificate(ctx)
+}
+
+// Recorder returns graph.RecorderResolver implementation.
+func (r *Resolver) Recorder() graph.RecorderResolver { return &recorderResolver{r} }
+
+type recorderResolver struct{ *Resolver }
@@ -0,0 +1,21 @@
+package resolvers
+
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+// Code generated by github.com/99designs/gqlgen version v0.17.81
+
+import (
+	"context"
+
+	"github.com/linn221/bane/models"
+)
+
+// SetScope is the resolver for the setScope field.
+func (r *mutationResolver) SetScope(ctx context.Context, projectID int, inScope []*models.ScopeRule, outOfScope []*models.ScopeRule) (*models.Project, error) {
+	return r.app.Services.ScopeService.SetScope(ctx, projectID, inScope, outOfScope)
+}
+
+// ScopeCheck is the resolver for the scopeCheck field.
+func (r *queryResolver) ScopeCheck(ctx context.Context, url string, projectID *int) ([]*models.ScopeCheck, error) {
+	return r.app.Services.ScopeService.Check(ctx, url, projectID)
+}
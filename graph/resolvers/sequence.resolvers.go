@@ -0,0 +1,70 @@
+package resolvers
+
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+// Code generated by github.com/99designs/gqlgen version v0.17.81
+
+import (
+	"context"
+
+	"github.com/linn221/bane/graph"
+	"github.com/linn221/bane/loaders"
+	"github.com/linn221/bane/models"
+	"github.com/linn221/bane/mystructs"
+	"github.com/linn221/bane/utils"
+)
+
+// NewSequence is the resolver for the newSequence field.
+func (r *mutationResolver) NewSequence(ctx context.Context, input models.SequenceInput) (*models.Sequence, error) {
+	return r.app.Services.SequenceService.Create(ctx, &input)
+}
+
+// RunSequence is the resolver for the runSequence field.
+func (r *mutationResolver) RunSequence(ctx context.Context, alias string, variables *mystructs.KVGroup, env *string, ignoreScope *bool) (*models.Job, error) {
+	return r.app.Services.SequenceService.Run(ctx, alias, utils.SafeDeref(variables), env, utils.SafeDeref(ignoreScope))
+}
+
+// Sequence is the resolver for the sequence field.
+func (r *queryResolver) Sequence(ctx context.Context, id *int, alias *string) (*models.Sequence, error) {
+	return r.app.Services.SequenceService.Get(ctx, id, alias)
+}
+
+// Sequences is the resolver for the sequences field.
+func (r *queryResolver) Sequences(ctx context.Context) ([]*models.Sequence, error) {
+	return r.app.Services.SequenceService.List(ctx)
+}
+
+// ResponseLatency is the resolver for the responseLatency field.
+func (r *requestResolver) ResponseLatency(ctx context.Context, obj *models.Request) (int, error) {
+	return int(obj.ResponseLatency.Milliseconds()), nil
+}
+
+// ExecutedAt is the resolver for the executedAt field.
+func (r *requestResolver) ExecutedAt(ctx context.Context, obj *models.Request) (string, error) {
+	return obj.ExecutedAt.Format("2006-01-02T15:04:05Z07:00"), nil
+}
+
+// Alias is the resolver for the alias field.
+func (r *sequenceResolver) Alias(ctx context.Context, obj *models.Sequence) (string, error) {
+	return loaders.GetSequenceAlias(ctx, obj.Id)
+}
+
+// Endpoint is the resolver for the endpoint field.
+func (r *sequenceStepResolver) Endpoint(ctx context.Context, obj *models.SequenceStep) (*models.Endpoint, error) {
+	var endpoint models.Endpoint
+	err := r.app.DB.WithContext(ctx).First(&endpoint, obj.EndpointId).Error
+	return &endpoint, err
+}
+
+// Request returns graph.RequestResolver implementation.
+func (r *Resolver) Request() graph.RequestResolver { return &requestResolver{r} }
+
+// Sequence returns graph.SequenceResolver implementation.
+func (r *Resolver) Sequence() graph.SequenceResolver { return &sequenceResolver{r} }
+
+// SequenceStep returns graph.SequenceStepResolver implementation.
+func (r *Resolver) SequenceStep() graph.SequenceStepResolver { return &sequenceStepResolver{r} }
+
+type requestResolver struct{ *Resolver }
+type sequenceResolver struct{ *Resolver }
+type sequenceStepResolver struct{ *Resolver }
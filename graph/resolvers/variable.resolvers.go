@@ -0,0 +1,37 @@
+package resolvers
+
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+// Code generated by github.com/99designs/gqlgen version v0.17.81
+
+import (
+	"context"
+
+	"github.com/linn221/bane/graph"
+	"github.com/linn221/bane/models"
+)
+
+// SetVariable is the resolver for the setVariable field.
+func (r *mutationResolver) SetVariable(ctx context.Context, name string, value string) (*models.Variable, error) {
+	return r.app.Services.VariableService.Set(ctx, name, value, nil)
+}
+
+// DeleteVariable is the resolver for the deleteVariable field.
+func (r *mutationResolver) DeleteVariable(ctx context.Context, name string) (bool, error) {
+	return r.app.Services.VariableService.Delete(ctx, name)
+}
+
+// Variables is the resolver for the variables field.
+func (r *queryResolver) Variables(ctx context.Context) ([]*models.Variable, error) {
+	return r.app.Services.VariableService.List(ctx)
+}
+
+// UpdatedAt is the resolver for the updatedAt field.
+func (r *variableResolver) UpdatedAt(ctx context.Context, obj *models.Variable) (string, error) {
+	return obj.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"), nil
+}
+
+// Variable returns graph.VariableResolver implementation.
+func (r *Resolver) Variable() graph.VariableResolver { return &variableResolver{r} }
+
+type variableResolver struct{ *Resolver }
@@ -79,3 +79,15 @@ func GetProjectAlias(ctx context.Context, id int) (string, error) {
 	loaders := For(ctx)
 	return loaders.projectAliasLoader.Load(ctx, id)()
 }
+
+// GetSequenceAlias returns a single alias for a Sequence by ID efficiently using dataloader
+func GetSequenceAlias(ctx context.Context, id int) (string, error) {
+	loaders := For(ctx)
+	return loaders.sequenceAliasLoader.Load(ctx, id)()
+}
+
+// GetEnvironmentAlias returns a single alias for an Environment by ID efficiently using dataloader
+func GetEnvironmentAlias(ctx context.Context, id int) (string, error) {
+	loaders := For(ctx)
+	return loaders.envAliasLoader.Load(ctx, id)()
+}
@@ -25,7 +25,10 @@ type Loaders struct {
 	wordListAliasLoader *dataloader.Loader[int, string]
 	endpointAliasLoader *dataloader.Loader[int, string]
 	projectAliasLoader  *dataloader.Loader[int, string]
+	sequenceAliasLoader *dataloader.Loader[int, string]
+	envAliasLoader      *dataloader.Loader[int, string]
 	projectLoader       *dataloader.Loader[int, *models.Project]
+	responseBodyLoader  *dataloader.Loader[string, string]
 }
 
 // NewLoaders instantiates data loaders for the middleware
@@ -36,6 +39,9 @@ func NewLoaders(conn *gorm.DB) *Loaders {
 	wordListAliasReader := &AliasReader{db: conn, referenceType: "wordlists"}
 	endpointAliasReader := &AliasReader{db: conn, referenceType: "endpoints"}
 	projectAliasReader := &AliasReader{db: conn, referenceType: "projects"}
+	sequenceAliasReader := &AliasReader{db: conn, referenceType: "sequences"}
+	envAliasReader := &AliasReader{db: conn, referenceType: "environments"}
+	responseBodyReader := &ResponseBodyReader{db: conn}
 	projectReader := newGenericReader[*models.Project, int](conn,
 		func(p *models.Project) int {
 			return p.Id
@@ -50,7 +56,10 @@ func NewLoaders(conn *gorm.DB) *Loaders {
 		wordListAliasLoader: wordListAliasReader.Loader(),
 		endpointAliasLoader: endpointAliasReader.Loader(),
 		projectAliasLoader:  projectAliasReader.Loader(),
+		sequenceAliasLoader: sequenceAliasReader.Loader(),
+		envAliasLoader:      envAliasReader.Loader(),
 		projectLoader:       projectReader.Loader(),
+		responseBodyLoader:  responseBodyReader.Loader(),
 	}
 }
 
@@ -0,0 +1,43 @@
+package loaders
+
+import (
+	"context"
+	"time"
+
+	"github.com/graph-gophers/dataloader/v7"
+	"github.com/linn221/bane/models"
+	"gorm.io/gorm"
+)
+
+// ResponseBodyReader loads response bodies by the hash of their ResponseBlob,
+// so listing requests reads the blobs of the bodies asked for in one query
+type ResponseBodyReader struct {
+	db *gorm.DB
+}
+
+// GetBodies is the batch function that loads the bodies of multiple blobs
+func (r *ResponseBodyReader) GetBodies(ctx context.Context, hashes []string) []*dataloader.Result[string] {
+	bodies, err := models.ResponseBodies(r.db.WithContext(ctx), hashes)
+	if err != nil {
+		return handleError[string](len(hashes), err)
+	}
+	loaderResults := make([]*dataloader.Result[string], 0, len(hashes))
+	for _, hash := range hashes {
+		loaderResults = append(loaderResults, &dataloader.Result[string]{Data: bodies[hash]})
+	}
+	return loaderResults
+}
+
+func (reader *ResponseBodyReader) Loader() *dataloader.Loader[string, string] {
+	return dataloader.NewBatchedLoader(reader.GetBodies, dataloader.WithWait[string, string](time.Millisecond))
+}
+
+// GetResponseBody returns the response body of a request, reading it from
+// its blob unless the request already holds it
+func GetResponseBody(ctx context.Context, request *models.MyRequest) (string, error) {
+	if request.ResponseBody != "" || request.ResponseBodyHash == "" {
+		return request.ResponseBody, nil
+	}
+	loaders := For(ctx)
+	return loaders.responseBodyLoader.Load(ctx, request.ResponseBodyHash)()
+}
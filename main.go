@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
 	"os"
@@ -10,6 +11,7 @@ import (
 	"github.com/linn221/bane/config"
 	"github.com/linn221/bane/loaders"
 	"github.com/linn221/bane/middlewares"
+	"github.com/linn221/bane/models"
 	"github.com/linn221/bane/utils"
 )
 
@@ -22,6 +24,14 @@ func main() {
 
 	port := utils.GetEnv("PORT", "6423")
 
+	if address := utils.GetEnv("RECORDER_ADDRESS", ""); address != "" {
+		recorder, err := app.Services.RecorderService.Start(context.Background(), &models.RecorderInput{Address: &address})
+		if err != nil {
+			log.Fatalf("failed to start the recorder: %v", err)
+		}
+		log.Printf("recording proxy listening on %s", recorder.Address)
+	}
+
 	mux := SetupRoutes(app)
 
 	secretConfig := middlewares.SecretConfig{
@@ -0,0 +1,131 @@
+package models
+
+import (
+	"iter"
+	"math"
+)
+
+// AttackMode decides how the payloads of several positions are combined,
+// following the Burp Intruder attack types
+type AttackMode string
+
+const (
+	AttackModeSniper       AttackMode = "sniper"        // one position at a time, the others keep their defaults
+	AttackModeBatteringRam AttackMode = "battering_ram" // the same word in every position
+	AttackModePitchfork    AttackMode = "pitchfork"     // the nth word of every list together
+	AttackModeClusterBomb  AttackMode = "cluster_bomb"  // every combination of the lists
+)
+
+// AttackPosition binds a placeholder to the words sent in its place
+type AttackPosition struct {
+	Variable string
+	Words    []string
+}
+
+// AttackPayload is the GraphQL input naming a placeholder and its wordlist
+type AttackPayload struct {
+	Variable      string `json:"variable"`
+	WordListAlias string `json:"wordListAlias"`
+}
+
+// Count returns the number of requests the attack sends
+func (m AttackMode) Count(positions []AttackPosition) int {
+	if len(positions) == 0 {
+		return 0
+	}
+	switch m {
+	case AttackModeSniper:
+		total := 0
+		for _, p := range positions {
+			total += len(p.Words)
+		}
+		return total
+	case AttackModeBatteringRam:
+		return len(positions[0].Words)
+	case AttackModePitchfork:
+		total := len(positions[0].Words)
+		for _, p := range positions[1:] {
+			total = min(total, len(p.Words))
+		}
+		return total
+	case AttackModeClusterBomb:
+		total := 1
+		for _, p := range positions {
+			if len(p.Words) == 0 {
+				return 0
+			}
+			if total > math.MaxInt/len(p.Words) {
+				return math.MaxInt
+			}
+			total *= len(p.Words)
+		}
+		return total
+	}
+	return 0
+}
+
+// Payloads yields the variables of each request in order. They are produced
+// lazily, since a cluster bomb can be far too large to hold in memory.
+func (m AttackMode) Payloads(positions []AttackPosition) iter.Seq[map[string]string] {
+	return func(yield func(map[string]string) bool) {
+		if len(positions) == 0 {
+			return
+		}
+		switch m {
+		case AttackModeSniper:
+			for _, p := range positions {
+				for _, word := range p.Words {
+					if !yield(map[string]string{p.Variable: word}) {
+						return
+					}
+				}
+			}
+		case AttackModeBatteringRam:
+			for _, word := range positions[0].Words {
+				vars := make(map[string]string, len(positions))
+				for _, p := range positions {
+					vars[p.Variable] = word
+				}
+				if !yield(vars) {
+					return
+				}
+			}
+		case AttackModePitchfork:
+			for i := 0; i < m.Count(positions); i++ {
+				vars := make(map[string]string, len(positions))
+				for _, p := range positions {
+					vars[p.Variable] = p.Words[i]
+				}
+				if !yield(vars) {
+					return
+				}
+			}
+		case AttackModeClusterBomb:
+			if m.Count(positions) == 0 {
+				return
+			}
+			// odometer over the lists, the last position turning fastest
+			indexes := make([]int, len(positions))
+			for {
+				vars := make(map[string]string, len(positions))
+				for i, p := range positions {
+					vars[p.Variable] = p.Words[indexes[i]]
+				}
+				if !yield(vars) {
+					return
+				}
+				i := len(indexes) - 1
+				for ; i >= 0; i-- {
+					indexes[i]++
+					if indexes[i] < len(positions[i].Words) {
+						break
+					}
+					indexes[i] = 0
+				}
+				if i < 0 {
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestAttackMode_Payloads(t *testing.T) {
+	positions := []AttackPosition{
+		{Variable: "user", Words: []string{"admin", "root"}},
+		{Variable: "pass", Words: []string{"123", "abc", "xyz"}},
+	}
+	tests := []struct {
+		mode AttackMode
+		want []string
+	}{
+		{AttackModeSniper, []string{"user=admin", "user=root", "pass=123", "pass=abc", "pass=xyz"}},
+		{AttackModeBatteringRam, []string{"pass=admin user=admin", "pass=root user=root"}},
+		{AttackModePitchfork, []string{"pass=123 user=admin", "pass=abc user=root"}},
+		{AttackModeClusterBomb, []string{
+			"pass=123 user=admin", "pass=abc user=admin", "pass=xyz user=admin",
+			"pass=123 user=root", "pass=abc user=root", "pass=xyz user=root",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			var got []string
+			for vars := range tt.mode.Payloads(positions) {
+				got = append(got, formatVars(vars))
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("payloads=%v want %v", got, tt.want)
+			}
+			if count := tt.mode.Count(positions); count != len(tt.want) {
+				t.Errorf("count=%d want %d", count, len(tt.want))
+			}
+		})
+	}
+}
+
+func TestAttackMode_ClusterBombCountSaturates(t *testing.T) {
+	huge := make([]string, 1<<20)
+	positions := []AttackPosition{{"a", huge}, {"b", huge}, {"c", huge}, {"d", huge}}
+	if got := AttackModeClusterBomb.Count(positions); got != math.MaxInt {
+		t.Errorf("count=%d want MaxInt", got)
+	}
+}
+
+func formatVars(vars map[string]string) string {
+	parts := make([]string, 0, len(vars))
+	for k, v := range vars {
+		parts = append(parts, fmt.Sprintf("%s=%s", k, v))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, " ")
+}
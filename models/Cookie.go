@@ -0,0 +1,253 @@
+package models
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/linn221/bane/mystructs"
+	"golang.org/x/net/publicsuffix"
+)
+
+// Cookie is a cookie in a project's jar. Name, domain and path identify it,
+// as in RFC 6265.
+type Cookie struct {
+	Id        int        `gorm:"primaryKey"`
+	ProjectId int        `gorm:"not null;uniqueIndex:idx_cookie_key"`
+	Name      string     `gorm:"not null;uniqueIndex:idx_cookie_key"`
+	Domain    string     `gorm:"not null;uniqueIndex:idx_cookie_key"` // lowercase, without a leading dot
+	Path      string     `gorm:"not null;uniqueIndex:idx_cookie_key"`
+	Value     string     `gorm:"not null"`
+	HostOnly  bool       `gorm:"not null"`     // false when the cookie also goes to subdomains
+	Expires   *time.Time `gorm:"default:null"` // nil for a session cookie, which is kept until cleared
+	Secure    bool       `gorm:"not null"`
+	HttpOnly  bool       `gorm:"not null"`
+	SameSite  string     `gorm:"size:10"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type CookieInput struct {
+	ProjectId int        `json:"projectId"`
+	Name      string     `json:"name"`
+	Value     string     `json:"value"`
+	Domain    string     `json:"domain"` // a leading dot lets the cookie go to subdomains
+	Path      *string    `json:"path,omitempty"`
+	Expires   *time.Time `json:"expires,omitempty"`
+	Secure    *bool      `json:"secure,omitempty"`
+	HttpOnly  *bool      `json:"httpOnly,omitempty"`
+}
+
+// Expired reports whether the cookie should no longer be sent
+func (c *Cookie) Expired(now time.Time) bool {
+	return c.Expires != nil && !c.Expires.After(now)
+}
+
+// Matches reports whether the cookie is sent with a request to u
+func (c *Cookie) Matches(u *url.URL, now time.Time) bool {
+	if c.Expired(now) || (c.Secure && u.Scheme != "https") {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	if c.HostOnly && host != c.Domain {
+		return false
+	}
+	if !c.HostOnly && !domainMatch(host, c.Domain) {
+		return false
+	}
+	return pathMatch(u.EscapedPath(), c.Path)
+}
+
+// NewCookieFromSetCookie applies the rules of RFC 6265 section 5.3 to a
+// cookie set by a response to u. An expired cookie is returned as such so
+// the caller can remove the one it replaces.
+func NewCookieFromSetCookie(projectId int, setCookie *http.Cookie, u *url.URL, now time.Time) (*Cookie, error) {
+	host := strings.ToLower(u.Hostname())
+	cookie := &Cookie{
+		ProjectId: projectId,
+		Name:      setCookie.Name,
+		Value:     setCookie.Value,
+		Domain:    host,
+		HostOnly:  true,
+		Path:      setCookie.Path,
+		Secure:    setCookie.Secure,
+		HttpOnly:  setCookie.HttpOnly,
+		SameSite:  sameSiteName(setCookie.SameSite),
+	}
+	if cookie.Secure && u.Scheme != "https" {
+		return nil, fmt.Errorf("secure cookie %s set over http", cookie.Name)
+	}
+
+	if domain := strings.ToLower(strings.TrimPrefix(setCookie.Domain, ".")); domain != "" && domain != host {
+		if !domainMatch(host, domain) {
+			return nil, fmt.Errorf("cookie %s: domain %s does not match %s", cookie.Name, domain, host)
+		}
+		if suffix, _ := publicsuffix.PublicSuffix(domain); suffix == domain {
+			return nil, fmt.Errorf("cookie %s: domain %s is a public suffix", cookie.Name, domain)
+		}
+		cookie.Domain, cookie.HostOnly = domain, false
+	} else if domain != "" {
+		cookie.HostOnly = false
+	}
+
+	if cookie.Path == "" || !strings.HasPrefix(cookie.Path, "/") {
+		cookie.Path = defaultCookiePath(u.EscapedPath())
+	}
+
+	switch {
+	case setCookie.MaxAge < 0:
+		cookie.Expires = &time.Time{}
+	case setCookie.MaxAge > 0:
+		expires := now.Add(time.Duration(setCookie.MaxAge) * time.Second)
+		cookie.Expires = &expires
+	case !setCookie.Expires.IsZero():
+		expires := setCookie.Expires
+		cookie.Expires = &expires
+	}
+	return cookie, nil
+}
+
+// NewCookie builds a cookie entered by hand or imported from a file
+func NewCookie(input *CookieInput) (*Cookie, error) {
+	if input.Name == "" {
+		return nil, fmt.Errorf("cookie name is required")
+	}
+	domain := strings.ToLower(strings.TrimSpace(input.Domain))
+	cookie := &Cookie{
+		ProjectId: input.ProjectId,
+		Name:      input.Name,
+		Value:     input.Value,
+		Domain:    strings.TrimPrefix(domain, "."),
+		HostOnly:  !strings.HasPrefix(domain, "."),
+		Path:      "/",
+		Expires:   input.Expires,
+	}
+	if cookie.Domain == "" {
+		return nil, fmt.Errorf("cookie domain is required")
+	}
+	if input.Path != nil && strings.HasPrefix(*input.Path, "/") {
+		cookie.Path = *input.Path
+	}
+	if input.Secure != nil {
+		cookie.Secure = *input.Secure
+	}
+	if input.HttpOnly != nil {
+		cookie.HttpOnly = *input.HttpOnly
+	}
+	return cookie, nil
+}
+
+// CookieJar is the cookies of a project, as loaded for sending requests
+type CookieJar []*Cookie
+
+// Header returns the Cookie header value for a request to rawUrl, with longer
+// paths first and then older cookies first, as RFC 6265 recommends
+func (jar CookieJar) Header(rawUrl string, now time.Time) string {
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return ""
+	}
+	var matched []*Cookie
+	for _, c := range jar {
+		if c.Matches(u, now) {
+			matched = append(matched, c)
+		}
+	}
+	sort.SliceStable(matched, func(i, j int) bool {
+		if len(matched[i].Path) != len(matched[j].Path) {
+			return len(matched[i].Path) > len(matched[j].Path)
+		}
+		return matched[i].CreatedAt.Before(matched[j].CreatedAt)
+	})
+	pairs := make([]string, 0, len(matched))
+	for _, c := range matched {
+		pairs = append(pairs, c.Name+"="+c.Value)
+	}
+	return strings.Join(pairs, "; ")
+}
+
+// Apply adds the jar's cookies for the request's URL to its Cookie header.
+// Cookies the request already sends keep their value.
+func (jar CookieJar) Apply(rendered *RenderedRequest, now time.Time) {
+	header := jar.Header(rendered.Url, now)
+	if header == "" {
+		return
+	}
+	for i, h := range rendered.Headers {
+		if !strings.EqualFold(h.Key, "Cookie") {
+			continue
+		}
+		existing := (&http.Request{Header: http.Header{"Cookie": {h.Value}}}).Cookies()
+		var added []string
+		for _, pair := range strings.Split(header, "; ") {
+			name, _, _ := strings.Cut(pair, "=")
+			if !hasCookie(existing, name) {
+				added = append(added, pair)
+			}
+		}
+		if len(added) > 0 {
+			rendered.Headers[i].Value = strings.Join(append([]string{h.Value}, added...), "; ")
+		}
+		return
+	}
+	rendered.Headers = append(rendered.Headers, mystructs.KVPair{Key: "Cookie", Value: header})
+}
+
+func hasCookie(cookies []*http.Cookie, name string) bool {
+	for _, c := range cookies {
+		if c.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// domainMatch is the domain-matching of RFC 6265 section 5.1.3
+func domainMatch(host string, domain string) bool {
+	if host == domain {
+		return true
+	}
+	return strings.HasSuffix(host, "."+domain) && net.ParseIP(host) == nil
+}
+
+// pathMatch is the path-matching of RFC 6265 section 5.1.4
+func pathMatch(requestPath string, cookiePath string) bool {
+	if requestPath == "" {
+		requestPath = "/"
+	}
+	if requestPath == cookiePath {
+		return true
+	}
+	if !strings.HasPrefix(requestPath, cookiePath) {
+		return false
+	}
+	return strings.HasSuffix(cookiePath, "/") || requestPath[len(cookiePath)] == '/'
+}
+
+// defaultCookiePath is the default-path of RFC 6265 section 5.1.4
+func defaultCookiePath(requestPath string) string {
+	if !strings.HasPrefix(requestPath, "/") {
+		return "/"
+	}
+	i := strings.LastIndex(requestPath, "/")
+	if i == 0 {
+		return "/"
+	}
+	return requestPath[:i]
+}
+
+func sameSiteName(mode http.SameSite) string {
+	switch mode {
+	case http.SameSiteLaxMode:
+		return "Lax"
+	case http.SameSiteStrictMode:
+		return "Strict"
+	case http.SameSiteNoneMode:
+		return "None"
+	}
+	return ""
+}
@@ -0,0 +1,95 @@
+package models
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestNewCookieFromSetCookie(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	u, _ := url.Parse("https://www.shop.example.co.uk/account/login")
+
+	tests := []struct {
+		name      string
+		setCookie http.Cookie
+		wantErr   bool
+		domain    string
+		hostOnly  bool
+		path      string
+		expires   *time.Time
+	}{
+		{"host only", http.Cookie{Name: "a"}, false, "www.shop.example.co.uk", true, "/account", nil},
+		{"parent domain", http.Cookie{Name: "a", Domain: ".Example.co.uk", Path: "/"}, false, "example.co.uk", false, "/", nil},
+		{"public suffix", http.Cookie{Name: "a", Domain: "co.uk"}, true, "", false, "", nil},
+		{"other domain", http.Cookie{Name: "a", Domain: "other.com"}, true, "", false, "", nil},
+		{"relative path", http.Cookie{Name: "a", Path: "x"}, false, "www.shop.example.co.uk", true, "/account", nil},
+		{"max-age wins", http.Cookie{Name: "a", MaxAge: 60, Expires: now.Add(time.Hour)}, false, "www.shop.example.co.uk", true, "/account", ptrTime(now.Add(time.Minute))},
+		{"expires", http.Cookie{Name: "a", Expires: now.Add(time.Hour)}, false, "www.shop.example.co.uk", true, "/account", ptrTime(now.Add(time.Hour))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewCookieFromSetCookie(1, &tt.setCookie, u, now)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected an error, got %+v", c)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.Domain != tt.domain || c.HostOnly != tt.hostOnly || c.Path != tt.path {
+				t.Errorf("domain=%s hostOnly=%v path=%s", c.Domain, c.HostOnly, c.Path)
+			}
+			if (c.Expires == nil) != (tt.expires == nil) || (c.Expires != nil && !c.Expires.Equal(*tt.expires)) {
+				t.Errorf("expires = %v, want %v", c.Expires, tt.expires)
+			}
+		})
+	}
+
+	plain, _ := url.Parse("http://example.com/")
+	if _, err := NewCookieFromSetCookie(1, &http.Cookie{Name: "a", Secure: true}, plain, now); err == nil {
+		t.Error("expected a secure cookie set over http to be rejected")
+	}
+	deleted, err := NewCookieFromSetCookie(1, &http.Cookie{Name: "a", MaxAge: -1}, plain, now)
+	if err != nil || !deleted.Expired(now) {
+		t.Errorf("Max-Age=-1 should give an expired cookie, got %+v, %v", deleted, err)
+	}
+}
+
+func TestCookie_Matches(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		rawUrl   string
+		hostOnly bool
+		secure   bool
+		want     bool
+	}{
+		{"http://example.com/app", true, false, true},
+		{"http://example.com/app/x", true, false, true},
+		{"http://example.com/application", true, false, false},
+		{"http://sub.example.com/app", true, false, false},
+		{"http://sub.example.com/app", false, false, true},
+		{"http://badexample.com/app", false, false, false},
+		{"http://example.com/other", false, false, false},
+		{"http://example.com/app", false, true, false},
+		{"https://example.com/app", false, true, true},
+	}
+	for _, tt := range tests {
+		cookie := &Cookie{Domain: "example.com", Path: "/app", HostOnly: tt.hostOnly, Secure: tt.secure}
+		u, _ := url.Parse(tt.rawUrl)
+		if got := cookie.Matches(u, now); got != tt.want {
+			t.Errorf("%s hostOnly=%v secure=%v: matches = %v, want %v", tt.rawUrl, tt.hostOnly, tt.secure, got, tt.want)
+		}
+	}
+	expired := &Cookie{Domain: "example.com", Path: "/", Expires: ptrTime(now.Add(-time.Second))}
+	if u, _ := url.Parse("http://example.com/"); expired.Matches(u, now) {
+		t.Error("an expired cookie should not match")
+	}
+}
+
+func ptrTime(t time.Time) *time.Time {
+	return &t
+}
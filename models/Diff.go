@@ -0,0 +1,243 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/linn221/bane/utils"
+)
+
+type DiffMode string
+
+const (
+	DiffModeLine DiffMode = "LINE"
+	DiffModeWord DiffMode = "WORD"
+	DiffModeJson DiffMode = "JSON"
+)
+
+const (
+	DiffOpInsert  = "insert"
+	DiffOpDelete  = "delete"
+	DiffOpReplace = "replace"
+)
+
+// DiffHunk is one change between request a and request b. Path names the
+// header or JSON key path; AStart and BStart are 1-based line or word
+// positions for text diffs.
+type DiffHunk struct {
+	Op     string  `json:"op"`
+	Path   *string `json:"path,omitempty"`
+	AStart *int    `json:"aStart,omitempty"`
+	BStart *int    `json:"bStart,omitempty"`
+	A      *string `json:"a,omitempty"`
+	B      *string `json:"b,omitempty"`
+}
+
+// RequestDiff compares the responses of two MyRequests. Mode is the mode
+// actually used for the body, which falls back to LINE when a body is not JSON.
+type RequestDiff struct {
+	Mode      DiffMode    `json:"mode"`
+	Identical bool        `json:"identical"`
+	Status    []*DiffHunk `json:"status"`
+	Headers   []*DiffHunk `json:"headers"`
+	Body      []*DiffHunk `json:"body"`
+}
+
+// DiffRequests compares the status, headers and body of two responses
+func DiffRequests(a, b *MyRequest, mode DiffMode) *RequestDiff {
+	result := &RequestDiff{
+		Mode:    mode,
+		Status:  []*DiffHunk{},
+		Headers: diffHeaders(a.ResponseHeaders, b.ResponseHeaders),
+	}
+	if a.ResponseStatus != b.ResponseStatus {
+		result.Status = append(result.Status, replaceHunk("status", strconv.Itoa(a.ResponseStatus), strconv.Itoa(b.ResponseStatus)))
+	}
+
+	switch mode {
+	case DiffModeJson:
+		var aValue, bValue any
+		if json.Unmarshal([]byte(a.ResponseBody), &aValue) == nil && json.Unmarshal([]byte(b.ResponseBody), &bValue) == nil {
+			result.Body = []*DiffHunk{}
+			diffJson("$", aValue, bValue, &result.Body)
+			break
+		}
+		result.Mode = DiffModeLine
+		fallthrough
+	case DiffModeLine:
+		result.Body = diffText(strings.Split(a.ResponseBody, "\n"), strings.Split(b.ResponseBody, "\n"), "\n")
+	case DiffModeWord:
+		result.Body = diffText(strings.Fields(a.ResponseBody), strings.Fields(b.ResponseBody), " ")
+	}
+
+	result.Identical = len(result.Status) == 0 && len(result.Headers) == 0 && len(result.Body) == 0
+	return result
+}
+
+// diffText groups each run of changed tokens into a hunk
+func diffText(a, b []string, sep string) []*DiffHunk {
+	hunks := []*DiffHunk{}
+	var current *DiffHunk
+	var deleted, inserted []string
+	flush := func() {
+		if current == nil {
+			return
+		}
+		switch {
+		case len(deleted) > 0 && len(inserted) > 0:
+			current.Op = DiffOpReplace
+		case len(deleted) > 0:
+			current.Op = DiffOpDelete
+		default:
+			current.Op = DiffOpInsert
+		}
+		if len(deleted) > 0 {
+			current.A = ptr(strings.Join(deleted, sep))
+		}
+		if len(inserted) > 0 {
+			current.B = ptr(strings.Join(inserted, sep))
+		}
+		hunks = append(hunks, current)
+		current, deleted, inserted = nil, nil, nil
+	}
+
+	aPos, bPos := 0, 0
+	for _, e := range utils.DiffStrings(a, b) {
+		if e.Op == utils.EditEqual {
+			flush()
+			aPos++
+			bPos++
+			continue
+		}
+		if current == nil {
+			current = &DiffHunk{AStart: ptr(aPos + 1), BStart: ptr(bPos + 1)}
+		}
+		if e.Op == utils.EditDelete {
+			deleted = append(deleted, a[e.Index])
+			aPos++
+		} else {
+			inserted = append(inserted, b[e.Index])
+			bPos++
+		}
+	}
+	flush()
+	return hunks
+}
+
+// diffHeaders compares the JSON-encoded response headers by name
+func diffHeaders(aJson, bJson string) []*DiffHunk {
+	var aHeaders, bHeaders map[string][]string
+	json.Unmarshal([]byte(aJson), &aHeaders)
+	json.Unmarshal([]byte(bJson), &bHeaders)
+
+	names := make([]string, 0, len(aHeaders)+len(bHeaders))
+	for name := range aHeaders {
+		names = append(names, name)
+	}
+	for name := range bHeaders {
+		if _, ok := aHeaders[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	hunks := []*DiffHunk{}
+	for _, name := range names {
+		aValues, inA := aHeaders[name]
+		bValues, inB := bHeaders[name]
+		aValue, bValue := strings.Join(aValues, ", "), strings.Join(bValues, ", ")
+		switch {
+		case !inB:
+			hunks = append(hunks, &DiffHunk{Op: DiffOpDelete, Path: ptr(name), A: ptr(aValue)})
+		case !inA:
+			hunks = append(hunks, &DiffHunk{Op: DiffOpInsert, Path: ptr(name), B: ptr(bValue)})
+		case aValue != bValue:
+			hunks = append(hunks, replaceHunk(name, aValue, bValue))
+		}
+	}
+	return hunks
+}
+
+// diffJson walks both documents together, comparing objects by key and
+// arrays by index, and reports each differing value with its key path
+func diffJson(path string, a, b any, hunks *[]*DiffHunk) {
+	switch aValue := a.(type) {
+	case map[string]any:
+		bValue, ok := b.(map[string]any)
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(aValue)+len(bValue))
+		for k := range aValue {
+			keys = append(keys, k)
+		}
+		for k := range bValue {
+			if _, ok := aValue[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			childPath := jsonKeyPath(path, k)
+			aChild, inA := aValue[k]
+			bChild, inB := bValue[k]
+			switch {
+			case !inB:
+				*hunks = append(*hunks, &DiffHunk{Op: DiffOpDelete, Path: ptr(childPath), A: ptr(compactJson(aChild))})
+			case !inA:
+				*hunks = append(*hunks, &DiffHunk{Op: DiffOpInsert, Path: ptr(childPath), B: ptr(compactJson(bChild))})
+			default:
+				diffJson(childPath, aChild, bChild, hunks)
+			}
+		}
+		return
+	case []any:
+		bValue, ok := b.([]any)
+		if !ok {
+			break
+		}
+		for i := 0; i < max(len(aValue), len(bValue)); i++ {
+			childPath := fmt.Sprintf("%s[%d]", path, i)
+			switch {
+			case i >= len(bValue):
+				*hunks = append(*hunks, &DiffHunk{Op: DiffOpDelete, Path: ptr(childPath), A: ptr(compactJson(aValue[i]))})
+			case i >= len(aValue):
+				*hunks = append(*hunks, &DiffHunk{Op: DiffOpInsert, Path: ptr(childPath), B: ptr(compactJson(bValue[i]))})
+			default:
+				diffJson(childPath, aValue[i], bValue[i], hunks)
+			}
+		}
+		return
+	}
+
+	aText, bText := compactJson(a), compactJson(b)
+	if aText != bText {
+		*hunks = append(*hunks, replaceHunk(path, aText, bText))
+	}
+}
+
+var plainJsonKey = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+func jsonKeyPath(parent, key string) string {
+	if plainJsonKey.MatchString(key) {
+		return parent + "." + key
+	}
+	return parent + "[" + strconv.Quote(key) + "]"
+}
+
+func compactJson(v any) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+func replaceHunk(path, a, b string) *DiffHunk {
+	return &DiffHunk{Op: DiffOpReplace, Path: ptr(path), A: ptr(a), B: ptr(b)}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"testing"
+)
+
+func TestDiffRequests_Json(t *testing.T) {
+	a := &MyRequest{
+		ResponseStatus:  200,
+		ResponseHeaders: `{"Content-Type":["application/json"],"X-Admin":["1"]}`,
+		ResponseBody:    `{"user":{"name":"alice","roles":["admin","dev"]},"id":1}`,
+	}
+	b := &MyRequest{
+		ResponseStatus:  403,
+		ResponseHeaders: `{"Content-Type":["application/json"],"X-Request-Id":["abc"]}`,
+		ResponseBody:    `{"user":{"name":"alice","roles":["dev"]},"error":"forbidden","id":1}`,
+	}
+	diff := DiffRequests(a, b, DiffModeJson)
+	if diff.Mode != DiffModeJson || diff.Identical {
+		t.Fatalf("mode=%s identical=%v", diff.Mode, diff.Identical)
+	}
+	if len(diff.Status) != 1 || *diff.Status[0].A != "200" || *diff.Status[0].B != "403" {
+		t.Errorf("status hunks=%+v", diff.Status)
+	}
+	assertPaths(t, diff.Headers, "delete X-Admin", "insert X-Request-Id")
+	assertPaths(t, diff.Body, `insert $.error`, `replace $.user.roles[0]`, `delete $.user.roles[1]`)
+}
+
+func TestDiffRequests_LineFallback(t *testing.T) {
+	a := &MyRequest{ResponseStatus: 200, ResponseBody: "one\ntwo\nthree"}
+	b := &MyRequest{ResponseStatus: 200, ResponseBody: "one\n2\nthree\nfour"}
+	diff := DiffRequests(a, b, DiffModeJson)
+	if diff.Mode != DiffModeLine || len(diff.Body) != 2 {
+		t.Fatalf("mode=%s body=%+v", diff.Mode, diff.Body)
+	}
+	first := diff.Body[0]
+	if first.Op != DiffOpReplace || *first.AStart != 2 || *first.A != "two" || *first.B != "2" {
+		t.Errorf("first hunk=%+v", first)
+	}
+	if second := diff.Body[1]; second.Op != DiffOpInsert || *second.BStart != 4 || *second.B != "four" {
+		t.Errorf("second hunk=%+v", second)
+	}
+
+	if same := DiffRequests(a, a, DiffModeWord); !same.Identical {
+		t.Errorf("a request should be identical to itself: %+v", same)
+	}
+}
+
+func assertPaths(t *testing.T, hunks []*DiffHunk, want ...string) {
+	t.Helper()
+	var got []string
+	for _, h := range hunks {
+		got = append(got, h.Op+" "+*h.Path)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("hunks=%v want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("hunk %d=%q want %q", i, got[i], want[i])
+		}
+	}
+}
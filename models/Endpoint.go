@@ -1,9 +1,11 @@
 package models
 
 import (
+	"slices"
 	"strings"
 
 	"github.com/linn221/bane/mystructs"
+	"gorm.io/gorm"
 )
 
 type HttpSchema string
@@ -39,17 +41,50 @@ type Endpoint struct {
 	Headers     mystructs.VarKVGroup `gorm:"not null;column:http_headers"`
 	Body        mystructs.VarString  `gorm:"not null;column:http_body"`
 	Input       string               `gorm:"type:text;default:null"` // JSON-encoded EndpointInput for review
+	Extractors  mystructs.KVGroup    `gorm:"type:text"`              // "name:<kind>[.<argument>]" pairs saved to the variable store after each run
+	NoProxy     bool                 `gorm:"not null;default:false"` // send directly, bypassing the project and global proxies
+	RateLimit   RateLimit            `gorm:"embedded;embeddedPrefix:rate_"`
+	Transport   TransportOptions     `gorm:"embedded;embeddedPrefix:transport_"`
 	// Vulns       []Vuln               `gorm:"many2many:endpoint_vulns"`
 }
 
+// BeforeSave rejects rate limits and transport options the executor could
+// not use
+func (e *Endpoint) BeforeSave(tx *gorm.DB) error {
+	endpoint, err := patched(tx, e)
+	if err != nil {
+		return err
+	}
+	if err := endpoint.RateLimit.Validate(); err != nil {
+		return err
+	}
+	return endpoint.Transport.Validate()
+}
+
 type EndpointInput struct {
 	Name        string               `json:"name,omitempty"` // Optional
 	Description string               `json:"description"`
-	ProjectId   *int                 `json:"projectId,omitempty"` // Optional project reference
-	Method      *HttpMethod          `json:"method"`              // Required HTTP method
-	Url         mystructs.VarString  `json:"url"`                 // Full URL with optional VarString placeholders
-	Headers     mystructs.VarKVGroup `json:"headers"`             // HTTP headers
-	Body        *mystructs.VarString `json:"body,omitempty"`      // Optional HTTP body
+	ProjectId   *int                 `json:"projectId,omitempty"`  // Optional project reference
+	Method      *HttpMethod          `json:"method"`               // Required HTTP method
+	Url         mystructs.VarString  `json:"url"`                  // Full URL with optional VarString placeholders
+	Headers     mystructs.VarKVGroup `json:"headers"`              // HTTP headers
+	Body        *mystructs.VarString `json:"body,omitempty"`       // Optional HTTP body
+	Extractors  *mystructs.KVGroup   `json:"extractors,omitempty"` // Optional response extractors
+	NoProxy     *bool                `json:"noProxy,omitempty"`    // Optional opt-out of the upstream proxy
+	RateLimit   *RateLimit           `json:"rateLimit,omitempty"`  // Optional override of the project's rate limit
+	Transport   *TransportOptions    `json:"transport,omitempty"`  // Optional timeout, redirect, TLS and HTTP version settings
+}
+
+// ImportedEndpoint is an EndpointInput recovered from another format, such as a
+// curl command, along with the client options it asked for. Insecure and
+// FollowRedirects are also set on the input's transport options.
+type ImportedEndpoint struct {
+	EndpointInput
+	Format          string    `json:"format"` // the format the request was written in, such as curl or raw
+	Insecure        bool      `json:"insecure"`
+	FollowRedirects bool      `json:"followRedirects"`
+	Compressed      bool      `json:"compressed"`
+	Endpoint        *Endpoint `json:"endpoint,omitempty"` // set when the import was persisted
 }
 
 type PatchEndpoint struct {
@@ -63,6 +98,10 @@ type PatchEndpoint struct {
 	Queries     *mystructs.VarKVGroup `json:"queries,omitempty"`
 	Headers     *mystructs.VarKVGroup `json:"headers,omitempty"`
 	Body        *mystructs.VarString  `json:"body,omitempty"`
+	Extractors  *mystructs.KVGroup    `json:"extractors,omitempty"`
+	NoProxy     *bool                 `json:"noProxy,omitempty"`
+	RateLimit   *RateLimit            `json:"rateLimit,omitempty"`
+	Transport   *TransportOptions     `json:"transport,omitempty"`
 }
 type EndpointFilter struct {
 	Https  *bool      `json:"https,omitempty"` // true for https, false for http, nil for both
@@ -82,3 +121,32 @@ func (e *Endpoint) Text() string {
 		e.Body.Exec(),
 	}, " ")
 }
+
+// Placeholders returns the names of the variables used anywhere in the
+// request, in order of first appearance
+func (e *Endpoint) Placeholders() []string {
+	parts := []mystructs.VarString{e.Path}
+	for _, group := range []mystructs.VarKVGroup{e.Queries, e.Headers} {
+		for _, kv := range group.VarKVs {
+			parts = append(parts, kv.Key, kv.Value)
+		}
+	}
+	parts = append(parts, e.Body)
+
+	var names []string
+	seen := map[string]bool{}
+	for _, part := range parts {
+		for _, name := range part.Placeholders {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// HasPlaceholder reports whether any part of the request uses the named variable
+func (e *Endpoint) HasPlaceholder(name string) bool {
+	return slices.Contains(e.Placeholders(), name)
+}
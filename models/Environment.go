@@ -0,0 +1,19 @@
+package models
+
+import "github.com/linn221/bane/mystructs"
+
+// Environment is a named set of variables under a project, such as "staging"
+// or "prod, user A", chosen per execution to override endpoint defaults
+type Environment struct {
+	Id        int               `gorm:"primaryKey"`
+	ProjectId int               `gorm:"not null;uniqueIndex:idx_environment_project_name"`
+	Name      string            `gorm:"size:255;not null;uniqueIndex:idx_environment_project_name"`
+	Variables mystructs.KVGroup `gorm:"type:text"`
+}
+
+type EnvironmentInput struct {
+	ProjectId int                `json:"projectId"`
+	Name      string             `json:"name"`
+	Alias     string             `json:"alias,omitempty"`
+	Variables *mystructs.KVGroup `json:"variables,omitempty"`
+}
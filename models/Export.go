@@ -0,0 +1,221 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type ExportFormat string
+
+const (
+	ExportFormatCurl   ExportFormat = "curl"
+	ExportFormatRaw    ExportFormat = "raw"
+	ExportFormatHttpie ExportFormat = "httpie"
+	ExportFormatPython ExportFormat = "python"
+	ExportFormatGo     ExportFormat = "go"
+	ExportFormatFetch  ExportFormat = "fetch"
+	ExportFormatFfuf   ExportFormat = "ffuf"
+)
+
+// FuzzKeyword is the placeholder ffuf replaces with each wordlist entry
+const FuzzKeyword = "FUZZ"
+
+// Export renders the request in the given format
+func (r *RenderedRequest) Export(format ExportFormat) (string, error) {
+	switch format {
+	case ExportFormatCurl:
+		return r.Curl(), nil
+	case ExportFormatRaw:
+		return r.Raw(), nil
+	case ExportFormatHttpie:
+		return r.Httpie(), nil
+	case ExportFormatPython:
+		return r.Python(), nil
+	case ExportFormatGo:
+		return r.Go(), nil
+	case ExportFormatFetch:
+		return r.Fetch(), nil
+	case ExportFormatFfuf:
+		return r.Ffuf()
+	default:
+		return "", fmt.Errorf("unsupported export format %q", format)
+	}
+}
+
+// Raw returns the request as it would appear on the wire over HTTP/1.1
+func (r *RenderedRequest) Raw() string {
+	target, host := "/", ""
+	if u, err := url.Parse(r.Url); err == nil {
+		target, host = u.RequestURI(), u.Host
+	}
+
+	var b strings.Builder
+	b.WriteString(r.Method + " " + target + " HTTP/1.1\r\n")
+	if r.Header("Host") == "" {
+		b.WriteString("Host: " + host + "\r\n")
+	}
+	for _, h := range r.Headers {
+		b.WriteString(h.Key + ": " + h.Value + "\r\n")
+	}
+	if r.Body != "" && r.Header("Content-Length") == "" {
+		b.WriteString("Content-Length: " + strconv.Itoa(len(r.Body)) + "\r\n")
+	}
+	b.WriteString("\r\n" + r.Body)
+	return b.String()
+}
+
+// RawResponse returns the recorded response as it would appear on the wire.
+// The body is stored decoded, so the transfer and content encodings are
+// dropped and Content-Length is set to match it.
+func (r *MyRequest) RawResponse() string {
+	var headers map[string][]string
+	json.Unmarshal([]byte(r.ResponseHeaders), &headers)
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		switch strings.ToLower(name) {
+		case "content-length", "content-encoding", "transfer-encoding":
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("HTTP/1.1 %d %s\r\n", r.ResponseStatus, http.StatusText(r.ResponseStatus)))
+	for _, name := range names {
+		for _, value := range headers[name] {
+			b.WriteString(name + ": " + value + "\r\n")
+		}
+	}
+	b.WriteString("Content-Length: " + strconv.Itoa(len(r.ResponseBody)) + "\r\n")
+	b.WriteString("\r\n" + r.ResponseBody)
+	return b.String()
+}
+
+// Httpie returns an httpie command line for the request
+func (r *RenderedRequest) Httpie() string {
+	parts := []string{"http", "--ignore-stdin", r.Method, shellQuote(r.Url)}
+	for _, h := range r.Headers {
+		parts = append(parts, shellQuote(h.Key+":"+h.Value))
+	}
+	if r.Body != "" {
+		parts = append(parts, "--raw", shellQuote(r.Body))
+	}
+	return strings.Join(parts, " ")
+}
+
+// Python returns a standalone PoC using the requests library
+func (r *RenderedRequest) Python() string {
+	var b strings.Builder
+	b.WriteString("import requests\n\n")
+	b.WriteString("url = " + jsonQuote(r.Url) + "\n")
+	b.WriteString("headers = {\n")
+	for _, h := range r.Headers {
+		b.WriteString("    " + jsonQuote(h.Key) + ": " + jsonQuote(h.Value) + ",\n")
+	}
+	b.WriteString("}\n")
+	args := "headers=headers"
+	if r.Body != "" {
+		b.WriteString("data = " + jsonQuote(r.Body) + "\n")
+		args += ", data=data"
+	}
+	b.WriteString("\nresponse = requests.request(" + jsonQuote(r.Method) + ", url, " + args + ", allow_redirects=False)\n")
+	b.WriteString("print(response.status_code)\n")
+	b.WriteString("print(response.text)\n")
+	return b.String()
+}
+
+// Go returns a standalone net/http program that sends the request
+func (r *RenderedRequest) Go() string {
+	var b strings.Builder
+	b.WriteString("package main\n\nimport (\n\t\"fmt\"\n\t\"io\"\n\t\"net/http\"\n")
+	if r.Body != "" {
+		b.WriteString("\t\"strings\"\n")
+	}
+	b.WriteString(")\n\nfunc main() {\n")
+	body := "nil"
+	if r.Body != "" {
+		b.WriteString("\tbody := strings.NewReader(" + strconv.Quote(r.Body) + ")\n")
+		body = "body"
+	}
+	b.WriteString("\treq, err := http.NewRequest(" + strconv.Quote(r.Method) + ", " + strconv.Quote(r.Url) + ", " + body + ")\n")
+	b.WriteString("\tif err != nil {\n\t\tpanic(err)\n\t}\n")
+	for _, h := range r.Headers {
+		if strings.EqualFold(h.Key, "Host") {
+			b.WriteString("\treq.Host = " + strconv.Quote(h.Value) + "\n")
+			continue
+		}
+		b.WriteString("\treq.Header.Add(" + strconv.Quote(h.Key) + ", " + strconv.Quote(h.Value) + ")\n")
+	}
+	b.WriteString("\tclient := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}\n")
+	b.WriteString("\tresp, err := client.Do(req)\n")
+	b.WriteString("\tif err != nil {\n\t\tpanic(err)\n\t}\n")
+	b.WriteString("\tdefer resp.Body.Close()\n")
+	b.WriteString("\trespBody, _ := io.ReadAll(resp.Body)\n")
+	b.WriteString("\tfmt.Println(resp.Status)\n")
+	b.WriteString("\tfmt.Println(string(respBody))\n")
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// Fetch returns a JavaScript fetch call for the request
+func (r *RenderedRequest) Fetch() string {
+	var b strings.Builder
+	b.WriteString("fetch(" + jsonQuote(r.Url) + ", {\n")
+	b.WriteString("  method: " + jsonQuote(r.Method) + ",\n")
+	b.WriteString("  headers: {\n")
+	for _, h := range r.Headers {
+		b.WriteString("    " + jsonQuote(h.Key) + ": " + jsonQuote(h.Value) + ",\n")
+	}
+	b.WriteString("  },\n")
+	if r.Body != "" {
+		b.WriteString("  body: " + jsonQuote(r.Body) + ",\n")
+	}
+	b.WriteString("  redirect: \"manual\",\n")
+	b.WriteString("}).then(res => res.text()).then(console.log);\n")
+	return b.String()
+}
+
+// Ffuf returns an ffuf command line. The request must already contain the
+// FUZZ keyword, which is done by rendering it with a variable set to FuzzKeyword.
+func (r *RenderedRequest) Ffuf() (string, error) {
+	if !r.contains(FuzzKeyword) {
+		return "", fmt.Errorf("request has no %s keyword; choose a variable to fuzz", FuzzKeyword)
+	}
+	parts := []string{"ffuf", "-w", "wordlist.txt", "-u", shellQuote(r.Url), "-X", r.Method}
+	for _, h := range r.Headers {
+		parts = append(parts, "-H", shellQuote(h.Key+": "+h.Value))
+	}
+	if r.Body != "" {
+		parts = append(parts, "-d", shellQuote(r.Body))
+	}
+	return strings.Join(parts, " "), nil
+}
+
+func (r *RenderedRequest) contains(s string) bool {
+	if strings.Contains(r.Url, s) || strings.Contains(r.Body, s) {
+		return true
+	}
+	for _, h := range r.Headers {
+		if strings.Contains(h.Key, s) || strings.Contains(h.Value, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonQuote returns s as a double-quoted string literal, which Python,
+// JavaScript and JSON all read the same way
+func jsonQuote(s string) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
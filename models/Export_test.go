@@ -0,0 +1,64 @@
+package models
+
+import (
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+
+	"github.com/linn221/bane/mystructs"
+)
+
+func exportTestRequest() *RenderedRequest {
+	return &RenderedRequest{
+		Method:  "POST",
+		Url:     "https://example.com/api/items?id=FUZZ",
+		Headers: []mystructs.KVPair{{Key: "Content-Type", Value: "application/json"}},
+		Body:    `{"note":"it's \"quoted\""}`,
+	}
+}
+
+func TestRenderedRequest_Raw(t *testing.T) {
+	want := "POST /api/items?id=FUZZ HTTP/1.1\r\n" +
+		"Host: example.com\r\n" +
+		"Content-Type: application/json\r\n" +
+		"Content-Length: 26\r\n" +
+		"\r\n" +
+		`{"note":"it's \"quoted\""}`
+	if got := exportTestRequest().Raw(); got != want {
+		t.Errorf("raw=\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestRenderedRequest_GoSnippetParses(t *testing.T) {
+	src := exportTestRequest().Go()
+	if _, err := parser.ParseFile(token.NewFileSet(), "main.go", src, 0); err != nil {
+		t.Fatalf("generated Go does not parse: %v\n%s", err, src)
+	}
+}
+
+func TestRenderedRequest_Ffuf(t *testing.T) {
+	got, err := exportTestRequest().Ffuf()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(got, "-u 'https://example.com/api/items?id=FUZZ'") || !strings.Contains(got, `-d '{"note":"it'\''s \"quoted\""}'`) {
+		t.Errorf("ffuf=%s", got)
+	}
+
+	noKeyword := exportTestRequest()
+	noKeyword.Url = "https://example.com/"
+	if _, err := noKeyword.Ffuf(); err == nil {
+		t.Error("expected an error when FUZZ is missing")
+	}
+}
+
+func TestExportFormat_UnmarshalGQL(t *testing.T) {
+	var f ExportFormat
+	if err := f.UnmarshalGQL("Python"); err != nil || f != ExportFormatPython {
+		t.Errorf("format=%q err=%v", f, err)
+	}
+	if err := f.UnmarshalGQL("perl"); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
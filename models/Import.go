@@ -0,0 +1,10 @@
+package models
+
+// ImportResult reports what an import stored. Endpoints holds one entry per
+// distinct endpoint in the import, whether created or matched to an existing one.
+type ImportResult struct {
+	Endpoints []*Endpoint  `json:"endpoints"`
+	Requests  []*MyRequest `json:"requests"`
+	Created   int          `json:"created"` // endpoints created
+	Reused    int          `json:"reused"`  // endpoints that already existed
+}
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"time"
+)
+
+// IssueSeverity ranks the issues the passive scanner finds
+type IssueSeverity string
+
+const (
+	IssueSeverityInfo   IssueSeverity = "info"
+	IssueSeverityLow    IssueSeverity = "low"
+	IssueSeverityMedium IssueSeverity = "medium"
+	IssueSeverityHigh   IssueSeverity = "high"
+)
+
+// AtLeast lists the severities from s up
+func (s IssueSeverity) AtLeast() []IssueSeverity {
+	all := []IssueSeverity{IssueSeverityInfo, IssueSeverityLow, IssueSeverityMedium, IssueSeverityHigh}
+	for i, severity := range all {
+		if severity == s {
+			return all[i:]
+		}
+	}
+	return nil
+}
+
+// Issue is a problem the passive scanner found in recorded responses. Finding
+// it again, such as the same missing header on every page of a host, links
+// the request to the issue rather than adding another.
+type Issue struct {
+	Id            int           `gorm:"primaryKey"`
+	Fingerprint   string        `gorm:"size:64;not null;uniqueIndex"` // the project, rule, host and what was found, hashed
+	Rule          string        `gorm:"size:50;not null;index"`
+	Severity      IssueSeverity `gorm:"size:10;not null;index"`
+	Title         string        `gorm:"not null"`
+	Detail        string        `gorm:"type:text"`
+	Evidence      string        `gorm:"type:text"` // the text that matched, with secrets masked
+	Host          string        `gorm:"size:255;index"`
+	ProjectId     *int          `gorm:"default:null;index"`
+	EndpointId    int           `gorm:"not null;index"` // of the first request
+	MyRequestId   int           `gorm:"not null;index"` // the first request it was found in
+	LastRequestId int           `gorm:"not null"`
+	Requests      int           `gorm:"not null;default:0"` // how many requests it was found in
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+// IssueRequest links an issue to a request it was found in
+type IssueRequest struct {
+	IssueId     int `gorm:"primaryKey"`
+	MyRequestId int `gorm:"primaryKey;index"`
+}
+
+// IssueFilter for filtering issues
+type IssueFilter struct {
+	ProjectId  *int           `json:"projectId,omitempty"`
+	EndpointId *int           `json:"endpointId,omitempty"`
+	RequestId  *int           `json:"requestId,omitempty"` // the issues found in one request
+	Rule       *string        `json:"rule,omitempty"`
+	Severity   *IssueSeverity `json:"severity,omitempty"` // this severity and above
+	Limit      int            `json:"limit,omitempty"`    // all issues when 0
+	Offset     int            `json:"offset,omitempty"`   // issues skipped, for paging with limit
+}
+
+// IssueFingerprint identifies a finding of a project's, so that finding it
+// again in other requests updates one issue
+func IssueFingerprint(projectId *int, host string, f Finding) string {
+	project := ""
+	if projectId != nil {
+		project = strconv.Itoa(*projectId)
+	}
+	sum := sha256.Sum256([]byte(project + "\x00" + f.Rule + "\x00" + host + "\x00" + f.Key))
+	return hex.EncodeToString(sum[:])
+}
@@ -1,7 +1,14 @@
 package models
 
 import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
 	"time"
+
+	"github.com/linn221/bane/utils"
+	"gorm.io/gorm"
 )
 
 // MyRequest represents a request execution with response data
@@ -10,40 +17,84 @@ type MyRequest struct {
 	Id         int      `gorm:"primaryKey"`
 	EndpointId int      `gorm:"not null;index"`
 	Endpoint   Endpoint `gorm:"foreignKey:EndpointId"`
+	JobId      *int     `gorm:"default:null;index"` // set when sent as part of a Job
 
 	// Request information
 	RequestMethod  string `gorm:"size:10;not null"`
 	RequestUrl     string `gorm:"not null"`
+	RequestHost    string `gorm:"size:255;index"`
 	RequestHeaders string `gorm:"type:text"` // JSON string of headers
 	RequestBody    string `gorm:"type:text"`
 
 	// Response information
-	ResponseStatus  int    `gorm:"not null"`
-	ResponseHeaders string `gorm:"type:text"` // JSON string of response headers
-	ResponseBody    string `gorm:"type:longtext"`
-	ContentType     string `gorm:"size:255"`
-	ContentLength   int64  `gorm:"default:0"`
+	ResponseStatus    int    `gorm:"not null"`
+	ResponseHeaders   string `gorm:"type:text"`                  // JSON string of response headers
+	ResponseBody      string `gorm:"-"`                          // kept in a ResponseBlob
+	ResponseBodyHash  string `gorm:"size:64;index;default:null"` // of the ResponseBlob, empty for an empty body
+	ResponseTruncated bool   `gorm:"default:false"`              // set when the body was cut to MAX_BODY_SIZE
+	ContentType       string `gorm:"size:255"`
+	ContentLength     int64  `gorm:"default:0"`
 
 	// Performance metrics
-	Latency int64 `gorm:"not null"`  // Latency in milliseconds
-	Size    int64 `gorm:"default:0"` // Response size in bytes
+	Latency        int64 `gorm:"not null"`  // Latency in milliseconds
+	DnsLatency     int64 `gorm:"default:0"` // DNS lookup time in milliseconds
+	ConnectLatency int64 `gorm:"default:0"` // TCP connect time in milliseconds
+	TlsLatency     int64 `gorm:"default:0"` // TLS handshake time in milliseconds
+	Ttfb           int64 `gorm:"default:0"` // Time to first response byte in milliseconds
+	Size           int64 `gorm:"default:0"` // Response size in bytes
+
+	// Redirects and TLS
+	Redirects    []RedirectHop    `gorm:"foreignKey:MyRequestId"` // saved with the request
+	TlsVersion   string           `gorm:"size:20"`                // empty for plain http
+	TlsCipher    string           `gorm:"size:100"`
+	TlsAlpn      string           `gorm:"size:20"`                // the protocol negotiated in the handshake, such as h2
+	Certificates []TlsCertificate `gorm:"foreignKey:MyRequestId"` // saved with the request
 
 	// Execution metadata
 	ExecutedAt  time.Time `gorm:"autoCreateTime"`
 	Variables   string    `gorm:"type:text"` // JSON string of variables used
-	CurlCommand string    `gorm:"type:text"` // The actual curl command executed
+	Extracted   string    `gorm:"type:text"` // JSON string of values saved to the variable store
+	CurlCommand string    `gorm:"type:text"` // Equivalent curl command, for copy/paste only
 
 	// Error information
-	Error   string `gorm:"type:text"` // Error message if request failed
-	Success bool   `gorm:"default:true"`
+	Error   string `gorm:"type:text"`     // Error message if request failed
+	Success bool   `gorm:"default:false"` // false when no response was received
+}
+
+// BeforeCreate fills RequestHost and stores the response body as a blob
+func (r *MyRequest) BeforeCreate(tx *gorm.DB) error {
+	r.RequestHost = RequestHost(r.RequestUrl)
+	return r.storeResponseBody(tx)
+}
+
+// RequestHost is the lowercased host of a request URL, or empty when it
+// has none
+func RequestHost(requestUrl string) string {
+	u, err := url.Parse(requestUrl)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+// Response returns the recorded response for value extraction
+func (r *MyRequest) Response() utils.Response {
+	headers := http.Header{}
+	json.Unmarshal([]byte(r.ResponseHeaders), &headers)
+	return utils.Response{Status: r.ResponseStatus, Headers: headers, Body: r.ResponseBody}
 }
 
 // MyRequestFilter for filtering requests
 type MyRequestFilter struct {
 	EndpointId int    `json:"endpointId,omitempty"`
+	JobId      int    `json:"jobId,omitempty"`
 	Success    *bool  `json:"success,omitempty"`
 	StatusMin  int    `json:"statusMin,omitempty"`
 	StatusMax  int    `json:"statusMax,omitempty"`
 	DateFrom   string `json:"dateFrom,omitempty"`
 	DateTo     string `json:"dateTo,omitempty"`
+	Query      string `json:"query,omitempty"`  // a RequestQuery expression
+	Sort       string `json:"sort,omitempty"`   // a key of the RequestQuery, with - in front for descending order
+	Limit      int    `json:"limit,omitempty"`  // all requests when 0
+	Offset     int    `json:"offset,omitempty"` // requests skipped, for paging with limit
 }
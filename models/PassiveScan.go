@@ -0,0 +1,252 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Passive scanner rules
+const (
+	IssueRuleMissingHeader    = "missing-security-header"
+	IssueRuleServerBanner     = "server-banner"
+	IssueRuleStackTrace       = "stack-trace"
+	IssueRuleSqlError         = "sql-error"
+	IssueRuleSecret           = "leaked-secret"
+	IssueRuleDirectoryListing = "directory-listing"
+	IssueRuleInsecureCookie   = "insecure-cookie"
+	IssueRuleMixedContent     = "mixed-content"
+)
+
+// Finding is something a passive rule found in a response
+type Finding struct {
+	Rule     string
+	Severity IssueSeverity
+	Title    string
+	Detail   string
+	Evidence string
+	Key      string // tells it apart from the rule's other findings on the host
+}
+
+// maxEvidence is how much of the matched text a finding keeps
+const maxEvidence = 200
+
+type pattern struct {
+	name string
+	re   *regexp.Regexp
+}
+
+var stackTracePatterns = []pattern{
+	{"Java", regexp.MustCompile(`at [\w$.]+\.[\w$<>]+\([\w$]+\.java:\d+\)`)},
+	{".NET", regexp.MustCompile(`at [\w.` + "`" + `<>]+\([^)\n]*\) in [^\n]+?:line \d+`)},
+	{"Python", regexp.MustCompile(`Traceback \(most recent call last\):`)},
+	{"PHP", regexp.MustCompile(`(?:Fatal error|Parse error|Warning|Notice)(?:</b>)?:[^\n]{0,300}? in (?:<b>)?[^<\n]+?(?:</b>)? on line (?:<b>)?\d+`)},
+	{"Node.js", regexp.MustCompile(`at (?:[\w.<>$]+ )?\(?(?:/|[A-Za-z]:\\|file://)[^\s()]+\.(?:js|mjs|cjs|ts):\d+:\d+\)?`)},
+	{"Ruby", regexp.MustCompile(`[\w/.-]+\.rb:\d+:in ` + "[`']")},
+	{"Go", regexp.MustCompile(`goroutine \d+ \[running\]:`)},
+}
+
+var sqlErrorPatterns = []pattern{
+	{"MySQL", regexp.MustCompile(`You have an error in your SQL syntax|com\.mysql\.jdbc|MySqlException|Warning</b>?: mysqli?_`)},
+	{"PostgreSQL", regexp.MustCompile(`PG::[A-Z]\w+Error|org\.postgresql\.util\.PSQLException|ERROR:\s+syntax error at or near|pg_query\(\)`)},
+	{"SQL Server", regexp.MustCompile(`Unclosed quotation mark after the character string|Microsoft OLE DB Provider for SQL Server|System\.Data\.SqlClient\.SqlException|\[SQL Server\]`)},
+	{"Oracle", regexp.MustCompile(`\bORA-\d{5}\b`)},
+	{"SQLite", regexp.MustCompile(`SQLite3?::\w+Exception|sqlite3\.OperationalError|SQLITE_ERROR|near "[^"\n]{1,50}": syntax error`)},
+	{"SQL", regexp.MustCompile(`SQLSTATE\[\w+\]`)},
+}
+
+var secretPatterns = []struct {
+	pattern
+	severity IssueSeverity
+}{
+	{pattern{"AWS access key ID", regexp.MustCompile(`\b(?:AKIA|ASIA|AIDA|AROA)[0-9A-Z]{16}\b`)}, IssueSeverityHigh},
+	{pattern{"AWS secret access key", regexp.MustCompile(`(?i)aws.{0,20}?secret.{0,20}?["']?\s*[:=]\s*["']?([A-Za-z0-9/+]{40})\b`)}, IssueSeverityHigh},
+	{pattern{"private key", regexp.MustCompile(`-----BEGIN (?:[A-Z]+ )*PRIVATE KEY(?: BLOCK)?-----\s+([A-Za-z0-9+/=]{16,})`)}, IssueSeverityHigh},
+	{pattern{"JWT", regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{5,}\.eyJ[A-Za-z0-9_-]{5,}\.[A-Za-z0-9_-]*`)}, IssueSeverityMedium},
+}
+
+var directoryListing = regexp.MustCompile(`(?i)<title>\s*(?:Index of /|Directory listing for /)|<h1>\s*Index of /|\[To Parent Directory\]`)
+
+// insecureResource matches resources an https page loads over http
+var insecureResource = regexp.MustCompile(`(?i)<(script|iframe|frame|link|object|embed|form|img|audio|video|source)\b[^>]*?\s(?:src|href|action|data)\s*=\s*["']?(http://[^"'\s>]+)`)
+
+// activeContent can change the page when loaded over http, unlike images
+// and media
+var activeContent = map[string]bool{"script": true, "iframe": true, "frame": true, "link": true, "object": true, "embed": true, "form": true}
+
+// PassiveScan runs the passive rules over a recorded response
+func PassiveScan(r *MyRequest) []Finding {
+	if !r.Success {
+		return nil
+	}
+	response := r.Response()
+	u, err := url.Parse(r.RequestUrl)
+	if err != nil {
+		return nil
+	}
+	https := u.Scheme == "https"
+	contentType := r.ContentType
+	if contentType == "" {
+		contentType = response.Headers.Get("Content-Type")
+	}
+	html := strings.Contains(strings.ToLower(contentType), "html")
+
+	var findings []Finding
+	findings = append(findings, missingHeaders(response.Headers, https, html)...)
+	findings = append(findings, serverBanners(response.Headers)...)
+	findings = append(findings, insecureCookies(response.Headers, https)...)
+	body := response.Body
+	for _, p := range stackTracePatterns {
+		if match := p.re.FindString(body); match != "" {
+			findings = append(findings, Finding{
+				Rule: IssueRuleStackTrace, Severity: IssueSeverityMedium,
+				Title:    p.name + " stack trace",
+				Detail:   "The response shows a stack trace, which reveals code paths, file names and frameworks.",
+				Evidence: evidence(match), Key: u.Path + " " + p.name,
+			})
+		}
+	}
+	for _, p := range sqlErrorPatterns {
+		if match := p.re.FindString(body); match != "" {
+			findings = append(findings, Finding{
+				Rule: IssueRuleSqlError, Severity: IssueSeverityMedium,
+				Title:    p.name + " error message",
+				Detail:   "The response shows a database error. Errors caused by input are a sign of SQL injection.",
+				Evidence: evidence(match), Key: u.Path + " " + p.name,
+			})
+		}
+	}
+	for _, p := range secretPatterns {
+		for _, match := range p.re.FindAllStringSubmatch(body, -1) {
+			// the secret itself, without what led up to it
+			secret := match[len(match)-1]
+			sum := sha256.Sum256([]byte(secret))
+			findings = append(findings, Finding{
+				Rule: IssueRuleSecret, Severity: p.severity,
+				Title:    "Leaked " + p.name,
+				Detail:   "The response contains what looks like a " + p.name + ".",
+				Evidence: mask(secret), Key: p.name + " " + hex.EncodeToString(sum[:]),
+			})
+		}
+	}
+	if match := directoryListing.FindString(body); match != "" {
+		findings = append(findings, Finding{
+			Rule: IssueRuleDirectoryListing, Severity: IssueSeverityLow,
+			Title:    "Directory listing",
+			Detail:   "The server lists the files of a directory.",
+			Evidence: evidence(match), Key: u.Path,
+		})
+	}
+	if https && html {
+		for _, match := range insecureResource.FindAllStringSubmatch(body, -1) {
+			tag := strings.ToLower(match[1])
+			severity := IssueSeverityLow
+			if activeContent[tag] {
+				severity = IssueSeverityMedium
+			}
+			findings = append(findings, Finding{
+				Rule: IssueRuleMixedContent, Severity: severity,
+				Title:    "Mixed content: " + tag + " over http",
+				Detail:   "An https page loads " + match[2] + " over plain http.",
+				Evidence: evidence(match[0]), Key: match[2],
+			})
+		}
+	}
+	return findings
+}
+
+func missingHeaders(headers http.Header, https, html bool) []Finding {
+	var missing []string
+	if https && headers.Get("Strict-Transport-Security") == "" {
+		missing = append(missing, "Strict-Transport-Security")
+	}
+	if html {
+		csp := headers.Get("Content-Security-Policy")
+		if csp == "" {
+			missing = append(missing, "Content-Security-Policy")
+		}
+		if headers.Get("X-Frame-Options") == "" && !strings.Contains(strings.ToLower(csp), "frame-ancestors") {
+			missing = append(missing, "X-Frame-Options")
+		}
+		if headers.Get("X-Content-Type-Options") == "" {
+			missing = append(missing, "X-Content-Type-Options")
+		}
+	}
+	findings := make([]Finding, 0, len(missing))
+	for _, name := range missing {
+		severity := IssueSeverityLow
+		if name == "X-Content-Type-Options" {
+			severity = IssueSeverityInfo
+		}
+		findings = append(findings, Finding{
+			Rule: IssueRuleMissingHeader, Severity: severity,
+			Title:  "Missing " + name + " header",
+			Detail: "The response does not set " + name + ".",
+			Key:    name,
+		})
+	}
+	return findings
+}
+
+var hasVersion = regexp.MustCompile(`\d+\.\d+`)
+
+func serverBanners(headers http.Header) []Finding {
+	var findings []Finding
+	for _, name := range []string{"Server", "X-Powered-By", "X-AspNet-Version", "X-AspNetMvc-Version"} {
+		value := headers.Get(name)
+		// a bare product name such as nginx says little
+		if value == "" || name == "Server" && !hasVersion.MatchString(value) {
+			continue
+		}
+		findings = append(findings, Finding{
+			Rule: IssueRuleServerBanner, Severity: IssueSeverityInfo,
+			Title:    "Software version in " + name + " header",
+			Detail:   "The " + name + " header names the software behind the server, which helps look up its known vulnerabilities.",
+			Evidence: evidence(name + ": " + value), Key: name + ": " + value,
+		})
+	}
+	return findings
+}
+
+func insecureCookies(headers http.Header, https bool) []Finding {
+	var findings []Finding
+	add := func(c *http.Cookie, flag, detail string) {
+		findings = append(findings, Finding{
+			Rule: IssueRuleInsecureCookie, Severity: IssueSeverityLow,
+			Title:    "Cookie " + c.Name + " " + flag,
+			Detail:   detail,
+			Evidence: evidence(c.Name + "=" + mask(c.Value)),
+			Key:      c.Name + " " + flag,
+		})
+	}
+	for _, c := range (&http.Response{Header: headers}).Cookies() {
+		if https && !c.Secure {
+			add(c, "without Secure", "The cookie can be sent over plain http, where it can be read.")
+		}
+		if !c.HttpOnly {
+			add(c, "without HttpOnly", "Scripts on the page can read the cookie.")
+		}
+		if c.SameSite == http.SameSiteNoneMode && !c.Secure {
+			add(c, "with SameSite=None without Secure", "Browsers reject SameSite=None cookies that are not Secure.")
+		}
+	}
+	return findings
+}
+
+func evidence(text string) string {
+	if len(text) > maxEvidence {
+		return text[:maxEvidence] + "…"
+	}
+	return text
+}
+
+// mask keeps the ends of a secret
+func mask(secret string) string {
+	if len(secret) <= 12 {
+		return strings.Repeat("*", len(secret))
+	}
+	return secret[:4] + "…" + secret[len(secret)-4:]
+}
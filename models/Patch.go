@@ -0,0 +1,57 @@
+package models
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// patched returns the model a save hook should validate. patch passes the
+// changed columns as a map instead of the model; then it is a zero model with
+// only those columns set, so validators check just the fields being changed.
+func patched[T any](tx *gorm.DB, model *T) (*T, error) {
+	updates, ok := tx.Statement.Dest.(map[string]any)
+	if !ok {
+		return model, nil
+	}
+	result := new(T)
+	value := reflect.ValueOf(result).Elem()
+	for column, v := range updates {
+		field := tx.Statement.Schema.LookUpField(column)
+		if field == nil {
+			continue // left for gorm to reject
+		}
+		if err := field.Set(tx.Statement.Context, value, fromString(field, v)); err != nil {
+			return nil, fmt.Errorf("%s: %v", column, err)
+		}
+	}
+	return result, nil
+}
+
+// fromString parses the strings patch passes for number and boolean
+// columns, which the database would convert on its own
+func fromString(field *schema.Field, v any) any {
+	s, ok := v.(string)
+	if !ok {
+		return v
+	}
+	var parsed any
+	var err error
+	switch field.IndirectFieldType.Kind() {
+	case reflect.Float32, reflect.Float64:
+		parsed, err = strconv.ParseFloat(s, 64)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		parsed, err = strconv.ParseInt(s, 10, 64)
+	case reflect.Bool:
+		parsed, err = strconv.ParseBool(s)
+	default:
+		return v
+	}
+	if err != nil {
+		return v
+	}
+	return parsed
+}
@@ -1,17 +1,59 @@
 package models
 
+import (
+	"github.com/linn221/bane/utils"
+	"gorm.io/gorm"
+)
+
 type Project struct {
-	Id          int    `gorm:"primaryKey"`
-	Name        string `gorm:"not null"`
-	Description string `gorm:"type:text"`
-	Url         string `gorm:"type:text;default:null"`
+	Id          int        `gorm:"primaryKey"`
+	Name        string     `gorm:"not null"`
+	Description string     `gorm:"type:text"`
+	Url         string     `gorm:"type:text;default:null"`
+	Proxy       string     `gorm:"type:text;default:null"` // upstream proxy for the project's requests, overriding the global one
+	InScope     ScopeRules `gorm:"type:text"`
+	OutOfScope  ScopeRules `gorm:"type:text"`
+	RateLimit   RateLimit  `gorm:"embedded;embeddedPrefix:rate_"`
+}
+
+// BeforeSave rejects scope rules, rate limits and proxies the executor could
+// not use, and stores the proxy in its canonical form
+func (p *Project) BeforeSave(tx *gorm.DB) error {
+	project, err := patched(tx, p)
+	if err != nil {
+		return err
+	}
+	if _, err := NewScope(project.InScope, project.OutOfScope); err != nil {
+		return err
+	}
+	if err := project.RateLimit.Validate(); err != nil {
+		return err
+	}
+	if project.Proxy == "" {
+		return nil
+	}
+	u, err := utils.ParseProxyUrl(project.Proxy)
+	if err != nil {
+		return err
+	}
+	tx.Statement.SetColumn("Proxy", u.String())
+	return nil
+}
+
+// Scope compiles the project's scope rules
+func (p *Project) Scope() (*Scope, error) {
+	return NewScope(p.InScope, p.OutOfScope)
 }
 
 type ProjectInput struct {
-	Name        string `json:"name"`
-	Alias       string `json:"alias,omitempty"`
-	Description string `json:"description,omitempty"`
-	Url         string `json:"url,omitempty"`
+	Name        string     `json:"name"`
+	Alias       string     `json:"alias,omitempty"`
+	Description string     `json:"description,omitempty"`
+	Url         string     `json:"url,omitempty"`
+	Proxy       string     `json:"proxy,omitempty"`
+	InScope     ScopeRules `json:"inScope,omitempty"`
+	OutOfScope  ScopeRules `json:"outOfScope,omitempty"`
+	RateLimit   *RateLimit `json:"rateLimit,omitempty"`
 }
 
 type ProjectFilter struct {
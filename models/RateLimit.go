@@ -0,0 +1,48 @@
+package models
+
+import "fmt"
+
+// RateLimit throttles the requests sent to each host. The fields an
+// endpoint leaves unset are taken from its project; the ones its project
+// leaves unset do not throttle.
+type RateLimit struct {
+	RequestsPerSecond *float64 `json:"requestsPerSecond,omitempty"`
+	Burst             *int     `json:"burst,omitempty"`          // requests that may go back to back after a pause, 1 by default
+	MaxConcurrency    *int     `json:"maxConcurrency,omitempty"` // requests in flight to the host at once
+}
+
+// RequestsPerSecond bounds. The limiter waits up to 1/RequestsPerSecond
+// seconds for a token, which overflows a time.Duration for tiny rates.
+const (
+	MinRequestsPerSecond = 0.001
+	MaxRequestsPerSecond = 10000
+)
+
+// Over fills the fields l leaves unset from base
+func (l RateLimit) Over(base RateLimit) RateLimit {
+	if l.RequestsPerSecond == nil {
+		l.RequestsPerSecond = base.RequestsPerSecond
+	}
+	if l.Burst == nil {
+		l.Burst = base.Burst
+	}
+	if l.MaxConcurrency == nil {
+		l.MaxConcurrency = base.MaxConcurrency
+	}
+	return l
+}
+
+// Validate rejects values that would never let a request through or that
+// the limiter cannot wait for
+func (l RateLimit) Validate() error {
+	if rate := l.RequestsPerSecond; rate != nil && !(*rate >= MinRequestsPerSecond && *rate <= MaxRequestsPerSecond) {
+		return fmt.Errorf("requestsPerSecond must be between %v and %v, got %v", MinRequestsPerSecond, MaxRequestsPerSecond, *rate)
+	}
+	if l.Burst != nil && *l.Burst < 1 {
+		return fmt.Errorf("burst must be at least 1, got %d", *l.Burst)
+	}
+	if l.MaxConcurrency != nil && *l.MaxConcurrency < 1 {
+		return fmt.Errorf("maxConcurrency must be at least 1, got %d", *l.MaxConcurrency)
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"math"
+	"testing"
+)
+
+func TestRateLimit_Validate(t *testing.T) {
+	rate := func(v float64) RateLimit { return RateLimit{RequestsPerSecond: &v} }
+	count := func(v int) *int { return &v }
+	tests := []struct {
+		limit RateLimit
+		valid bool
+	}{
+		{RateLimit{}, true},
+		{rate(0.001), true},
+		{rate(2.5), true},
+		{rate(10000), true},
+		{rate(0), false},
+		{rate(-1), false},
+		{rate(1e-12), false},
+		{rate(10001), false},
+		{rate(math.NaN()), false},
+		{rate(math.Inf(1)), false},
+		{RateLimit{Burst: count(1), MaxConcurrency: count(4)}, true},
+		{RateLimit{Burst: count(0)}, false},
+		{RateLimit{MaxConcurrency: count(0)}, false},
+	}
+	for _, tt := range tests {
+		if err := tt.limit.Validate(); (err == nil) != tt.valid {
+			t.Errorf("Validate(%+v) = %v, want valid %v", tt.limit, err, tt.valid)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package models
+
+import (
+	"path"
+	"strings"
+	"time"
+)
+
+// DefaultIgnoredExtensions are the static assets the recorder passes through
+// without recording
+var DefaultIgnoredExtensions = []string{
+	"css", "js", "mjs", "map", "png", "jpg", "jpeg", "gif", "webp", "avif", "svg", "ico", "bmp",
+	"woff", "woff2", "ttf", "otf", "eot", "mp3", "mp4", "webm", "ogg", "wav",
+}
+
+// Recorder is the state of the recording proxy. Every request through it is
+// forwarded; the ones that pass its filters are also recorded as MyRequests
+// of automatically created endpoints.
+type Recorder struct {
+	Running          bool       `json:"running"`
+	Address          string     `json:"address,omitempty"`
+	ProjectId        *int       `json:"projectId,omitempty"`
+	Hosts            []string   `json:"hosts"`        // hosts to record, all when empty; *.example.com also matches subdomains
+	ExcludeHosts     []string   `json:"excludeHosts"` // hosts never recorded
+	IgnoreExtensions []string   `json:"ignoreExtensions"`
+	Recorded         int        `json:"recorded"`
+	Ignored          int        `json:"ignored"`
+	StartedAt        *time.Time `json:"startedAt,omitempty"`
+}
+
+type RecorderInput struct {
+	Address          *string  `json:"address,omitempty"` // a loopback address, 127.0.0.1:8081 by default
+	ProjectId        *int     `json:"projectId,omitempty"`
+	Hosts            []string `json:"hosts,omitempty"`
+	ExcludeHosts     []string `json:"excludeHosts,omitempty"`
+	IgnoreExtensions []string `json:"ignoreExtensions,omitempty"` // defaults to DefaultIgnoredExtensions
+}
+
+// Records reports whether a request to host for urlPath passes the filters
+func (r *Recorder) Records(host string, urlPath string) bool {
+	host = strings.ToLower(host)
+	for _, pattern := range r.ExcludeHosts {
+		if HostMatches(host, pattern) {
+			return false
+		}
+	}
+	if len(r.Hosts) > 0 {
+		included := false
+		for _, pattern := range r.Hosts {
+			if HostMatches(host, pattern) {
+				included = true
+				break
+			}
+		}
+		if !included {
+			return false
+		}
+	}
+	ext := strings.TrimPrefix(strings.ToLower(path.Ext(urlPath)), ".")
+	for _, ignored := range r.IgnoreExtensions {
+		if ext != "" && ext == strings.TrimPrefix(strings.ToLower(ignored), ".") {
+			return false
+		}
+	}
+	return true
+}
+
+// HostMatches reports whether host is pattern, or a subdomain of it when the
+// pattern starts with *.
+func HostMatches(host string, pattern string) bool {
+	host, pattern = strings.ToLower(host), strings.ToLower(strings.TrimSpace(pattern))
+	if suffix, ok := strings.CutPrefix(pattern, "*."); ok {
+		return host == suffix || strings.HasSuffix(host, "."+suffix)
+	}
+	return host == pattern
+}
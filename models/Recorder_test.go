@@ -0,0 +1,33 @@
+package models
+
+import "testing"
+
+func TestRecorder_Records(t *testing.T) {
+	recorder := &Recorder{
+		Hosts:            []string{"*.example.com", "api.test"},
+		ExcludeHosts:     []string{"cdn.example.com"},
+		IgnoreExtensions: DefaultIgnoredExtensions,
+	}
+	tests := []struct {
+		host string
+		path string
+		want bool
+	}{
+		{"example.com", "/", true},
+		{"shop.EXAMPLE.com", "/cart", true},
+		{"api.test", "/v1/items.json", true},
+		{"cdn.example.com", "/data", false},
+		{"other.test", "/", false},
+		{"notexample.com", "/", false},
+		{"shop.example.com", "/assets/app.CSS", false},
+		{"shop.example.com", "/js/", true},
+	}
+	for _, tt := range tests {
+		if got := recorder.Records(tt.host, tt.path); got != tt.want {
+			t.Errorf("Records(%q, %q) = %v, want %v", tt.host, tt.path, got, tt.want)
+		}
+	}
+	if !(&Recorder{}).Records("anything.test", "/logo.png") {
+		t.Error("a recorder without filters should record everything")
+	}
+}
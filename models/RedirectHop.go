@@ -0,0 +1,22 @@
+package models
+
+import "encoding/json"
+
+// RedirectHop is one redirect response a MyRequest went through before its
+// final response
+type RedirectHop struct {
+	Id          int    `gorm:"primaryKey"`
+	MyRequestId int    `gorm:"not null;index"`
+	Position    int    `gorm:"not null"` // 1 for the first redirect
+	Url         string `gorm:"not null"` // the URL that answered with the redirect
+	Status      int    `gorm:"not null"`
+	Location    string `gorm:"type:text"`
+	SetCookies  string `gorm:"type:text"` // JSON array of the Set-Cookie header values
+}
+
+// SetCookieValues returns the Set-Cookie header values of the redirect
+func (h *RedirectHop) SetCookieValues() []string {
+	values := []string{}
+	json.Unmarshal([]byte(h.SetCookies), &values)
+	return values
+}
@@ -0,0 +1,98 @@
+package models
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/linn221/bane/mystructs"
+	"github.com/linn221/bane/utils"
+)
+
+// RenderedRequest is an Endpoint with every placeholder resolved,
+// ready to be sent or printed as a command
+type RenderedRequest struct {
+	Method  string
+	Url     string
+	Headers []mystructs.KVPair
+	Body    string
+}
+
+// Render resolves the endpoint's placeholders using vars, falling back to the
+// defaults stored in the endpoint for anything vars does not mention
+func (e *Endpoint) Render(vars map[string]string) *RenderedRequest {
+	schema := "http"
+	if e.Https {
+		schema = "https"
+	}
+	requestUrl := schema + "://" + e.Domain + e.Path.ExecWith(vars)
+	if len(e.Queries.VarKVs) > 0 {
+		// in the endpoint's order, which url.Values would sort
+		queries := make([]string, 0, len(e.Queries.VarKVs))
+		for _, kv := range e.Queries.VarKVs {
+			queries = append(queries, utils.EscapeQuery(kv.Key.ExecWith(vars))+"="+utils.EscapeQuery(kv.Value.ExecWith(vars)))
+		}
+		requestUrl += "?" + strings.Join(queries, "&")
+	}
+
+	headers := make([]mystructs.KVPair, 0, len(e.Headers.VarKVs))
+	for _, kv := range e.Headers.VarKVs {
+		headers = append(headers, mystructs.KVPair{
+			Key:   kv.Key.ExecWith(vars),
+			Value: kv.Value.ExecWith(vars),
+		})
+	}
+
+	return &RenderedRequest{
+		Method:  string(e.Method),
+		Url:     requestUrl,
+		Headers: headers,
+		Body:    e.Body.ExecWith(vars),
+	}
+}
+
+// Rendered returns the request exactly as it was recorded. Header order is
+// not stored, so headers come back sorted by name.
+func (r *MyRequest) Rendered() *RenderedRequest {
+	var headerMap map[string]string
+	json.Unmarshal([]byte(r.RequestHeaders), &headerMap)
+	headers := make([]mystructs.KVPair, 0, len(headerMap))
+	for k, v := range headerMap {
+		headers = append(headers, mystructs.KVPair{Key: k, Value: v})
+	}
+	sort.Slice(headers, func(i, j int) bool { return headers[i].Key < headers[j].Key })
+
+	return &RenderedRequest{
+		Method:  r.RequestMethod,
+		Url:     r.RequestUrl,
+		Headers: headers,
+		Body:    r.RequestBody,
+	}
+}
+
+// Header returns the first header value matching name, case-insensitively
+func (r *RenderedRequest) Header(name string) string {
+	for _, h := range r.Headers {
+		if strings.EqualFold(h.Key, name) {
+			return h.Value
+		}
+	}
+	return ""
+}
+
+// Curl returns a copy/paste friendly curl command for the request
+func (r *RenderedRequest) Curl() string {
+	parts := []string{"curl", "-X", r.Method, shellQuote(r.Url)}
+	for _, h := range r.Headers {
+		parts = append(parts, "-H", shellQuote(h.Key+": "+h.Value))
+	}
+	if r.Body != "" {
+		parts = append(parts, "--data-raw", shellQuote(r.Body))
+	}
+	return strings.Join(parts, " ")
+}
+
+// shellQuote wraps s in single quotes so a POSIX shell passes it through verbatim
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/linn221/bane/mystructs"
+)
+
+func TestEndpoint_RenderEscapesQuery(t *testing.T) {
+	varString := func(s string) mystructs.VarString {
+		t.Helper()
+		vs, err := mystructs.NewVarString(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return *vs
+	}
+	endpoint := &Endpoint{
+		Https:  true,
+		Method: HttpMethodGet,
+		Domain: "example.com",
+		Path:   varString("/search"),
+		Queries: mystructs.VarKVGroup{VarKVs: []mystructs.VarKV{
+			{Key: varString("q"), Value: varString("{q=x}")},
+			{Key: varString("a b"), Value: varString("caf%C3%A9")},
+			{Key: varString("rate"), Value: varString("100%")},
+		}},
+	}
+	tests := []struct {
+		q    string
+		want string
+	}{
+		{"x", "https://example.com/search?q=x&a%20b=caf%C3%A9&rate=100%"},
+		{"1' OR 1=1 #", "https://example.com/search?q=1'%20OR%201%3D1%20%23&a%20b=caf%C3%A9&rate=100%"},
+		{"a&admin=true", "https://example.com/search?q=a%26admin%3Dtrue&a%20b=caf%C3%A9&rate=100%"},
+		{"%2e%2e%2f", "https://example.com/search?q=%2e%2e%2f&a%20b=caf%C3%A9&rate=100%"},
+		{"a+b", "https://example.com/search?q=a+b&a%20b=caf%C3%A9&rate=100%"},
+		{"é\x01", "https://example.com/search?q=%C3%A9%01&a%20b=caf%C3%A9&rate=100%"},
+	}
+	for _, tt := range tests {
+		if got := endpoint.Render(map[string]string{"q": tt.q}).Url; got != tt.want {
+			t.Errorf("q=%q: url = %q, want %q", tt.q, got, tt.want)
+		}
+	}
+}
@@ -1,16 +1,46 @@
 package models
 
 import (
+	"net/http"
 	"time"
 
 	"github.com/linn221/bane/mystructs"
+	"github.com/linn221/bane/utils"
 )
 
+type JobKind string
+
+const (
+	JobKindFuzz     JobKind = "fuzz"
+	JobKindAttack   JobKind = "attack"
+	JobKindSequence JobKind = "sequence"
+)
+
+type JobStatus string
+
+const (
+	JobStatusRunning   JobStatus = "running"
+	JobStatusCompleted JobStatus = "completed"
+	JobStatusCancelled JobStatus = "cancelled"
+	JobStatusFailed    JobStatus = "failed"
+)
+
+// Job groups the requests sent by one batch run, such as a fuzz
 type Job struct {
-	Id          int       `gorm:"primaryKey"`
-	Name        string    `gorm:"size:255;not null"`
-	Description string    `gorm:"default:null"`
-	JobDate     time.Time `gorm:"not null"`
+	Id          int        `gorm:"primaryKey"`
+	Name        string     `gorm:"size:255;not null"`
+	Description string     `gorm:"default:null"`
+	JobDate     time.Time  `gorm:"not null"`
+	Kind        JobKind    `gorm:"size:20;default:null;index"`
+	Status      JobStatus  `gorm:"size:20;default:null;index"`
+	EndpointId  *int       `gorm:"default:null;index"`
+	SequenceId  *int       `gorm:"default:null;index"`
+	Total       int        `gorm:"default:0"` // requests planned
+	Done        int        `gorm:"default:0"` // requests sent, including failed ones
+	Failed      int        `gorm:"default:0"` // requests that got no response
+	Error       string     `gorm:"type:text"`
+	StartedAt   *time.Time `gorm:"default:null"`
+	FinishedAt  *time.Time `gorm:"default:null"`
 }
 
 type Request struct {
@@ -20,6 +50,7 @@ type Request struct {
 	JobId               int               `gorm:"not null;index"`
 	Job                 Job               `gorm:"foreignKey:JobId"`
 	SequenceNumber      int               `gorm:"not null"`
+	MyRequestId         *int              `gorm:"default:null;index"` // the step as stored in the request history, unless it was never sent
 	Description         string            `gorm:"default:null"`
 	HttpSchema          HttpSchema        `gorm:"size:10;not null"`
 	HttpMethod          HttpMethod        `gorm:"size:10;not null"`
@@ -33,9 +64,27 @@ type Request struct {
 	ResponseContentType string            `gorm:"not null"`
 	ResponseLatency     time.Duration     `gorm:"not null"`
 	ResponseSize        int               `gorm:"not null"`
-	ResponseBody        string            `gorm:"not null"`
+	ResponseBody        string            `gorm:"-"`                          // kept in a ResponseBlob
+	ResponseBodyHash    string            `gorm:"size:64;index;default:null"` // of the ResponseBlob, empty for an empty body
 	ResponseHeaders     mystructs.KVGroup `gorm:"not null"`
 	ResponseCookies     mystructs.KVGroup `gorm:"not null"`
+	Variables           string            `gorm:"type:text"` // JSON string of the variables the step was rendered with
+	Error               string            `gorm:"type:text"` // transport or mapping error that stopped the sequence
+	ExecutedAt          time.Time         `gorm:"autoCreateTime"`
+}
+
+// Response returns the recorded response in the form values are extracted from
+func (r *Request) Response() utils.Response {
+	headers := http.Header{}
+	for _, kv := range r.ResponseHeaders.KVPairs {
+		headers.Add(kv.Key, kv.Value)
+	}
+	return utils.Response{Status: r.ResponseStatusCode, Headers: headers, Body: r.ResponseBody}
+}
+
+type JobFilter struct {
+	Kind   JobKind   `json:"kind,omitempty"`
+	Status JobStatus `json:"status,omitempty"`
 }
 
 type NewJob struct {
@@ -0,0 +1,387 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RequestQuery is a parsed filter expression for recorded requests, such as
+//
+//	status:5xx host:*.example.com method:POST len>10000 body~"stack trace" -ctype:image
+//
+// Terms are separated by spaces and must all hold; a - in front of one
+// negates it. Each is a key, an operator and a value, quoted when it has
+// spaces. : matches the value, or any of several separated by commas, ~
+// looks for it as text and >, >=, < and <= compare. A term without a key
+// looks for text in the URL.
+type RequestQuery struct {
+	conditions      []requestCondition
+	unindexed       []requestCondition // conditions, for when there is no RequestSearchTable
+	UsesSearchIndex bool               // body terms are matched with the RequestSearchTable
+}
+
+type requestCondition struct {
+	sql  string
+	args []any
+}
+
+type fieldKind int
+
+const (
+	numberField fieldKind = iota // : with commas, >, >=, <, <=
+	statusField                  // a numberField that also takes 5xx
+	wordField                    // : with commas, exact and case-insensitive
+	globField                    // : with commas and * wildcards, ~
+	mimeField                    // : a type such as image, or a full type, ~
+	textField                    // : and ~ both look for text
+	searchField                  // : and ~ match a phrase in the RequestSearchTable, or in the ResponseBlob
+	boolField                    // : true or false
+	dateField                    // : a day, >, >=, <, <= a date or time
+)
+
+type requestField struct {
+	column string
+	kind   fieldKind
+}
+
+// requestFields are the keys of a RequestQuery
+var requestFields = map[string]requestField{
+	"id":        {"id", numberField},
+	"status":    {"response_status", statusField},
+	"method":    {"request_method", wordField},
+	"host":      {"request_host", globField},
+	"url":       {"request_url", globField},
+	"ctype":     {"content_type", mimeField},
+	"len":       {"size", numberField},
+	"size":      {"size", numberField},
+	"latency":   {"latency", numberField},
+	"ttfb":      {"ttfb", numberField},
+	"body":      {"response_body", searchField},
+	"header":    {"response_headers", textField},
+	"reqbody":   {"request_body", textField},
+	"reqheader": {"request_headers", textField},
+	"error":     {"error", textField},
+	"success":   {"success", boolField},
+	"truncated": {"response_truncated", boolField},
+	"job":       {"job_id", numberField},
+	"endpoint":  {"endpoint_id", numberField},
+	"date":      {"executed_at", dateField},
+}
+
+// requestSortColumns are the keys results can be sorted by
+var requestSortColumns = map[string]string{
+	"id":      "id",
+	"status":  "response_status",
+	"method":  "request_method",
+	"host":    "request_host",
+	"url":     "request_url",
+	"ctype":   "content_type",
+	"len":     "size",
+	"size":    "size",
+	"latency": "latency",
+	"ttfb":    "ttfb",
+	"date":    "executed_at",
+}
+
+// RequestSortOrder turns a sort key, with - in front for descending order,
+// into an ORDER BY clause. Requests are newest first without one.
+func RequestSortOrder(sort string) (string, error) {
+	if sort == "" {
+		return "executed_at DESC, id DESC", nil
+	}
+	key, direction := sort, "ASC"
+	if rest, ok := strings.CutPrefix(sort, "-"); ok {
+		key, direction = rest, "DESC"
+	}
+	column, ok := requestSortColumns[key]
+	if !ok {
+		return "", fmt.Errorf("cannot sort by %q", key)
+	}
+	return column + " " + direction + ", id " + direction, nil
+}
+
+// Apply adds the conditions of q to db. Without the RequestSearchTable, body
+// terms decompress and scan every response instead, which is much slower.
+func (q *RequestQuery) Apply(db *gorm.DB) *gorm.DB {
+	conditions := q.conditions
+	if q.UsesSearchIndex && !RequestSearchReady(db.Session(&gorm.Session{NewDB: true})) {
+		conditions = q.unindexed
+	}
+	for _, c := range conditions {
+		db = db.Where(c.sql, c.args...)
+	}
+	return db
+}
+
+// ParseRequestQuery parses a filter expression into parameterized
+// conditions
+func ParseRequestQuery(query string) (*RequestQuery, error) {
+	q := &RequestQuery{}
+	rest := strings.TrimSpace(query)
+	for rest != "" {
+		var term string
+		var err error
+		term, rest, err = nextTerm(rest)
+		if err != nil {
+			return nil, err
+		}
+		if err := q.add(term); err != nil {
+			return nil, err
+		}
+		rest = strings.TrimSpace(rest)
+	}
+	return q, nil
+}
+
+// nextTerm cuts the first term off query, keeping quoted spaces
+func nextTerm(query string) (string, string, error) {
+	quoted := false
+	for i := 0; i < len(query); i++ {
+		switch c := query[i]; {
+		case c == '\\' && quoted:
+			i++
+		case c == '"':
+			quoted = !quoted
+		case c == ' ' || c == '\t' || c == '\n':
+			if !quoted {
+				return query[:i], query[i:], nil
+			}
+		}
+	}
+	if quoted {
+		return "", "", fmt.Errorf("unterminated quote in %q", query)
+	}
+	return query, "", nil
+}
+
+func (q *RequestQuery) add(term string) error {
+	body, negate := strings.CutPrefix(term, "-")
+	key, op, raw := splitTerm(body)
+	field, ok := requestFields[key]
+	if op == "" || !ok && !isKey(key) {
+		// no key, or a colon in a URL such as http://example.com
+		field, op, raw = requestFields["url"], "~", body
+	} else if !ok {
+		return fmt.Errorf("unknown key %q in %q", key, term)
+	}
+	value, err := unquote(raw)
+	if err != nil {
+		return fmt.Errorf("%w in %q", err, term)
+	}
+	c, err := field.condition(op, value)
+	if err != nil {
+		return fmt.Errorf("%s: %w", term, err)
+	}
+	unindexed := c
+	if field.kind == searchField {
+		q.UsesSearchIndex = true
+		// the column's text is kept in the ResponseBlob its _hash names
+		unindexed = requestCondition{"bane_body_contains(COALESCE((SELECT data FROM response_blobs WHERE hash = " +
+			field.column + "_hash), X''), ?)", []any{value}}
+	}
+	if negate {
+		// NULL columns satisfy negated terms
+		c.sql = "NOT COALESCE((" + c.sql + "), FALSE)"
+		unindexed.sql = "NOT COALESCE((" + unindexed.sql + "), FALSE)"
+	}
+	q.conditions = append(q.conditions, c)
+	q.unindexed = append(q.unindexed, unindexed)
+	return nil
+}
+
+// splitTerm splits key>=value into its key, operator and value. The
+// operator is empty when the term has none.
+func splitTerm(term string) (string, string, string) {
+	i := strings.IndexAny(term, ":~<>")
+	if i < 0 {
+		return "", "", term
+	}
+	op := term[i : i+1]
+	if (op == "<" || op == ">") && strings.HasPrefix(term[i+1:], "=") {
+		op += "="
+	}
+	return strings.ToLower(term[:i]), op, term[i+len(op):]
+}
+
+func isKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for _, c := range key {
+		if c < 'a' || c > 'z' {
+			return false
+		}
+	}
+	return !strings.HasPrefix(key, "http")
+}
+
+func unquote(value string) (string, error) {
+	if !strings.HasPrefix(value, `"`) {
+		return value, nil
+	}
+	if len(value) < 2 || !strings.HasSuffix(value, `"`) {
+		return "", fmt.Errorf("unterminated quote")
+	}
+	var b strings.Builder
+	inner := value[1 : len(value)-1]
+	for i := 0; i < len(inner); i++ {
+		if inner[i] == '\\' && i+1 < len(inner) {
+			i++
+		}
+		b.WriteByte(inner[i])
+	}
+	return b.String(), nil
+}
+
+func (f requestField) condition(op, value string) (requestCondition, error) {
+	if value == "" {
+		return requestCondition{}, fmt.Errorf("missing value")
+	}
+	text := "COALESCE(" + f.column + ", '')"
+	switch f.kind {
+	case numberField, statusField:
+		if op == ":" {
+			return anyOf(value, func(v string) (requestCondition, error) {
+				if prefix, ok := strings.CutSuffix(strings.ToLower(v), "xx"); ok && f.kind == statusField && len(prefix) == 1 {
+					class, err := strconv.Atoi(prefix)
+					if err != nil {
+						return requestCondition{}, fmt.Errorf("invalid status class %q", v)
+					}
+					return requestCondition{f.column + " BETWEEN ? AND ?", []any{class * 100, class*100 + 99}}, nil
+				}
+				n, err := strconv.ParseInt(v, 10, 64)
+				if err != nil {
+					return requestCondition{}, fmt.Errorf("invalid number %q", v)
+				}
+				return requestCondition{f.column + " = ?", []any{n}}, nil
+			})
+		}
+		if op == "~" {
+			break
+		}
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return requestCondition{}, fmt.Errorf("invalid number %q", value)
+		}
+		return requestCondition{f.column + " " + op + " ?", []any{n}}, nil
+	case wordField:
+		if op != ":" {
+			break
+		}
+		return anyOf(value, func(v string) (requestCondition, error) {
+			return requestCondition{"UPPER(" + text + ") = ?", []any{strings.ToUpper(v)}}, nil
+		})
+	case globField:
+		if op == "~" {
+			return contains(text, value), nil
+		}
+		if op != ":" {
+			break
+		}
+		return anyOf(value, func(v string) (requestCondition, error) {
+			if f.column == "request_host" {
+				v = strings.ToLower(v)
+			}
+			return requestCondition{text + " LIKE ? ESCAPE '!'", []any{likeGlob(v)}}, nil
+		})
+	case mimeField:
+		if op == "~" {
+			return contains(text, value), nil
+		}
+		if op != ":" {
+			break
+		}
+		return anyOf(value, func(v string) (requestCondition, error) {
+			pattern := likeGlob(v)
+			if !strings.Contains(v, "/") {
+				pattern += "/"
+			}
+			// parameters such as ; charset=utf-8 follow the type
+			return requestCondition{text + " LIKE ? ESCAPE '!'", []any{pattern + "%"}}, nil
+		})
+	case textField:
+		if op == ":" || op == "~" {
+			return contains(text, value), nil
+		}
+	case searchField:
+		if op == ":" || op == "~" {
+			phrase := `"` + strings.ReplaceAll(value, `"`, `""`) + `"`
+			return requestCondition{"id IN (SELECT rowid FROM " + RequestSearchTable + " WHERE " + RequestSearchTable + " MATCH ?)",
+				[]any{f.column + " : " + phrase}}, nil
+		}
+	case boolField:
+		if op != ":" {
+			break
+		}
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return requestCondition{}, fmt.Errorf("want true or false, got %q", value)
+		}
+		return requestCondition{f.column + " = ?", []any{b}}, nil
+	case dateField:
+		if op == "~" {
+			break
+		}
+		at, day, err := parseQueryTime(value)
+		if err != nil {
+			return requestCondition{}, err
+		}
+		if op == ":" {
+			if !day {
+				return requestCondition{}, fmt.Errorf("date: takes a day such as 2006-01-02")
+			}
+			return requestCondition{f.column + " >= ? AND " + f.column + " < ?", []any{at, at.AddDate(0, 0, 1)}}, nil
+		}
+		if day && (op == ">" || op == "<=") {
+			// after a day is after its end
+			at, op = at.AddDate(0, 0, 1), map[string]string{">": ">=", "<=": "<"}[op]
+		}
+		return requestCondition{f.column + " " + op + " ?", []any{at}}, nil
+	}
+	return requestCondition{}, fmt.Errorf("%s is not supported here", op)
+}
+
+// anyOf matches any of the comma-separated values
+func anyOf(value string, match func(string) (requestCondition, error)) (requestCondition, error) {
+	var sql []string
+	var args []any
+	for _, v := range strings.Split(value, ",") {
+		c, err := match(strings.TrimSpace(v))
+		if err != nil {
+			return requestCondition{}, err
+		}
+		sql = append(sql, c.sql)
+		args = append(args, c.args...)
+	}
+	return requestCondition{"(" + strings.Join(sql, " OR ") + ")", args}, nil
+}
+
+func contains(column, value string) requestCondition {
+	return requestCondition{column + " LIKE ? ESCAPE '!'", []any{"%" + escapeLike(value) + "%"}}
+}
+
+// likeGlob turns a pattern with * wildcards into a LIKE pattern
+func likeGlob(pattern string) string {
+	return strings.ReplaceAll(escapeLike(pattern), "*", "%")
+}
+
+func escapeLike(value string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(value)
+}
+
+// parseQueryTime parses a day or a time, reporting whether it was a day
+func parseQueryTime(value string) (time.Time, bool, error) {
+	if at, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return at, true, nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02T15:04"} {
+		if at, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return at, false, nil
+		}
+	}
+	return time.Time{}, false, fmt.Errorf("invalid date %q, want 2006-01-02 or 2006-01-02T15:04:05", value)
+}
@@ -0,0 +1,127 @@
+package models
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestRequestQuery_Apply(t *testing.T) {
+	db, err := gorm.Open(sqlite.New(sqlite.Config{DriverName: SQLiteDriver, DSN: filepath.Join(t.TempDir(), "test.db")}), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&MyRequest{}, &RedirectHop{}, &TlsCertificate{}, &ResponseBlob{}); err != nil {
+		t.Fatal(err)
+	}
+	day := time.Date(2025, 3, 10, 12, 0, 0, 0, time.Local)
+	job := 7
+	for _, r := range []MyRequest{
+		{RequestMethod: "GET", RequestUrl: "https://api.example.com/users?id=1", ResponseStatus: 200, ContentType: "application/json; charset=utf-8", Size: 120, Latency: 40},
+		{RequestMethod: "POST", RequestUrl: "https://www.example.com/login", ResponseStatus: 500, ContentType: "text/html", Size: 20000, Latency: 900, JobId: &job, ResponseBody: "<pre>A Stack Trace follows</pre>"},
+		{RequestMethod: "post", RequestUrl: "https://example.com/upload", ResponseStatus: 503, ContentType: "image/png", Size: 50000, Latency: 300, JobId: &job},
+		{RequestMethod: "GET", RequestUrl: "http://evil.com/?next=.example.com/", ResponseStatus: 404, Size: 10, Latency: 5, Error: "timeout", RequestBody: `{"a":"50%_off"}`},
+	} {
+		r.ExecutedAt = day
+		day = day.AddDate(0, 0, 1)
+		if err := db.Create(&r).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"", "1 2 3 4"},
+		{"status:5xx", "2 3"},
+		{"status:200,404", "1 4"},
+		{"status>=500 status<503", "2"},
+		{"host:*.example.com", "1 2"},
+		{"host:example.com,API.example.com", "1 3"},
+		{"-host:*example.com", "4"},
+		{"method:POST", "2 3"},
+		{"method:post len>10000 -ctype:image", "2"},
+		{"ctype:application/json", "1"},
+		{"ctype~json", "1"},
+		{"-ctype:image", "1 2 4"},
+		{"url:https://*/log*", "2"},
+		{"users", "1"},
+		{`reqbody~"50%_"`, "4"},
+		{"reqbody~0%o", ""},
+		{"error:time -job:7", "4"},
+		// without the search index, as a plain go build has no FTS5
+		{`body~"stack trace"`, "2"},
+		{"-body:TRACE", "1 3 4"},
+		{"job:7 latency<=300", "3"},
+		{"success:false id:1,2", "1 2"},
+		{"date:2025-03-11", "2"},
+		{"date>2025-03-11", "3 4"},
+		{"date<=2025-03-11", "1 2"},
+		{"date>=2025-03-11T00:00 date<2025-03-12T13:00:00", "2 3"},
+	}
+	for _, tt := range tests {
+		q, err := ParseRequestQuery(tt.query)
+		if err != nil {
+			t.Errorf("%q: %v", tt.query, err)
+			continue
+		}
+		var ids []string
+		var requests []MyRequest
+		if err := q.Apply(db).Order("id").Find(&requests).Error; err != nil {
+			t.Errorf("%q: %v", tt.query, err)
+			continue
+		}
+		for _, r := range requests {
+			ids = append(ids, fmt.Sprint(r.Id))
+		}
+		if got := strings.Join(ids, " "); got != tt.want {
+			t.Errorf("%q matched %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestParseRequestQuery_Errors(t *testing.T) {
+	for _, query := range []string{
+		"colour:red",
+		"status:abc",
+		"status~500",
+		"method>GET",
+		`body~"stack trace`,
+		"success:maybe",
+		"date:yesterday",
+		"date:2025-03-11T10:00",
+		"len>",
+	} {
+		if _, err := ParseRequestQuery(query); err == nil {
+			t.Errorf("expected %q to be rejected", query)
+		}
+	}
+	q, err := ParseRequestQuery(`body~"stack trace"`)
+	if err != nil || !q.UsesSearchIndex {
+		t.Errorf("body terms should use the search index: %v", err)
+	}
+}
+
+func TestRequestSortOrder(t *testing.T) {
+	for sort, want := range map[string]string{
+		"":        "executed_at DESC, id DESC",
+		"len":     "size ASC, id ASC",
+		"-status": "response_status DESC, id DESC",
+	} {
+		if got, err := RequestSortOrder(sort); err != nil || got != want {
+			t.Errorf("RequestSortOrder(%q) = %q, %v, want %q", sort, got, err, want)
+		}
+	}
+	if _, err := RequestSortOrder("-password"); err == nil {
+		t.Error("expected an unknown sort key to be rejected")
+	}
+}
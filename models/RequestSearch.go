@@ -0,0 +1,203 @@
+package models
+
+import (
+	"database/sql"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/mattn/go-sqlite3"
+	"gorm.io/gorm"
+)
+
+// RequestSearchTable is the SQLite FTS5 index over each request's URL,
+// headers and bodies. It keeps no copy of the text, which stays with the
+// request and its ResponseBlob; snippets are cut from the request itself.
+const RequestSearchTable = "my_request_search"
+
+// HasFts5 reports whether db is SQLite built with FTS5, which bane is when
+// built with -tags sqlite_fts5
+func HasFts5(db *gorm.DB) bool {
+	if db.Dialector.Name() != "sqlite" {
+		return false
+	}
+	var fts5 bool
+	err := db.Raw("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&fts5).Error
+	return err == nil && fts5
+}
+
+// RequestSearchReady reports whether the RequestSearchTable can be queried
+func RequestSearchReady(db *gorm.DB) bool {
+	return HasFts5(db) && db.Migrator().HasTable(RequestSearchTable)
+}
+
+// SQLiteDriver is the database/sql driver bane opens SQLite with: go-sqlite3
+// with a bane_body_contains function, which body terms fall back on when
+// there is no RequestSearchTable
+const SQLiteDriver = "sqlite3_bane"
+
+func init() {
+	sql.Register(SQLiteDriver, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			return conn.RegisterFunc("bane_body_contains", bodyContains, true)
+		},
+	})
+}
+
+// bodyContains reports whether the body in a ResponseBlob's data contains
+// phrase, ignoring case
+func bodyContains(data []byte, phrase string) bool {
+	body, err := (&ResponseBlob{Data: data}).Body()
+	return err == nil && strings.Contains(strings.ToLower(body), strings.ToLower(phrase))
+}
+
+// IndexRequest adds r to the RequestSearchTable
+func IndexRequest(db *gorm.DB, r *MyRequest) error {
+	return db.Exec("INSERT INTO "+RequestSearchTable+" (rowid, request_url, request_headers, request_body, response_headers, response_body) VALUES (?, ?, ?, ?, ?, ?)",
+		r.Id, r.RequestUrl, r.RequestHeaders, r.RequestBody, r.ResponseHeaders, r.ResponseBody).Error
+}
+
+// RequestSearchHit is a request that matched a search
+type RequestSearchHit struct {
+	Request  *MyRequest
+	Rank     float64 // bm25, lower is a better match
+	Snippets []SearchSnippet
+}
+
+// SearchSnippet is the part of a field around its first match
+type SearchSnippet struct {
+	Field string // requestUrl, requestHeaders, requestBody, responseHeaders or responseBody
+	Text  string // matches are wrapped in <mark></mark>
+}
+
+const (
+	snippetBefore = 40  // bytes kept before the first match
+	snippetLength = 160 // bytes in a snippet at most
+)
+
+// SearchSnippets cuts a snippet from each of r's fields that has a term of
+// query, an FTS5 expression
+func (r *MyRequest) SearchSnippets(query string) []SearchSnippet {
+	terms := searchTerms(query)
+	var snippets []SearchSnippet
+	for _, field := range []struct{ name, text string }{
+		{"requestUrl", r.RequestUrl},
+		{"requestHeaders", r.RequestHeaders},
+		{"requestBody", r.RequestBody},
+		{"responseHeaders", r.ResponseHeaders},
+		{"responseBody", r.ResponseBody},
+	} {
+		if text, ok := snippet(field.text, terms); ok {
+			snippets = append(snippets, SearchSnippet{Field: field.name, Text: text})
+		}
+	}
+	return snippets
+}
+
+type searchTerm struct {
+	token  string
+	prefix bool // written as token*
+}
+
+// columnFilter matches the column filters of an FTS5 query, such as
+// response_body: and {request_body response_body}:
+var columnFilter = regexp.MustCompile(`(?:\{[^}]*\}|[A-Za-z_][A-Za-z0-9_]*)\s*:`)
+
+// searchTerms splits an FTS5 query into the tokens it matches, the way the
+// unicode61 tokenizer splits text, leaving out operators and column filters
+func searchTerms(query string) []searchTerm {
+	var terms []searchTerm
+	quoted := false
+	for _, part := range strings.Split(query, `"`) {
+		if !quoted {
+			part = columnFilter.ReplaceAllString(part, " ")
+		}
+		eachToken(part, func(start, end int) bool {
+			token := part[start:end]
+			if !quoted && (token == "AND" || token == "OR" || token == "NOT" || token == "NEAR") {
+				return true
+			}
+			terms = append(terms, searchTerm{
+				token:  strings.ToLower(token),
+				prefix: strings.HasPrefix(strings.TrimLeft(part[end:], " "), "*"),
+			})
+			return true
+		})
+		quoted = !quoted
+	}
+	return terms
+}
+
+// snippet cuts text around its first token matching terms and marks the
+// matches in the cut
+func snippet(text string, terms []searchTerm) (string, bool) {
+	matches := func(token string) bool {
+		token = strings.ToLower(token)
+		for _, term := range terms {
+			if token == term.token || term.prefix && strings.HasPrefix(token, term.token) {
+				return true
+			}
+		}
+		return false
+	}
+	first := -1
+	eachToken(text, func(start, end int) bool {
+		if matches(text[start:end]) {
+			first = start
+			return false
+		}
+		return true
+	})
+	if first < 0 {
+		return "", false
+	}
+
+	start := max(first-snippetBefore, 0)
+	for start > 0 && !utf8.RuneStart(text[start]) {
+		start--
+	}
+	end := min(start+snippetLength, len(text))
+	for end < len(text) && !utf8.RuneStart(text[end]) {
+		end++
+	}
+	cut := text[start:end]
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	last := 0
+	eachToken(cut, func(s, e int) bool {
+		// a token cut off at either end of the snippet is not marked
+		if (s > 0 || start == 0) && (e < len(cut) || end == len(text)) && matches(cut[s:e]) {
+			b.WriteString(cut[last:s] + "<mark>" + cut[s:e] + "</mark>")
+			last = e
+		}
+		return true
+	})
+	b.WriteString(cut[last:])
+	if end < len(text) {
+		b.WriteString("…")
+	}
+	return b.String(), true
+}
+
+// eachToken calls fn with the bounds of each run of letters and digits in
+// text until fn returns false
+func eachToken(text string, fn func(start, end int) bool) {
+	start := -1
+	for i, r := range text {
+		word := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if word && start < 0 {
+			start = i
+		} else if !word && start >= 0 {
+			if !fn(start, i) {
+				return
+			}
+			start = -1
+		}
+	}
+	if start >= 0 {
+		fn(start, len(text))
+	}
+}
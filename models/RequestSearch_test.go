@@ -0,0 +1,45 @@
+package models
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSearchTerms(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []searchTerm
+	}{
+		{"aws_secret", []searchTerm{{"aws", false}, {"secret", false}}},
+		{`"API Key" OR token*`, []searchTerm{{"api", false}, {"key", false}, {"token", true}}},
+		{"response_body:password NOT {request_url request_body}: admin", []searchTerm{{"password", false}, {"admin", false}}},
+		{`"this AND that"`, []searchTerm{{"this", false}, {"and", false}, {"that", false}}},
+	}
+	for _, tt := range tests {
+		if got := searchTerms(tt.query); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("searchTerms(%q) = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestMyRequest_SearchSnippets(t *testing.T) {
+	r := &MyRequest{
+		RequestUrl:   "https://example.com/config",
+		ResponseBody: strings.Repeat("x ", 40) + `{"AWS_SECRET":"abc","tokens":["t1"]}` + strings.Repeat(" y", 100),
+	}
+	snippets := r.SearchSnippets("aws_secret OR token*")
+	if len(snippets) != 1 || snippets[0].Field != "responseBody" {
+		t.Fatalf("snippets = %+v", snippets)
+	}
+	text := snippets[0].Text
+	if !strings.HasPrefix(text, "…") || !strings.HasSuffix(text, "…") {
+		t.Errorf("snippet %q is not cut on both sides", text)
+	}
+	if !strings.Contains(text, `{"<mark>AWS</mark>_<mark>SECRET</mark>":"abc","<mark>tokens</mark>"`) {
+		t.Errorf("snippet %q does not mark the matches", text)
+	}
+	if len(r.SearchSnippets("nothing")) != 0 {
+		t.Error("expected no snippets without a match")
+	}
+}
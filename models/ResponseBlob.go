@@ -0,0 +1,150 @@
+package models
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"slices"
+	"time"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DefaultMaxStoredBodySize is how many bytes of a response body are stored
+// unless MAX_BODY_SIZE says otherwise
+const DefaultMaxStoredBodySize = 10 << 20
+
+// ResponseBlob is a response body stored once, however many requests got it.
+// It is keyed by the SHA-256 of the body and kept gzip-compressed.
+type ResponseBlob struct {
+	Hash      string `gorm:"primaryKey;size:64"`
+	Size      int64  `gorm:"not null"` // of the uncompressed body
+	Data      []byte `gorm:"not null"`
+	CreatedAt time.Time
+}
+
+// NewResponseBlob compresses body
+func NewResponseBlob(body string) (*ResponseBlob, error) {
+	sum := sha256.Sum256([]byte(body))
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := io.WriteString(zw, body); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return &ResponseBlob{Hash: hex.EncodeToString(sum[:]), Size: int64(len(body)), Data: buf.Bytes()}, nil
+}
+
+// Body decompresses the stored body
+func (b *ResponseBlob) Body() (string, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(b.Data))
+	if err != nil {
+		return "", err
+	}
+	defer zr.Close()
+	body, err := io.ReadAll(zr)
+	return string(body), err
+}
+
+// TruncateBody keeps at most max bytes of the response body, all of it when
+// max is 0, and marks the request ResponseTruncated when it drops any. The
+// cut never splits a UTF-8 sequence.
+func (r *MyRequest) TruncateBody(max int) {
+	if max <= 0 || len(r.ResponseBody) <= max {
+		return
+	}
+	cut := max
+	for cut > 0 && cut > max-utf8.UTFMax && !utf8.RuneStart(r.ResponseBody[cut]) {
+		cut--
+	}
+	if !utf8.RuneStart(r.ResponseBody[cut]) {
+		cut = max // not UTF-8 after all
+	}
+	r.ResponseBody = r.ResponseBody[:cut]
+	r.ResponseTruncated = true
+}
+
+// storeResponseBody stores the response body as a blob, once per distinct
+// body
+func (r *MyRequest) storeResponseBody(tx *gorm.DB) error {
+	if r.ResponseBody == "" {
+		return nil
+	}
+	hash, err := SaveResponseBlob(tx.Session(&gorm.Session{NewDB: true}), r.ResponseBody)
+	if err != nil {
+		return err
+	}
+	r.ResponseBodyHash = hash
+	return nil
+}
+
+// LoadResponseBodies fills in the response bodies of requests loaded from
+// the database, which leave them out, reading each distinct blob once
+func LoadResponseBodies(db *gorm.DB, requests ...*MyRequest) error {
+	var hashes []string
+	for _, r := range requests {
+		if r.ResponseBodyHash != "" && r.ResponseBody == "" {
+			hashes = append(hashes, r.ResponseBodyHash)
+		}
+	}
+	bodies, err := ResponseBodies(db, hashes)
+	if err != nil {
+		return err
+	}
+	for _, r := range requests {
+		if body, ok := bodies[r.ResponseBodyHash]; ok && r.ResponseBody == "" {
+			r.ResponseBody = body
+		}
+	}
+	return nil
+}
+
+// ResponseBodies returns the bodies of the blobs with the given hashes,
+// keyed by hash
+func ResponseBodies(db *gorm.DB, hashes []string) (map[string]string, error) {
+	hashes = slices.Compact(slices.Sorted(slices.Values(hashes)))
+	bodies := make(map[string]string, len(hashes))
+	for start := 0; start < len(hashes); start += 500 {
+		var blobs []ResponseBlob
+		if err := db.Where("hash IN ?", hashes[start:min(start+500, len(hashes))]).Find(&blobs).Error; err != nil {
+			return nil, err
+		}
+		for _, blob := range blobs {
+			body, err := blob.Body()
+			if err != nil {
+				return nil, fmt.Errorf("response blob %s: %w", blob.Hash, err)
+			}
+			bodies[blob.Hash] = body
+		}
+	}
+	return bodies, nil
+}
+
+// DeleteOrphanBlobs deletes the blobs no request refers to any more, and
+// returns how many it deleted
+func DeleteOrphanBlobs(db *gorm.DB) (int64, error) {
+	referenced := db.Model(&MyRequest{}).Select("response_body_hash").Where("response_body_hash IS NOT NULL")
+	steps := db.Model(&Request{}).Select("response_body_hash").Where("response_body_hash IS NOT NULL")
+	result := db.Where("hash NOT IN (?) AND hash NOT IN (?)", referenced, steps).Delete(&ResponseBlob{})
+	return result.RowsAffected, result.Error
+}
+
+// SaveResponseBlob stores body unless an identical one is stored already,
+// and returns its hash
+func SaveResponseBlob(db *gorm.DB, body string) (string, error) {
+	blob, err := NewResponseBlob(body)
+	if err != nil {
+		return "", err
+	}
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(blob).Error; err != nil {
+		return "", err
+	}
+	return blob.Hash, nil
+}
@@ -0,0 +1,104 @@
+package models
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestMyRequest_StoresBodiesAsBlobs(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&MyRequest{}, &RedirectHop{}, &TlsCertificate{}, &ResponseBlob{}, &Request{}); err != nil {
+		t.Fatal(err)
+	}
+
+	binary := "\x89PNG\r\n\x1a\n\x00\xff"
+	requests := []MyRequest{
+		{ResponseBody: "same body"},
+		{ResponseBody: "same body"},
+		{ResponseBody: strings.Repeat("a", 20)},
+		{ResponseBody: binary},
+		{},
+	}
+	for i := range requests {
+		requests[i].RequestMethod, requests[i].RequestUrl = "GET", "http://example.com/"
+		requests[i].TruncateBody(16)
+		if err := db.Create(&requests[i]).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var blobs int64
+	db.Model(&ResponseBlob{}).Count(&blobs)
+	if blobs != 3 {
+		t.Errorf("stored %d blobs for 3 distinct bodies", blobs)
+	}
+	var stored []*MyRequest
+	if err := db.Order("id").Find(&stored).Error; err != nil {
+		t.Fatal(err)
+	}
+	if stored[0].ResponseBody != "" {
+		t.Error("bodies should only be read when asked for")
+	}
+	if err := LoadResponseBodies(db, stored...); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"same body", "same body", strings.Repeat("a", 16), binary, ""}
+	for i, r := range stored {
+		if r.ResponseBody != want[i] {
+			t.Errorf("request %d body = %q, want %q", r.Id, r.ResponseBody, want[i])
+		}
+		if r.ResponseTruncated != (i == 2) {
+			t.Errorf("request %d truncated = %v", r.Id, r.ResponseTruncated)
+		}
+	}
+	if stored[0].ResponseBodyHash != stored[1].ResponseBodyHash || stored[4].ResponseBodyHash != "" {
+		t.Errorf("hashes = %q %q %q", stored[0].ResponseBodyHash, stored[1].ResponseBodyHash, stored[4].ResponseBodyHash)
+	}
+
+	// only the third and fourth bodies are left unreferenced
+	if err := db.Where("id IN ?", []int{stored[0].Id, stored[2].Id, stored[3].Id}).Delete(&MyRequest{}).Error; err != nil {
+		t.Fatal(err)
+	}
+	deleted, err := DeleteOrphanBlobs(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Model(&ResponseBlob{}).Count(&blobs)
+	if deleted != 2 || blobs != 1 {
+		t.Errorf("deleted %d orphan blobs, %d left", deleted, blobs)
+	}
+}
+
+func TestMyRequest_TruncateBody(t *testing.T) {
+	cases := []struct {
+		body      string
+		max       int
+		want      string
+		truncated bool
+	}{
+		{"short", 16, "short", false},
+		{"whole", 0, "whole", false},
+		{"abcdef", 4, "abcd", true},
+		{"naïve café", 3, "na", true}, // ï is two bytes
+		{"naïve café", 4, "naï", true},
+		{"日本語", 5, "日", true},
+		{"\x80\x80\x80\x80\x80\x80", 5, "\x80\x80\x80\x80\x80", true}, // not UTF-8
+	}
+	for _, c := range cases {
+		r := &MyRequest{ResponseBody: c.body}
+		r.TruncateBody(c.max)
+		if r.ResponseBody != c.want || r.ResponseTruncated != c.truncated {
+			t.Errorf("TruncateBody(%q, %d) = %q, %v; want %q, %v", c.body, c.max, r.ResponseBody, r.ResponseTruncated, c.want, c.truncated)
+		}
+	}
+}
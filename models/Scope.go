@@ -0,0 +1,233 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Kinds of scope rule
+const (
+	ScopeRuleDomain = "domain" // a host, or *.example.com for it and its subdomains
+	ScopeRuleCidr   = "cidr"   // an IP range such as 10.0.0.0/8, matched against the host's addresses
+	ScopeRulePrefix = "prefix" // a URL prefix such as https://example.com/api/
+	ScopeRuleRegex  = "regex"  // a regular expression matched against the whole URL
+)
+
+// ScopeRule is one in-scope or out-of-scope rule of a project
+type ScopeRule struct {
+	Kind  string `json:"kind"`
+	Value string `json:"value"`
+}
+
+func (r ScopeRule) String() string {
+	return r.Kind + ":" + r.Value
+}
+
+// ScopeRules is stored as a JSON array
+type ScopeRules []*ScopeRule
+
+// Value implements the driver.Valuer interface for GORM
+func (rules ScopeRules) Value() (driver.Value, error) {
+	if rules == nil {
+		rules = ScopeRules{}
+	}
+	b, err := json.Marshal(rules)
+	return string(b), err
+}
+
+// Scan implements the sql.Scanner interface for GORM
+func (rules *ScopeRules) Scan(value interface{}) error {
+	*rules = ScopeRules{}
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []byte:
+		return json.Unmarshal(v, rules)
+	case string:
+		if v == "" {
+			return nil
+		}
+		return json.Unmarshal([]byte(v), rules)
+	default:
+		return fmt.Errorf("cannot scan %T into ScopeRules", value)
+	}
+}
+
+// ScopeCheck is the verdict on one URL
+type ScopeCheck struct {
+	Url       string     `json:"url"`
+	ProjectId *int       `json:"projectId,omitempty"`
+	InScope   bool       `json:"inScope"`
+	Rule      *ScopeRule `json:"rule,omitempty"` // the rule that decided, if any
+	Reason    string     `json:"reason"`
+}
+
+// Scope decides whether URLs are in a project's scope. A URL matching an
+// out-of-scope rule is out of scope; otherwise it must match one of the
+// in-scope rules, if there are any. Without rules everything is in scope.
+type Scope struct {
+	in  []scopeMatcher
+	out []scopeMatcher
+}
+
+type scopeMatcher struct {
+	rule  ScopeRule
+	match func(u *url.URL, addrs []net.IP) bool
+}
+
+// NewScope compiles the rules, normalizing them in place
+func NewScope(in ScopeRules, out ScopeRules) (*Scope, error) {
+	scope := &Scope{}
+	for _, set := range []struct {
+		rules    ScopeRules
+		matchers *[]scopeMatcher
+		name     string
+	}{{in, &scope.in, "in-scope"}, {out, &scope.out, "out-of-scope"}} {
+		for i := range set.rules {
+			if set.rules[i] == nil {
+				set.rules[i] = &ScopeRule{}
+			}
+			matcher, err := newScopeMatcher(set.rules[i])
+			if err != nil {
+				return nil, fmt.Errorf("%s rule %d: %v", set.name, i+1, err)
+			}
+			*set.matchers = append(*set.matchers, matcher)
+		}
+	}
+	return scope, nil
+}
+
+func newScopeMatcher(rule *ScopeRule) (scopeMatcher, error) {
+	rule.Kind = strings.ToLower(strings.TrimSpace(rule.Kind))
+	rule.Value = strings.TrimSpace(rule.Value)
+	if rule.Value == "" {
+		return scopeMatcher{}, fmt.Errorf("empty %s rule", rule.Kind)
+	}
+	switch rule.Kind {
+	case ScopeRuleDomain:
+		rule.Value = strings.ToLower(rule.Value)
+		if strings.ContainsAny(rule.Value, "/: ") || strings.Contains(strings.TrimPrefix(rule.Value, "*."), "*") {
+			return scopeMatcher{}, fmt.Errorf("invalid domain %q, expected a host or *.example.com", rule.Value)
+		}
+		pattern := rule.Value
+		return scopeMatcher{*rule, func(u *url.URL, _ []net.IP) bool {
+			return HostMatches(u.Hostname(), pattern)
+		}}, nil
+	case ScopeRuleCidr:
+		prefix, err := netip.ParsePrefix(rule.Value)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(rule.Value)
+			if addrErr != nil {
+				return scopeMatcher{}, fmt.Errorf("invalid CIDR %q", rule.Value)
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		prefix = prefix.Masked()
+		rule.Value = prefix.String()
+		return scopeMatcher{*rule, func(_ *url.URL, addrs []net.IP) bool {
+			for _, ip := range addrs {
+				if addr, ok := netip.AddrFromSlice(ip); ok && prefix.Contains(addr.Unmap()) {
+					return true
+				}
+			}
+			return false
+		}}, nil
+	case ScopeRulePrefix:
+		u, err := url.Parse(rule.Value)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return scopeMatcher{}, fmt.Errorf("invalid URL prefix %q, expected an http or https URL", rule.Value)
+		}
+		rule.Value = normalizedUrl(u)
+		prefix := rule.Value
+		return scopeMatcher{*rule, func(u *url.URL, _ []net.IP) bool {
+			return strings.HasPrefix(normalizedUrl(u), prefix)
+		}}, nil
+	case ScopeRuleRegex:
+		re, err := regexp.Compile(rule.Value)
+		if err != nil {
+			return scopeMatcher{}, fmt.Errorf("invalid regex: %v", err)
+		}
+		return scopeMatcher{*rule, func(u *url.URL, _ []net.IP) bool {
+			return re.MatchString(u.String())
+		}}, nil
+	}
+	return scopeMatcher{}, fmt.Errorf("unknown kind %q, expected domain, cidr, prefix or regex", rule.Kind)
+}
+
+// normalizedUrl lowercases the scheme and host, which are case-insensitive,
+// and gives an empty path its slash, so that the prefix https://example.com
+// does not match https://example.com.evil.net
+func normalizedUrl(u *url.URL) string {
+	normalized := *u
+	normalized.Scheme = strings.ToLower(u.Scheme)
+	normalized.Host = strings.ToLower(u.Host)
+	if normalized.Path == "" && normalized.Opaque == "" {
+		normalized.Path = "/"
+	}
+	return normalized.String()
+}
+
+// Empty reports whether the scope has no rules, so everything is in scope
+func (s *Scope) Empty() bool {
+	return s == nil || len(s.in)+len(s.out) == 0
+}
+
+// NeedsAddresses reports whether checking u needs the addresses its host
+// resolves to, for the CIDR rules
+func (s *Scope) NeedsAddresses(u *url.URL) bool {
+	if s.Empty() || net.ParseIP(u.Hostname()) != nil {
+		return false
+	}
+	for _, matchers := range [][]scopeMatcher{s.in, s.out} {
+		for _, m := range matchers {
+			if m.rule.Kind == ScopeRuleCidr {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Check decides on u. addrs are the addresses of its host; an IP host is
+// used as its own address.
+func (s *Scope) Check(u *url.URL, addrs []net.IP) *ScopeCheck {
+	check := &ScopeCheck{Url: u.String()}
+	if s.Empty() {
+		check.InScope = true
+		check.Reason = "the project has no scope rules"
+		return check
+	}
+	if ip := net.ParseIP(u.Hostname()); ip != nil {
+		addrs = []net.IP{ip}
+	}
+	for _, m := range s.out {
+		if m.match(u, addrs) {
+			rule := m.rule
+			check.Rule = &rule
+			check.Reason = "matches out-of-scope rule " + rule.String()
+			return check
+		}
+	}
+	for _, m := range s.in {
+		if m.match(u, addrs) {
+			rule := m.rule
+			check.Rule = &rule
+			check.InScope = true
+			check.Reason = "matches in-scope rule " + rule.String()
+			return check
+		}
+	}
+	if len(s.in) == 0 {
+		check.InScope = true
+		check.Reason = "matches no out-of-scope rule"
+		return check
+	}
+	check.Reason = "matches no in-scope rule"
+	return check
+}
@@ -0,0 +1,100 @@
+package models
+
+import (
+	"net"
+	"net/url"
+	"testing"
+)
+
+func TestScope_Check(t *testing.T) {
+	scope, err := NewScope(
+		ScopeRules{
+			{Kind: "domain", Value: "*.Example.com"},
+			{Kind: "prefix", Value: "https://partner.test"},
+			{Kind: "cidr", Value: "203.0.113.0/24"},
+		},
+		ScopeRules{
+			{Kind: "domain", Value: "blog.example.com"},
+			{Kind: "regex", Value: `/logout\b`},
+			{Kind: "CIDR", Value: "10.0.0.1"},
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		url   string
+		addrs []string
+		want  bool
+		rule  string
+	}{
+		{"https://example.com/", nil, true, "domain:*.example.com"},
+		{"https://API.example.com/v1", nil, true, "domain:*.example.com"},
+		{"https://blog.example.com/", nil, false, "domain:blog.example.com"},
+		{"https://app.example.com/logout", nil, false, `regex:/logout\b`},
+		{"https://partner.test/api", nil, true, "prefix:https://partner.test/"},
+		{"https://partner.test.evil.net/", nil, false, ""},
+		{"http://partner.test/", nil, false, ""},
+		{"https://203.0.113.7:8443/", nil, true, "cidr:203.0.113.0/24"},
+		{"https://internal.corp/", []string{"203.0.113.9"}, true, "cidr:203.0.113.0/24"},
+		{"https://app.example.com/", []string{"10.0.0.1"}, false, "cidr:10.0.0.1/32"},
+		{"https://other.test/", nil, false, ""},
+	}
+	for _, tt := range tests {
+		u, _ := url.Parse(tt.url)
+		var addrs []net.IP
+		for _, a := range tt.addrs {
+			addrs = append(addrs, net.ParseIP(a))
+		}
+		check := scope.Check(u, addrs)
+		rule := ""
+		if check.Rule != nil {
+			rule = check.Rule.String()
+		}
+		if check.InScope != tt.want || rule != tt.rule {
+			t.Errorf("%s: inScope=%v rule=%q (%s), want %v %q", tt.url, check.InScope, rule, check.Reason, tt.want, tt.rule)
+		}
+	}
+
+	if !scope.NeedsAddresses(&url.URL{Host: "app.example.com"}) || scope.NeedsAddresses(&url.URL{Host: "10.0.0.1"}) {
+		t.Error("NeedsAddresses should only ask for the addresses of host names")
+	}
+	var empty *Scope
+	if check := empty.Check(&url.URL{Scheme: "https", Host: "anything.test"}, nil); !check.InScope {
+		t.Error("a project without rules should allow everything")
+	}
+}
+
+func TestNewScope_InvalidRules(t *testing.T) {
+	for _, rule := range []*ScopeRule{
+		{Kind: "domain", Value: "https://example.com"},
+		{Kind: "domain", Value: "a.*.example.com"},
+		{Kind: "cidr", Value: "10.0.0.0/33"},
+		{Kind: "prefix", Value: "example.com/api"},
+		{Kind: "regex", Value: "(unclosed"},
+		{Kind: "port", Value: "443"},
+		{Kind: "domain", Value: " "},
+	} {
+		if _, err := NewScope(ScopeRules{rule}, nil); err == nil {
+			t.Errorf("expected %s to be rejected", rule)
+		}
+	}
+}
+
+func TestScopeRules_ValueScan(t *testing.T) {
+	rules := ScopeRules{{Kind: "domain", Value: "example.com"}}
+	value, err := rules.Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var scanned ScopeRules
+	if err := scanned.Scan(value); err != nil {
+		t.Fatal(err)
+	}
+	if len(scanned) != 1 || *scanned[0] != *rules[0] {
+		t.Errorf("scanned %v", scanned)
+	}
+	if err := scanned.Scan(nil); err != nil || len(scanned) != 0 {
+		t.Errorf("scanning NULL gave %v, %v", scanned, err)
+	}
+}
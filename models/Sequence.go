@@ -0,0 +1,72 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/linn221/bane/mystructs"
+)
+
+// Sequence is an ordered list of endpoints sent one after another, where later
+// steps can use values taken from earlier responses
+type Sequence struct {
+	Id          int            `gorm:"primaryKey"`
+	Name        string         `gorm:"size:255;not null"`
+	Description string         `gorm:"default:null"`
+	ProjectId   *int           `gorm:"default:null;index"`
+	Steps       []SequenceStep `gorm:"foreignKey:SequenceId"`
+}
+
+// SequenceStep sends one endpoint. Variables are fixed values for this step;
+// Mappings fill variables from earlier responses, one "name:source" pair each,
+// where source is "<step>.<status|body|header|cookie|json|regex>[.<argument>]",
+// for example "csrf:2.regex.name=\"csrf\"\ value=\"([^\"]+)\"" or "token:1.json.$.data.token".
+// Mapped values stay available to every later step.
+type SequenceStep struct {
+	Id             int               `gorm:"primaryKey"`
+	SequenceId     int               `gorm:"not null;index"`
+	SequenceNumber int               `gorm:"not null"`
+	EndpointId     int               `gorm:"not null;index"`
+	Endpoint       Endpoint          `gorm:"foreignKey:EndpointId"`
+	Variables      mystructs.KVGroup `gorm:"type:text"`
+	Mappings       mystructs.KVGroup `gorm:"type:text"`
+}
+
+type SequenceInput struct {
+	Name        string               `json:"name"`
+	Alias       string               `json:"alias,omitempty"`
+	Description string               `json:"description,omitempty"`
+	ProjectId   *int                 `json:"projectId,omitempty"`
+	Steps       []*SequenceStepInput `json:"steps"`
+}
+
+type SequenceStepInput struct {
+	EndpointAlias string             `json:"endpointAlias"`
+	Variables     *mystructs.KVGroup `json:"variables,omitempty"`
+	Mappings      *mystructs.KVGroup `json:"mappings,omitempty"`
+}
+
+// ValueSource is the parsed source side of a step mapping
+type ValueSource struct {
+	Step int
+	Extraction
+}
+
+// ParseValueSource reads "<step>.<kind>[.<argument>]"; the argument is
+// everything after the second dot, so it may contain dots itself
+func ParseValueSource(source string) (ValueSource, error) {
+	step, extraction, ok := strings.Cut(source, ".")
+	if !ok {
+		return ValueSource{}, fmt.Errorf("invalid mapping source %q, expected <step>.<kind>[.<argument>]", source)
+	}
+	number, err := strconv.Atoi(step)
+	if err != nil || number < 1 {
+		return ValueSource{}, fmt.Errorf("invalid step number in mapping source %q", source)
+	}
+	e, err := ParseExtraction(extraction)
+	if err != nil {
+		return ValueSource{}, fmt.Errorf("mapping source %q: %v", source, err)
+	}
+	return ValueSource{Step: number, Extraction: e}, nil
+}
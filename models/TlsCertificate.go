@@ -0,0 +1,26 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// TlsCertificate is one certificate of the chain the server presented to a
+// MyRequest
+type TlsCertificate struct {
+	Id          int       `gorm:"primaryKey"`
+	MyRequestId int       `gorm:"not null;index"`
+	Position    int       `gorm:"not null"` // 0 for the server's own certificate, then its issuers
+	Subject     string    `gorm:"not null"`
+	Issuer      string    `gorm:"not null"`
+	Sans        string    `gorm:"type:text"` // JSON array of the DNS names, IP addresses and emails it covers
+	NotBefore   time.Time `gorm:"not null"`
+	NotAfter    time.Time `gorm:"not null"`
+}
+
+// SanList returns the subject alternative names of the certificate
+func (c *TlsCertificate) SanList() []string {
+	sans := []string{}
+	json.Unmarshal([]byte(c.Sans), &sans)
+	return sans
+}
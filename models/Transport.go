@@ -0,0 +1,62 @@
+package models
+
+import "fmt"
+
+// HttpVersion limits a request to one version of HTTP
+type HttpVersion string
+
+const (
+	HttpVersion11 HttpVersion = "1.1" // HTTP/1.1 even when the server offers HTTP/2
+	HttpVersion2  HttpVersion = "2"   // HTTP/2 only, with prior knowledge over plain http
+)
+
+// DefaultMaxRedirects is how many redirects are followed when an endpoint
+// follows redirects without setting a limit
+const DefaultMaxRedirects = 10
+
+// TransportOptions control how an endpoint's requests are sent. runCurl can
+// override the fields an endpoint sets; unset fields keep the defaults: no
+// timeout, redirects not followed, certificates verified, HTTP/2 negotiated
+// over TLS and compressed responses decoded.
+type TransportOptions struct {
+	TimeoutMs       *int         `json:"timeoutMs,omitempty"` // the whole exchange, body included
+	FollowRedirects *bool        `json:"followRedirects,omitempty"`
+	MaxRedirects    *int         `json:"maxRedirects,omitempty"` // hops followed at most, DefaultMaxRedirects when unset
+	Insecure        *bool        `json:"insecure,omitempty"`     // skip TLS certificate verification
+	HttpVersion     *HttpVersion `json:"httpVersion,omitempty"`
+	NoDecompression *bool        `json:"noDecompression,omitempty"` // keep compressed bodies as received
+}
+
+// Over fills the fields o leaves unset from base
+func (o TransportOptions) Over(base TransportOptions) TransportOptions {
+	if o.TimeoutMs == nil {
+		o.TimeoutMs = base.TimeoutMs
+	}
+	if o.FollowRedirects == nil {
+		o.FollowRedirects = base.FollowRedirects
+	}
+	if o.MaxRedirects == nil {
+		o.MaxRedirects = base.MaxRedirects
+	}
+	if o.Insecure == nil {
+		o.Insecure = base.Insecure
+	}
+	if o.HttpVersion == nil {
+		o.HttpVersion = base.HttpVersion
+	}
+	if o.NoDecompression == nil {
+		o.NoDecompression = base.NoDecompression
+	}
+	return o
+}
+
+// Validate rejects negative limits
+func (o TransportOptions) Validate() error {
+	if o.TimeoutMs != nil && *o.TimeoutMs <= 0 {
+		return fmt.Errorf("timeoutMs must be positive, got %d", *o.TimeoutMs)
+	}
+	if o.MaxRedirects != nil && *o.MaxRedirects < 0 {
+		return fmt.Errorf("maxRedirects cannot be negative, got %d", *o.MaxRedirects)
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/linn221/bane/utils"
+)
+
+// Variable is a named value in the variable store. Endpoint extractors write
+// to it after each run, and runCurl reads from it to fill placeholders the
+// caller left out.
+type Variable struct {
+	Id         int       `gorm:"primaryKey"`
+	Name       string    `gorm:"size:255;not null;uniqueIndex"`
+	Value      string    `gorm:"type:text"`
+	EndpointId *int      `gorm:"default:null;index"` // the endpoint that last extracted it, nil when set by hand
+	UpdatedAt  time.Time `gorm:"autoUpdateTime"`
+}
+
+// Extraction names one value to pull out of a response
+type Extraction struct {
+	Kind string
+	Arg  string
+}
+
+// ParseExtraction reads "<status|body|header|cookie|json|regex>[.<argument>]";
+// the argument is everything after the first dot, so it may contain dots itself
+func ParseExtraction(s string) (Extraction, error) {
+	kind, arg, _ := strings.Cut(s, ".")
+	e := Extraction{Kind: kind, Arg: arg}
+	switch e.Kind {
+	case utils.ExtractStatus, utils.ExtractBody:
+	case utils.ExtractHeader, utils.ExtractCookie, utils.ExtractJson, utils.ExtractRegex:
+		if e.Arg == "" {
+			return Extraction{}, fmt.Errorf("%s extraction needs an argument", e.Kind)
+		}
+		if e.Kind == utils.ExtractRegex {
+			if _, err := regexp.Compile(e.Arg); err != nil {
+				return Extraction{}, fmt.Errorf("invalid regex: %w", err)
+			}
+		}
+	default:
+		return Extraction{}, fmt.Errorf("unknown extraction kind %q", e.Kind)
+	}
+	return e, nil
+}
+
+// Extract pulls the value out of resp
+func (e Extraction) Extract(resp utils.Response) (string, error) {
+	return utils.Extract(e.Kind, e.Arg, resp)
+}
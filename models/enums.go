@@ -440,3 +440,139 @@ type PatchInput struct {
 	Values    []KVString `json:"values,omitempty"`
 	ValuesInt []KVInt    `json:"valuesInt,omitempty"`
 }
+
+// ExportFormat GraphQL methods
+func (f ExportFormat) MarshalGQL(w io.Writer) {
+	w.Write([]byte(strconv.Quote(string(f))))
+}
+
+func (f *ExportFormat) UnmarshalGQL(i interface{}) error {
+	str, ok := i.(string)
+	if !ok {
+		return errors.New("export format must be string")
+	}
+	switch format := ExportFormat(strings.ToLower(str)); format {
+	case ExportFormatCurl, ExportFormatRaw, ExportFormatHttpie, ExportFormatPython,
+		ExportFormatGo, ExportFormatFetch, ExportFormatFfuf:
+		*f = format
+	default:
+		return errors.New("invalid export format, expected curl, raw, httpie, python, go, fetch or ffuf")
+	}
+	return nil
+}
+
+// JobKind GraphQL methods
+func (k JobKind) MarshalGQL(w io.Writer) {
+	w.Write([]byte(strconv.Quote(string(k))))
+}
+
+func (k *JobKind) UnmarshalGQL(i interface{}) error {
+	str, ok := i.(string)
+	if !ok {
+		return errors.New("job kind must be string")
+	}
+	switch kind := JobKind(str); kind {
+	case JobKindFuzz, JobKindAttack, JobKindSequence:
+		*k = kind
+	default:
+		return errors.New("invalid job kind")
+	}
+	return nil
+}
+
+// JobStatus GraphQL methods
+func (s JobStatus) MarshalGQL(w io.Writer) {
+	w.Write([]byte(strconv.Quote(string(s))))
+}
+
+func (s *JobStatus) UnmarshalGQL(i interface{}) error {
+	str, ok := i.(string)
+	if !ok {
+		return errors.New("job status must be string")
+	}
+	switch status := JobStatus(str); status {
+	case JobStatusRunning, JobStatusCompleted, JobStatusCancelled, JobStatusFailed:
+		*s = status
+	default:
+		return errors.New("invalid job status")
+	}
+	return nil
+}
+
+// AttackMode GraphQL methods
+func (m AttackMode) MarshalGQL(w io.Writer) {
+	w.Write([]byte(strconv.Quote(string(m))))
+}
+
+func (m *AttackMode) UnmarshalGQL(i interface{}) error {
+	str, ok := i.(string)
+	if !ok {
+		return errors.New("attack mode must be string")
+	}
+	switch mode := AttackMode(strings.ToLower(str)); mode {
+	case AttackModeSniper, AttackModeBatteringRam, AttackModePitchfork, AttackModeClusterBomb:
+		*m = mode
+	default:
+		return errors.New("invalid attack mode, expected sniper, battering_ram, pitchfork or cluster_bomb")
+	}
+	return nil
+}
+
+// DiffMode GraphQL methods
+func (m DiffMode) MarshalGQL(w io.Writer) {
+	w.Write([]byte(strconv.Quote(string(m))))
+}
+
+func (m *DiffMode) UnmarshalGQL(i interface{}) error {
+	str, ok := i.(string)
+	if !ok {
+		return errors.New("diff mode must be string")
+	}
+	switch mode := DiffMode(strings.ToUpper(str)); mode {
+	case DiffModeLine, DiffModeWord, DiffModeJson:
+		*m = mode
+	default:
+		return errors.New("invalid diff mode, expected LINE, WORD or JSON")
+	}
+	return nil
+}
+
+// HttpVersion GraphQL methods
+func (v HttpVersion) MarshalGQL(w io.Writer) {
+	w.Write([]byte(strconv.Quote(string(v))))
+}
+
+func (v *HttpVersion) UnmarshalGQL(i interface{}) error {
+	str, ok := i.(string)
+	if !ok {
+		return errors.New("http version must be string")
+	}
+	switch version := HttpVersion(strings.TrimPrefix(strings.ToUpper(str), "HTTP/")); version {
+	case HttpVersion11, HttpVersion2:
+		*v = version
+	case "1", "1.0":
+		*v = HttpVersion11
+	default:
+		return errors.New("invalid http version, expected 1.1 or 2")
+	}
+	return nil
+}
+
+// IssueSeverity GraphQL methods
+func (s IssueSeverity) MarshalGQL(w io.Writer) {
+	w.Write([]byte(strconv.Quote(string(s))))
+}
+
+func (s *IssueSeverity) UnmarshalGQL(i interface{}) error {
+	str, ok := i.(string)
+	if !ok {
+		return errors.New("issue severity must be string")
+	}
+	switch severity := IssueSeverity(strings.ToLower(str)); severity {
+	case IssueSeverityInfo, IssueSeverityLow, IssueSeverityMedium, IssueSeverityHigh:
+		*s = severity
+	default:
+		return errors.New("invalid issue severity, expected info, low, medium or high")
+	}
+	return nil
+}
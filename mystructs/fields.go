@@ -0,0 +1,69 @@
+package mystructs
+
+import (
+	"strings"
+	"unicode"
+)
+
+// splitFields splits a "key:value key:value" string on unescaped whitespace.
+// A backslash escapes a following whitespace character or backslash, which lets
+// values such as user agents keep their spaces; any other backslash is literal.
+func splitFields(s string) []string {
+	var fields []string
+	var current strings.Builder
+	inField := false
+	runes := []rune(s)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		if r == '\\' && i+1 < len(runes) && (unicode.IsSpace(runes[i+1]) || runes[i+1] == '\\') {
+			current.WriteRune(runes[i+1])
+			inField = true
+			i++
+			continue
+		}
+		if unicode.IsSpace(r) {
+			if inField {
+				fields = append(fields, current.String())
+				current.Reset()
+				inField = false
+			}
+			continue
+		}
+		current.WriteRune(r)
+		inField = true
+	}
+	if inField {
+		fields = append(fields, current.String())
+	}
+	return fields
+}
+
+// escapeField is the inverse of splitFields for a single field
+func escapeField(s string) string {
+	if !strings.ContainsFunc(s, func(r rune) bool { return unicode.IsSpace(r) || r == '\\' }) {
+		return s
+	}
+	var b strings.Builder
+	runes := []rune(s)
+	for i, r := range runes {
+		switch {
+		case unicode.IsSpace(r):
+			b.WriteRune('\\')
+		case r == '\\' && (i+1 == len(runes) || unicode.IsSpace(runes[i+1]) || runes[i+1] == '\\'):
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// EscapeLegacyFields rewrites a group stored before fields were escaped, when
+// it was split on plain whitespace and every backslash was literal, so that it
+// reads back as the same pairs
+func EscapeLegacyFields(s string) string {
+	fields := strings.Fields(s)
+	for i, field := range fields {
+		fields[i] = escapeField(field)
+	}
+	return strings.Join(fields, " ")
+}
@@ -34,13 +34,7 @@ func (kv KVGroup) MarshalGQL(w io.Writer) {
 		return
 	}
 
-	var parts []string
-	for _, pair := range kv.KVPairs {
-		parts = append(parts, fmt.Sprintf("%s:%s", pair.Key, pair.Value))
-	}
-
-	result := strings.Join(parts, " ")
-	fmt.Fprint(w, strconv.Quote(result))
+	fmt.Fprint(w, strconv.Quote(kv.String()))
 }
 
 // UnmarshalGQL implements the graphql.Unmarshaler interface for GraphQL deserialization
@@ -55,19 +49,13 @@ func (kv *KVGroup) UnmarshalGQL(v interface{}) error {
 		return fmt.Errorf("KVGroup must be a string, got %T", v)
 	}
 
-	// Parse the input string in format "key1:value1 key2:value2 ..."
-	if input == "" {
-		kv.KVPairs = []KVPair{}
-		return nil
-	}
-
-	var pairs []KVPair
-	// Parse the input string by finding key:value pairs
-	// Each pair is separated by spaces, but values can contain spaces
-	// We need to be smart about splitting: look for the pattern "key:value " (space after value)
+	return kv.parse(input)
+}
 
-	// First, let's try a simpler approach: split by spaces and handle each part
-	parts := strings.Fields(input) // Split by whitespace
+// parse reads the "key1:value1 key2:value2 ..." format shared by GraphQL and the database
+func (kv *KVGroup) parse(input string) error {
+	parts := splitFields(input)
+	pairs := make([]KVPair, 0, len(parts))
 
 	for _, part := range parts {
 		// Split each part by the first colon
@@ -76,12 +64,9 @@ func (kv *KVGroup) UnmarshalGQL(v interface{}) error {
 			return fmt.Errorf("invalid format: missing colon in '%s'", part)
 		}
 
-		key := part[:colonIndex]
-		value := part[colonIndex+1:]
-
 		pairs = append(pairs, KVPair{
-			Key:   key,
-			Value: value,
+			Key:   part[:colonIndex],
+			Value: part[colonIndex+1:],
 		})
 	}
 
@@ -97,12 +82,21 @@ func (kv KVGroup) String() string {
 
 	var parts []string
 	for _, pair := range kv.KVPairs {
-		parts = append(parts, fmt.Sprintf("%s:%s", pair.Key, pair.Value))
+		parts = append(parts, escapeField(pair.Key+":"+pair.Value))
 	}
 
 	return strings.Join(parts, " ")
 }
 
+// ToMap returns the pairs as a map; later pairs win over earlier ones with the same key
+func (kv KVGroup) ToMap() map[string]string {
+	m := make(map[string]string, len(kv.KVPairs))
+	for _, pair := range kv.KVPairs {
+		m[pair.Key] = pair.Value
+	}
+	return m
+}
+
 // ToKVPairGroup converts KVGroupInput to KVPairGroup
 func (kv KVGroup) ToKVGroup() KVGroup {
 	return kv
@@ -123,16 +117,7 @@ func NewKVGroupFromString(input string) (KVGroup, error) {
 // Value implements the driver.Valuer interface for GORM
 // Stores the KVPairGroup as a string in format "key:value key:value ..."
 func (kg KVGroup) Value() (driver.Value, error) {
-	if len(kg.KVPairs) == 0 {
-		return "", nil
-	}
-
-	var parts []string
-	for _, kv := range kg.KVPairs {
-		parts = append(parts, fmt.Sprintf("%s:%s", kv.Key, kv.Value))
-	}
-
-	return strings.Join(parts, " "), nil
+	return kg.String(), nil
 }
 
 // Scan implements the sql.Scanner interface for GORM
@@ -153,31 +138,5 @@ func (kg *KVGroup) Scan(value interface{}) error {
 		return fmt.Errorf("cannot scan %T into KVPairGroup", value)
 	}
 
-	if input == "" {
-		kg.KVPairs = []KVPair{}
-		return nil
-	}
-
-	// Split by spaces to get individual key:value pairs
-	parts := strings.Fields(input)
-	var pairs []KVPair
-
-	for _, part := range parts {
-		// Split by colon to separate key and value
-		colonIndex := strings.Index(part, ":")
-		if colonIndex == -1 {
-			return fmt.Errorf("invalid format: missing colon in '%s'", part)
-		}
-
-		key := part[:colonIndex]
-		value := part[colonIndex+1:]
-
-		pairs = append(pairs, KVPair{
-			Key:   key,
-			Value: value,
-		})
-	}
-
-	kg.KVPairs = pairs
-	return nil
+	return kg.parse(input)
 }
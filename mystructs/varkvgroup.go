@@ -20,18 +20,22 @@ type VarKV struct {
 // Value implements the driver.Valuer interface for GORM
 // Stores the VarKVGroup as a string in format "key:value key:value ..."
 func (vkg VarKVGroup) Value() (driver.Value, error) {
+	return vkg.String(), nil
+}
+
+// String returns the original "key:value key:value ..." form, with whitespace
+// inside keys and values escaped by a backslash
+func (vkg VarKVGroup) String() string {
 	if len(vkg.VarKVs) == 0 {
-		return "", nil
+		return ""
 	}
 
 	var parts []string
 	for _, kv := range vkg.VarKVs {
-		keyStr := kv.Key.OriginalString
-		valueStr := kv.Value.OriginalString
-		parts = append(parts, fmt.Sprintf("%s:%s", keyStr, valueStr))
+		parts = append(parts, escapeField(kv.Key.OriginalString+":"+kv.Value.OriginalString))
 	}
 
-	return strings.Join(parts, " "), nil
+	return strings.Join(parts, " ")
 }
 
 // Scan implements the sql.Scanner interface for GORM
@@ -52,45 +56,7 @@ func (vkg *VarKVGroup) Scan(value interface{}) error {
 		return fmt.Errorf("cannot scan %T into VarKVGroup", value)
 	}
 
-	if input == "" {
-		vkg.VarKVs = []VarKV{}
-		return nil
-	}
-
-	// Split by spaces to get individual key:value pairs
-	parts := strings.Fields(input)
-	var varKVs []VarKV
-
-	for _, part := range parts {
-		// Split by first colon to separate key and value
-		colonIndex := strings.Index(part, ":")
-		if colonIndex == -1 {
-			return fmt.Errorf("invalid format: missing colon in '%s'", part)
-		}
-
-		keyStr := part[:colonIndex]
-		valueStr := part[colonIndex+1:]
-
-		// Parse key as VarString
-		keyVarString, err := NewVarString(keyStr)
-		if err != nil {
-			return fmt.Errorf("failed to parse key VarString '%s': %v", keyStr, err)
-		}
-
-		// Parse value as VarString
-		valueVarString, err := NewVarString(valueStr)
-		if err != nil {
-			return fmt.Errorf("failed to parse value VarString '%s': %v", valueStr, err)
-		}
-
-		varKVs = append(varKVs, VarKV{
-			Key:   *keyVarString,
-			Value: *valueVarString,
-		})
-	}
-
-	vkg.VarKVs = varKVs
-	return nil
+	return vkg.parse(input)
 }
 
 // MarshalGQL implements the graphql.Marshaler interface for GraphQL serialization
@@ -101,13 +67,7 @@ func (vkg VarKVGroup) MarshalGQL(w io.Writer) {
 	}
 
 	// Return the original string format as stored in database
-	var parts []string
-	for _, kv := range vkg.VarKVs {
-		parts = append(parts, fmt.Sprintf("%s:%s", kv.Key.OriginalString, kv.Value.OriginalString))
-	}
-
-	result := strings.Join(parts, " ")
-	fmt.Fprint(w, strconv.Quote(result))
+	fmt.Fprint(w, strconv.Quote(vkg.String()))
 }
 
 // UnmarshalGQL implements the graphql.Unmarshaler interface for GraphQL deserialization
@@ -122,14 +82,13 @@ func (vkg *VarKVGroup) UnmarshalGQL(v interface{}) error {
 		return fmt.Errorf("VarKVGroup must be a string, got %T", v)
 	}
 
-	if input == "" {
-		vkg.VarKVs = []VarKV{}
-		return nil
-	}
+	return vkg.parse(input)
+}
 
-	// Parse the input string in format "key:value key:value ..."
-	parts := strings.Fields(input)
-	var varKVs []VarKV
+// parse reads the "key:value key:value ..." format shared by GraphQL and the database
+func (vkg *VarKVGroup) parse(input string) error {
+	parts := splitFields(input)
+	varKVs := make([]VarKV, 0, len(parts))
 
 	for _, part := range parts {
 		// Split by first colon to separate key and value
@@ -181,3 +140,21 @@ func (vkg VarKVGroup) Exec() string {
 func (vkg VarKVGroup) IsZero() bool {
 	return len(vkg.VarKVs) == 0
 }
+
+// NewVarKVGroup builds a VarKVGroup from plain pairs, parsing any {name=default}
+// placeholders they contain
+func NewVarKVGroup(pairs []KVPair) (VarKVGroup, error) {
+	varKVs := make([]VarKV, 0, len(pairs))
+	for _, pair := range pairs {
+		key, err := NewVarString(pair.Key)
+		if err != nil {
+			return VarKVGroup{}, fmt.Errorf("failed to parse key VarString '%s': %v", pair.Key, err)
+		}
+		value, err := NewVarString(pair.Value)
+		if err != nil {
+			return VarKVGroup{}, fmt.Errorf("failed to parse value VarString '%s': %v", pair.Value, err)
+		}
+		varKVs = append(varKVs, VarKV{Key: *key, Value: *value})
+	}
+	return VarKVGroup{VarKVs: varKVs}, nil
+}
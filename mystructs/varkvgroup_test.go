@@ -55,3 +55,82 @@ func TestVarKVGroup_Roundtrip_Value_Scan(t *testing.T) {
 		t.Errorf("roundtrip Exec=%q", back.Exec())
 	}
 }
+
+func TestVarKVGroup_EscapedWhitespace(t *testing.T) {
+	cases := []struct {
+		in    string
+		pairs [][2]string
+	}{
+		{`UA:Mozilla/5.0\ (X11;\ Linux) Accept:*/*`, [][2]string{{"UA", "Mozilla/5.0 (X11; Linux)"}, {"Accept", "*/*"}}},
+		{`Path:C:\Windows\System32`, [][2]string{{"Path", `C:\Windows\System32`}}},
+		{`Trail:ends\\ Next:v`, [][2]string{{"Trail", `ends\`}, {"Next", "v"}}},
+		{`Name:{who=Henry\ Cohle}`, [][2]string{{"Name", "{who=Henry Cohle}"}}},
+		{"  A:1 \t B:2\n", [][2]string{{"A", "1"}, {"B", "2"}}},
+	}
+	for i, c := range cases {
+		var vkg VarKVGroup
+		if err := vkg.UnmarshalGQL(c.in); err != nil {
+			t.Fatalf("case %d: %v", i, err)
+		}
+		if len(vkg.VarKVs) != len(c.pairs) {
+			t.Fatalf("case %d: got %d pairs, want %d", i, len(vkg.VarKVs), len(c.pairs))
+		}
+		for j, p := range c.pairs {
+			if vkg.VarKVs[j].Key.OriginalString != p[0] || vkg.VarKVs[j].Value.OriginalString != p[1] {
+				t.Errorf("case %d pair %d: got (%q,%q) want (%q,%q)", i, j, vkg.VarKVs[j].Key.OriginalString, vkg.VarKVs[j].Value.OriginalString, p[0], p[1])
+			}
+		}
+
+		// whatever was parsed must survive a database roundtrip unchanged
+		dv, err := vkg.Value()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var back VarKVGroup
+		if err := back.Scan(dv); err != nil {
+			t.Fatalf("case %d: scan %q: %v", i, dv, err)
+		}
+		if back.String() != vkg.String() || len(back.VarKVs) != len(vkg.VarKVs) {
+			t.Errorf("case %d: roundtrip %q became %q", i, vkg.String(), back.String())
+		}
+	}
+	var withPlaceholder VarKVGroup
+	withPlaceholder.UnmarshalGQL(`Name:{who=Henry\ Cohle}`)
+	if got := withPlaceholder.VarKVs[0].Value.Exec(); got != "Henry Cohle" {
+		t.Errorf("placeholder default=%q", got)
+	}
+}
+
+func TestKVGroup_EscapedWhitespaceRoundtrip(t *testing.T) {
+	kv := KVGroup{KVPairs: []KVPair{{Key: "q", Value: "two words"}, {Key: "slash", Value: `a\`}, {Key: "plain", Value: "x"}}}
+	back, err := NewKVGroupFromString(kv.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(back.KVPairs) != 3 {
+		t.Fatalf("got %d pairs from %q", len(back.KVPairs), kv.String())
+	}
+	for i := range kv.KVPairs {
+		if back.KVPairs[i] != kv.KVPairs[i] {
+			t.Errorf("pair %d: got %+v want %+v", i, back.KVPairs[i], kv.KVPairs[i])
+		}
+	}
+}
+
+func TestEscapeLegacyFields(t *testing.T) {
+	// split on whitespace, with every backslash literal
+	legacy := `dir:C:\\share\ q:a\  plain:x`
+	back, err := NewKVGroupFromString(EscapeLegacyFields(legacy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []KVPair{{Key: "dir", Value: `C:\\share\`}, {Key: "q", Value: `a\`}, {Key: "plain", Value: "x"}}
+	if len(back.KVPairs) != len(want) {
+		t.Fatalf("got %d pairs from %q", len(back.KVPairs), EscapeLegacyFields(legacy))
+	}
+	for i := range want {
+		if back.KVPairs[i] != want[i] {
+			t.Errorf("pair %d: got %+v want %+v", i, back.KVPairs[i], want[i])
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 )
@@ -19,6 +20,7 @@ type VarString struct {
 }
 
 // NewVarString parses a string with variable placeholders in the format {name=default}
+// or {name}. A bare {name} has no default and is left as is unless a value is given.
 // Returns a VarString with parsed placeholders and default values
 func NewVarString(s string) (*VarString, error) {
 	vs := &VarString{
@@ -27,21 +29,27 @@ func NewVarString(s string) (*VarString, error) {
 		Placeholders:   make([]string, 0),
 	}
 
-	// Parse the string to extract placeholders in format {name=default}
+	// Parse the string to extract placeholders in format {name=default} or {name}
 	// This regex matches {name=default} where name is alphanumeric and default can contain any character except }
-	re := regexp.MustCompile(`\{([a-zA-Z_][a-zA-Z0-9_]*)=([^}]*)\}`)
+	re := regexp.MustCompile(`\{([a-zA-Z_][a-zA-Z0-9_]*)(=[^}]*)?\}`)
 	matches := re.FindAllStringSubmatch(s, -1)
 
 	// Build the parsed template by replacing placeholders with variable references
 	parsedTemplate := s
 	for _, match := range matches {
-		placeholder := match[0]  // Full match like "{id=1}"
-		varName := match[1]      // Variable name like "id"
-		defaultValue := match[2] // Default value like "1"
+		placeholder := match[0] // Full match like "{id=1}"
+		varName := match[1]     // Variable name like "id"
 
-		// Store the default value
-		vs.Variables[varName] = defaultValue
-		vs.Placeholders = append(vs.Placeholders, varName)
+		if !slices.Contains(vs.Placeholders, varName) {
+			vs.Placeholders = append(vs.Placeholders, varName)
+		}
+		if match[2] == "" {
+			// A bare {name} is already a variable reference
+			continue
+		}
+
+		// Store the default value, like "1"
+		vs.Variables[varName] = match[2][1:]
 
 		// Replace the placeholder with a simple variable reference for later substitution
 		parsedTemplate = strings.ReplaceAll(parsedTemplate, placeholder, "{"+varName+"}")
@@ -73,6 +81,28 @@ func (vs *VarString) Exec() string {
 	return result
 }
 
+// placeholderRef matches the {name} references left in ParsedTemplate
+var placeholderRef = regexp.MustCompile(`\{([a-zA-Z_][a-zA-Z0-9_]*)\}`)
+
+// ExecWith returns the final string using vars for the placeholders it names
+// and the defaults for the rest. Unlike Inject, the VarString is left untouched,
+// so the same stored value can be rendered many times with different inputs.
+func (vs VarString) ExecWith(vars map[string]string) string {
+	if vs.ParsedTemplate == "" && len(vs.Placeholders) == 0 {
+		return vs.OriginalString
+	}
+	return placeholderRef.ReplaceAllStringFunc(vs.ParsedTemplate, func(ref string) string {
+		name := ref[1 : len(ref)-1]
+		if value, ok := vars[name]; ok {
+			return value
+		}
+		if value, ok := vs.Variables[name]; ok {
+			return value
+		}
+		return ref
+	})
+}
+
 // String implements the Stringer interface
 func (vs *VarString) String() string {
 	return vs.Exec()
@@ -28,3 +28,40 @@ func TestVarString_ParseEqualsPlaceholders(t *testing.T) {
 		}
 	}
 }
+
+func TestVarString_ExecWith_LeavesReceiverUntouched(t *testing.T) {
+	vs, err := NewVarString("/users/{id=1}/posts/{post=first}?raw={literal}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := vs.ExecWith(map[string]string{"id": "42", "unused": "x"})
+	if got != "/users/42/posts/first?raw={literal}" {
+		t.Errorf("ExecWith=%q", got)
+	}
+	if vs.Exec() != "/users/1/posts/first?raw={literal}" {
+		t.Errorf("receiver mutated, Exec=%q", vs.Exec())
+	}
+	plain := VarString{OriginalString: "no placeholders"}
+	if plain.ExecWith(map[string]string{"a": "b"}) != "no placeholders" {
+		t.Errorf("unparsed VarString should render its original string")
+	}
+}
+
+func TestVarString_BarePlaceholders(t *testing.T) {
+	vs, err := NewVarString("Bearer {token} for {user=admin} and {token}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vs.Placeholders) != 2 || vs.Placeholders[0] != "token" || vs.Placeholders[1] != "user" {
+		t.Errorf("Placeholders=%v", vs.Placeholders)
+	}
+	if _, ok := vs.Variables["token"]; ok {
+		t.Errorf("bare placeholder should have no default")
+	}
+	if got := vs.Exec(); got != "Bearer {token} for admin and {token}" {
+		t.Errorf("Exec=%q", got)
+	}
+	if got := vs.ExecWith(map[string]string{"token": "abc"}); got != "Bearer abc for admin and abc" {
+		t.Errorf("ExecWith=%q", got)
+	}
+}
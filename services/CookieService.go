@@ -0,0 +1,211 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/linn221/bane/models"
+	"github.com/linn221/bane/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// cookieService keeps a cookie jar per project. Responses to a project's
+// endpoints update its jar, and the jar's matching cookies go out with the
+// project's later requests.
+type cookieService struct {
+	db *gorm.DB
+}
+
+// Jar loads the cookies that have not expired. Endpoints outside a project
+// get an empty jar.
+func (s *cookieService) Jar(ctx context.Context, projectId *int) (models.CookieJar, error) {
+	if projectId == nil {
+		return nil, nil
+	}
+	var jar models.CookieJar
+	err := s.db.WithContext(ctx).
+		Where("project_id = ? AND (expires IS NULL OR expires > ?)", *projectId, time.Now()).
+		Order("created_at").Find(&jar).Error
+	return jar, err
+}
+
+// Capture stores the cookies the recorded response, and the redirects before
+// it, set, removing those they expired. Cookies a browser would reject are
+// skipped.
+func (s *cookieService) Capture(ctx context.Context, projectId *int, request *models.MyRequest) error {
+	if projectId == nil || (!request.Success && len(request.Redirects) == 0) {
+		return nil
+	}
+	now := time.Now()
+	finalUrl := request.RequestUrl
+	for _, hop := range request.Redirects {
+		var setCookies []string
+		json.Unmarshal([]byte(hop.SetCookies), &setCookies)
+		if err := s.captureFrom(ctx, *projectId, hop.Url, http.Header{"Set-Cookie": setCookies}, now); err != nil {
+			return err
+		}
+		if u, err := url.Parse(hop.Url); err == nil {
+			if next, err := u.Parse(hop.Location); err == nil {
+				finalUrl = next.String()
+			}
+		}
+	}
+	if !request.Success {
+		return nil
+	}
+	var headers http.Header
+	if err := json.Unmarshal([]byte(request.ResponseHeaders), &headers); err != nil {
+		return nil
+	}
+	return s.captureFrom(ctx, *projectId, finalUrl, headers, now)
+}
+
+// captureFrom stores the cookies of the Set-Cookie headers of a response
+// from rawUrl
+func (s *cookieService) captureFrom(ctx context.Context, projectId int, rawUrl string, headers http.Header, now time.Time) error {
+	if len(headers["Set-Cookie"]) == 0 {
+		return nil
+	}
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return nil
+	}
+	for _, setCookie := range (&http.Response{Header: headers}).Cookies() {
+		cookie, err := models.NewCookieFromSetCookie(projectId, setCookie, u, now)
+		if err != nil {
+			continue
+		}
+		if err := s.save(ctx, cookie, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// save stores the cookie in place of the one with the same name, domain and
+// path, or deletes that one when the cookie has already expired
+func (s *cookieService) save(ctx context.Context, cookie *models.Cookie, now time.Time) error {
+	key := s.db.WithContext(ctx).Where("project_id = ? AND name = ? AND domain = ? AND path = ?",
+		cookie.ProjectId, cookie.Name, cookie.Domain, cookie.Path)
+	if cookie.Expired(now) {
+		return key.Delete(&models.Cookie{}).Error
+	}
+	return s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "project_id"}, {Name: "name"}, {Name: "domain"}, {Name: "path"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"value", "host_only", "expires", "secure", "http_only", "same_site", "updated_at",
+		}),
+	}).Create(cookie).Error
+}
+
+// List returns a project's cookies. With a URL, only the cookies that would
+// be sent to it are listed.
+func (s *cookieService) List(ctx context.Context, projectId int, domain *string, rawUrl *string) ([]*models.Cookie, error) {
+	query := s.db.WithContext(ctx).Where("project_id = ?", projectId)
+	if domain != nil {
+		query = query.Where("domain = ?", *domain)
+	}
+	var cookies []*models.Cookie
+	if err := query.Order("domain, path, name").Find(&cookies).Error; err != nil {
+		return nil, err
+	}
+	if rawUrl == nil {
+		return cookies, nil
+	}
+	u, err := url.Parse(*rawUrl)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url: %w", err)
+	}
+	matched := make([]*models.Cookie, 0, len(cookies))
+	for _, c := range cookies {
+		if c.Matches(u, time.Now()) {
+			matched = append(matched, c)
+		}
+	}
+	return matched, nil
+}
+
+// Set adds a cookie by hand, or replaces the one with the same name, domain
+// and path
+func (s *cookieService) Set(ctx context.Context, input *models.CookieInput) (*models.Cookie, error) {
+	if _, err := firstById[models.Project](s.db.WithContext(ctx), input.ProjectId); err != nil {
+		return nil, fmt.Errorf("project %d not found: %v", input.ProjectId, err)
+	}
+	cookie, err := models.NewCookie(input)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.save(ctx, cookie, time.Now()); err != nil {
+		return nil, err
+	}
+	var stored models.Cookie
+	err = s.db.WithContext(ctx).Where("project_id = ? AND name = ? AND domain = ? AND path = ?",
+		cookie.ProjectId, cookie.Name, cookie.Domain, cookie.Path).First(&stored).Error
+	return &stored, err
+}
+
+func (s *cookieService) Delete(ctx context.Context, id int) (bool, error) {
+	result := s.db.WithContext(ctx).Delete(&models.Cookie{}, id)
+	return result.RowsAffected > 0, result.Error
+}
+
+// Clear removes a project's cookies, or only those of one domain, and
+// returns how many were removed
+func (s *cookieService) Clear(ctx context.Context, projectId int, domain *string) (int, error) {
+	query := s.db.WithContext(ctx).Where("project_id = ?", projectId)
+	if domain != nil {
+		query = query.Where("domain = ?", *domain)
+	}
+	result := query.Delete(&models.Cookie{})
+	return int(result.RowsAffected), result.Error
+}
+
+// ImportNetscape adds the cookies of a Netscape cookies.txt file to the
+// project's jar and returns how many were stored. Expired cookies are skipped.
+func (s *cookieService) ImportNetscape(ctx context.Context, file io.Reader, projectId int) (int, error) {
+	if _, err := firstById[models.Project](s.db.WithContext(ctx), projectId); err != nil {
+		return 0, fmt.Errorf("project %d not found: %v", projectId, err)
+	}
+	parsed, err := utils.ParseNetscapeCookies(file)
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	imported := 0
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		txService := &cookieService{db: tx}
+		for _, c := range parsed {
+			cookie, err := models.NewCookie(&models.CookieInput{
+				ProjectId: projectId,
+				Name:      c.Name,
+				Value:     c.Value,
+				Domain:    c.Domain,
+				Path:      &c.Path,
+				Expires:   c.Expires,
+				Secure:    &c.Secure,
+				HttpOnly:  &c.HttpOnly,
+			})
+			if err != nil {
+				return err
+			}
+			if cookie.Expired(now) {
+				continue
+			}
+			if err := txService.save(ctx, cookie, now); err != nil {
+				return err
+			}
+			imported++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return imported, nil
+}
@@ -0,0 +1,126 @@
+package services
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/linn221/bane/models"
+	"github.com/linn221/bane/mystructs"
+)
+
+func TestCookieService_JarFollowsRunCurl(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/app/login":
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "s1", Path: "/app"})
+			http.SetCookie(w, &http.Cookie{Name: "pref", Value: "dark", Path: "/"})
+			http.SetCookie(w, &http.Cookie{Name: "scoped", Value: "1"}) // defaults to /app
+			http.SetCookie(w, &http.Cookie{Name: "tls", Value: "x", Secure: true})
+			http.SetCookie(w, &http.Cookie{Name: "evil", Value: "x", Domain: "example.com"})
+		case "/app/logout":
+			http.SetCookie(w, &http.Cookie{Name: "session", Path: "/app", MaxAge: -1})
+		}
+		io.WriteString(w, r.Header.Get("Cookie"))
+	}))
+	defer srv.Close()
+
+	services := newTestServices(t)
+	ctx := context.Background()
+	project, err := services.ProjectService.Create(ctx, &models.ProjectInput{Name: "jar"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"/app/login", "/app/me", "/other", "/app/logout"} {
+		if _, err := services.EndpointService.Create(ctx, &models.EndpointInput{
+			ProjectId: &project.Id,
+			Url:       mustVarString(t, srv.URL+path),
+			Headers:   mustVarKVGroup(t, "Cookie:manual=1"),
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	run := func(alias string) string {
+		t.Helper()
+		request, err := services.MyRequestService.ExecuteCurl(ctx, alias, mystructs.KVGroup{}, nil, false, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return request.ResponseBody
+	}
+
+	if got := run("endpoints1"); got != "manual=1" {
+		t.Errorf("login sent %q", got)
+	}
+	cookies, err := services.CookieService.List(ctx, project.Id, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cookies) != 3 {
+		t.Fatalf("stored %d cookies, want session, pref and scoped only", len(cookies))
+	}
+	if got := run("endpoints2"); got != "manual=1; session=s1; scoped=1; pref=dark" {
+		t.Errorf("/app/me sent %q", got)
+	}
+	if got := run("endpoints3"); got != "manual=1; pref=dark" {
+		t.Errorf("/other sent %q", got)
+	}
+	run("endpoints4")
+	if got := run("endpoints2"); got != "manual=1; scoped=1; pref=dark" {
+		t.Errorf("after logout /app/me sent %q", got)
+	}
+
+	matching, err := services.CookieService.List(ctx, project.Id, nil, &srv.URL)
+	if err != nil || len(matching) != 1 || matching[0].Name != "pref" {
+		t.Errorf("cookies for %s = %v, %v", srv.URL, matching, err)
+	}
+	if cleared, err := services.CookieService.Clear(ctx, project.Id, nil); err != nil || cleared != 2 {
+		t.Errorf("cleared %d, %v", cleared, err)
+	}
+}
+
+func TestCookieService_ImportNetscape(t *testing.T) {
+	services := newTestServices(t)
+	ctx := context.Background()
+	project, err := services.ProjectService.Create(ctx, &models.ProjectInput{Name: "jar"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := "# Netscape HTTP Cookie File\n" +
+		".example.com\tTRUE\t/\tTRUE\t0\tsid\tabc\n" +
+		"#HttpOnly_api.example.com\tFALSE\t/v1\tFALSE\t4102444800\ttoken\txyz\n" +
+		"old.example.com\tFALSE\t/\tFALSE\t1\texpired\tgone\n"
+	imported, err := services.CookieService.ImportNetscape(ctx, strings.NewReader(file), project.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if imported != 2 {
+		t.Fatalf("imported %d cookies", imported)
+	}
+
+	jar, err := services.CookieService.Jar(ctx, &project.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := jar.Header("https://api.example.com/v1/users", time.Now()); got != "token=xyz; sid=abc" {
+		t.Errorf("header = %q", got)
+	}
+	if got := jar.Header("http://www.example.com/", time.Now()); got != "" {
+		t.Errorf("secure cookie sent over http: %q", got)
+	}
+
+	edited, err := services.CookieService.Set(ctx, &models.CookieInput{ProjectId: project.Id, Name: "sid", Value: "new", Domain: ".example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if edited.Value != "new" || edited.HostOnly || edited.Secure {
+		t.Errorf("edited cookie = %+v", edited)
+	}
+	if _, err := services.CookieService.ImportNetscape(ctx, strings.NewReader("bad line\n"), project.Id); err == nil {
+		t.Error("expected an error for a malformed line")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 
 	"github.com/linn221/bane/models"
 	"github.com/linn221/bane/mystructs"
@@ -29,6 +30,23 @@ func (s *endpointService) Create(ctx context.Context, input *models.EndpointInpu
 		body = *input.Body
 	}
 
+	extractors := mystructs.KVGroup{}
+	if input.Extractors != nil {
+		extractors = *input.Extractors
+	}
+	if err := validateExtractors(extractors); err != nil {
+		return nil, err
+	}
+
+	rateLimit := utils.SafeDeref(input.RateLimit)
+	if err := rateLimit.Validate(); err != nil {
+		return nil, err
+	}
+	transport := utils.SafeDeref(input.Transport)
+	if err := transport.Validate(); err != nil {
+		return nil, err
+	}
+
 	// Serialize input to JSON for storage
 	inputJSON, err := json.Marshal(input)
 	if err != nil {
@@ -48,6 +66,10 @@ func (s *endpointService) Create(ctx context.Context, input *models.EndpointInpu
 		Headers:     input.Headers,
 		Body:        body,
 		Input:       string(inputJSON),
+		Extractors:  extractors,
+		NoProxy:     utils.SafeDeref(input.NoProxy),
+		RateLimit:   rateLimit,
+		Transport:   transport,
 	}
 
 	// Create the endpoint directly
@@ -70,6 +92,94 @@ func (s *endpointService) Create(ctx context.Context, input *models.EndpointInpu
 	return &endpoint, nil
 }
 
+// Patch updates the fields input sets, validated as Create validates them
+func (s *endpointService) Patch(ctx context.Context, id *int, alias *string, input *models.PatchEndpoint) (*models.Endpoint, error) {
+	endpoint, err := s.Get(ctx, id, alias)
+	if err != nil {
+		return nil, err
+	}
+	if input.Name != nil {
+		endpoint.Name = *input.Name
+	}
+	if input.Description != nil {
+		endpoint.Description = *input.Description
+	}
+	if input.Https != nil {
+		endpoint.Https = *input.Https
+	}
+	if input.Method != nil {
+		endpoint.Method = *input.Method
+	}
+	if input.Domain != nil {
+		endpoint.Domain = *input.Domain
+	}
+	if input.Path != nil {
+		endpoint.Path = *input.Path
+	}
+	if input.Queries != nil {
+		endpoint.Queries = *input.Queries
+	}
+	if input.Headers != nil {
+		endpoint.Headers = *input.Headers
+	}
+	if input.Body != nil {
+		endpoint.Body = *input.Body
+	}
+	if input.Extractors != nil {
+		if err := validateExtractors(*input.Extractors); err != nil {
+			return nil, err
+		}
+		endpoint.Extractors = *input.Extractors
+	}
+	if input.NoProxy != nil {
+		endpoint.NoProxy = *input.NoProxy
+	}
+	if input.RateLimit != nil {
+		if err := input.RateLimit.Validate(); err != nil {
+			return nil, err
+		}
+		endpoint.RateLimit = *input.RateLimit
+	}
+	if input.Transport != nil {
+		if err := input.Transport.Validate(); err != nil {
+			return nil, err
+		}
+		endpoint.Transport = *input.Transport
+	}
+
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(endpoint).Error; err != nil {
+			return err
+		}
+		if input.Alias == nil || *input.Alias == "" {
+			return nil
+		}
+		current, err := s.aliasService.getAliasByReference(tx, "endpoints", endpoint.Id)
+		if err != nil {
+			return err
+		}
+		return tx.Model(current).Update("name", *input.Alias).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return endpoint, nil
+}
+
+// validateExtractors checks the variable names and extraction rules of
+// "name:<kind>[.<argument>]" pairs
+func validateExtractors(extractors mystructs.KVGroup) error {
+	for _, extractor := range extractors.KVPairs {
+		if !variableName.MatchString(extractor.Key) {
+			return fmt.Errorf("invalid extractor variable name %q", extractor.Key)
+		}
+		if _, err := models.ParseExtraction(extractor.Value); err != nil {
+			return fmt.Errorf("extractor '%s': %v", extractor.Key, err)
+		}
+	}
+	return nil
+}
+
 func (s *endpointService) List(ctx context.Context, filter *models.EndpointFilter) ([]*models.Endpoint, error) {
 	query := s.db.WithContext(ctx).Model(&models.Endpoint{})
 
@@ -114,3 +224,97 @@ func (s *endpointService) Get(ctx context.Context, id *int, alias *string) (*mod
 	}
 	return nil, gorm.ErrRecordNotFound
 }
+
+// ImportCurl turns a curl command line into an EndpointInput, persisting it
+// through Create when create is true
+func (s *endpointService) ImportCurl(ctx context.Context, curl string, create bool) (*models.ImportedEndpoint, error) {
+	parsed, err := utils.ParseCurl(curl)
+	if err != nil {
+		return nil, err
+	}
+	return s.imported(ctx, &utils.PastedRequest{CurlRequest: parsed, Format: utils.RequestFormatCurl}, create)
+}
+
+// ImportRequest is ImportCurl for any pasted format utils.ParseRequest
+// detects. https is the scheme assumed for raw requests and bare URLs.
+func (s *endpointService) ImportRequest(ctx context.Context, text string, https bool, create bool) (*models.ImportedEndpoint, error) {
+	scheme := "http"
+	if https {
+		scheme = "https"
+	}
+	parsed, err := utils.ParseRequest(text, scheme)
+	if err != nil {
+		return nil, err
+	}
+	return s.imported(ctx, parsed, create)
+}
+
+func (s *endpointService) imported(ctx context.Context, parsed *utils.PastedRequest, create bool) (*models.ImportedEndpoint, error) {
+	input, err := endpointInput(&models.RenderedRequest{
+		Method:  parsed.Method,
+		Url:     parsed.Url,
+		Headers: parsed.Headers,
+		Body:    parsed.Body,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if parsed.Insecure || parsed.FollowRedirects {
+		input.Transport = &models.TransportOptions{}
+		if parsed.Insecure {
+			input.Transport.Insecure = &parsed.Insecure
+		}
+		if parsed.FollowRedirects {
+			input.Transport.FollowRedirects = &parsed.FollowRedirects
+		}
+	}
+	result := &models.ImportedEndpoint{
+		EndpointInput:   *input,
+		Format:          string(parsed.Format),
+		Insecure:        parsed.Insecure,
+		FollowRedirects: parsed.FollowRedirects,
+		Compressed:      parsed.Compressed,
+	}
+
+	if create {
+		endpoint, err := s.Create(ctx, &result.EndpointInput)
+		if err != nil {
+			return nil, err
+		}
+		result.Endpoint = endpoint
+	}
+	return result, nil
+}
+
+// Export renders the endpoint with the given variables in the requested format
+func (s *endpointService) Export(endpoint *models.Endpoint, format models.ExportFormat, variables *mystructs.KVGroup, fuzz *string) (string, error) {
+	return endpoint.Render(exportVariables(variables, fuzz)).Export(format)
+}
+
+// ExportPostman writes the project's endpoints as a Postman v2.1 collection.
+// Placeholders become {{name}} variables, defined on the collection with
+// their defaults.
+func (s *endpointService) ExportPostman(ctx context.Context, projectId int) (string, error) {
+	project, err := firstById[models.Project](s.db.WithContext(ctx), projectId)
+	if err != nil {
+		return "", err
+	}
+	var endpoints []*models.Endpoint
+	if err := s.db.WithContext(ctx).Where("project_id = ?", projectId).Order("id").Find(&endpoints).Error; err != nil {
+		return "", err
+	}
+
+	collection := utils.NewPostmanCollection(project.Name, project.Description)
+	for _, e := range endpoints {
+		name := e.Name
+		if name == "" {
+			name = string(e.Method) + " " + e.Path.OriginalString
+		}
+		collection.AddRequest(name, e.Description, string(e.Method), e.Https, e.Domain, e.Path, e.Queries, e.Headers, e.Body)
+	}
+	var b strings.Builder
+	if err := collection.Write(&b); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
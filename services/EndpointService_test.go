@@ -0,0 +1,65 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/linn221/bane/models"
+	"github.com/linn221/bane/mystructs"
+)
+
+func TestEndpointService_Patch(t *testing.T) {
+	services := newTestServices(t)
+	ctx := context.Background()
+	endpoint, err := services.EndpointService.Create(ctx, &models.EndpointInput{
+		Name: "login",
+		Url:  mustVarString(t, "https://example.com/login"),
+		RateLimit: &models.RateLimit{
+			Burst: ptr(3),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	invalid := []models.PatchEndpoint{
+		{Extractors: &mystructs.KVGroup{KVPairs: []mystructs.KVPair{{Key: "bad name", Value: "status"}}}},
+		{Extractors: &mystructs.KVGroup{KVPairs: []mystructs.KVPair{{Key: "token", Value: "nothing"}}}},
+		{RateLimit: &models.RateLimit{Burst: ptr(0)}},
+		{Transport: &models.TransportOptions{TimeoutMs: ptr(-1)}},
+	}
+	for i, input := range invalid {
+		if _, err := services.EndpointService.Patch(ctx, &endpoint.Id, nil, &input); err == nil {
+			t.Errorf("patch %d: expected an error", i)
+		}
+	}
+
+	extractors := mystructs.KVGroup{KVPairs: []mystructs.KVPair{{Key: "token", Value: "json.token"}}}
+	patched, err := services.EndpointService.Patch(ctx, nil, ptr("endpoints1"), &models.PatchEndpoint{
+		Alias:      ptr("login"),
+		Extractors: &extractors,
+		NoProxy:    ptr(true),
+		RateLimit:  &models.RateLimit{RequestsPerSecond: ptr(2.0)},
+		Transport:  &models.TransportOptions{TimeoutMs: ptr(500)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if patched.Name != "login" || patched.Domain != "example.com" {
+		t.Errorf("untouched fields changed: name=%q domain=%q", patched.Name, patched.Domain)
+	}
+
+	stored, err := services.EndpointService.Get(ctx, nil, ptr("login"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !stored.NoProxy || len(stored.Extractors.KVPairs) != 1 || stored.Extractors.KVPairs[0].Value != "json.token" {
+		t.Errorf("noProxy=%v extractors=%v", stored.NoProxy, stored.Extractors)
+	}
+	if stored.RateLimit.RequestsPerSecond == nil || *stored.RateLimit.RequestsPerSecond != 2 || stored.RateLimit.Burst != nil {
+		t.Errorf("rateLimit=%+v, want only requestsPerSecond 2", stored.RateLimit)
+	}
+	if stored.Transport.TimeoutMs == nil || *stored.Transport.TimeoutMs != 500 {
+		t.Errorf("transport=%+v", stored.Transport)
+	}
+}
@@ -0,0 +1,72 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/linn221/bane/models"
+	"gorm.io/gorm"
+)
+
+type environmentService struct {
+	db           *gorm.DB
+	aliasService *aliasService
+}
+
+func (s *environmentService) Create(ctx context.Context, input *models.EnvironmentInput) (*models.Environment, error) {
+	if _, err := firstById[models.Project](s.db.WithContext(ctx), input.ProjectId); err != nil {
+		return nil, fmt.Errorf("project %d not found: %v", input.ProjectId, err)
+	}
+	environment := models.Environment{
+		ProjectId: input.ProjectId,
+		Name:      input.Name,
+		Variables: derefKVGroup(input.Variables),
+	}
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&environment).Error; err != nil {
+			return err
+		}
+		return s.aliasService.CreateAlias(tx, "environments", environment.Id, input.Alias)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &environment, nil
+}
+
+func (s *environmentService) Get(ctx context.Context, id *int, alias *string) (*models.Environment, error) {
+	if id != nil {
+		return firstById[models.Environment](s.db.WithContext(ctx), *id)
+	}
+	if alias != nil {
+		return first[models.Environment](ctx, s.db, s.aliasService, *alias)
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (s *environmentService) List(ctx context.Context, projectId *int) ([]*models.Environment, error) {
+	query := s.db.WithContext(ctx).Model(&models.Environment{})
+	if projectId != nil {
+		query = query.Where("project_id = ?", *projectId)
+	}
+	var environments []*models.Environment
+	err := query.Order("project_id, name").Find(&environments).Error
+	return environments, err
+}
+
+// Variables returns the variables of the environment with the given alias, or
+// none when no alias is given. An environment can only be used for the
+// project it belongs to; projectId is nil for requests outside any project.
+func (s *environmentService) Variables(ctx context.Context, alias *string, projectId *int) (map[string]string, error) {
+	if alias == nil || *alias == "" {
+		return map[string]string{}, nil
+	}
+	environment, err := first[models.Environment](ctx, s.db, s.aliasService, *alias)
+	if err != nil {
+		return nil, fmt.Errorf("environment with alias '%s' not found: %v", *alias, err)
+	}
+	if projectId != nil && *projectId != environment.ProjectId {
+		return nil, fmt.Errorf("environment '%s' belongs to another project", *alias)
+	}
+	return environment.Variables.ToMap(), nil
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/linn221/bane/models"
+	"github.com/linn221/bane/mystructs"
+)
+
+func TestEnvironmentService_OverridesDefaults(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Header.Get("X-User")+" "+r.URL.Query().Get("id"))
+	}))
+	defer srv.Close()
+
+	services := newTestServices(t)
+	ctx := context.Background()
+	projects := make([]*models.Project, 2)
+	for i, name := range []string{"shop", "blog"} {
+		project, err := services.ProjectService.Create(ctx, &models.ProjectInput{Name: name})
+		if err != nil {
+			t.Fatal(err)
+		}
+		projects[i] = project
+	}
+	if _, err := services.EnvironmentService.Create(ctx, &models.EnvironmentInput{ProjectId: 99, Name: "x"}); err == nil {
+		t.Error("expected an error for an unknown project")
+	}
+	if _, err := services.EnvironmentService.Create(ctx, &models.EnvironmentInput{
+		ProjectId: projects[0].Id,
+		Name:      "staging",
+		Alias:     "staging",
+		Variables: mustKVGroup(t, "user:alice id:7"),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := services.EnvironmentService.Create(ctx, &models.EnvironmentInput{
+		ProjectId: projects[1].Id,
+		Name:      "prod",
+		Alias:     "blogprod",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := services.EndpointService.Create(ctx, &models.EndpointInput{
+		ProjectId: &projects[0].Id,
+		Url:       mustVarString(t, srv.URL+"/item?id={id=1}"),
+		Headers:   mustVarKVGroup(t, "X-User:{user=guest}"),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := services.VariableService.Set(ctx, "user", "stored", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		variables string
+		env       *string
+		want      string
+	}{
+		{"", nil, "stored 1"},
+		{"", ptr("staging"), "alice 7"},
+		{"id:8", ptr("staging"), "alice 8"},
+	}
+	for _, c := range cases {
+		variables, err := mystructs.NewKVGroupFromString(c.variables)
+		if err != nil {
+			t.Fatal(err)
+		}
+		request, err := services.MyRequestService.ExecuteCurl(ctx, "endpoints1", variables, c.env, false, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if request.ResponseBody != c.want {
+			t.Errorf("variables %q: body = %q, want %q", c.variables, request.ResponseBody, c.want)
+		}
+	}
+
+	if _, err := services.MyRequestService.ExecuteCurl(ctx, "endpoints1", mystructs.KVGroup{}, ptr("blogprod"), false, nil); err == nil {
+		t.Error("expected an error for an environment of another project")
+	}
+
+	environments, err := services.EnvironmentService.List(ctx, &projects[0].Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(environments) != 1 || environments[0].Name != "staging" {
+		t.Errorf("environments = %+v", environments)
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
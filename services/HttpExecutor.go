@@ -0,0 +1,378 @@
+package services
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/linn221/bane/models"
+	"github.com/linn221/bane/utils"
+)
+
+// httpExecutor sends rendered requests with net/http and records the exchange
+// as a MyRequest. Unless the transport options in the request's context say
+// otherwise, redirects are not followed, the same as a plain curl call.
+// Every request waits its turn with the host limiter.
+type httpExecutor struct {
+	client  *http.Client
+	proxy   *url.URL // the global upstream proxy, for requests whose context names none
+	limiter *hostLimiter
+
+	mu         sync.Mutex
+	transports map[transportKey]*http.Transport // variants of the client's transport
+}
+
+func newHttpExecutor() *httpExecutor {
+	x := &httpExecutor{limiter: &hostLimiter{}}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = x.proxyFor
+	x.client = &http.Client{
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	return x
+}
+
+type transportOptionsKey struct{}
+
+// withTransportOptions makes the executor send requests made with ctx with
+// options
+func withTransportOptions(ctx context.Context, options models.TransportOptions) context.Context {
+	return context.WithValue(ctx, transportOptionsKey{}, options)
+}
+
+func transportOptionsFrom(ctx context.Context) models.TransportOptions {
+	options, _ := ctx.Value(transportOptionsKey{}).(models.TransportOptions)
+	return options
+}
+
+// transportKey names the options that need a transport of their own
+type transportKey struct {
+	insecure        bool
+	version         models.HttpVersion
+	noDecompression bool
+}
+
+// clientFor returns a client sending with options. The transports differing
+// from the default one are made on first use and kept, so their connections
+// are reused.
+func (x *httpExecutor) clientFor(options models.TransportOptions) *http.Client {
+	key := transportKey{
+		insecure:        utils.SafeDeref(options.Insecure),
+		version:         utils.SafeDeref(options.HttpVersion),
+		noDecompression: utils.SafeDeref(options.NoDecompression),
+	}
+	client := *x.client
+	if key != (transportKey{}) {
+		x.mu.Lock()
+		transport, ok := x.transports[key]
+		if !ok {
+			transport = x.client.Transport.(*http.Transport).Clone()
+			if transport.TLSClientConfig == nil {
+				transport.TLSClientConfig = &tls.Config{}
+			}
+			transport.TLSClientConfig.InsecureSkipVerify = key.insecure
+			switch key.version {
+			case models.HttpVersion11:
+				transport.Protocols = new(http.Protocols)
+				transport.Protocols.SetHTTP1(true)
+				// the clone may already offer h2 in the TLS handshake
+				transport.TLSClientConfig.NextProtos = nil
+			case models.HttpVersion2:
+				transport.Protocols = new(http.Protocols)
+				transport.Protocols.SetHTTP2(true)
+				transport.Protocols.SetUnencryptedHTTP2(true)
+			}
+			transport.DisableCompression = key.noDecompression
+			if x.transports == nil {
+				x.transports = map[transportKey]*http.Transport{}
+			}
+			x.transports[key] = transport
+		}
+		x.mu.Unlock()
+		client.Transport = transport
+	}
+	if utils.SafeDeref(options.FollowRedirects) {
+		maxRedirects := utils.SafeDeref(options.MaxRedirects, models.DefaultMaxRedirects)
+		client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+			// stopping at the limit keeps the last redirect as the response
+			if len(via) > maxRedirects {
+				return http.ErrUseLastResponse
+			}
+			guard, _ := req.Context().Value(scopeGuardKey{}).(*scopeGuard)
+			if !guard.allows(req.Context(), req.URL) {
+				return fmt.Errorf("%w: redirected to %s", ErrOutOfScope, req.URL)
+			}
+			return nil
+		}
+	}
+	return &client
+}
+
+type proxyKey struct{}
+
+// withProxy makes the executor send requests made with ctx through proxy, or
+// directly when proxy is nil, instead of through the global proxy
+func withProxy(ctx context.Context, proxy *url.URL) context.Context {
+	return context.WithValue(ctx, proxyKey{}, proxy)
+}
+
+// proxyFor picks the request's upstream proxy. The transport keeps separate
+// connections per proxy, so requests with different proxies can share it.
+func (x *httpExecutor) proxyFor(req *http.Request) (*url.URL, error) {
+	if proxy, ok := req.Context().Value(proxyKey{}).(*url.URL); ok {
+		return proxy, nil
+	}
+	return x.proxy, nil
+}
+
+// Execute sends the request with the transport options in ctx, once the
+// rate limit in ctx allows, and returns the unsaved record of it. Redirects
+// are only followed within the scope guarding ctx. Transport failures are
+// recorded on the MyRequest rather than returned, so failed attempts still
+// show up in history.
+func (x *httpExecutor) Execute(ctx context.Context, rendered *models.RenderedRequest) *models.MyRequest {
+	record := &models.MyRequest{
+		RequestMethod:  rendered.Method,
+		RequestUrl:     rendered.Url,
+		RequestHeaders: serializeRequestHeaders(rendered),
+		RequestBody:    rendered.Body,
+		CurlCommand:    rendered.Curl(),
+		ExecutedAt:     time.Now(),
+	}
+
+	var body io.Reader
+	if rendered.Body != "" {
+		body = strings.NewReader(rendered.Body)
+	}
+	req, err := http.NewRequestWithContext(ctx, rendered.Method, rendered.Url, body)
+	if err != nil {
+		record.Error = fmt.Sprintf("invalid request: %v", err)
+		return record
+	}
+	for _, h := range rendered.Headers {
+		if strings.EqualFold(h.Key, "Host") {
+			req.Host = h.Value
+			continue
+		}
+		req.Header.Add(h.Key, h.Value)
+	}
+
+	release, err := x.limiter.acquire(ctx, req.URL.Hostname(), rateLimitFrom(ctx))
+	if err != nil {
+		record.Error = fmt.Sprintf("not sent while waiting for the rate limit: %v", err)
+		return record
+	}
+	record.ExecutedAt = time.Now()
+
+	options := transportOptionsFrom(ctx)
+	sendCtx := req.Context()
+	if options.TimeoutMs != nil {
+		var cancel context.CancelFunc
+		sendCtx, cancel = context.WithTimeout(sendCtx, time.Duration(*options.TimeoutMs)*time.Millisecond)
+		defer cancel()
+	}
+	timing := &traceTiming{}
+	req = req.WithContext(httptrace.WithClientTrace(sendCtx, timing.clientTrace()))
+
+	start := time.Now()
+	resp, err := x.clientFor(options).Do(req)
+	if err != nil {
+		release(nil)
+		record.Latency = time.Since(start).Milliseconds()
+		timing.fill(record)
+		record.Error = err.Error()
+		// a redirect that was refused comes back with the error
+		if resp != nil {
+			record.Redirects = redirectHops(resp)
+		}
+		return record
+	}
+	defer resp.Body.Close()
+	defer release(resp)
+	record.Redirects = redirectHops(resp.Request.Response)
+
+	raw, err := io.ReadAll(resp.Body)
+	record.Latency = time.Since(start).Milliseconds()
+	timing.fill(record)
+	if err != nil {
+		record.Error = fmt.Sprintf("failed to read response body: %v", err)
+	}
+	fillResponse(record, resp, raw, !utils.SafeDeref(options.NoDecompression))
+	record.Success = err == nil
+	return record
+}
+
+// redirectHops lists the redirects that led to last, and last itself, oldest
+// first
+func redirectHops(last *http.Response) []models.RedirectHop {
+	var hops []models.RedirectHop
+	for resp := last; resp != nil; resp = resp.Request.Response {
+		setCookies, _ := json.Marshal(append([]string{}, resp.Header.Values("Set-Cookie")...))
+		hops = append(hops, models.RedirectHop{
+			Url:        resp.Request.URL.String(),
+			Status:     resp.StatusCode,
+			Location:   resp.Header.Get("Location"),
+			SetCookies: string(setCookies),
+		})
+	}
+	slices.Reverse(hops)
+	for i := range hops {
+		hops[i].Position = i + 1
+	}
+	return hops
+}
+
+// fillResponse copies the response, with raw as its body as received, onto
+// the record, decoding a compressed body when decode is set
+func fillResponse(record *models.MyRequest, resp *http.Response, raw []byte, decode bool) {
+	responseBody := raw
+	if decode {
+		responseBody = decodeBody(resp, raw)
+	}
+	headersJSON, _ := json.Marshal(resp.Header)
+	record.ResponseStatus = resp.StatusCode
+	record.ResponseHeaders = string(headersJSON)
+	record.ResponseBody = string(responseBody)
+	record.ContentType = resp.Header.Get("Content-Type")
+	record.ContentLength = resp.ContentLength
+	if record.ContentLength < 0 {
+		record.ContentLength = int64(len(raw))
+	}
+	record.Size = int64(len(responseBody))
+	if resp.TLS != nil {
+		fillTls(record, resp.TLS)
+	}
+}
+
+// fillTls records the negotiated TLS parameters and the certificates the
+// server presented
+func fillTls(record *models.MyRequest, state *tls.ConnectionState) {
+	record.TlsVersion = tls.VersionName(state.Version)
+	record.TlsCipher = tls.CipherSuiteName(state.CipherSuite)
+	record.TlsAlpn = state.NegotiatedProtocol
+	for i, cert := range state.PeerCertificates {
+		sans := append([]string{}, cert.DNSNames...)
+		for _, ip := range cert.IPAddresses {
+			sans = append(sans, ip.String())
+		}
+		sans = append(sans, cert.EmailAddresses...)
+		for _, uri := range cert.URIs {
+			sans = append(sans, uri.String())
+		}
+		sansJSON, _ := json.Marshal(sans)
+		record.Certificates = append(record.Certificates, models.TlsCertificate{
+			Position:  i,
+			Subject:   cert.Subject.String(),
+			Issuer:    cert.Issuer.String(),
+			Sans:      string(sansJSON),
+			NotBefore: cert.NotBefore,
+			NotAfter:  cert.NotAfter,
+		})
+	}
+}
+
+// decodeBody undoes a gzip or deflate Content-Encoding that net/http left alone,
+// which happens whenever the endpoint sets its own Accept-Encoding header
+func decodeBody(resp *http.Response, raw []byte) []byte {
+	if resp.Uncompressed {
+		return raw
+	}
+	var reader io.ReadCloser
+	var err error
+	switch strings.ToLower(resp.Header.Get("Content-Encoding")) {
+	case "gzip":
+		reader, err = gzip.NewReader(bytes.NewReader(raw))
+	case "deflate":
+		reader = flate.NewReader(bytes.NewReader(raw))
+	default:
+		return raw
+	}
+	if err != nil {
+		return raw
+	}
+	defer reader.Close()
+	decoded, err := io.ReadAll(reader)
+	if err != nil {
+		return raw
+	}
+	return decoded
+}
+
+// serializeRequestHeaders converts the rendered headers to a JSON object
+func serializeRequestHeaders(rendered *models.RenderedRequest) string {
+	headerMap := make(map[string]string, len(rendered.Headers))
+	for _, h := range rendered.Headers {
+		headerMap[h.Key] = h.Value
+	}
+	jsonBytes, _ := json.Marshal(headerMap)
+	return string(jsonBytes)
+}
+
+// traceTiming collects the latency breakdown of a single request.
+// The transport may call the hooks from its own goroutines, hence the lock.
+type traceTiming struct {
+	mu           sync.Mutex
+	start        time.Time
+	dnsStart     time.Time
+	dnsDone      time.Time
+	connectStart time.Time
+	connectDone  time.Time
+	tlsStart     time.Time
+	tlsDone      time.Time
+	firstByte    time.Time
+}
+
+func (t *traceTiming) clientTrace() *httptrace.ClientTrace {
+	t.start = time.Now()
+	return &httptrace.ClientTrace{
+		DNSStart:             func(httptrace.DNSStartInfo) { t.mark(&t.dnsStart) },
+		DNSDone:              func(httptrace.DNSDoneInfo) { t.mark(&t.dnsDone) },
+		ConnectStart:         func(string, string) { t.mark(&t.connectStart) },
+		ConnectDone:          func(string, string, error) { t.mark(&t.connectDone) },
+		TLSHandshakeStart:    func() { t.mark(&t.tlsStart) },
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { t.mark(&t.tlsDone) },
+		GotFirstResponseByte: func() { t.mark(&t.firstByte) },
+	}
+}
+
+// mark records the first time a phase boundary is reached
+func (t *traceTiming) mark(at *time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if at.IsZero() {
+		*at = time.Now()
+	}
+}
+
+// fill copies the measured phases onto the record; phases skipped because of
+// a reused connection stay at zero
+func (t *traceTiming) fill(record *models.MyRequest) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	record.DnsLatency = elapsedMillis(t.dnsStart, t.dnsDone)
+	record.ConnectLatency = elapsedMillis(t.connectStart, t.connectDone)
+	record.TlsLatency = elapsedMillis(t.tlsStart, t.tlsDone)
+	record.Ttfb = elapsedMillis(t.start, t.firstByte)
+}
+
+func elapsedMillis(from, to time.Time) int64 {
+	if from.IsZero() || to.IsZero() {
+		return 0
+	}
+	return to.Sub(from).Milliseconds()
+}
@@ -0,0 +1,571 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/linn221/bane/models"
+	"github.com/linn221/bane/mystructs"
+)
+
+func TestHttpExecutor_RecordsRealResponse(t *testing.T) {
+	var gotBody, gotToken string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		gotBody = string(b)
+		gotToken = r.Header.Get("X-Token")
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Add("Set-Cookie", "a=1")
+		w.Header().Add("Set-Cookie", "b=2")
+		w.WriteHeader(http.StatusTeapot)
+		io.WriteString(w, `{"ok":false}`)
+	}))
+	defer srv.Close()
+
+	endpoint := &models.Endpoint{
+		Method:  models.HttpMethodPost,
+		Domain:  strings.TrimPrefix(srv.URL, "http://"),
+		Path:    mustVarString(t, "/items/{id=1}"),
+		Headers: mustVarKVGroup(t, "X-Token:{token=default}"),
+		Body:    mustVarString(t, `{"id":{id=1}}`),
+	}
+	record := newHttpExecutor().Execute(context.Background(), endpoint.Render(map[string]string{"id": "7", "token": "secret"}))
+
+	if !record.Success || record.Error != "" {
+		t.Fatalf("unexpected failure: %q", record.Error)
+	}
+	if record.ResponseStatus != http.StatusTeapot {
+		t.Errorf("status=%d want %d", record.ResponseStatus, http.StatusTeapot)
+	}
+	if record.RequestUrl != srv.URL+"/items/7" {
+		t.Errorf("url=%q", record.RequestUrl)
+	}
+	if gotBody != `{"id":7}` || gotToken != "secret" {
+		t.Errorf("server saw body=%q token=%q", gotBody, gotToken)
+	}
+	if record.ContentType != "application/json" || record.Size != int64(len(`{"ok":false}`)) {
+		t.Errorf("contentType=%q size=%d", record.ContentType, record.Size)
+	}
+	var headers http.Header
+	if err := json.Unmarshal([]byte(record.ResponseHeaders), &headers); err != nil {
+		t.Fatalf("response headers are not JSON: %v", err)
+	}
+	if got := headers.Values("Set-Cookie"); len(got) != 2 {
+		t.Errorf("Set-Cookie=%v want both cookies", got)
+	}
+	if !strings.HasPrefix(record.CurlCommand, "curl -X POST ") {
+		t.Errorf("curl=%q", record.CurlCommand)
+	}
+}
+
+func TestHttpExecutor_DoesNotFollowRedirects(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/elsewhere", http.StatusFound)
+	}))
+	defer srv.Close()
+
+	record := newHttpExecutor().Execute(context.Background(), &models.RenderedRequest{Method: "GET", Url: srv.URL})
+	if record.ResponseStatus != http.StatusFound {
+		t.Errorf("status=%d want %d", record.ResponseStatus, http.StatusFound)
+	}
+}
+
+func TestHttpExecutor_TransportOptions(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/hop/"):
+			n, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/hop/"))
+			if n > 0 {
+				http.Redirect(w, r, fmt.Sprintf("/hop/%d", n-1), http.StatusFound)
+				return
+			}
+		case r.URL.Path == "/slow":
+			time.Sleep(200 * time.Millisecond)
+		case r.URL.Path == "/gzip":
+			zw := gzip.NewWriter(w)
+			w.Header().Set("Content-Encoding", "gzip")
+			io.WriteString(zw, "hello")
+			zw.Close()
+			return
+		}
+		io.WriteString(w, r.Proto+" "+r.URL.Path)
+	})
+	srv := httptest.NewUnstartedServer(handler)
+	srv.Config.Protocols = new(http.Protocols)
+	srv.Config.Protocols.SetHTTP1(true)
+	srv.Config.Protocols.SetUnencryptedHTTP2(true)
+	srv.Start()
+	defer srv.Close()
+	tlsSrv := httptest.NewUnstartedServer(handler)
+	tlsSrv.EnableHTTP2 = true
+	tlsSrv.StartTLS()
+	defer tlsSrv.Close()
+
+	yes, one, two, timeout := true, 1, 2, 50
+	v11, v2 := models.HttpVersion11, models.HttpVersion2
+	send := func(options models.TransportOptions, rawUrl string) *models.MyRequest {
+		ctx := withTransportOptions(context.Background(), options)
+		return newHttpExecutor().Execute(ctx, &models.RenderedRequest{Method: "GET", Url: rawUrl})
+	}
+	tests := []struct {
+		name    string
+		options models.TransportOptions
+		url     string
+		status  int
+		body    string
+		failed  bool
+	}{
+		{"redirects are not followed by default", models.TransportOptions{}, srv.URL + "/hop/2", http.StatusFound, "", false},
+		{"redirects are followed", models.TransportOptions{FollowRedirects: &yes}, srv.URL + "/hop/2", http.StatusOK, "HTTP/1.1 /hop/0", false},
+		{"the hop limit keeps the last redirect", models.TransportOptions{FollowRedirects: &yes, MaxRedirects: &one}, srv.URL + "/hop/2", http.StatusFound, "", false},
+		{"within the hop limit", models.TransportOptions{FollowRedirects: &yes, MaxRedirects: &two}, srv.URL + "/hop/2", http.StatusOK, "HTTP/1.1 /hop/0", false},
+		{"timeout", models.TransportOptions{TimeoutMs: &timeout}, srv.URL + "/slow", 0, "", true},
+		{"certificates are verified", models.TransportOptions{}, tlsSrv.URL + "/", 0, "", true},
+		{"insecure negotiates HTTP/2", models.TransportOptions{Insecure: &yes}, tlsSrv.URL + "/", http.StatusOK, "HTTP/2.0 /", false},
+		{"forced HTTP/1.1", models.TransportOptions{Insecure: &yes, HttpVersion: &v11}, tlsSrv.URL + "/", http.StatusOK, "HTTP/1.1 /", false},
+		{"HTTP/2 with prior knowledge", models.TransportOptions{HttpVersion: &v2}, srv.URL + "/", http.StatusOK, "HTTP/2.0 /", false},
+		{"decompressed by default", models.TransportOptions{}, srv.URL + "/gzip", http.StatusOK, "hello", false},
+	}
+	for _, tt := range tests {
+		record := send(tt.options, tt.url)
+		if tt.failed {
+			if record.Success || record.Error == "" {
+				t.Errorf("%s: expected a failure, got status %d", tt.name, record.ResponseStatus)
+			}
+			continue
+		}
+		if !record.Success || record.ResponseStatus != tt.status || (tt.body != "" && record.ResponseBody != tt.body) {
+			t.Errorf("%s: status=%d body=%q err=%q", tt.name, record.ResponseStatus, record.ResponseBody, record.Error)
+		}
+	}
+
+	record := send(models.TransportOptions{NoDecompression: &yes}, srv.URL+"/gzip")
+	if zr, err := gzip.NewReader(strings.NewReader(record.ResponseBody)); err != nil {
+		t.Errorf("expected the gzip body as received, got %q", record.ResponseBody)
+	} else if body, _ := io.ReadAll(zr); string(body) != "hello" {
+		t.Errorf("gzip body = %q", body)
+	}
+
+	// a redirect leaving the scope is not followed
+	scope, err := models.NewScope(models.ScopeRules{{Kind: "domain", Value: "localhost"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	offsite := httptest.NewServer(http.RedirectHandler(srv.URL+"/", http.StatusFound))
+	defer offsite.Close()
+	ctx := withScopeGuard(withTransportOptions(context.Background(), models.TransportOptions{FollowRedirects: &yes}), &scopeGuard{scope: scope})
+	localhost := strings.Replace(offsite.URL, "127.0.0.1", "localhost", 1)
+	record = newHttpExecutor().Execute(ctx, &models.RenderedRequest{Method: "GET", Url: localhost})
+	if record.Success || !strings.Contains(record.Error, "out of scope") {
+		t.Errorf("followed a redirect out of scope: status=%d err=%q", record.ResponseStatus, record.Error)
+	}
+}
+
+func TestMyRequestService_RecordsRedirectsAndTls(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			http.SetCookie(w, &http.Cookie{Name: "state", Value: "s1", Path: "/"})
+			http.Redirect(w, r, "/callback?code=1", http.StatusFound)
+		case "/callback":
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc", Path: "/"})
+			http.Redirect(w, r, "/home", http.StatusSeeOther)
+		default:
+			io.WriteString(w, "home")
+		}
+	}))
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	defer srv.Close()
+
+	services := newTestServices(t)
+	ctx := context.Background()
+	project, err := services.ProjectService.Create(ctx, &models.ProjectInput{Name: "oauth"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	yes := true
+	endpoint, err := services.EndpointService.Create(ctx, &models.EndpointInput{
+		ProjectId: &project.Id,
+		Url:       mustVarString(t, srv.URL+"/login"),
+		Transport: &models.TransportOptions{FollowRedirects: &yes, Insecure: &yes},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	request, err := services.MyRequestService.ExecuteCurl(ctx, fmt.Sprintf("endpoints%d", endpoint.Id), mystructs.KVGroup{}, nil, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if request.ResponseBody != "home" {
+		t.Fatalf("body=%q err=%q", request.ResponseBody, request.Error)
+	}
+
+	hops, err := services.MyRequestService.Redirects(ctx, request.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hops) != 2 {
+		t.Fatalf("recorded %d hops", len(hops))
+	}
+	if hops[0].Position != 1 || hops[0].Url != srv.URL+"/login" || hops[0].Status != http.StatusFound || hops[0].Location != "/callback?code=1" {
+		t.Errorf("first hop = %+v", hops[0])
+	}
+	if cookies := hops[1].SetCookieValues(); hops[1].Status != http.StatusSeeOther || len(cookies) != 1 || cookies[0] != "session=abc; Path=/" {
+		t.Errorf("second hop = %+v", hops[1])
+	}
+	jar, err := services.CookieService.List(ctx, project.Id, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jar) != 2 {
+		t.Errorf("the jar took %d of the cookies set along the redirects", len(jar))
+	}
+
+	if request.TlsVersion != "TLS 1.3" || request.TlsCipher == "" || request.TlsAlpn != "h2" {
+		t.Errorf("tls version=%q cipher=%q alpn=%q", request.TlsVersion, request.TlsCipher, request.TlsAlpn)
+	}
+	certificates, err := services.MyRequestService.Certificates(ctx, request.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(certificates) != 1 || !slices.Contains(certificates[0].SanList(), "example.com") || !slices.Contains(certificates[0].SanList(), "127.0.0.1") {
+		t.Fatalf("certificates = %+v", certificates)
+	}
+	if leaf := srv.Certificate(); !certificates[0].NotAfter.Equal(leaf.NotAfter) || certificates[0].Issuer != leaf.Issuer.String() {
+		t.Errorf("certificate = %+v", certificates[0])
+	}
+}
+
+func TestHttpExecutor_DecodesExplicitGzip(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		io.WriteString(zw, "hello")
+		zw.Close()
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write(buf.Bytes())
+	}))
+	defer srv.Close()
+
+	rendered := &models.RenderedRequest{
+		Method:  "GET",
+		Url:     srv.URL,
+		Headers: []mystructs.KVPair{{Key: "Accept-Encoding", Value: "gzip"}},
+	}
+	record := newHttpExecutor().Execute(context.Background(), rendered)
+	if record.ResponseBody != "hello" {
+		t.Errorf("body=%q want decoded gzip", record.ResponseBody)
+	}
+}
+
+func TestHttpExecutor_RecordsTransportErrors(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	url := srv.URL
+	srv.Close()
+
+	record := newHttpExecutor().Execute(context.Background(), &models.RenderedRequest{Method: "GET", Url: url})
+	if record.Success || record.Error == "" || record.ResponseStatus != 0 {
+		t.Errorf("expected a failed record, got success=%v status=%d err=%q", record.Success, record.ResponseStatus, record.Error)
+	}
+}
+
+func TestHttpExecutor_UpstreamProxy(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "plain "+r.URL.Path)
+	}))
+	defer target.Close()
+	tlsTarget := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "tls "+r.URL.Path)
+	}))
+	defer tlsTarget.Close()
+	httpProxy := newFakeHttpProxy(t)
+	socksProxy := newFakeSocksProxy(t)
+
+	tests := []struct {
+		name   string
+		proxy  *fakeProxy
+		scheme string
+		url    string
+		body   string
+		seen   string
+	}{
+		{"http forward", httpProxy, "http", target.URL + "/a", "plain /a", "GET " + target.URL + "/a"},
+		{"https connect", httpProxy, "http", tlsTarget.URL + "/b", "tls /b", "CONNECT " + strings.TrimPrefix(tlsTarget.URL, "https://")},
+		{"socks5", socksProxy, "socks5", target.URL + "/c", "plain /c", strings.TrimPrefix(target.URL, "http://")},
+		{"socks5 to tls", socksProxy, "socks5h", tlsTarget.URL + "/d", "tls /d", strings.TrimPrefix(tlsTarget.URL, "https://")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			x := newHttpExecutor()
+			x.client.Transport.(*http.Transport).TLSClientConfig = tlsTarget.Client().Transport.(*http.Transport).TLSClientConfig
+			x.proxy = &url.URL{Scheme: tt.scheme, Host: tt.proxy.addr}
+
+			record := x.Execute(context.Background(), &models.RenderedRequest{Method: "GET", Url: tt.url})
+			if !record.Success || record.ResponseBody != tt.body {
+				t.Fatalf("body=%q err=%q", record.ResponseBody, record.Error)
+			}
+			if seen := tt.proxy.last(); seen != tt.seen {
+				t.Errorf("proxy saw %q want %q", seen, tt.seen)
+			}
+
+			// a context without a proxy sends the request directly
+			before := tt.proxy.count()
+			record = x.Execute(withProxy(context.Background(), nil), &models.RenderedRequest{Method: "GET", Url: tt.url})
+			if !record.Success || tt.proxy.count() != before {
+				t.Errorf("direct request went through the proxy: err=%q", record.Error)
+			}
+		})
+	}
+}
+
+func TestMyRequestService_ProxyPrecedence(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer target.Close()
+	global := newFakeHttpProxy(t)
+	projectProxy := newFakeHttpProxy(t)
+
+	services := newTestServices(t)
+	services.MyRequestService.executor.proxy = &url.URL{Scheme: "http", Host: global.addr}
+	ctx := context.Background()
+	proxied, err := services.ProjectService.Create(ctx, &models.ProjectInput{Name: "proxied", Proxy: projectProxy.addr})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if proxied.Proxy != "http://"+projectProxy.addr {
+		t.Errorf("stored proxy %q", proxied.Proxy)
+	}
+	plain, err := services.ProjectService.Create(ctx, &models.ProjectInput{Name: "plain"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := services.ProjectService.Create(ctx, &models.ProjectInput{Name: "bad", Proxy: "ftp://127.0.0.1:21"}); err == nil {
+		t.Error("expected an ftp proxy to be rejected")
+	}
+
+	endpoints := []struct {
+		projectId *int
+		noProxy   bool
+		via       *fakeProxy
+	}{
+		{&proxied.Id, false, projectProxy},
+		{&plain.Id, false, global},
+		{nil, false, global},
+		{&proxied.Id, true, nil},
+	}
+	for i, e := range endpoints {
+		endpoint, err := services.EndpointService.Create(ctx, &models.EndpointInput{
+			ProjectId: e.projectId,
+			Url:       mustVarString(t, fmt.Sprintf("%s/%d", target.URL, i)),
+			NoProxy:   &e.noProxy,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		globalBefore, projectBefore := global.count(), projectProxy.count()
+		request, err := services.MyRequestService.ExecuteCurl(ctx, fmt.Sprintf("endpoints%d", endpoint.Id), mystructs.KVGroup{}, nil, false, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !request.Success {
+			t.Fatalf("endpoint %d failed: %s", i, request.Error)
+		}
+		globalUsed, projectUsed := global.count() > globalBefore, projectProxy.count() > projectBefore
+		if globalUsed != (e.via == global) || projectUsed != (e.via == projectProxy) {
+			t.Errorf("endpoint %d: global used=%v, project proxy used=%v", i, globalUsed, projectUsed)
+		}
+	}
+}
+
+// fakeProxy records what each connection through it asked for
+type fakeProxy struct {
+	addr string
+	mu   sync.Mutex
+	seen []string
+}
+
+func (p *fakeProxy) record(s string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.seen = append(p.seen, s)
+}
+
+func (p *fakeProxy) last() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.seen) == 0 {
+		return ""
+	}
+	return p.seen[len(p.seen)-1]
+}
+
+func (p *fakeProxy) count() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.seen)
+}
+
+// newFakeHttpProxy forwards absolute-URI requests and tunnels CONNECT
+func newFakeHttpProxy(t *testing.T) *fakeProxy {
+	p := &fakeProxy{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.record(r.Method + " " + r.RequestURI)
+		if r.Method == http.MethodConnect {
+			upstream, err := net.Dial("tcp", r.Host)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadGateway)
+				return
+			}
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				upstream.Close()
+				return
+			}
+			io.WriteString(conn, "HTTP/1.1 200 Connection Established\r\n\r\n")
+			pipe(conn, upstream)
+			return
+		}
+		r.RequestURI = ""
+		resp, err := http.DefaultTransport.RoundTrip(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		defer resp.Body.Close()
+		for k, v := range resp.Header {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(resp.StatusCode)
+		io.Copy(w, resp.Body)
+	}))
+	t.Cleanup(srv.Close)
+	p.addr = strings.TrimPrefix(srv.URL, "http://")
+	return p
+}
+
+// newFakeSocksProxy is a SOCKS5 proxy without authentication that only
+// supports CONNECT
+func newFakeSocksProxy(t *testing.T) *fakeProxy {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	p := &fakeProxy{addr: ln.Addr().String()}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				target, err := socksHandshake(conn)
+				if err != nil {
+					conn.Close()
+					return
+				}
+				p.record(target)
+				upstream, err := net.Dial("tcp", target)
+				if err != nil {
+					conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+					conn.Close()
+					return
+				}
+				conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+				pipe(conn, upstream)
+			}()
+		}
+	}()
+	return p
+}
+
+// socksHandshake accepts the no-authentication method and returns the
+// address of the CONNECT request
+func socksHandshake(conn net.Conn) (string, error) {
+	r := bufio.NewReader(conn)
+	greeting := make([]byte, 2)
+	if _, err := io.ReadFull(r, greeting); err != nil {
+		return "", err
+	}
+	if _, err := io.ReadFull(r, make([]byte, greeting[1])); err != nil {
+		return "", err
+	}
+	conn.Write([]byte{5, 0})
+
+	request := make([]byte, 4)
+	if _, err := io.ReadFull(r, request); err != nil {
+		return "", err
+	}
+	var host string
+	switch request[3] {
+	case 1, 4:
+		ip := make([]byte, map[byte]int{1: 4, 4: 16}[request[3]])
+		if _, err := io.ReadFull(r, ip); err != nil {
+			return "", err
+		}
+		host = net.IP(ip).String()
+	case 3:
+		length, err := r.ReadByte()
+		if err != nil {
+			return "", err
+		}
+		name := make([]byte, length)
+		if _, err := io.ReadFull(r, name); err != nil {
+			return "", err
+		}
+		host = string(name)
+	default:
+		return "", fmt.Errorf("unsupported address type %d", request[3])
+	}
+	port := make([]byte, 2)
+	if _, err := io.ReadFull(r, port); err != nil {
+		return "", err
+	}
+	return net.JoinHostPort(host, fmt.Sprint(binary.BigEndian.Uint16(port))), nil
+}
+
+// pipe copies both ways until either side closes
+func pipe(a, b net.Conn) {
+	go func() {
+		io.Copy(a, b)
+		a.Close()
+	}()
+	io.Copy(b, a)
+	b.Close()
+}
+
+func mustVarString(t *testing.T, s string) mystructs.VarString {
+	t.Helper()
+	vs, err := mystructs.NewVarString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return *vs
+}
+
+func mustVarKVGroup(t *testing.T, s string) mystructs.VarKVGroup {
+	t.Helper()
+	var vkg mystructs.VarKVGroup
+	if err := vkg.UnmarshalGQL(s); err != nil {
+		t.Fatal(err)
+	}
+	return vkg
+}
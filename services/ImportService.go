@@ -0,0 +1,400 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/linn221/bane/models"
+	"github.com/linn221/bane/mystructs"
+	"github.com/linn221/bane/utils"
+	"gorm.io/gorm"
+)
+
+// importService turns requests recorded or described by other tools into
+// endpoints and request history
+type importService struct {
+	db               *gorm.DB
+	endpointService  *endpointService
+	myRequestService *myRequestService
+}
+
+// endpointKey identifies an endpoint for deduplication
+type endpointKey struct {
+	method models.HttpMethod
+	domain string
+	path   string
+}
+
+// endpointImport deduplicates the endpoints of one import against each other
+// and against the endpoints already stored for the project
+type endpointImport struct {
+	service   *importService
+	projectId *int
+	seen      map[endpointKey]*models.Endpoint
+	result    *models.ImportResult
+	lock      sync.Locker // held while an endpoint is found or created, if set
+}
+
+func (s *importService) newEndpointImport(projectId *int) *endpointImport {
+	return &endpointImport{
+		service:   s,
+		projectId: projectId,
+		seen:      map[endpointKey]*models.Endpoint{},
+		result:    &models.ImportResult{Endpoints: []*models.Endpoint{}, Requests: []*models.MyRequest{}},
+	}
+}
+
+// inTransaction runs fn with an import whose writes all go to one
+// transaction, so an import that fails partway leaves nothing behind. The
+// recorded requests are queued for scanning once it commits.
+func (s *importService) inTransaction(ctx context.Context, projectId *int, fn func(imp *endpointImport) error) (*models.ImportResult, error) {
+	var result *models.ImportResult
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		endpointService := *s.endpointService
+		endpointService.db = tx
+		myRequestService := *s.myRequestService
+		myRequestService.db = tx
+		myRequestService.inTransaction = true
+		txService := &importService{db: tx, endpointService: &endpointService, myRequestService: &myRequestService}
+
+		imp := txService.newEndpointImport(projectId)
+		if err := fn(imp); err != nil {
+			return err
+		}
+		result = imp.result
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, request := range result.Requests {
+		scanned := *request
+		s.myRequestService.issueService.Enqueue(&scanned)
+	}
+	return result, nil
+}
+
+// endpoint returns the stored endpoint with the input's method, domain and
+// path, creating it when there is none
+func (imp *endpointImport) endpoint(ctx context.Context, input *models.EndpointInput) (*models.Endpoint, error) {
+	parsedUrl, err := utils.ParseHttpUrl(input.Url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse url: %w", err)
+	}
+	key := endpointKey{
+		method: utils.SafeDeref(input.Method, models.HttpMethodGet),
+		domain: parsedUrl.HttpDomain,
+		path:   parsedUrl.HttpPath.OriginalString,
+	}
+	if endpoint, ok := imp.seen[key]; ok {
+		return endpoint, nil
+	}
+	if imp.lock != nil {
+		imp.lock.Lock()
+		defer imp.lock.Unlock()
+	}
+
+	query := imp.service.db.WithContext(ctx).
+		Where("http_method = ? AND http_domain = ? AND http_path = ?", key.method, key.domain, key.path)
+	if imp.projectId != nil {
+		query = query.Where("project_id = ?", *imp.projectId)
+	} else {
+		query = query.Where("project_id IS NULL")
+	}
+	var existing []*models.Endpoint
+	if err := query.Limit(1).Find(&existing).Error; err != nil {
+		return nil, err
+	}
+
+	var endpoint *models.Endpoint
+	if len(existing) > 0 {
+		endpoint = existing[0]
+		imp.result.Reused++
+	} else {
+		input.ProjectId = imp.projectId
+		if endpoint, err = imp.service.endpointService.Create(ctx, input); err != nil {
+			return nil, err
+		}
+		imp.result.Created++
+	}
+	imp.seen[key] = endpoint
+	imp.result.Endpoints = append(imp.result.Endpoints, endpoint)
+	return endpoint, nil
+}
+
+// record stores a recorded request under the endpoint it was sent to
+func (imp *endpointImport) record(ctx context.Context, rendered *models.RenderedRequest, record *models.MyRequest) error {
+	input, err := endpointInput(rendered)
+	if err != nil {
+		return err
+	}
+	endpoint, err := imp.endpoint(ctx, input)
+	if err != nil {
+		return err
+	}
+	record.EndpointId = endpoint.Id
+	if _, err := imp.service.myRequestService.Create(ctx, record); err != nil {
+		return err
+	}
+	imp.result.Requests = append(imp.result.Requests, record)
+	return nil
+}
+
+// ImportHar creates an endpoint for each distinct request of a HAR file and
+// records every entry, with its response, as a MyRequest of that endpoint
+func (s *importService) ImportHar(ctx context.Context, file io.Reader, projectId *int) (*models.ImportResult, error) {
+	har, err := utils.ParseHar(file)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.inTransaction(ctx, projectId, func(imp *endpointImport) error {
+		for i, entry := range har.Log.Entries {
+			rendered := &models.RenderedRequest{
+				Method:  entry.Request.Method,
+				Url:     entry.Request.RequestUrl(),
+				Headers: entry.Request.RequestHeaders(),
+				Body:    entry.Request.Body(),
+			}
+			if err := imp.record(ctx, rendered, harRecord(entry, rendered)); err != nil {
+				return fmt.Errorf("entry %d: %v", i+1, err)
+			}
+		}
+		return nil
+	})
+}
+
+// ImportBurp creates an endpoint for each distinct request of a Burp Suite
+// "Save items" file and records every item, with its response, as a MyRequest
+func (s *importService) ImportBurp(ctx context.Context, file io.Reader, projectId *int) (*models.ImportResult, error) {
+	items, err := utils.ParseBurp(file)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.inTransaction(ctx, projectId, func(imp *endpointImport) error {
+		for i, item := range items.Items {
+			rendered, record, err := burpRecord(item)
+			if err == nil {
+				err = imp.record(ctx, rendered, record)
+			}
+			if err != nil {
+				return fmt.Errorf("item %d: %v", i+1, err)
+			}
+		}
+		return nil
+	})
+}
+
+// ImportOpenApi creates an endpoint for each operation of an OpenAPI 2.0 or
+// 3.x document. Operations matching an existing endpoint are left alone.
+func (s *importService) ImportOpenApi(ctx context.Context, spec string, projectId *int, baseUrl string) (*models.ImportResult, error) {
+	operations, err := utils.ParseOpenApi(spec, baseUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.inTransaction(ctx, projectId, func(imp *endpointImport) error {
+		for _, op := range operations {
+			input, err := endpointInput(&models.RenderedRequest{
+				Method:  op.Method,
+				Url:     op.Url,
+				Headers: op.Headers,
+				Body:    op.Body,
+			})
+			if err != nil {
+				return fmt.Errorf("%s: %v", op.Name, err)
+			}
+			input.Name = op.Name
+			input.Description = op.Description
+			if _, err := imp.endpoint(ctx, input); err != nil {
+				return fmt.Errorf("%s: %v", op.Name, err)
+			}
+		}
+		return nil
+	})
+}
+
+// ImportPostman creates an endpoint for each request of a Postman v2.1
+// collection, with {{name}} variables kept as placeholders whose defaults come
+// from the environment, when given, and the collection variables
+func (s *importService) ImportPostman(ctx context.Context, file io.Reader, environment io.Reader, projectId *int) (*models.ImportResult, error) {
+	collection, err := utils.ParsePostmanCollection(file)
+	if err != nil {
+		return nil, err
+	}
+	var env *utils.PostmanEnvironment
+	if environment != nil {
+		if env, err = utils.ParsePostmanEnvironment(environment); err != nil {
+			return nil, err
+		}
+	}
+	operations, err := collection.Operations(env)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.inTransaction(ctx, projectId, func(imp *endpointImport) error {
+		for _, op := range operations {
+			input, err := endpointInput(&models.RenderedRequest{
+				Method:  op.Method,
+				Url:     op.Url,
+				Headers: op.Headers,
+				Body:    op.Body,
+			})
+			if err != nil {
+				return fmt.Errorf("%s: %v", op.Name, err)
+			}
+			input.Name = op.Name
+			input.Description = op.Description
+			if _, err := imp.endpoint(ctx, input); err != nil {
+				return fmt.Errorf("%s: %v", op.Name, err)
+			}
+		}
+		return nil
+	})
+}
+
+// endpointInput turns a concrete request into an EndpointInput. Anything that
+// looks like a {name} placeholder is kept as one.
+func endpointInput(rendered *models.RenderedRequest) (*models.EndpointInput, error) {
+	url, err := mystructs.NewVarString(rendered.Url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse url: %w", err)
+	}
+	headers, err := mystructs.NewVarKVGroup(rendered.Headers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse headers: %w", err)
+	}
+	method := models.HttpMethod(rendered.Method)
+	input := &models.EndpointInput{
+		Method:  &method,
+		Url:     *url,
+		Headers: headers,
+	}
+	if parsedUrl, err := utils.ParseHttpUrl(*url); err == nil {
+		input.Name = rendered.Method + " " + parsedUrl.HttpPath.OriginalString
+	}
+	if rendered.Body != "" {
+		body, err := mystructs.NewVarString(rendered.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse body: %w", err)
+		}
+		input.Body = body
+	}
+	return input, nil
+}
+
+// harRecord builds the MyRequest for a recorded HAR entry
+func harRecord(entry utils.HarEntry, rendered *models.RenderedRequest) *models.MyRequest {
+	headers := http.Header{}
+	for _, h := range entry.Response.Headers {
+		headers.Add(h.Name, h.Value)
+	}
+	headersJSON, _ := json.Marshal(headers)
+	body := entry.Response.Body()
+
+	record := &models.MyRequest{
+		RequestMethod:   rendered.Method,
+		RequestUrl:      rendered.Url,
+		RequestHeaders:  serializeRequestHeaders(rendered),
+		RequestBody:     rendered.Body,
+		CurlCommand:     rendered.Curl(),
+		ResponseStatus:  entry.Response.Status,
+		ResponseHeaders: string(headersJSON),
+		ResponseBody:    body,
+		ContentType:     entry.Response.Header("Content-Type"),
+		ContentLength:   entry.Response.Content.Size,
+		Latency:         harMillis(entry.Time),
+		DnsLatency:      harMillis(entry.Timings.Dns),
+		ConnectLatency:  harMillis(entry.Timings.Connect),
+		TlsLatency:      harMillis(entry.Timings.Ssl),
+		Ttfb:            harMillis(entry.Timings.Dns) + harMillis(entry.Timings.Connect) + harMillis(entry.Timings.Send) + harMillis(entry.Timings.Wait),
+		Size:            int64(len(body)),
+		ExecutedAt:      entry.StartedDateTime,
+		Variables:       "{}",
+		Success:         entry.Response.Status > 0,
+	}
+	if length, err := strconv.ParseInt(entry.Response.Header("Content-Length"), 10, 64); err == nil {
+		record.ContentLength = length
+	}
+	if !record.Success {
+		record.Error = "no response was recorded"
+	}
+	return record
+}
+
+// burpRecord parses the raw messages of a Burp item into the request to
+// replay and the MyRequest recording it
+func burpRecord(item utils.BurpItem) (*models.RenderedRequest, *models.MyRequest, error) {
+	rawRequest, err := item.Request.Decoded()
+	if err != nil {
+		return nil, nil, fmt.Errorf("request: %v", err)
+	}
+	req, err := utils.ParseRawRequest(rawRequest)
+	if err != nil {
+		return nil, nil, fmt.Errorf("request: %v", err)
+	}
+	url := item.Url.Text
+	if url == "" {
+		url = req.Url(item.Protocol, item.Host.Name)
+	}
+	rendered := &models.RenderedRequest{
+		Method:  req.Method,
+		Url:     url,
+		Headers: req.ReplayHeaders(url),
+		Body:    req.Body,
+	}
+
+	record := &models.MyRequest{
+		RequestMethod:  rendered.Method,
+		RequestUrl:     rendered.Url,
+		RequestHeaders: serializeRequestHeaders(rendered),
+		RequestBody:    rendered.Body,
+		CurlCommand:    rendered.Curl(),
+		ExecutedAt:     item.ParsedTime(),
+		Variables:      "{}",
+		Error:          "no response was recorded",
+	}
+	rawResponse, err := item.Response.Decoded()
+	if err != nil {
+		return nil, nil, fmt.Errorf("response: %v", err)
+	}
+	if rawResponse == "" {
+		return rendered, record, nil
+	}
+	resp, err := utils.ParseRawResponse(rawResponse)
+	if err != nil {
+		return nil, nil, fmt.Errorf("response: %v", err)
+	}
+	headers := http.Header{}
+	for _, h := range resp.Headers {
+		headers.Add(h.Key, h.Value)
+	}
+	headersJSON, _ := json.Marshal(headers)
+	record.ResponseStatus = resp.Status
+	record.ResponseHeaders = string(headersJSON)
+	record.ResponseBody = resp.Body
+	record.ContentType = resp.Header("Content-Type")
+	record.ContentLength = int64(len(resp.Body))
+	if length, err := strconv.ParseInt(resp.Header("Content-Length"), 10, 64); err == nil {
+		record.ContentLength = length
+	}
+	record.Size = int64(len(resp.Body))
+	record.Success = true
+	record.Error = ""
+	return rendered, record, nil
+}
+
+// harMillis rounds a HAR duration, where -1 means not applicable
+func harMillis(ms float64) int64 {
+	if ms < 0 {
+		return 0
+	}
+	return int64(ms + 0.5)
+}
@@ -0,0 +1,347 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/linn221/bane/models"
+)
+
+const testHar = `{"log": {"version": "1.2", "entries": [
+  {
+    "startedDateTime": "2024-05-01T10:00:00.000Z",
+    "time": 120.4,
+    "request": {
+      "method": "GET",
+      "url": "https://shop.example.com/api/items",
+      "headers": [{"name": ":authority", "value": "shop.example.com"}, {"name": "Accept", "value": "application/json"}],
+      "queryString": [{"name": "page", "value": "2"}],
+      "cookies": [{"name": "session", "value": "abc"}, {"name": "theme", "value": "dark"}]
+    },
+    "response": {
+      "status": 200,
+      "headers": [{"name": "content-type", "value": "application/json"}, {"name": "set-cookie", "value": "seen=1"}],
+      "content": {"size": 13, "mimeType": "application/json", "text": "eyJpdGVtcyI6W119", "encoding": "base64"}
+    },
+    "timings": {"dns": -1, "connect": 10, "ssl": 5, "send": 1, "wait": 100}
+  },
+  {
+    "startedDateTime": "2024-05-01T10:00:01.000Z",
+    "time": 80,
+    "request": {
+      "method": "GET",
+      "url": "https://shop.example.com/api/items?page=3",
+      "headers": [{"name": "Cookie", "value": "session=abc"}],
+      "queryString": [{"name": "page", "value": "3"}]
+    },
+    "response": {"status": 200, "headers": [], "content": {"size": 2, "text": "[]"}},
+    "timings": {"wait": 70}
+  },
+  {
+    "startedDateTime": "2024-05-01T10:00:02.000Z",
+    "time": 50,
+    "request": {
+      "method": "POST",
+      "url": "https://shop.example.com/login",
+      "headers": [{"name": "Content-Type", "value": "application/x-www-form-urlencoded"}, {"name": "Content-Length", "value": "20"}],
+      "postData": {"mimeType": "application/x-www-form-urlencoded", "params": [{"name": "user", "value": "a b"}, {"name": "pass", "value": "x"}]}
+    },
+    "response": {"status": 0, "headers": [], "content": {"size": 0, "text": ""}},
+    "timings": {}
+  }
+]}}`
+
+func TestImportService_ImportHar(t *testing.T) {
+	services := newTestServices(t)
+	ctx := context.Background()
+	project, err := services.ProjectService.Create(ctx, &models.ProjectInput{Name: "shop"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := services.ImportService.ImportHar(ctx, strings.NewReader(testHar), &project.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Created != 2 || result.Reused != 0 || len(result.Endpoints) != 2 || len(result.Requests) != 3 {
+		t.Fatalf("created=%d reused=%d endpoints=%d requests=%d", result.Created, result.Reused, len(result.Endpoints), len(result.Requests))
+	}
+
+	items := result.Endpoints[0]
+	if items.Method != models.HttpMethodGet || items.Domain != "shop.example.com" || items.Path.OriginalString != "/api/items" || *items.ProjectId != project.Id {
+		t.Errorf("endpoint = %s %s%s", items.Method, items.Domain, items.Path.OriginalString)
+	}
+	if got := items.Queries.Exec(); got != "page:2" {
+		t.Errorf("queries = %q", got)
+	}
+	if got := items.Headers.Exec(); got != "Accept:application/json Cookie:session=abc; theme=dark" {
+		t.Errorf("headers = %q", got)
+	}
+
+	first := result.Requests[0]
+	if first.EndpointId != items.Id || first.RequestUrl != "https://shop.example.com/api/items?page=2" {
+		t.Errorf("request = %d %s", first.EndpointId, first.RequestUrl)
+	}
+	if first.ResponseBody != `{"items":[]}` || first.ContentType != "application/json" || !first.Success {
+		t.Errorf("response body=%q type=%q", first.ResponseBody, first.ContentType)
+	}
+	if first.Latency != 120 || first.ConnectLatency != 10 || first.TlsLatency != 5 || first.Ttfb != 111 {
+		t.Errorf("latency=%d connect=%d tls=%d ttfb=%d", first.Latency, first.ConnectLatency, first.TlsLatency, first.Ttfb)
+	}
+	if !strings.Contains(first.ResponseHeaders, `"Set-Cookie":["seen=1"]`) {
+		t.Errorf("response headers = %s", first.ResponseHeaders)
+	}
+	if result.Requests[1].EndpointId != items.Id {
+		t.Errorf("second entry should reuse the first endpoint")
+	}
+
+	login := result.Requests[2]
+	if login.RequestBody != "user=a+b&pass=x" || strings.Contains(login.RequestHeaders, "Content-Length") {
+		t.Errorf("login body=%q headers=%s", login.RequestBody, login.RequestHeaders)
+	}
+	if login.Success || login.Error == "" {
+		t.Errorf("an entry without a response should be recorded as failed")
+	}
+
+	again, err := services.ImportService.ImportHar(ctx, strings.NewReader(testHar), &project.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Created != 0 || again.Reused != 2 || again.Endpoints[0].Id != items.Id {
+		t.Errorf("reimport created=%d reused=%d", again.Created, again.Reused)
+	}
+
+	if _, err := services.ImportService.ImportHar(ctx, strings.NewReader("not json"), nil); err == nil {
+		t.Error("expected an error for an invalid HAR file")
+	}
+
+	before := countImported(t, services)
+	partial := `{"log": {"version": "1.2", "entries": [
+  {"request": {"method": "GET", "url": "https://new.example.com/a", "headers": []}, "response": {"status": 200, "headers": [], "content": {"text": "a"}}, "timings": {}},
+  {"request": {"method": "GET", "url": "ftp://new.example.com/b", "headers": []}, "response": {"status": 200, "headers": [], "content": {"text": "b"}}, "timings": {}}
+]}}`
+	if _, err := services.ImportService.ImportHar(ctx, strings.NewReader(partial), &project.Id); err == nil || !strings.Contains(err.Error(), "entry 2") {
+		t.Fatalf("expected entry 2 to fail, got %v", err)
+	}
+	if after := countImported(t, services); after != before {
+		t.Errorf("a failed import left rows behind: %v, was %v", after, before)
+	}
+}
+
+// failEndpoint makes storing an endpoint with the given name fail
+func failEndpoint(t *testing.T, services *MyServices, name string) {
+	t.Helper()
+	err := services.ImportService.db.Exec("CREATE TRIGGER fail_endpoint BEFORE INSERT ON endpoints WHEN NEW.name = '" + name +
+		"' BEGIN SELECT RAISE(ABORT, 'refused'); END").Error
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// countImported counts the rows an import writes
+func countImported(t *testing.T, services *MyServices) [3]int64 {
+	t.Helper()
+	var counts [3]int64
+	for i, model := range []any{&models.Endpoint{}, &models.Alias{}, &models.MyRequest{}} {
+		if err := services.ImportService.db.Model(model).Count(&counts[i]).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	return counts
+}
+
+func TestImportService_BurpRoundTrip(t *testing.T) {
+	services := newTestServices(t)
+	ctx := context.Background()
+	har, err := services.ImportService.ImportHar(ctx, strings.NewReader(testHar), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make([]int, 0, len(har.Requests))
+	for _, r := range har.Requests {
+		ids = append(ids, r.Id)
+	}
+
+	xml, err := services.MyRequestService.ExportBurp(ctx, ids)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`<items exportTime=`, `<url><![CDATA[https://shop.example.com/api/items?page=2]]></url>`,
+		`<host ip="">shop.example.com</host>`, `<port>443</port>`, `<request base64="true">`, `<mimetype>JSON</mimetype>`} {
+		if !strings.Contains(xml, want) {
+			t.Errorf("export is missing %s:\n%s", want, xml)
+		}
+	}
+	if _, err := services.MyRequestService.ExportBurp(ctx, []int{ids[0], 999}); err == nil {
+		t.Error("expected an error for a missing request")
+	}
+
+	project, err := services.ProjectService.Create(ctx, &models.ProjectInput{Name: "burp"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	burp, err := services.ImportService.ImportBurp(ctx, strings.NewReader(xml), &project.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if burp.Created != 2 || len(burp.Requests) != 3 {
+		t.Fatalf("created=%d requests=%d", burp.Created, len(burp.Requests))
+	}
+	for i, got := range burp.Requests {
+		want := har.Requests[i]
+		if got.RequestMethod != want.RequestMethod || got.RequestUrl != want.RequestUrl || got.RequestBody != want.RequestBody {
+			t.Errorf("request %d = %s %s %q", i, got.RequestMethod, got.RequestUrl, got.RequestBody)
+		}
+		if got.ResponseStatus != want.ResponseStatus || got.ResponseBody != want.ResponseBody || got.Success != want.Success {
+			t.Errorf("response %d = %d %q", i, got.ResponseStatus, got.ResponseBody)
+		}
+		if !got.ExecutedAt.Equal(want.ExecutedAt) {
+			t.Errorf("time %d = %v, want %v", i, got.ExecutedAt, want.ExecutedAt)
+		}
+	}
+	if got := burp.Endpoints[0].Headers.Exec(); got != har.Endpoints[0].Headers.Exec() {
+		t.Errorf("headers = %q", got)
+	}
+
+	before := countImported(t, services)
+	broken := strings.Replace(xml, "</items>", `<item><request base64="true">!!!</request></item></items>`, 1)
+	if _, err := services.ImportService.ImportBurp(ctx, strings.NewReader(broken), nil); err == nil || !strings.Contains(err.Error(), "item 4") {
+		t.Fatalf("expected item 4 to fail, got %v", err)
+	}
+	if after := countImported(t, services); after != before {
+		t.Errorf("a failed import left rows behind: %v, was %v", after, before)
+	}
+}
+
+func TestImportService_ImportOpenApi(t *testing.T) {
+	services := newTestServices(t)
+	ctx := context.Background()
+	spec := `
+openapi: 3.0.0
+servers: [{url: "https://api.example.com"}]
+paths:
+  /users/{id}:
+    get:
+      operationId: getUser
+      parameters:
+        - {name: id, in: path, required: true, schema: {type: integer, example: 7}}
+        - {name: verbose, in: query, schema: {type: boolean}}
+    delete:
+      summary: Remove a user
+      parameters:
+        - {name: id, in: path, required: true, schema: {type: integer}}
+`
+	result, err := services.ImportService.ImportOpenApi(ctx, spec, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Created != 2 || result.Reused != 0 || len(result.Requests) != 0 {
+		t.Fatalf("created=%d reused=%d requests=%d", result.Created, result.Reused, len(result.Requests))
+	}
+	get := result.Endpoints[0]
+	if get.Name != "getUser" || get.Method != models.HttpMethodGet || get.Domain != "api.example.com" {
+		t.Errorf("endpoint = %s %s %s", get.Name, get.Method, get.Domain)
+	}
+	if get.Path.OriginalString != "/users/{id=7}" || get.Queries.Exec() != "verbose:true" {
+		t.Errorf("path = %s queries = %q", get.Path.OriginalString, get.Queries.Exec())
+	}
+	if del := result.Endpoints[1]; del.Method != models.HttpMethodDelete || del.Description != "Remove a user" {
+		t.Errorf("endpoint = %s %s", del.Method, del.Description)
+	}
+
+	again, err := services.ImportService.ImportOpenApi(ctx, spec, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Created != 0 || again.Reused != 2 {
+		t.Errorf("reimport created=%d reused=%d", again.Created, again.Reused)
+	}
+
+	failEndpoint(t, services, "deleteUser")
+	before := countImported(t, services)
+	if _, err := services.ImportService.ImportOpenApi(ctx, strings.ReplaceAll(spec, "/users/", "/accounts/")+`      operationId: deleteUser
+`, nil, ""); err == nil || !strings.Contains(err.Error(), "refused") {
+		t.Fatalf("expected the second operation to fail, got %v", err)
+	}
+	if after := countImported(t, services); after != before {
+		t.Errorf("a failed import left rows behind: %v, was %v", after, before)
+	}
+}
+
+func TestImportService_PostmanRoundTrip(t *testing.T) {
+	services := newTestServices(t)
+	ctx := context.Background()
+	collection := `{
+  "info": {"name": "Shop", "schema": "https://schema.getpostman.com/json/collection/v2.1.0/collection.json"},
+  "variable": [{"key": "host", "value": "shop.example.com"}, {"key": "id", "value": "7"}],
+  "item": [{"name": "Folder", "item": [
+    {"name": "Get item", "request": {"method": "GET", "header": [{"key": "X-Token", "value": "{{token}}"}],
+      "url": "https://{{host}}/items/{{id}}?q=x"}},
+    {"name": "Update item", "request": {"method": "PUT", "header": [],
+      "body": {"mode": "raw", "raw": "{\"id\": {{id}}}"}, "url": "https://{{host}}/items/{{id}}"}}
+  ]}]
+}`
+	env := `{"name": "dev", "values": [{"key": "token", "value": "t0k"}]}`
+	project, err := services.ProjectService.Create(ctx, &models.ProjectInput{Name: "shop", Description: "the shop"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := services.ImportService.ImportPostman(ctx, strings.NewReader(collection), strings.NewReader(env), &project.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Created != 2 || len(result.Endpoints) != 2 {
+		t.Fatalf("created=%d endpoints=%d", result.Created, len(result.Endpoints))
+	}
+	get := result.Endpoints[0]
+	if get.Name != "Folder / Get item" || get.Domain != "shop.example.com" || get.Path.OriginalString != "/items/{id=7}" {
+		t.Errorf("endpoint = %s %s%s", get.Name, get.Domain, get.Path.OriginalString)
+	}
+	if got := get.Headers.Exec(); got != "X-Token:t0k" {
+		t.Errorf("headers = %q", got)
+	}
+
+	exported, err := services.EndpointService.ExportPostman(ctx, project.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"name": "shop"`, `"raw": "https://shop.example.com/items/{{id}}?q=x"`,
+		`"key": "X-Token",`, `"value": "{{token}}"`, `"raw": "{\"id\": {{id}}}"`, `"key": "id",`, `"value": "7"`} {
+		if !strings.Contains(exported, want) {
+			t.Errorf("export is missing %s:\n%s", want, exported)
+		}
+	}
+
+	other, err := services.ProjectService.Create(ctx, &models.ProjectInput{Name: "copy"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := services.ImportService.ImportPostman(ctx, strings.NewReader(exported), nil, &other.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Created != 2 {
+		t.Fatalf("reimport created=%d", again.Created)
+	}
+	for i, e := range again.Endpoints {
+		want := result.Endpoints[i]
+		if e.Method != want.Method || e.Path.OriginalString != want.Path.OriginalString || e.Body.OriginalString != want.Body.OriginalString || e.Headers.Exec() != want.Headers.Exec() {
+			t.Errorf("endpoint %d = %s %s %q", i, e.Method, e.Path.OriginalString, e.Body.OriginalString)
+		}
+	}
+
+	if _, err := services.EndpointService.ExportPostman(ctx, 999); err == nil {
+		t.Error("expected an error for a missing project")
+	}
+
+	failEndpoint(t, services, "Folder / Update item")
+	before := countImported(t, services)
+	if _, err := services.ImportService.ImportPostman(ctx, strings.NewReader(collection), nil, nil); err == nil || !strings.Contains(err.Error(), "refused") {
+		t.Fatalf("expected the second request to fail, got %v", err)
+	}
+	if after := countImported(t, services); after != before {
+		t.Errorf("a failed import left rows behind: %v, was %v", after, before)
+	}
+}
@@ -0,0 +1,236 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/linn221/bane/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type issueService struct {
+	db *gorm.DB
+
+	startQueue sync.Once
+	queue      chan *models.MyRequest
+	scanning   sync.WaitGroup // queued requests not scanned yet
+}
+
+// scanBatchSize is how many queued requests are scanned in one transaction
+const scanBatchSize = 100
+
+// Enqueue has a stored request scanned in the background, keeping the
+// passive rules and their writes off the path of sending requests. Queued
+// requests are scanned in batches, one transaction each.
+func (s *issueService) Enqueue(request *models.MyRequest) {
+	s.startQueue.Do(func() {
+		s.queue = make(chan *models.MyRequest, 4*scanBatchSize)
+		go s.scanQueued()
+	})
+	s.scanning.Add(1)
+	s.queue <- request
+}
+
+func (s *issueService) scanQueued() {
+	for request := range s.queue {
+		batch := []*models.MyRequest{request}
+	fill:
+		for len(batch) < scanBatchSize {
+			select {
+			case request := <-s.queue:
+				batch = append(batch, request)
+			default:
+				break fill
+			}
+		}
+		ctx := context.Background()
+		if _, err := s.scanAll(ctx, batch); err != nil {
+			// one request the batch fails on should not lose the others
+			for _, request := range batch {
+				if _, err := s.Scan(ctx, request); err != nil {
+					log.Printf("passive scan of request %d: %v", request.Id, err)
+				}
+			}
+		}
+		s.scanning.Add(-len(batch))
+	}
+}
+
+// Scan runs the passive rules over a stored request and records what they
+// find, linking the request to issues found before. It returns the issues
+// the request has.
+func (s *issueService) Scan(ctx context.Context, request *models.MyRequest) ([]*models.Issue, error) {
+	return s.scanAll(ctx, []*models.MyRequest{request})
+}
+
+// scanAll is Scan for several requests, recorded in one transaction. It
+// returns the issues any of them has.
+func (s *issueService) scanAll(ctx context.Context, requests []*models.MyRequest) ([]*models.Issue, error) {
+	type scanned struct {
+		request  *models.MyRequest
+		findings []models.Finding
+	}
+	var results []scanned
+	var endpointIds []int
+	for _, request := range requests {
+		if findings := models.PassiveScan(request); len(findings) > 0 {
+			results = append(results, scanned{request, findings})
+			endpointIds = append(endpointIds, request.EndpointId)
+		}
+	}
+	if len(results) == 0 {
+		return nil, nil
+	}
+	var endpoints []models.Endpoint
+	if err := s.db.WithContext(ctx).Select("id", "project_id").Where("id IN ?", endpointIds).Find(&endpoints).Error; err != nil {
+		return nil, err
+	}
+	projectIds := make(map[int]*int, len(endpoints))
+	for _, endpoint := range endpoints {
+		projectIds[endpoint.Id] = endpoint.ProjectId
+	}
+
+	var issues []*models.Issue
+	seen := map[int]bool{}
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, r := range results {
+			projectId, ok := projectIds[r.request.EndpointId]
+			if !ok {
+				return fmt.Errorf("endpoint %d of request %d: %w", r.request.EndpointId, r.request.Id, gorm.ErrRecordNotFound)
+			}
+			for _, f := range r.findings {
+				issue, err := s.record(tx, projectId, r.request, f)
+				if err != nil {
+					return err
+				}
+				if !seen[issue.Id] {
+					seen[issue.Id] = true
+					issues = append(issues, issue)
+				}
+			}
+		}
+		return nil
+	})
+	return issues, err
+}
+
+// record stores a finding of request as an issue, or links the request to
+// the issue it was found as before
+func (s *issueService) record(tx *gorm.DB, projectId *int, request *models.MyRequest, f models.Finding) (*models.Issue, error) {
+	host := models.RequestHost(request.RequestUrl)
+	issue := models.Issue{
+		Fingerprint:   models.IssueFingerprint(projectId, host, f),
+		Rule:          f.Rule,
+		Severity:      f.Severity,
+		Title:         f.Title,
+		Detail:        f.Detail,
+		Evidence:      f.Evidence,
+		Host:          host,
+		ProjectId:     projectId,
+		EndpointId:    request.EndpointId,
+		MyRequestId:   request.Id,
+		LastRequestId: request.Id,
+	}
+	err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&issue).Error
+	if err != nil {
+		return nil, err
+	}
+	if issue.Id == 0 {
+		if err := tx.Where("fingerprint = ?", issue.Fingerprint).Take(&issue).Error; err != nil {
+			return nil, err
+		}
+	}
+	link := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.IssueRequest{IssueId: issue.Id, MyRequestId: request.Id})
+	if link.Error != nil {
+		return nil, link.Error
+	}
+	if link.RowsAffected == 0 {
+		// scanned before
+		return &issue, nil
+	}
+	err = tx.Model(&issue).UpdateColumns(map[string]any{
+		"requests":        gorm.Expr("requests + 1"),
+		"last_request_id": gorm.Expr("CASE WHEN last_request_id > ? THEN last_request_id ELSE ? END", request.Id, request.Id),
+		"updated_at":      time.Now(),
+	}).Error
+	return &issue, err
+}
+
+// ScanHistory runs the passive rules over the stored requests matching
+// filter and returns how many issues they were found to have
+func (s *issueService) ScanHistory(ctx context.Context, filter *models.MyRequestFilter) (int, error) {
+	query, err := filterMyRequests(s.db.WithContext(ctx), filter)
+	if err != nil {
+		return 0, err
+	}
+	var ids []int
+	if err := query.Model(&models.MyRequest{}).Order("id").Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+	found := map[int]bool{}
+	for start := 0; start < len(ids); start += scanBatchSize {
+		var requests []*models.MyRequest
+		err := s.db.WithContext(ctx).Where("id IN ?", ids[start:min(start+scanBatchSize, len(ids))]).Order("id").Find(&requests).Error
+		if err != nil {
+			return 0, err
+		}
+		if err := models.LoadResponseBodies(s.db.WithContext(ctx), requests...); err != nil {
+			return 0, err
+		}
+		issues, err := s.scanAll(ctx, requests)
+		if err != nil {
+			return 0, err
+		}
+		for _, issue := range issues {
+			found[issue.Id] = true
+		}
+	}
+	return len(found), nil
+}
+
+// List returns the issues matching filter, the most severe first
+func (s *issueService) List(ctx context.Context, filter *models.IssueFilter) ([]*models.Issue, error) {
+	query := s.db.WithContext(ctx)
+	if filter != nil {
+		if filter.ProjectId != nil {
+			query = query.Where("project_id = ?", *filter.ProjectId)
+		}
+		if filter.EndpointId != nil {
+			query = query.Where("endpoint_id = ?", *filter.EndpointId)
+		}
+		if filter.RequestId != nil {
+			query = query.Where("id IN (?)", s.db.Model(&models.IssueRequest{}).Select("issue_id").Where("my_request_id = ?", *filter.RequestId))
+		}
+		if filter.Rule != nil {
+			query = query.Where("rule = ?", *filter.Rule)
+		}
+		if filter.Severity != nil {
+			query = query.Where("severity IN ?", filter.Severity.AtLeast())
+		}
+		if filter.Limit > 0 {
+			query = query.Limit(filter.Limit)
+		}
+		if filter.Offset > 0 {
+			query = query.Offset(filter.Offset)
+		}
+	}
+	var issues []*models.Issue
+	err := query.Order(clause.OrderBy{Expression: clause.Expr{
+		SQL:  "CASE severity WHEN ? THEN 0 WHEN ? THEN 1 WHEN ? THEN 2 ELSE 3 END, id",
+		Vars: []any{models.IssueSeverityHigh, models.IssueSeverityMedium, models.IssueSeverityLow},
+	}}).Find(&issues).Error
+	return issues, err
+}
+
+// Requests returns the requests an issue was found in, oldest first
+func (s *issueService) Requests(ctx context.Context, issueId int) ([]*models.MyRequest, error) {
+	var requests []*models.MyRequest
+	err := s.db.WithContext(ctx).
+		Where("id IN (?)", s.db.Model(&models.IssueRequest{}).Select("my_request_id").Where("issue_id = ?", issueId)).
+		Order("id").Find(&requests).Error
+	return requests, err
+}
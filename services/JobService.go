@@ -0,0 +1,335 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"maps"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/linn221/bane/models"
+	"gorm.io/gorm"
+)
+
+// jobService runs batches of requests in the background and tracks them as Jobs
+type jobService struct {
+	db                 *gorm.DB
+	aliasService       *aliasService
+	myRequestService   *myRequestService
+	environmentService *environmentService
+
+	mu      sync.Mutex
+	cancels map[int]context.CancelFunc
+	running sync.WaitGroup
+}
+
+// maxJobRateLimit is the highest rateLimit a job can be given, in requests
+// per second
+const maxJobRateLimit = 10000
+
+func (s *jobService) Get(ctx context.Context, id int) (*models.Job, error) {
+	var job models.Job
+	err := s.db.WithContext(ctx).First(&job, id).Error
+	return &job, err
+}
+
+func (s *jobService) List(ctx context.Context, filter *models.JobFilter) ([]*models.Job, error) {
+	query := s.db.WithContext(ctx).Model(&models.Job{})
+	if filter != nil {
+		if filter.Kind != "" {
+			query = query.Where("kind = ?", filter.Kind)
+		}
+		if filter.Status != "" {
+			query = query.Where("status = ?", filter.Status)
+		}
+	}
+	var jobs []*models.Job
+	err := query.Order("id DESC").Find(&jobs).Error
+	return jobs, err
+}
+
+// Fuzz sends the endpoint once per word of the wordlist, with the word in
+// place of the named variable. It returns as soon as the job is started.
+func (s *jobService) Fuzz(ctx context.Context, endpointAlias string, variable string, wordListAlias string, concurrency int, rateLimit int, env *string, ignoreScope bool) (*models.Job, error) {
+	payloads := []*models.AttackPayload{{Variable: variable, WordListAlias: wordListAlias}}
+	endpoint, positions, err := s.attackPositions(ctx, endpointAlias, models.AttackModeSniper, payloads)
+	if err != nil {
+		return nil, err
+	}
+	job := &models.Job{
+		Name:        fmt.Sprintf("fuzz %s %s with %s", endpointAlias, variable, wordListAlias),
+		Kind:        models.JobKindFuzz,
+		Description: jobDescription(concurrency, rateLimit, env),
+	}
+	return s.start(ctx, job, endpoint, models.AttackModeSniper, positions, concurrency, rateLimit, env, ignoreScope)
+}
+
+// Attack binds several placeholders to wordlists and combines them according
+// to the mode. It returns as soon as the job is started.
+func (s *jobService) Attack(ctx context.Context, endpointAlias string, mode models.AttackMode, payloads []*models.AttackPayload, concurrency int, rateLimit int, env *string, ignoreScope bool) (*models.Job, error) {
+	endpoint, positions, err := s.attackPositions(ctx, endpointAlias, mode, payloads)
+	if err != nil {
+		return nil, err
+	}
+	variables := make([]string, 0, len(positions))
+	for _, p := range positions {
+		variables = append(variables, p.Variable)
+	}
+	job := &models.Job{
+		Name:        fmt.Sprintf("%s %s on %s", mode, endpointAlias, strings.Join(variables, ",")),
+		Kind:        models.JobKindAttack,
+		Description: jobDescription(concurrency, rateLimit, env),
+	}
+	return s.start(ctx, job, endpoint, mode, positions, concurrency, rateLimit, env, ignoreScope)
+}
+
+func jobDescription(concurrency int, rateLimit int, env *string) string {
+	description := fmt.Sprintf("concurrency=%d rateLimit=%d", concurrency, rateLimit)
+	if env != nil && *env != "" {
+		description += " env=" + *env
+	}
+	return description
+}
+
+// AttackCount returns how many requests Attack would send with the same arguments
+func (s *jobService) AttackCount(ctx context.Context, endpointAlias string, mode models.AttackMode, payloads []*models.AttackPayload) (int, error) {
+	_, positions, err := s.attackPositions(ctx, endpointAlias, mode, payloads)
+	if err != nil {
+		return 0, err
+	}
+	return mode.Count(positions), nil
+}
+
+// attackPositions loads the endpoint and the words of every payload,
+// checking each variable against the endpoint's placeholders
+func (s *jobService) attackPositions(ctx context.Context, endpointAlias string, mode models.AttackMode, payloads []*models.AttackPayload) (*models.Endpoint, []models.AttackPosition, error) {
+	endpoint, err := first[models.Endpoint](ctx, s.db, s.aliasService, endpointAlias)
+	if err != nil {
+		return nil, nil, fmt.Errorf("endpoint with alias '%s' not found: %v", endpointAlias, err)
+	}
+	if len(payloads) == 0 {
+		return nil, nil, fmt.Errorf("at least one payload position is required")
+	}
+
+	positions := make([]models.AttackPosition, 0, len(payloads))
+	wordsByList := map[string][]string{}
+	seen := map[string]bool{}
+	for _, payload := range payloads {
+		if !endpoint.HasPlaceholder(payload.Variable) {
+			return nil, nil, fmt.Errorf("endpoint '%s' has no placeholder named '%s'", endpointAlias, payload.Variable)
+		}
+		if seen[payload.Variable] {
+			return nil, nil, fmt.Errorf("placeholder '%s' is given more than once", payload.Variable)
+		}
+		seen[payload.Variable] = true
+		if mode == models.AttackModeBatteringRam && payload.WordListAlias != payloads[0].WordListAlias {
+			return nil, nil, fmt.Errorf("battering ram uses one wordlist for every position")
+		}
+
+		words, ok := wordsByList[payload.WordListAlias]
+		if !ok {
+			if words, err = s.listWords(ctx, payload.WordListAlias); err != nil {
+				return nil, nil, err
+			}
+			wordsByList[payload.WordListAlias] = words
+		}
+		positions = append(positions, models.AttackPosition{Variable: payload.Variable, Words: words})
+	}
+	return endpoint, positions, nil
+}
+
+func (s *jobService) listWords(ctx context.Context, wordListAlias string) ([]string, error) {
+	wordList, err := first[models.WordList](ctx, s.db, s.aliasService, wordListAlias)
+	if err != nil {
+		return nil, fmt.Errorf("wordlist with alias '%s' not found: %v", wordListAlias, err)
+	}
+	var words []models.Word
+	if err := s.db.WithContext(ctx).Model(wordList).Order("words.id").Association("Words").Find(&words); err != nil {
+		return nil, err
+	}
+	if len(words) == 0 {
+		return nil, fmt.Errorf("wordlist '%s' is empty", wordListAlias)
+	}
+	result := make([]string, 0, len(words))
+	for _, word := range words {
+		result = append(result, word.Word)
+	}
+	return result, nil
+}
+
+// Cancel stops a running job; requests already in flight are still recorded
+func (s *jobService) Cancel(ctx context.Context, id int) (*models.Job, error) {
+	s.mu.Lock()
+	cancel, ok := s.cancels[id]
+	s.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("job %d is not running", id)
+	}
+	cancel()
+	return s.Get(ctx, id)
+}
+
+// start saves the job and sends one request per payload in the background,
+// storing the results from a single goroutine. Responses leave the
+// project's cookie jar alone.
+func (s *jobService) start(ctx context.Context, job *models.Job, endpoint *models.Endpoint, mode models.AttackMode, positions []models.AttackPosition, concurrency int, rateLimit int, env *string, ignoreScope bool) (*models.Job, error) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	if rateLimit < 0 || rateLimit > maxJobRateLimit {
+		return nil, fmt.Errorf("rateLimit must be between 0 and %d requests per second", maxJobRateLimit)
+	}
+	envVars, err := s.environmentService.Variables(ctx, env, endpoint.ProjectId)
+	if err != nil {
+		return nil, err
+	}
+	base := map[string]string{}
+	fillVariables(base, endpoint.Placeholders(), envVars)
+	guard, err := s.myRequestService.scopeService.guard(ctx, endpoint.ProjectId, ignoreScope)
+	if err != nil {
+		return nil, err
+	}
+	if err := guard.enforce(ctx, endpoint.Render(base).Url); err != nil {
+		return nil, err
+	}
+	jar, err := s.myRequestService.cookieService.Jar(ctx, endpoint.ProjectId)
+	if err != nil {
+		return nil, err
+	}
+	proxy, err := s.myRequestService.proxy(ctx, endpoint)
+	if err != nil {
+		return nil, err
+	}
+	limit, err := s.myRequestService.rateLimit(ctx, endpoint)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	job.JobDate = now
+	job.StartedAt = &now
+	job.Status = models.JobStatusRunning
+	job.EndpointId = &endpoint.Id
+	job.Total = mode.Count(positions)
+	if job.Total == 0 {
+		return nil, fmt.Errorf("the attack would send no requests")
+	}
+	if err := s.db.WithContext(ctx).Create(job).Error; err != nil {
+		return nil, err
+	}
+
+	sendCtx := withTransportOptions(withRateLimit(withProxy(context.Background(), proxy), limit), endpoint.Transport)
+	s.spawn(sendCtx, job.Id, func(ctx context.Context) {
+		s.run(ctx, *job, endpoint, withBase(base, mode.Payloads(positions)), jar, guard, concurrency, rateLimit)
+	})
+	return job, nil
+}
+
+// spawn runs the work of a saved job in the background, until it returns or
+// Cancel is called
+func (s *jobService) spawn(ctx context.Context, jobId int, work func(ctx context.Context)) {
+	runCtx, cancel := context.WithCancel(ctx)
+	s.mu.Lock()
+	if s.cancels == nil {
+		s.cancels = make(map[int]context.CancelFunc)
+	}
+	s.cancels[jobId] = cancel
+	s.mu.Unlock()
+
+	s.running.Add(1)
+	go func() {
+		defer s.running.Done()
+		defer func() {
+			s.mu.Lock()
+			delete(s.cancels, jobId)
+			s.mu.Unlock()
+			cancel()
+		}()
+		work(runCtx)
+	}()
+}
+
+// withBase lays each payload over a copy of the base variables
+func withBase(base map[string]string, payloads iter.Seq[map[string]string]) iter.Seq[map[string]string] {
+	if len(base) == 0 {
+		return payloads
+	}
+	return func(yield func(map[string]string) bool) {
+		for payload := range payloads {
+			vars := maps.Clone(base)
+			maps.Copy(vars, payload)
+			if !yield(vars) {
+				return
+			}
+		}
+	}
+}
+
+func (s *jobService) run(ctx context.Context, job models.Job, endpoint *models.Endpoint, payloads iter.Seq[map[string]string], jar models.CookieJar, guard *scopeGuard, concurrency int, rateLimit int) {
+	queue := make(chan map[string]string)
+	results := make(chan *models.MyRequest)
+
+	go func() {
+		defer close(queue)
+		var tick <-chan time.Time
+		if rateLimit > 0 {
+			ticker := time.NewTicker(time.Second / time.Duration(rateLimit))
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+		for vars := range payloads {
+			if tick != nil {
+				select {
+				case <-tick:
+				case <-ctx.Done():
+					return
+				}
+			}
+			select {
+			case queue <- vars:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var workers sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for vars := range queue {
+				results <- s.myRequestService.execute(ctx, endpoint, vars, jar, guard)
+			}
+		}()
+	}
+	go func() {
+		workers.Wait()
+		close(results)
+	}()
+
+	var storeErr error
+	for request := range results {
+		request.JobId = &job.Id
+		job.Done++
+		if !request.Success {
+			job.Failed++
+		}
+		if _, err := s.myRequestService.Create(context.Background(), request); err != nil && storeErr == nil {
+			storeErr = err
+		}
+		s.db.Model(&models.Job{Id: job.Id}).Updates(map[string]any{"done": job.Done, "failed": job.Failed})
+	}
+
+	finished := time.Now()
+	updates := map[string]any{"status": models.JobStatusCompleted, "finished_at": finished}
+	switch {
+	case storeErr != nil:
+		updates["status"] = models.JobStatusFailed
+		updates["error"] = storeErr.Error()
+	case ctx.Err() != nil:
+		updates["status"] = models.JobStatusCancelled
+	}
+	s.db.Model(&models.Job{Id: job.Id}).Updates(updates)
+}
@@ -0,0 +1,167 @@
+package services
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/linn221/bane/config"
+	"github.com/linn221/bane/models"
+	"github.com/linn221/bane/mystructs"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestJobService_Fuzz(t *testing.T) {
+	var mu sync.Mutex
+	seen := map[string]bool{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		seen[r.URL.Query().Get("q")] = true
+		mu.Unlock()
+		io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+
+	services := newTestServices(t)
+	ctx := context.Background()
+	endpoint, err := services.EndpointService.Create(ctx, &models.EndpointInput{
+		Url: mustVarString(t, srv.URL+"/search?q={q=x}"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wordList, err := services.WordService.CreateWordList(&models.WordListInput{Name: "fuzz"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	words := []string{"admin", "root", "test", "guest"}
+	if err := services.WordService.AddWordsToWordList(wordList.Id, words); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := services.JobService.Fuzz(ctx, "endpoints1", "missing", "wordlists1", 2, 0, nil, false); err == nil {
+		t.Error("expected an error for an unknown placeholder")
+	}
+	for _, rateLimit := range []int{-1, maxJobRateLimit + 1, 2000000000} {
+		if _, err := services.JobService.Fuzz(ctx, "endpoints1", "q", "wordlists1", 2, rateLimit, nil, false); err == nil {
+			t.Errorf("expected an error for rateLimit %d", rateLimit)
+		}
+	}
+	job, err := services.JobService.Fuzz(ctx, "endpoints1", "q", "wordlists1", 2, 0, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	services.JobService.running.Wait()
+
+	job, err = services.JobService.Get(ctx, job.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Status != models.JobStatusCompleted || job.Total != len(words) || job.Done != len(words) || job.Failed != 0 {
+		t.Errorf("job status=%s total=%d done=%d failed=%d", job.Status, job.Total, job.Done, job.Failed)
+	}
+	if job.FinishedAt == nil || *job.EndpointId != endpoint.Id {
+		t.Errorf("finishedAt=%v endpointId=%v", job.FinishedAt, job.EndpointId)
+	}
+	for _, w := range words {
+		if !seen[w] {
+			t.Errorf("word %q was not sent", w)
+		}
+	}
+	requests, err := services.MyRequestService.List(ctx, &models.MyRequestFilter{JobId: job.Id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(requests) != len(words) {
+		t.Errorf("stored %d requests, want %d", len(requests), len(words))
+	}
+}
+
+func TestJobService_LeavesCookieJarAlone(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: r.URL.Query().Get("q"), Path: "/"})
+		io.WriteString(w, r.Header.Get("Cookie"))
+	}))
+	defer srv.Close()
+
+	services := newTestServices(t)
+	ctx := context.Background()
+	project, err := services.ProjectService.Create(ctx, &models.ProjectInput{Name: "jar"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := services.EndpointService.Create(ctx, &models.EndpointInput{
+		ProjectId: &project.Id,
+		Url:       mustVarString(t, srv.URL+"/login?q={q=x}"),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := services.MyRequestService.ExecuteCurl(ctx, "endpoints1", mystructs.KVGroup{}, nil, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	wordList, err := services.WordService.CreateWordList(&models.WordListInput{Name: "fuzz"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := services.WordService.AddWordsToWordList(wordList.Id, []string{"admin", "guest"}); err != nil {
+		t.Fatal(err)
+	}
+
+	job, err := services.JobService.Fuzz(ctx, "endpoints1", "q", "wordlists1", 1, 0, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	services.JobService.running.Wait()
+
+	requests, err := services.MyRequestService.List(ctx, &models.MyRequestFilter{JobId: job.Id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := models.LoadResponseBodies(services.JobService.db, requests...); err != nil {
+		t.Fatal(err)
+	}
+	for _, request := range requests {
+		if request.ResponseBody != "session=x" {
+			t.Errorf("job request sent %q, want the jar from before the job", request.ResponseBody)
+		}
+	}
+	cookies, err := services.CookieService.List(ctx, project.Id, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cookies) != 1 || cookies[0].Value != "x" {
+		t.Errorf("project jar after the job = %v", cookies)
+	}
+}
+
+// newTestServices returns services backed by a fresh SQLite database
+func newTestServices(t *testing.T) *MyServices {
+	t.Helper()
+	db, err := gorm.Open(config.SQLite(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.AutoMigrate(&models.Endpoint{}, &models.Job{}, &models.WordList{}, &models.Word{},
+		&models.Project{}, &models.MyRequest{}, &models.Alias{}, &models.Note{},
+		&models.Request{}, &models.Sequence{}, &models.SequenceStep{}, &models.Variable{},
+		&models.Environment{}, &models.Cookie{}, &models.RedirectHop{}, &models.TlsCertificate{},
+		&models.ResponseBlob{}, &models.Issue{}, &models.IssueRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	services := NewMyServices(db, nil)
+	t.Cleanup(func() {
+		services.IssueService.scanning.Wait()
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return services
+}
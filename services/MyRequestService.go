@@ -3,25 +3,44 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"os/exec"
+	"net/url"
+	"path"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/linn221/bane/models"
 	"github.com/linn221/bane/mystructs"
+	"github.com/linn221/bane/utils"
 	"gorm.io/gorm"
 )
 
 type myRequestService struct {
-	db *gorm.DB
+	db                 *gorm.DB
+	aliasService       *aliasService
+	variableService    *variableService
+	environmentService *environmentService
+	cookieService      *cookieService
+	scopeService       *scopeService
+	issueService       *issueService
+	executor           *httpExecutor
+	maxBodySize        int  // bytes of a response body stored, 0 for all of them
+	inTransaction      bool // Create leaves queueing the scan to whoever commits
 }
 
 // Create creates a new MyRequest record
 func (s *myRequestService) Create(ctx context.Context, request *models.MyRequest) (*models.MyRequest, error) {
+	request.TruncateBody(s.maxBodySize)
 	if err := s.db.WithContext(ctx).Create(request).Error; err != nil {
 		return nil, err
 	}
+	if !s.inTransaction {
+		// a copy, as the caller goes on using request
+		scanned := *request
+		s.issueService.Enqueue(&scanned)
+	}
 	return request, nil
 }
 
@@ -38,225 +57,391 @@ func (s *myRequestService) Get(ctx context.Context, id *int) (*models.MyRequest,
 // List retrieves MyRequests with optional filtering
 func (s *myRequestService) List(ctx context.Context, filter *models.MyRequestFilter) ([]*models.MyRequest, error) {
 	var requests []*models.MyRequest
-	query := s.db.WithContext(ctx).Preload("Endpoint")
-
+	query, err := filterMyRequests(s.db.WithContext(ctx).Preload("Endpoint"), filter)
+	if err != nil {
+		return nil, err
+	}
 	if filter != nil {
-		if filter.EndpointId != 0 {
-			query = query.Where("endpoint_id = ?", filter.EndpointId)
-		}
-		if filter.Success != nil {
-			query = query.Where("success = ?", *filter.Success)
-		}
-		if filter.StatusMin != 0 {
-			query = query.Where("response_status >= ?", filter.StatusMin)
-		}
-		if filter.StatusMax != 0 {
-			query = query.Where("response_status <= ?", filter.StatusMax)
+		if filter.Limit > 0 {
+			query = query.Limit(filter.Limit)
 		}
-		if filter.DateFrom != "" {
-			query = query.Where("executed_at >= ?", filter.DateFrom)
-		}
-		if filter.DateTo != "" {
-			query = query.Where("executed_at <= ?", filter.DateTo)
+		if filter.Offset > 0 {
+			query = query.Offset(filter.Offset)
 		}
 	}
 
-	err := query.Order("executed_at DESC").Find(&requests).Error
+	order, err := models.RequestSortOrder(utils.SafeDeref(filter).Sort)
+	if err != nil {
+		return nil, err
+	}
+	err = query.Order(order).Find(&requests).Error
 	return requests, err
 }
 
-// ExecuteCurl runs a curl command and captures the response
-func (s *myRequestService) ExecuteCurl(ctx context.Context, endpointAlias string, variables mystructs.VarKVGroup) (*models.MyRequest, error) {
-	// Find endpoint by alias
-	var endpoint models.Endpoint
-	err := s.db.WithContext(ctx).Where("alias = ?", endpointAlias).First(&endpoint).Error
-	if err != nil {
-		return nil, fmt.Errorf("endpoint with alias '%s' not found: %v", endpointAlias, err)
+// filterMyRequests adds the conditions of filter to query, leaving its
+// sorting and paging to the caller
+func filterMyRequests(query *gorm.DB, filter *models.MyRequestFilter) (*gorm.DB, error) {
+	if filter == nil {
+		return query, nil
 	}
-
-	// Generate curl command with variable injection
-	curlCommand, err := s.generateCurlCommand(&endpoint, variables)
-	if err != nil {
-		return nil, fmt.Errorf("failed to generate curl command: %v", err)
+	if filter.EndpointId != 0 {
+		query = query.Where("endpoint_id = ?", filter.EndpointId)
+	}
+	if filter.JobId != 0 {
+		query = query.Where("job_id = ?", filter.JobId)
+	}
+	if filter.Success != nil {
+		query = query.Where("success = ?", *filter.Success)
+	}
+	if filter.StatusMin != 0 {
+		query = query.Where("response_status >= ?", filter.StatusMin)
+	}
+	if filter.StatusMax != 0 {
+		query = query.Where("response_status <= ?", filter.StatusMax)
+	}
+	if filter.DateFrom != "" {
+		query = query.Where("executed_at >= ?", filter.DateFrom)
+	}
+	if filter.DateTo != "" {
+		query = query.Where("executed_at <= ?", filter.DateTo)
 	}
+	if filter.Query != "" {
+		parsed, err := models.ParseRequestQuery(filter.Query)
+		if err != nil {
+			return nil, err
+		}
+		query = parsed.Apply(query)
+	}
+	return query, nil
+}
 
-	// Execute curl command
-	startTime := time.Now()
-	cmd := exec.Command("bash", "-c", curlCommand)
-	output, err := cmd.CombinedOutput()
-	latency := time.Since(startTime).Milliseconds()
+// searchRequestsLimit is how many hits Search returns at most
+const searchRequestsLimit = 50
 
-	// Create MyRequest record
-	request := &models.MyRequest{
-		EndpointId:     endpoint.Id,
-		RequestMethod:  string(endpoint.Method),
-		RequestUrl:     s.buildUrl(&endpoint),
-		RequestHeaders: s.serializeHeaders(endpoint.Headers),
-		RequestBody:    endpoint.Body.Exec(),
-		Latency:        latency,
-		ExecutedAt:     time.Now(),
-		Variables:      s.serializeVariables(variables),
-		CurlCommand:    curlCommand,
-		Success:        err == nil,
+// Search finds the requests whose URL, headers or bodies match query, an
+// FTS5 expression, best matches first
+func (s *myRequestService) Search(ctx context.Context, query string, projectId *int) ([]*models.RequestSearchHit, error) {
+	if !models.RequestSearchReady(s.db) {
+		return nil, errors.New("full-text search is unavailable, bane must run on SQLite built with -tags sqlite_fts5")
 	}
-
+	var ranked []struct {
+		Id    int
+		Score float64
+	}
+	search := s.db.WithContext(ctx).Table(models.RequestSearchTable).
+		Select(models.RequestSearchTable+".rowid AS id, "+models.RequestSearchTable+".rank AS score").
+		Joins("JOIN my_requests ON my_requests.id = "+models.RequestSearchTable+".rowid").
+		Where(models.RequestSearchTable+" MATCH ?", query)
+	if projectId != nil {
+		search = search.Joins("JOIN endpoints ON endpoints.id = my_requests.endpoint_id").
+			Where("endpoints.project_id = ?", *projectId)
+	}
+	err := search.Order(models.RequestSearchTable + ".rank").Limit(searchRequestsLimit).Scan(&ranked).Error
 	if err != nil {
-		request.Error = string(output)
-		request.ResponseStatus = 0
-		request.ResponseBody = string(output)
-	} else {
-		// Parse curl output to extract response information
-		responseInfo := s.parseCurlOutput(string(output))
-		request.ResponseStatus = responseInfo.Status
-		request.ResponseHeaders = responseInfo.Headers
-		request.ResponseBody = responseInfo.Body
-		request.ContentType = responseInfo.ContentType
-		request.ContentLength = int64(len(responseInfo.Body))
-		request.Size = int64(len(responseInfo.Body))
+		return nil, fmt.Errorf("searching for %q: %w", query, err)
+	}
+	if len(ranked) == 0 {
+		return []*models.RequestSearchHit{}, nil
 	}
 
-	// Save to database
-	return s.Create(ctx, request)
+	ids := make([]int, len(ranked))
+	for i, r := range ranked {
+		ids[i] = r.Id
+	}
+	var requests []*models.MyRequest
+	if err := s.db.WithContext(ctx).Preload("Endpoint").Where("id IN ?", ids).Find(&requests).Error; err != nil {
+		return nil, err
+	}
+	if err := models.LoadResponseBodies(s.db.WithContext(ctx), requests...); err != nil {
+		return nil, err
+	}
+	byId := make(map[int]*models.MyRequest, len(requests))
+	for _, r := range requests {
+		byId[r.Id] = r
+	}
+	hits := make([]*models.RequestSearchHit, 0, len(ranked))
+	for _, r := range ranked {
+		if request, ok := byId[r.Id]; ok {
+			hits = append(hits, &models.RequestSearchHit{Request: request, Rank: r.Score, Snippets: request.SearchSnippets(query)})
+		}
+	}
+	return hits, nil
 }
 
-// generateCurlCommand creates a curl command from endpoint and variables
-func (s *myRequestService) generateCurlCommand(endpoint *models.Endpoint, variables mystructs.VarKVGroup) (string, error) {
-	// Inject variables into endpoint fields
-	path := endpoint.Path.Exec()
-	headers := endpoint.Headers
-	body := endpoint.Body.Exec()
+// Redirects returns the redirects the request went through, oldest first
+func (s *myRequestService) Redirects(ctx context.Context, requestId int) ([]*models.RedirectHop, error) {
+	var hops []*models.RedirectHop
+	err := s.db.WithContext(ctx).Where("my_request_id = ?", requestId).Order("position").Find(&hops).Error
+	return hops, err
+}
 
-	// Apply variable injection to path, headers, and body
-	for _, kv := range variables.VarKVs {
-		key := kv.Key.Exec()
-		value := kv.Value.Exec()
+// Certificates returns the certificate chain the request was answered with
+func (s *myRequestService) Certificates(ctx context.Context, requestId int) ([]*models.TlsCertificate, error) {
+	var certificates []*models.TlsCertificate
+	err := s.db.WithContext(ctx).Where("my_request_id = ?", requestId).Order("position").Find(&certificates).Error
+	return certificates, err
+}
 
-		// Replace variables in path
-		path = strings.ReplaceAll(path, "{"+key+"}", value)
+// ExecuteCurl renders the endpoint with the given variables, sends it and
+// stores the response
+func (s *myRequestService) ExecuteCurl(ctx context.Context, endpointAlias string, variables mystructs.KVGroup, env *string, ignoreScope bool, transport *models.TransportOptions) (*models.MyRequest, error) {
+	endpoint, err := first[models.Endpoint](ctx, s.db, s.aliasService, endpointAlias)
+	if err != nil {
+		return nil, fmt.Errorf("endpoint with alias '%s' not found: %v", endpointAlias, err)
+	}
+	options := utils.SafeDeref(transport).Over(endpoint.Transport)
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+	envVars, err := s.environmentService.Variables(ctx, env, endpoint.ProjectId)
+	if err != nil {
+		return nil, err
+	}
 
-		// Replace variables in headers
-		for i, header := range headers.VarKVs {
-			headers.VarKVs[i].Key = mystructs.VarString{OriginalString: strings.ReplaceAll(header.Key.Exec(), "{"+key+"}", value)}
-			headers.VarKVs[i].Value = mystructs.VarString{OriginalString: strings.ReplaceAll(header.Value.Exec(), "{"+key+"}", value)}
+	vars := variables.ToMap()
+	var missing []string
+	for _, name := range endpoint.Placeholders() {
+		_, given := vars[name]
+		_, inEnv := envVars[name]
+		if !given && !inEnv {
+			missing = append(missing, name)
 		}
-
-		// Replace variables in body
-		body = strings.ReplaceAll(body, "{"+key+"}", value)
 	}
-
-	// Build curl command
-	var curlParts []string
-	curlParts = append(curlParts, "curl")
-	curlParts = append(curlParts, "-X", string(endpoint.Method))
-
-	// Add URL
-	url := s.buildUrl(endpoint)
-	url = strings.ReplaceAll(url, endpoint.Path.Exec(), path)
-	curlParts = append(curlParts, fmt.Sprintf("'%s'", url))
-
-	// Add headers
-	for _, header := range headers.VarKVs {
-		curlParts = append(curlParts, "-H", fmt.Sprintf("'%s: %s'", header.Key.Exec(), header.Value.Exec()))
+	stored, err := s.variableService.Lookup(ctx, missing)
+	if err != nil {
+		return nil, err
 	}
+	fillVariables(vars, endpoint.Placeholders(), envVars, stored)
 
-	// Add body if present
-	if body != "" {
-		curlParts = append(curlParts, "-d", fmt.Sprintf("'%s'", body))
+	guard, err := s.scopeService.guard(ctx, endpoint.ProjectId, ignoreScope)
+	if err != nil {
+		return nil, err
+	}
+	if err := guard.enforce(ctx, endpoint.Render(vars).Url); err != nil {
+		return nil, err
+	}
+	jar, err := s.cookieService.Jar(ctx, endpoint.ProjectId)
+	if err != nil {
+		return nil, err
 	}
+	proxy, err := s.proxy(ctx, endpoint)
+	if err != nil {
+		return nil, err
+	}
+	limit, err := s.rateLimit(ctx, endpoint)
+	if err != nil {
+		return nil, err
+	}
+	sendCtx := withTransportOptions(withRateLimit(withProxy(ctx, proxy), limit), options)
+	request := s.execute(sendCtx, endpoint, vars, jar, guard)
+	if err := s.cookieService.Capture(ctx, endpoint.ProjectId, request); err != nil {
+		return nil, err
+	}
+	if err := s.extract(ctx, endpoint, request); err != nil {
+		return nil, err
+	}
+	return s.Create(ctx, request)
+}
 
-	// Add verbose output for parsing
-	curlParts = append(curlParts, "-v", "-s")
+// extract runs the endpoint's extractors against the response, saving each
+// value found to the variable store and recording them on the request.
+// Extractors that find nothing are skipped.
+func (s *myRequestService) extract(ctx context.Context, endpoint *models.Endpoint, request *models.MyRequest) error {
+	if !request.Success || len(endpoint.Extractors.KVPairs) == 0 {
+		return nil
+	}
+	resp := request.Response()
+	extracted := map[string]string{}
+	for _, extractor := range endpoint.Extractors.KVPairs {
+		extraction, err := models.ParseExtraction(extractor.Value)
+		if err != nil {
+			continue
+		}
+		value, err := extraction.Extract(resp)
+		if err != nil {
+			continue
+		}
+		if _, err := s.variableService.Set(ctx, extractor.Key, value, &endpoint.Id); err != nil {
+			return err
+		}
+		extracted[extractor.Key] = value
+	}
+	request.Extracted = serializeVariables(extracted)
+	return nil
+}
 
-	return strings.Join(curlParts, " "), nil
+// proxy returns the upstream proxy for the endpoint's requests: none when the
+// endpoint opts out, else its project's proxy, else the global one
+func (s *myRequestService) proxy(ctx context.Context, endpoint *models.Endpoint) (*url.URL, error) {
+	if endpoint.NoProxy {
+		return nil, nil
+	}
+	if endpoint.ProjectId != nil {
+		project, err := firstById[models.Project](s.db.WithContext(ctx), *endpoint.ProjectId)
+		if err != nil {
+			return nil, err
+		}
+		if project.Proxy != "" {
+			proxy, err := utils.ParseProxyUrl(project.Proxy)
+			if err != nil {
+				return nil, fmt.Errorf("project %s: %w", project.Name, err)
+			}
+			return proxy, nil
+		}
+	}
+	return s.executor.proxy, nil
 }
 
-// buildUrl constructs the full URL from endpoint
-func (s *myRequestService) buildUrl(endpoint *models.Endpoint) string {
-	schema := "http"
-	if endpoint.Https {
-		schema = "https"
-	}
-	domain := endpoint.Domain
-	path := endpoint.Path.Exec()
+// rateLimit returns the limit for the endpoint's requests: its own, with the
+// settings it leaves unset taken from its project
+func (s *myRequestService) rateLimit(ctx context.Context, endpoint *models.Endpoint) (models.RateLimit, error) {
+	limit := endpoint.RateLimit
+	if endpoint.ProjectId != nil {
+		project, err := firstById[models.Project](s.db.WithContext(ctx), *endpoint.ProjectId)
+		if err != nil {
+			return limit, err
+		}
+		limit = limit.Over(project.RateLimit)
+	}
+	return limit, nil
+}
 
-	return fmt.Sprintf("%s://%s%s", schema, domain, path)
+// execute sends the endpoint rendered with vars, with the jar's cookies, and
+// returns the unsaved record. A request the guard refuses is not sent; its
+// record carries the reason. Redirects leaving the guard's scope are not
+// followed.
+func (s *myRequestService) execute(ctx context.Context, endpoint *models.Endpoint, vars map[string]string, jar models.CookieJar, guard *scopeGuard) *models.MyRequest {
+	rendered := endpoint.Render(vars)
+	jar.Apply(rendered, time.Now())
+	var request *models.MyRequest
+	if err := guard.enforce(ctx, rendered.Url); err != nil {
+		request = refusedRequest(rendered, err)
+	} else {
+		request = s.executor.Execute(withScopeGuard(ctx, guard), rendered)
+	}
+	request.EndpointId = endpoint.Id
+	request.Variables = serializeVariables(vars)
+	return request
 }
 
-// serializeHeaders converts VarKVGroup to JSON string
-func (s *myRequestService) serializeHeaders(headers mystructs.VarKVGroup) string {
-	headerMap := make(map[string]string)
-	for _, kv := range headers.VarKVs {
-		headerMap[kv.Key.Exec()] = kv.Value.Exec()
+// refusedRequest is the record of a request that was not sent
+func refusedRequest(rendered *models.RenderedRequest, err error) *models.MyRequest {
+	return &models.MyRequest{
+		RequestMethod:  rendered.Method,
+		RequestUrl:     rendered.Url,
+		RequestHeaders: serializeRequestHeaders(rendered),
+		RequestBody:    rendered.Body,
+		CurlCommand:    rendered.Curl(),
+		ExecutedAt:     time.Now(),
+		Error:          err.Error(),
 	}
-	jsonBytes, _ := json.Marshal(headerMap)
+}
+
+// serializeVariables converts the injected variables to a JSON string
+func serializeVariables(vars map[string]string) string {
+	jsonBytes, _ := json.Marshal(vars)
 	return string(jsonBytes)
 }
 
-// serializeVariables converts VarKVGroup to JSON string
-func (s *myRequestService) serializeVariables(variables mystructs.VarKVGroup) string {
-	varMap := make(map[string]string)
-	for _, kv := range variables.VarKVs {
-		varMap[kv.Key.Exec()] = kv.Value.Exec()
+// Export renders a recorded request in the requested format. Without variables
+// the request is exported exactly as it was sent; otherwise its endpoint is
+// rendered again with the recorded variables overridden by the given ones.
+func (s *myRequestService) Export(ctx context.Context, request *models.MyRequest, format models.ExportFormat, variables *mystructs.KVGroup, fuzz *string) (string, error) {
+	if variables == nil && fuzz == nil {
+		return request.Rendered().Export(format)
 	}
-	jsonBytes, _ := json.Marshal(varMap)
-	return string(jsonBytes)
+
+	endpoint, err := firstById[models.Endpoint](s.db.WithContext(ctx), request.EndpointId)
+	if err != nil {
+		return "", fmt.Errorf("endpoint of request %d not found: %v", request.Id, err)
+	}
+	vars := map[string]string{}
+	json.Unmarshal([]byte(request.Variables), &vars)
+	for k, v := range exportVariables(variables, fuzz) {
+		vars[k] = v
+	}
+	return endpoint.Render(vars).Export(format)
 }
 
-// parseCurlOutput extracts response information from curl verbose output
-func (s *myRequestService) parseCurlOutput(output string) struct {
-	Status      int
-	Headers     string
-	Body        string
-	ContentType string
-} {
-	// This is a simplified parser - in a real implementation,
-	// you'd want more robust parsing of curl's verbose output
-	lines := strings.Split(output, "\n")
+// Diff compares the responses of two recorded requests
+func (s *myRequestService) Diff(ctx context.Context, aId int, bId int, mode models.DiffMode) (*models.RequestDiff, error) {
+	a, err := firstById[models.MyRequest](s.db.WithContext(ctx), aId)
+	if err != nil {
+		return nil, fmt.Errorf("request %d not found: %v", aId, err)
+	}
+	b, err := firstById[models.MyRequest](s.db.WithContext(ctx), bId)
+	if err != nil {
+		return nil, fmt.Errorf("request %d not found: %v", bId, err)
+	}
+	if err := models.LoadResponseBodies(s.db.WithContext(ctx), a, b); err != nil {
+		return nil, err
+	}
+	return models.DiffRequests(a, b, mode), nil
+}
 
-	result := struct {
-		Status      int
-		Headers     string
-		Body        string
-		ContentType string
-	}{
-		Status: 200, // Default
+// ExportBurp writes the given requests, in id order, as a Burp Suite
+// "Save items" document
+func (s *myRequestService) ExportBurp(ctx context.Context, ids []int) (string, error) {
+	var requests []*models.MyRequest
+	if err := s.db.WithContext(ctx).Where("id IN ?", ids).Order("id").Find(&requests).Error; err != nil {
+		return "", err
+	}
+	if len(requests) != len(ids) {
+		return "", fmt.Errorf("found %d of the %d requests", len(requests), len(ids))
+	}
+	if err := models.LoadResponseBodies(s.db.WithContext(ctx), requests...); err != nil {
+		return "", err
 	}
 
-	// Find HTTP status line
-	for _, line := range lines {
-		if strings.Contains(line, "HTTP/") {
-			parts := strings.Fields(line)
-			if len(parts) >= 2 {
-				if status := s.extractStatus(parts[1]); status > 0 {
-					result.Status = status
-				}
+	items := utils.BurpItems{ExportTime: utils.FormatBurpTime(time.Now())}
+	for _, request := range requests {
+		rendered := request.Rendered()
+		item := utils.BurpItem{
+			Time:      utils.FormatBurpTime(request.ExecutedAt),
+			Url:       utils.BurpText{Text: rendered.Url},
+			Method:    utils.BurpText{Text: rendered.Method},
+			Extension: "null",
+			Request:   utils.NewBurpData(rendered.Raw()),
+			MimeType:  burpMimeType(request.ContentType),
+		}
+		if u, err := url.Parse(rendered.Url); err == nil {
+			item.Host.Name = u.Hostname()
+			item.Protocol = u.Scheme
+			item.Path = utils.BurpText{Text: u.RequestURI()}
+			item.Port, _ = strconv.Atoi(u.Port())
+			if item.Port == 0 {
+				item.Port = map[string]int{"http": 80, "https": 443}[u.Scheme]
+			}
+			if ext := path.Ext(u.Path); ext != "" {
+				item.Extension = ext[1:]
 			}
 		}
-	}
-
-	// Extract body (everything after the last empty line)
-	bodyStart := -1
-	for i, line := range lines {
-		if line == "" && i < len(lines)-1 {
-			bodyStart = i + 1
+		if request.Success {
+			raw := request.RawResponse()
+			item.Status = request.ResponseStatus
+			item.ResponseLength = len(raw)
+			item.Response = utils.NewBurpData(raw)
 		}
+		items.Items = append(items.Items, item)
 	}
 
-	if bodyStart >= 0 && bodyStart < len(lines) {
-		result.Body = strings.Join(lines[bodyStart:], "\n")
+	var b strings.Builder
+	if err := items.Write(&b); err != nil {
+		return "", err
 	}
-
-	return result
+	return b.String(), nil
 }
 
-// extractStatus extracts HTTP status code from string
-func (s *myRequestService) extractStatus(statusStr string) int {
-	// Simple status extraction - could be more robust
-	if len(statusStr) >= 3 {
-		if status := statusStr[:3]; status >= "100" && status <= "599" {
-			// This would need proper string to int conversion
-			return 200 // Simplified for now
+// burpMimeType names a content type the way Burp's mimetype column does
+func burpMimeType(contentType string) string {
+	contentType = strings.ToLower(contentType)
+	for _, t := range []struct{ match, name string }{
+		{"json", "JSON"}, {"html", "HTML"}, {"xml", "XML"}, {"javascript", "script"},
+		{"css", "CSS"}, {"image/", "image"}, {"text/", "text"},
+	} {
+		if strings.Contains(contentType, t.match) {
+			return t.name
 		}
 	}
-	return 0
+	return ""
 }
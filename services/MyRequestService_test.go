@@ -0,0 +1,129 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/linn221/bane/config"
+	"github.com/linn221/bane/models"
+)
+
+func TestMyRequestService_Search(t *testing.T) {
+	services := newTestServices(t)
+	ctx := context.Background()
+	projects := map[string]int{}
+	record := func(projectName, body string) *models.MyRequest {
+		t.Helper()
+		project, err := services.ProjectService.Create(ctx, &models.ProjectInput{Name: projectName})
+		if err != nil {
+			t.Fatal(err)
+		}
+		projects[projectName] = project.Id
+		endpoint, err := services.EndpointService.Create(ctx, &models.EndpointInput{ProjectId: &project.Id, Url: mustVarString(t, "http://127.0.0.1/")})
+		if err != nil {
+			t.Fatal(err)
+		}
+		request, err := services.MyRequestService.Create(ctx, &models.MyRequest{
+			EndpointId: endpoint.Id, RequestMethod: "GET", RequestUrl: "http://127.0.0.1/", ResponseBody: body,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return request
+	}
+
+	// recorded before the index exists, so indexed when it is created
+	leak := record("one", `{"aws_secret":"AKIA"}`)
+	if !models.HasFts5(services.MyRequestService.db) {
+		t.Skip("SQLite was built without FTS5")
+	}
+	if err := config.SetupRequestSearch(services.MyRequestService.db); err != nil {
+		t.Fatal(err)
+	}
+	other := record("two", "the aws_secret is rotated, the aws_secret is gone, and no aws_secret remains")
+	record("three", "nothing to see")
+
+	hits, err := services.MyRequestService.Search(ctx, "aws_secret", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hits) != 2 || hits[0].Request.Id != other.Id || hits[1].Request.Id != leak.Id || hits[0].Rank > hits[1].Rank {
+		t.Fatalf("hits = %+v", hits)
+	}
+	if len(hits[1].Snippets) != 1 || hits[1].Snippets[0].Text != `{"<mark>aws</mark>_<mark>secret</mark>":"AKIA"}` {
+		t.Errorf("snippets = %+v", hits[1].Snippets)
+	}
+
+	one := projects["one"]
+	hits, err = services.MyRequestService.Search(ctx, "aws_secret", &one)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hits) != 1 || hits[0].Request.Id != leak.Id {
+		t.Errorf("hits in project one = %+v", hits)
+	}
+	if _, err := services.MyRequestService.Search(ctx, `"unbalanced`, nil); err == nil {
+		t.Error("expected an invalid query to fail")
+	}
+
+	requests, err := services.MyRequestService.List(ctx, &models.MyRequestFilter{Query: `body~"aws secret" -body~rotated`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(requests) != 1 || requests[0].Id != leak.Id {
+		t.Errorf("requests with body~ = %+v", requests)
+	}
+}
+
+func TestMyRequestService_ListSortsAndPages(t *testing.T) {
+	services := newTestServices(t)
+	ctx := context.Background()
+	endpoint, err := services.EndpointService.Create(ctx, &models.EndpointInput{Url: mustVarString(t, "http://127.0.0.1/")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []int
+	for _, status := range []int{200, 500, 404, 503} {
+		request, err := services.MyRequestService.Create(ctx, &models.MyRequest{
+			EndpointId: endpoint.Id, RequestMethod: "GET", RequestUrl: "http://127.0.0.1/", ResponseStatus: status,
+			ResponseBody: http.StatusText(status),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, request.Id)
+	}
+	listed := func(filter *models.MyRequestFilter) []int {
+		t.Helper()
+		requests, err := services.MyRequestService.List(ctx, filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []int
+		for _, r := range requests {
+			got = append(got, r.Id)
+		}
+		return got
+	}
+
+	if got := listed(&models.MyRequestFilter{Sort: "id", Limit: 2, Offset: 1}); !slices.Equal(got, ids[1:3]) {
+		t.Errorf("second page = %v, want %v", got, ids[1:3])
+	}
+	if got := listed(&models.MyRequestFilter{Sort: "-status"}); !slices.Equal(got, []int{ids[3], ids[1], ids[2], ids[0]}) {
+		t.Errorf("by status descending = %v", got)
+	}
+	if got := listed(&models.MyRequestFilter{Query: "status:5xx", Sort: "id", Offset: 1}); !slices.Equal(got, ids[3:]) {
+		t.Errorf("5xx after the first = %v, want %v", got, ids[3:])
+	}
+	if got := listed(&models.MyRequestFilter{Query: `body~"server error"`, Sort: "id"}); !slices.Equal(got, ids[1:2]) {
+		t.Errorf("body~ = %v, want %v", got, ids[1:2])
+	}
+	if got := listed(&models.MyRequestFilter{Query: "-body:found status:4xx,5xx", Sort: "id"}); !slices.Equal(got, []int{ids[1], ids[3]}) {
+		t.Errorf("-body: = %v, want %v", got, []int{ids[1], ids[3]})
+	}
+	if _, err := services.MyRequestService.List(ctx, &models.MyRequestFilter{Sort: "secret"}); err == nil {
+		t.Error("expected an error for an unknown sort key")
+	}
+}
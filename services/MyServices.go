@@ -1,18 +1,32 @@
 package services
 
 import (
+	"log"
+	"strconv"
+
 	"github.com/linn221/bane/config"
+	"github.com/linn221/bane/models"
+	"github.com/linn221/bane/utils"
 	"gorm.io/gorm"
 )
 
 // MyServices contains all service instances
 type MyServices struct {
-	EndpointService *endpointService
-	NoteService     *noteService
-	MyRequestService *myRequestService
-	WordService     *wordService
-	ProjectService  *projectService
-	AliasService    *aliasService
+	EndpointService    *endpointService
+	NoteService        *noteService
+	MyRequestService   *myRequestService
+	WordService        *wordService
+	ProjectService     *projectService
+	AliasService       *aliasService
+	JobService         *jobService
+	SequenceService    *sequenceService
+	VariableService    *variableService
+	EnvironmentService *environmentService
+	ImportService      *importService
+	CookieService      *cookieService
+	RecorderService    *recorderService
+	ScopeService       *scopeService
+	IssueService       *issueService
 }
 
 // NewMyServices creates a new MyServices instance with all services initialized
@@ -28,10 +42,58 @@ func NewMyServices(db *gorm.DB, cache config.CacheService) *MyServices {
 		db: db,
 	}
 
-	myRequestService := &myRequestService{
+	variableService := &variableService{
+		db: db,
+	}
+
+	environmentService := &environmentService{
+		db:           db,
+		aliasService: aliasService,
+	}
+
+	cookieService := &cookieService{
+		db: db,
+	}
+
+	scopeService := &scopeService{
+		db: db,
+	}
+
+	issueService := &issueService{
 		db: db,
 	}
 
+	executor := newHttpExecutor()
+	if proxy := utils.GetEnv("UPSTREAM_PROXY", ""); proxy != "" {
+		proxyUrl, err := utils.ParseProxyUrl(proxy)
+		if err != nil {
+			// sending directly would leak traffic meant for the proxy
+			log.Fatalf("UPSTREAM_PROXY: %v", err)
+		}
+		executor.proxy = proxyUrl
+	}
+
+	maxBodySize := models.DefaultMaxStoredBodySize
+	if size := utils.GetEnv("MAX_BODY_SIZE", ""); size != "" {
+		limit, err := strconv.Atoi(size)
+		if err != nil || limit < 0 {
+			log.Fatalf("MAX_BODY_SIZE: want a number of bytes, got %q", size)
+		}
+		maxBodySize = limit
+	}
+
+	myRequestService := &myRequestService{
+		db:                 db,
+		aliasService:       aliasService,
+		variableService:    variableService,
+		environmentService: environmentService,
+		cookieService:      cookieService,
+		scopeService:       scopeService,
+		issueService:       issueService,
+		executor:           executor,
+		maxBodySize:        maxBodySize,
+	}
+
 	wordService := &wordService{
 		db:           db,
 		aliasService: aliasService,
@@ -42,12 +104,49 @@ func NewMyServices(db *gorm.DB, cache config.CacheService) *MyServices {
 		aliasService: aliasService,
 	}
 
+	jobService := &jobService{
+		db:                 db,
+		aliasService:       aliasService,
+		myRequestService:   myRequestService,
+		environmentService: environmentService,
+	}
+
+	sequenceService := &sequenceService{
+		db:                 db,
+		aliasService:       aliasService,
+		myRequestService:   myRequestService,
+		environmentService: environmentService,
+		jobService:         jobService,
+	}
+
+	importService := &importService{
+		db:               db,
+		endpointService:  endpointService,
+		myRequestService: myRequestService,
+	}
+
+	recorderService := &recorderService{
+		db:               db,
+		importService:    importService,
+		myRequestService: myRequestService,
+		caDir:            config.GetBaseDir(),
+	}
+
 	return &MyServices{
-		AliasService:     aliasService,
-		EndpointService:  endpointService,
-		NoteService:      noteService,
-		MyRequestService: myRequestService,
-		WordService:      wordService,
-		ProjectService:   projectService,
+		AliasService:       aliasService,
+		EndpointService:    endpointService,
+		NoteService:        noteService,
+		MyRequestService:   myRequestService,
+		WordService:        wordService,
+		ProjectService:     projectService,
+		JobService:         jobService,
+		SequenceService:    sequenceService,
+		VariableService:    variableService,
+		EnvironmentService: environmentService,
+		ImportService:      importService,
+		CookieService:      cookieService,
+		RecorderService:    recorderService,
+		ScopeService:       scopeService,
+		IssueService:       issueService,
 	}
 }
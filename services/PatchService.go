@@ -23,7 +23,7 @@ func PatchModel(ctx context.Context, db *gorm.DB, aliasService *aliasService, al
 		updates[v.Key] = v.Value
 	}
 
-	err = db.WithContext(ctx).Model(&modelStruct).Where("id = ?", alias.ReferenceId).Updates(updates).Error
+	err = db.WithContext(ctx).Model(modelStruct).Where("id = ?", alias.ReferenceId).Updates(updates).Error
 	if err != nil {
 		return false, err
 	}
@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/linn221/bane/models"
+	"github.com/linn221/bane/utils"
 	"gorm.io/gorm"
 )
 
@@ -17,6 +18,15 @@ func (s *projectService) Create(ctx context.Context, input *models.ProjectInput)
 		Name:        input.Name,
 		Description: input.Description,
 		Url:         input.Url,
+		Proxy:       input.Proxy,
+	}
+	if _, err := models.NewScope(input.InScope, input.OutOfScope); err != nil {
+		return nil, err
+	}
+	project.InScope, project.OutOfScope = input.InScope, input.OutOfScope
+	project.RateLimit = utils.SafeDeref(input.RateLimit)
+	if err := project.RateLimit.Validate(); err != nil {
+		return nil, err
 	}
 
 	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
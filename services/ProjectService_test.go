@@ -0,0 +1,108 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/linn221/bane/models"
+)
+
+func TestProjectService_ValidatesProxy(t *testing.T) {
+	services := newTestServices(t)
+	ctx := context.Background()
+	if _, err := services.ProjectService.Create(ctx, &models.ProjectInput{Name: "bad", Proxy: "ftp://127.0.0.1"}); err == nil {
+		t.Error("expected an error for an ftp proxy")
+	}
+	project, err := services.ProjectService.Create(ctx, &models.ProjectInput{Name: "shop", Alias: "shop", Proxy: "127.0.0.1:8080"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if project.Proxy != "http://127.0.0.1:8080" {
+		t.Errorf("proxy = %q", project.Proxy)
+	}
+
+	patch := func(proxy string) error {
+		_, err := PatchModel(ctx, services.ProjectService.db, services.AliasService, "shop", models.PatchInput{
+			Values: []models.KVString{{Key: "proxy", Value: proxy}},
+		})
+		return err
+	}
+	for _, proxy := range []string{"ftp://127.0.0.1", "http://", "http://127.0.0.1:8080/path"} {
+		if err := patch(proxy); err == nil {
+			t.Errorf("patching the proxy to %q: expected an error", proxy)
+		}
+	}
+	if err := patch("socks5://127.0.0.1:1080"); err != nil {
+		t.Fatal(err)
+	}
+	if err := patch(""); err != nil {
+		t.Fatal(err)
+	}
+	project, err = services.ProjectService.Get(ctx, &project.Id, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if project.Proxy != "" {
+		t.Errorf("proxy = %q after clearing it", project.Proxy)
+	}
+}
+
+func TestPatchModel_ValidatesRulesAndLimits(t *testing.T) {
+	services := newTestServices(t)
+	ctx := context.Background()
+	project, err := services.ProjectService.Create(ctx, &models.ProjectInput{Name: "shop", Alias: "shop"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := services.EndpointService.Create(ctx, &models.EndpointInput{
+		ProjectId: &project.Id,
+		Url:       mustVarString(t, "https://shop.example.com/cart"),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	patch := func(alias string, input models.PatchInput) error {
+		_, err := PatchModel(ctx, services.ProjectService.db, services.AliasService, alias, input)
+		return err
+	}
+	invalid := []struct {
+		alias string
+		input models.PatchInput
+	}{
+		{"shop", models.PatchInput{Values: []models.KVString{{Key: "in_scope", Value: `[{"kind":"regex","value":"("}]`}}}},
+		{"shop", models.PatchInput{Values: []models.KVString{{Key: "out_of_scope", Value: `[{"kind":"planet","value":"mars"}]`}}}},
+		{"shop", models.PatchInput{Values: []models.KVString{{Key: "rate_requests_per_second", Value: "0"}}}},
+		{"shop", models.PatchInput{ValuesInt: []models.KVInt{{Key: "rate_burst", Value: 0}}}},
+		{"endpoints1", models.PatchInput{ValuesInt: []models.KVInt{{Key: "rate_max_concurrency", Value: -1}}}},
+		{"endpoints1", models.PatchInput{ValuesInt: []models.KVInt{{Key: "transport_timeout_ms", Value: 0}}}},
+		{"endpoints1", models.PatchInput{ValuesInt: []models.KVInt{{Key: "transport_max_redirects", Value: -1}}}},
+	}
+	for _, c := range invalid {
+		if err := patch(c.alias, c.input); err == nil {
+			t.Errorf("patching %s with %+v: expected an error", c.alias, c.input)
+		}
+	}
+
+	valid := []struct {
+		alias string
+		input models.PatchInput
+	}{
+		{"shop", models.PatchInput{Values: []models.KVString{{Key: "in_scope", Value: `[{"kind":"domain","value":"*.example.com"}]`}, {Key: "rate_requests_per_second", Value: "2.5"}}}},
+		{"endpoints1", models.PatchInput{
+			Values:    []models.KVString{{Key: "name", Value: "cart"}, {Key: "http_path", Value: "/cart/{id=1}"}, {Key: "http_headers", Value: "Accept:*/*"}},
+			ValuesInt: []models.KVInt{{Key: "transport_timeout_ms", Value: 500}, {Key: "rate_burst", Value: 3}},
+		}},
+	}
+	for _, c := range valid {
+		if err := patch(c.alias, c.input); err != nil {
+			t.Errorf("patching %s with %+v: %v", c.alias, c.input, err)
+		}
+	}
+	project, err = services.ProjectService.Get(ctx, &project.Id, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(project.InScope) != 1 || project.RateLimit.RequestsPerSecond == nil || *project.RateLimit.RequestsPerSecond != 2.5 {
+		t.Errorf("project scope=%v rate=%v", project.InScope, project.RateLimit.RequestsPerSecond)
+	}
+}
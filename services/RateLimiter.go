@@ -0,0 +1,157 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/linn221/bane/models"
+	"github.com/linn221/bane/utils"
+)
+
+// maxBackoff caps how long a host is left alone after asking to slow down
+const maxBackoff = 2 * time.Minute
+
+type rateLimitKey struct{}
+
+// withRateLimit makes the executor hold requests made with ctx to limit
+func withRateLimit(ctx context.Context, limit models.RateLimit) context.Context {
+	return context.WithValue(ctx, rateLimitKey{}, limit)
+}
+
+func rateLimitFrom(ctx context.Context) models.RateLimit {
+	limit, _ := ctx.Value(rateLimitKey{}).(models.RateLimit)
+	return limit
+}
+
+// hostLimiter throttles the requests to each host with a token bucket and a
+// cap on requests in flight, and backs off from hosts that answer 429 or 503
+// or send Retry-After. Its state is per host and shared by all of bane's
+// traffic, so the limit a request brings refills the bucket its host already
+// has; endpoints with different limits on one host take turns setting it.
+type hostLimiter struct {
+	mu    sync.Mutex
+	hosts map[string]*hostState
+}
+
+type hostState struct {
+	tokens   float64
+	refilled time.Time
+	inFlight int
+	freed    chan struct{} // closed, and replaced, whenever a request finishes
+	backoff  time.Duration // the last backoff, doubled while the host keeps refusing
+	until    time.Time     // when requests may go again after a backoff
+}
+
+// acquire waits until a request to host may be sent under limit. The
+// returned function must be called once the exchange is over, with the
+// response, or nil when there is none.
+func (l *hostLimiter) acquire(ctx context.Context, host string, limit models.RateLimit) (func(*http.Response), error) {
+	host = strings.ToLower(host)
+	for {
+		wait, freed, ok := l.take(host, limit, time.Now())
+		if ok {
+			return func(resp *http.Response) { l.release(host, resp, time.Now()) }, nil
+		}
+		var timer *time.Timer
+		var fired <-chan time.Time
+		if wait > 0 {
+			timer = time.NewTimer(wait)
+			fired = timer.C
+		}
+		select {
+		case <-ctx.Done():
+		case <-fired:
+		case <-freed:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// take claims a token and an in-flight slot for host. Otherwise it returns
+// how long to wait, or a channel to wait on for a slot.
+func (l *hostLimiter) take(host string, limit models.RateLimit, now time.Time) (time.Duration, <-chan struct{}, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	burst := float64(utils.SafeDeref(limit.Burst, 1))
+	rate := utils.SafeDeref(limit.RequestsPerSecond)
+	if rate > 0 {
+		// limits saved before Validate bounded them
+		rate = max(rate, models.MinRequestsPerSecond)
+	}
+	h, ok := l.hosts[host]
+	if !ok {
+		if l.hosts == nil {
+			l.hosts = map[string]*hostState{}
+		}
+		h = &hostState{tokens: burst, refilled: now, freed: make(chan struct{})}
+		l.hosts[host] = h
+	}
+	if rate > 0 {
+		h.tokens = min(burst, h.tokens+now.Sub(h.refilled).Seconds()*rate)
+	}
+	h.refilled = now
+
+	if now.Before(h.until) {
+		return h.until.Sub(now), nil, false
+	}
+	if limit.MaxConcurrency != nil && h.inFlight >= *limit.MaxConcurrency {
+		return 0, h.freed, false
+	}
+	if rate > 0 {
+		if h.tokens < 1 {
+			return time.Duration((1 - h.tokens) / rate * float64(time.Second)), nil, false
+		}
+		h.tokens--
+	}
+	h.inFlight++
+	return 0, nil, true
+}
+
+func (l *hostLimiter) release(host string, resp *http.Response, now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	h := l.hosts[host]
+	h.inFlight--
+	close(h.freed)
+	h.freed = make(chan struct{})
+	if resp == nil {
+		return
+	}
+	delay, ok := retryDelay(resp, h.backoff, now)
+	if !ok {
+		h.backoff = 0
+		return
+	}
+	h.backoff = delay
+	if until := now.Add(delay); until.After(h.until) {
+		h.until = until
+	}
+}
+
+// retryDelay is how long to leave the host alone after resp: what its
+// Retry-After asks for, else, for a 429 or 503, twice the last backoff
+// starting from a second. It reports false for responses that ask for
+// neither.
+func retryDelay(resp *http.Response, last time.Duration, now time.Time) (time.Duration, bool) {
+	if value := strings.TrimSpace(resp.Header.Get("Retry-After")); value != "" {
+		if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+			return min(time.Duration(seconds)*time.Second, maxBackoff), true
+		}
+		if at, err := http.ParseTime(value); err == nil {
+			return min(max(at.Sub(now), 0), maxBackoff), true
+		}
+	}
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		return min(max(2*last, time.Second), maxBackoff), true
+	}
+	return 0, false
+}
@@ -0,0 +1,181 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/linn221/bane/models"
+)
+
+func TestHostLimiter_ThrottlesPerHost(t *testing.T) {
+	var mu sync.Mutex
+	inFlight, peak := 0, 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		inFlight++
+		peak = max(peak, inFlight)
+		mu.Unlock()
+		time.Sleep(30 * time.Millisecond)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+		io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+
+	executor := newHttpExecutor()
+	send := func(ctx context.Context, limit models.RateLimit, n int) {
+		t.Helper()
+		var wg sync.WaitGroup
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				rendered := &models.RenderedRequest{Method: "GET", Url: srv.URL}
+				if record := executor.Execute(withRateLimit(ctx, limit), rendered); !record.Success {
+					t.Errorf("request failed: %s", record.Error)
+				}
+			}()
+		}
+		wg.Wait()
+	}
+
+	two := 2
+	send(context.Background(), models.RateLimit{MaxConcurrency: &two}, 8)
+	if peak != 2 {
+		t.Errorf("peak concurrency %d, want 2", peak)
+	}
+
+	rate, burst := 20.0, 2
+	start := time.Now()
+	send(context.Background(), models.RateLimit{RequestsPerSecond: &rate, Burst: &burst}, 6)
+	// the bucket starts full, so the first two go at once and the other four
+	// wait 50ms each
+	if elapsed := time.Since(start); elapsed < 180*time.Millisecond || elapsed > 2*time.Second {
+		t.Errorf("6 requests at 20/s with a burst of 2 took %v", elapsed)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	slow := 0.5
+	executor.Execute(withRateLimit(ctx, models.RateLimit{RequestsPerSecond: &slow}), &models.RenderedRequest{Method: "GET", Url: srv.URL})
+	record := executor.Execute(withRateLimit(ctx, models.RateLimit{RequestsPerSecond: &slow}), &models.RenderedRequest{Method: "GET", Url: srv.URL})
+	if record.Success || record.Error == "" {
+		t.Error("expected a request cancelled while waiting for the rate limit to fail")
+	}
+}
+
+func TestHostLimiter_BoundsTinyRates(t *testing.T) {
+	var limiter hostLimiter
+	// saved before Validate rejected it
+	rate := 1e-12
+	now := time.Now()
+	if _, _, ok := limiter.take("example.com", models.RateLimit{RequestsPerSecond: &rate}, now); !ok {
+		t.Fatal("the first request should take the burst token")
+	}
+	limiter.release("example.com", nil, now)
+	wait, _, ok := limiter.take("example.com", models.RateLimit{RequestsPerSecond: &rate}, now)
+	if ok || wait <= 0 || wait > time.Duration(float64(time.Second)/models.MinRequestsPerSecond) {
+		t.Errorf("waiting %v for a token at %v requests per second", wait, rate)
+	}
+}
+
+func TestHostLimiter_BacksOff(t *testing.T) {
+	var mu sync.Mutex
+	var seen []time.Time
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		seen = append(seen, time.Now())
+		first := len(seen) == 1
+		mu.Unlock()
+		if first {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+
+	executor := newHttpExecutor()
+	for i := 0; i < 2; i++ {
+		executor.Execute(context.Background(), &models.RenderedRequest{Method: "GET", Url: srv.URL})
+	}
+	if len(seen) != 2 || seen[1].Sub(seen[0]) < 900*time.Millisecond {
+		t.Errorf("the request after Retry-After: 1 came %v later", seen[1].Sub(seen[0]))
+	}
+
+	now := time.Now()
+	for _, tt := range []struct {
+		status     int
+		retryAfter string
+		last       time.Duration
+		want       time.Duration
+		ok         bool
+	}{
+		{http.StatusTooManyRequests, "", 0, time.Second, true},
+		{http.StatusServiceUnavailable, "", 4 * time.Second, 8 * time.Second, true},
+		{http.StatusTooManyRequests, "", time.Minute + time.Second, maxBackoff, true},
+		{http.StatusTooManyRequests, "3", 0, 3 * time.Second, true},
+		{http.StatusOK, "86400", 0, maxBackoff, true},
+		{http.StatusServiceUnavailable, now.Add(5 * time.Second).UTC().Format(http.TimeFormat), 0, 4 * time.Second, true},
+		{http.StatusTooManyRequests, "soon", 0, time.Second, true},
+		{http.StatusOK, "", 8 * time.Second, 0, false},
+		{http.StatusInternalServerError, "", 0, 0, false},
+	} {
+		resp := &http.Response{StatusCode: tt.status, Header: http.Header{}}
+		if tt.retryAfter != "" {
+			resp.Header.Set("Retry-After", tt.retryAfter)
+		}
+		got, ok := retryDelay(resp, tt.last, now)
+		// HTTP dates have whole seconds
+		if ok != tt.ok || got < tt.want || got > tt.want+time.Second {
+			t.Errorf("status %d, Retry-After %q, last %v: got %v %v, want %v %v", tt.status, tt.retryAfter, tt.last, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestMyRequestService_RateLimitPrecedence(t *testing.T) {
+	services := newTestServices(t)
+	ctx := context.Background()
+	rate, burst, concurrency := 5.0, 3, 2
+	project, err := services.ProjectService.Create(ctx, &models.ProjectInput{
+		Name:      "limited",
+		RateLimit: &models.RateLimit{RequestsPerSecond: &rate, Burst: &burst},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	zero := 0
+	if _, err := services.ProjectService.Create(ctx, &models.ProjectInput{Name: "bad", RateLimit: &models.RateLimit{MaxConcurrency: &zero}}); err == nil {
+		t.Error("expected a maxConcurrency of 0 to be rejected")
+	}
+
+	faster := 10.0
+	endpoint, err := services.EndpointService.Create(ctx, &models.EndpointInput{
+		ProjectId: &project.Id,
+		Url:       mustVarString(t, "http://127.0.0.1/"),
+		RateLimit: &models.RateLimit{RequestsPerSecond: &faster, MaxConcurrency: &concurrency},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, err := firstById[models.Endpoint](services.EndpointService.db, endpoint.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	limit, err := services.MyRequestService.rateLimit(ctx, stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := fmt.Sprint(*limit.RequestsPerSecond, *limit.Burst, *limit.MaxConcurrency)
+	if got != "10 3 2" {
+		t.Errorf("limit = %s, want the endpoint's rate and concurrency with the project's burst", got)
+	}
+}
@@ -0,0 +1,499 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/linn221/bane/models"
+	"github.com/linn221/bane/mystructs"
+	"github.com/linn221/bane/utils"
+	"gorm.io/gorm"
+)
+
+const defaultRecorderAddress = "127.0.0.1:8081"
+
+// recorderService runs the recording proxy, a forward proxy for browsers.
+// HTTPS is intercepted with certificates signed by a local CA, and every
+// exchange that passes the filters is recorded as a MyRequest of an endpoint
+// created, or reused, the same way imports do.
+type recorderService struct {
+	db               *gorm.DB
+	importService    *importService
+	myRequestService *myRequestService
+	caDir            string // where the CA is kept
+
+	mu      sync.Mutex
+	ca      *utils.CertAuthority
+	current *recorder
+	last    models.Recorder // the settings and counts of the last run, once stopped
+}
+
+// recorder is one run of the recording proxy
+type recorder struct {
+	service   *recorderService
+	server    *http.Server
+	transport *http.Transport
+	ca        *utils.CertAuthority
+	guard     *scopeGuard
+	limit     models.RateLimit
+
+	mu       sync.Mutex
+	settings models.Recorder
+	tunnels  map[net.Conn]struct{}
+
+	// endpointMu serializes finding or creating endpoints, so concurrent
+	// requests to a new endpoint create it only once
+	endpointMu sync.Mutex
+}
+
+// authority loads the CA on first use
+func (s *recorderService) authority() (*utils.CertAuthority, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ca == nil {
+		ca, err := utils.LoadCertAuthority(s.caDir)
+		if err != nil {
+			return nil, err
+		}
+		s.ca = ca
+	}
+	return s.ca, nil
+}
+
+// CACertificate returns the PEM certificate browsers must trust for HTTPS
+// capture
+func (s *recorderService) CACertificate(ctx context.Context) (string, error) {
+	ca, err := s.authority()
+	if err != nil {
+		return "", err
+	}
+	return ca.PEM(), nil
+}
+
+// Start listens for proxy connections. Requests are forwarded through the
+// upstream proxy and under the rate limit that apply to the project.
+func (s *recorderService) Start(ctx context.Context, input *models.RecorderInput) (*models.Recorder, error) {
+	ca, err := s.authority()
+	if err != nil {
+		return nil, err
+	}
+	settings := models.Recorder{
+		Running:          true,
+		Address:          utils.SafeDeref(input.Address, defaultRecorderAddress),
+		ProjectId:        input.ProjectId,
+		Hosts:            slices.Clone(input.Hosts),
+		ExcludeHosts:     slices.Clone(input.ExcludeHosts),
+		IgnoreExtensions: slices.Clone(input.IgnoreExtensions),
+	}
+	if err := checkLoopback(settings.Address); err != nil {
+		return nil, err
+	}
+	if settings.Hosts == nil {
+		settings.Hosts = []string{}
+	}
+	if settings.ExcludeHosts == nil {
+		settings.ExcludeHosts = []string{}
+	}
+	if settings.IgnoreExtensions == nil {
+		settings.IgnoreExtensions = slices.Clone(models.DefaultIgnoredExtensions)
+	}
+	if input.ProjectId != nil {
+		if _, err := firstById[models.Project](s.db.WithContext(ctx), *input.ProjectId); err != nil {
+			return nil, fmt.Errorf("project %d not found: %v", *input.ProjectId, err)
+		}
+	}
+	proxy, err := s.myRequestService.proxy(ctx, &models.Endpoint{ProjectId: input.ProjectId})
+	if err != nil {
+		return nil, err
+	}
+	guard, err := s.myRequestService.scopeService.guard(ctx, input.ProjectId, false)
+	if err != nil {
+		return nil, err
+	}
+	limit, err := s.myRequestService.rateLimit(ctx, &models.Endpoint{ProjectId: input.ProjectId})
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.current != nil {
+		return nil, fmt.Errorf("the recorder is already running on %s", s.current.status().Address)
+	}
+	listener, err := net.Listen("tcp", settings.Address)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	settings.Address = listener.Addr().String()
+	settings.StartedAt = &now
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyURL(proxy)
+	// the browser picks the encodings; responses are relayed as received
+	transport.DisableCompression = true
+	// like other intercepting proxies, accept the certificates of the sites
+	// under test, which are often self-signed
+	transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+
+	rec := &recorder{
+		service:   s,
+		transport: transport,
+		ca:        ca,
+		guard:     guard,
+		limit:     limit,
+		settings:  settings,
+		tunnels:   map[net.Conn]struct{}{},
+	}
+	rec.server = &http.Server{Handler: rec, ReadHeaderTimeout: 30 * time.Second}
+	go rec.server.Serve(listener)
+	s.current = rec
+	return rec.status(), nil
+}
+
+// checkLoopback refuses addresses other machines could reach, as the proxy
+// relays anything for anyone who connects
+func checkLoopback(address string) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("invalid recorder address %q: %v", address, err)
+	}
+	if ip := net.ParseIP(host); host == "localhost" || ip != nil && ip.IsLoopback() {
+		return nil
+	}
+	return fmt.Errorf("the recorder must listen on a loopback address such as %s, not %q", defaultRecorderAddress, address)
+}
+
+// Stop closes the listener and every intercepted connection
+func (s *recorderService) Stop(ctx context.Context) (*models.Recorder, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.current == nil {
+		return nil, fmt.Errorf("the recorder is not running")
+	}
+	rec := s.current
+	s.current = nil
+	rec.server.Close()
+	rec.mu.Lock()
+	for conn := range rec.tunnels {
+		conn.Close()
+	}
+	rec.settings.Running = false
+	rec.mu.Unlock()
+	rec.transport.CloseIdleConnections()
+	s.last = *rec.status()
+	return &s.last, nil
+}
+
+// Status reports the running recorder, or the last one after it stopped
+func (s *recorderService) Status(ctx context.Context) *models.Recorder {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.current != nil {
+		return s.current.status()
+	}
+	status := s.last
+	if status.Hosts == nil {
+		status.Hosts, status.ExcludeHosts, status.IgnoreExtensions = []string{}, []string{}, models.DefaultIgnoredExtensions
+	}
+	return &status
+}
+
+func (rec *recorder) status() *models.Recorder {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	status := rec.settings
+	return &status
+}
+
+// ServeHTTP handles proxy requests. A request that is not for the proxy,
+// such as http://127.0.0.1:8081/ typed into the browser, downloads the CA
+// certificate.
+func (rec *recorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == http.MethodConnect:
+		rec.intercept(w, r)
+	case r.URL.IsAbs():
+		rec.relay(w, r)
+	default:
+		w.Header().Set("Content-Type", "application/x-x509-ca-cert")
+		w.Header().Set("Content-Disposition", `attachment; filename="bane-ca.pem"`)
+		io.WriteString(w, rec.ca.PEM())
+	}
+}
+
+// intercept answers a CONNECT as the target host, with a certificate from
+// the CA, and relays the requests sent through the tunnel
+func (rec *recorder) intercept(w http.ResponseWriter, r *http.Request) {
+	target := r.Host
+	hostname, _, err := net.SplitHostPort(target)
+	if err != nil {
+		http.Error(w, "CONNECT needs a host:port target", http.StatusBadRequest)
+		return
+	}
+	conn, _, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		return
+	}
+	if _, err := io.WriteString(conn, "HTTP/1.1 200 Connection Established\r\n\r\n"); err != nil {
+		conn.Close()
+		return
+	}
+	if !rec.track(conn) {
+		conn.Close()
+		return
+	}
+
+	tlsConn := tls.Server(conn, &tls.Config{
+		GetCertificate: func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			if hello.ServerName != "" {
+				return rec.ca.Certificate(hello.ServerName)
+			}
+			return rec.ca.Certificate(hostname)
+		},
+		NextProtos: []string{"http/1.1"},
+	})
+	tunnel := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.URL.Scheme = "https"
+			r.URL.Host = r.Host
+			if r.URL.Host == "" {
+				r.URL.Host = target
+			}
+			rec.relay(w, r)
+		}),
+		ReadHeaderTimeout: 30 * time.Second,
+		ConnState: func(_ net.Conn, state http.ConnState) {
+			if state == http.StateClosed {
+				rec.untrack(conn)
+			}
+		},
+	}
+	tunnel.Serve(&tunnelListener{conn: tlsConn})
+}
+
+// track registers an intercepted connection for Stop to close, refusing it
+// once the recorder has stopped
+func (rec *recorder) track(conn net.Conn) bool {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if !rec.settings.Running {
+		return false
+	}
+	rec.tunnels[conn] = struct{}{}
+	return true
+}
+
+func (rec *recorder) untrack(conn net.Conn) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	delete(rec.tunnels, conn)
+}
+
+// relay forwards the request and streams the response back, recording the
+// exchange once the body is through. Failed requests are answered with 502
+// and recorded with their error.
+func (rec *recorder) relay(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "failed to read the request body", http.StatusBadRequest)
+		return
+	}
+	out, err := http.NewRequestWithContext(r.Context(), r.Method, r.URL.String(), bytes.NewReader(body))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	out.Header = r.Header.Clone()
+	removeHopHeaders(out.Header)
+	out.Host = r.Host
+
+	rendered := &models.RenderedRequest{
+		Method:  r.Method,
+		Url:     r.URL.String(),
+		Headers: sortedHeaders(out.Header),
+		Body:    string(body),
+	}
+	record := &models.MyRequest{
+		RequestMethod:  rendered.Method,
+		RequestUrl:     rendered.Url,
+		RequestHeaders: serializeRequestHeaders(rendered),
+		RequestBody:    rendered.Body,
+		CurlCommand:    rendered.Curl(),
+		ExecutedAt:     time.Now(),
+		Variables:      "{}",
+	}
+
+	release, err := rec.service.myRequestService.executor.limiter.acquire(r.Context(), r.URL.Hostname(), rec.limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	record.ExecutedAt = time.Now()
+	timing := &traceTiming{}
+	out = out.WithContext(httptrace.WithClientTrace(out.Context(), timing.clientTrace()))
+	start := time.Now()
+	resp, err := rec.transport.RoundTrip(out)
+	if err != nil {
+		release(nil)
+		record.Latency = time.Since(start).Milliseconds()
+		timing.fill(record)
+		record.Error = err.Error()
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		rec.record(r, rendered, record)
+		return
+	}
+	defer resp.Body.Close()
+	// the status and Retry-After are all the limiter needs; a stream must
+	// not hold the host's slot
+	release(resp)
+
+	header := resp.Header.Clone()
+	removeHopHeaders(header)
+	for key, values := range header {
+		w.Header()[key] = values
+	}
+	w.WriteHeader(resp.StatusCode)
+	// the client gets the body as it arrives, which keeps event streams
+	// working, while the part that will be stored is kept aside
+	captured := &cappedBuffer{max: rec.service.myRequestService.maxBodySize}
+	_, err = io.Copy(flushWriter{w}, io.TeeReader(resp.Body, captured))
+	record.Latency = time.Since(start).Milliseconds()
+	timing.fill(record)
+	if err != nil {
+		record.Error = fmt.Sprintf("failed to relay response body: %v", err)
+	}
+	fillResponse(record, resp, captured.Bytes(), true)
+	if captured.truncated {
+		record.ResponseTruncated = true
+		record.Size = captured.total
+	}
+	record.Success = err == nil
+	rec.record(r, rendered, record)
+}
+
+// cappedBuffer keeps the first max bytes written to it, all of them when max
+// is 0, and counts the rest
+type cappedBuffer struct {
+	bytes.Buffer
+	max       int
+	total     int64
+	truncated bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	b.total += int64(len(p))
+	keep := p
+	if b.max > 0 && b.Len()+len(p) > b.max {
+		keep = p[:b.max-b.Len()]
+		b.truncated = true
+	}
+	b.Buffer.Write(keep)
+	return len(p), nil
+}
+
+// flushWriter flushes every write through to the client
+type flushWriter struct {
+	w http.ResponseWriter
+}
+
+func (f flushWriter) Write(p []byte) (int, error) {
+	n, err := f.w.Write(p)
+	if err == nil {
+		err = http.NewResponseController(f.w).Flush()
+	}
+	return n, err
+}
+
+// record stores the exchange when it passes the filters and the project's
+// scope, and lets the project's cookie jar take the cookies the response set
+func (rec *recorder) record(r *http.Request, rendered *models.RenderedRequest, record *models.MyRequest) {
+	settings := rec.status()
+	if !settings.Records(r.URL.Hostname(), r.URL.Path) || !rec.guard.allows(r.Context(), r.URL) {
+		rec.count(&rec.settings.Ignored)
+		return
+	}
+
+	ctx := context.Background()
+	imp := rec.service.importService.newEndpointImport(settings.ProjectId)
+	imp.lock = &rec.endpointMu
+	if err := imp.record(ctx, rendered, record); err != nil {
+		log.Printf("recorder: %s %s: %v", rendered.Method, rendered.Url, err)
+		return
+	}
+	if err := rec.service.myRequestService.cookieService.Capture(ctx, settings.ProjectId, record); err != nil {
+		log.Printf("recorder: %s %s: %v", rendered.Method, rendered.Url, err)
+	}
+	rec.count(&rec.settings.Recorded)
+}
+
+func (rec *recorder) count(counter *int) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	*counter++
+}
+
+// hopHeaders only concern one connection and are not forwarded, as in
+// RFC 9110 section 7.6.1
+var hopHeaders = []string{
+	"Connection", "Proxy-Connection", "Keep-Alive", "Proxy-Authenticate",
+	"Proxy-Authorization", "Te", "Trailer", "Transfer-Encoding", "Upgrade",
+}
+
+func removeHopHeaders(header http.Header) {
+	for _, value := range header.Values("Connection") {
+		for _, name := range strings.Split(value, ",") {
+			header.Del(strings.TrimSpace(name))
+		}
+	}
+	for _, name := range hopHeaders {
+		header.Del(name)
+	}
+}
+
+// sortedHeaders flattens the header in key order
+func sortedHeaders(header http.Header) []mystructs.KVPair {
+	keys := make([]string, 0, len(header))
+	for key := range header {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var pairs []mystructs.KVPair
+	for _, key := range keys {
+		for _, value := range header[key] {
+			pairs = append(pairs, mystructs.KVPair{Key: key, Value: value})
+		}
+	}
+	return pairs
+}
+
+// tunnelListener hands the one connection of a CONNECT tunnel to an
+// http.Server, which keeps serving it after Accept fails
+type tunnelListener struct {
+	conn     net.Conn
+	accepted bool
+}
+
+func (l *tunnelListener) Accept() (net.Conn, error) {
+	if l.accepted {
+		return nil, net.ErrClosed
+	}
+	l.accepted = true
+	return l.conn, nil
+}
+
+func (l *tunnelListener) Close() error { return nil }
+
+func (l *tunnelListener) Addr() net.Addr { return l.conn.LocalAddr() }
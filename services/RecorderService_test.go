@@ -0,0 +1,219 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/linn221/bane/models"
+)
+
+func TestRecorderService_RecordsProxiedTraffic(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/login" {
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "s1", Path: "/", Secure: true})
+		}
+		io.WriteString(w, r.Method+" "+r.URL.RequestURI())
+	})
+	target := httptest.NewServer(handler)
+	defer target.Close()
+	tlsTarget := httptest.NewTLSServer(handler)
+	defer tlsTarget.Close()
+
+	services := newTestServices(t)
+	services.RecorderService.caDir = t.TempDir()
+	ctx := context.Background()
+	project, err := services.ProjectService.Create(ctx, &models.ProjectInput{Name: "capture"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := "127.0.0.1:0"
+	recorder, err := services.RecorderService.Start(ctx, &models.RecorderInput{
+		Address:   &address,
+		ProjectId: &project.Id,
+		Hosts:     []string{"127.0.0.1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer services.RecorderService.Stop(ctx)
+	if _, err := services.RecorderService.Start(ctx, &models.RecorderInput{Address: &address}); err == nil {
+		t.Error("expected a second recorder to be refused")
+	}
+
+	caPEM, err := services.RecorderService.CACertificate(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM([]byte(caPEM)) {
+		t.Fatal("invalid CA certificate")
+	}
+	transport := tlsTarget.Client().Transport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyURL(&url.URL{Scheme: "http", Host: recorder.Address})
+	transport.TLSClientConfig.RootCAs = roots
+	client := &http.Client{Transport: transport}
+	get := func(rawUrl string) string {
+		t.Helper()
+		resp, err := client.Get(rawUrl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return string(body)
+	}
+
+	localhost := strings.Replace(target.URL, "127.0.0.1", "localhost", 1)
+	for _, u := range []string{
+		target.URL + "/items?id=1",
+		target.URL + "/items?id=2",
+		target.URL + "/static/app.css",
+		localhost + "/items?id=3",
+		tlsTarget.URL + "/login",
+	} {
+		parsed, _ := url.Parse(u)
+		if got, want := get(u), "GET "+parsed.RequestURI(); got != want {
+			t.Errorf("%s answered %q", u, got)
+		}
+	}
+
+	status := waitForRecorder(t, services, 5)
+	if status.Recorded != 3 || status.Ignored != 2 {
+		t.Errorf("recorded %d, ignored %d; want 3 and 2", status.Recorded, status.Ignored)
+	}
+	var endpoints []models.Endpoint
+	if err := services.EndpointService.db.Order("id").Find(&endpoints).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(endpoints) != 2 || endpoints[0].Path.OriginalString != "/items" || !endpoints[1].Https {
+		t.Fatalf("endpoints = %+v", endpoints)
+	}
+	requests, err := services.MyRequestService.List(ctx, &models.MyRequestFilter{EndpointId: endpoints[0].Id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := models.LoadResponseBodies(services.MyRequestService.db, requests...); err != nil {
+		t.Fatal(err)
+	}
+	if len(requests) != 2 || requests[0].ResponseBody != "GET /items?id=2" {
+		t.Errorf("recorded %d requests of /items", len(requests))
+	}
+	cookies, err := services.CookieService.List(ctx, project.Id, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cookies) != 1 || cookies[0].Value != "s1" {
+		t.Errorf("cookies = %+v", cookies)
+	}
+
+	// the recorder's own address serves the CA certificate
+	resp, err := http.Get("http://" + recorder.Address + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != caPEM {
+		t.Errorf("CA download = %q", body)
+	}
+
+	stopped, err := services.RecorderService.Stop(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stopped.Running || stopped.Recorded != 3 {
+		t.Errorf("stopped = %+v", stopped)
+	}
+	transport.CloseIdleConnections()
+	if _, err := client.Get(target.URL + "/items"); err == nil {
+		t.Error("expected the proxy to be closed")
+	}
+}
+
+// waitForRecorder waits until the recorder has dealt with n requests, which
+// it records after relaying them
+func waitForRecorder(t *testing.T, services *MyServices, n int) *models.Recorder {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		status := services.RecorderService.Status(context.Background())
+		if status.Recorded+status.Ignored >= n || time.Now().After(deadline) {
+			return status
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRecorderService_StreamsResponses(t *testing.T) {
+	next := make(chan struct{})
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for i := 1; i <= 2; i++ {
+			fmt.Fprintf(w, "data: %d\n\n", i)
+			w.(http.Flusher).Flush()
+			if i == 1 {
+				<-next
+			}
+		}
+	}))
+	defer target.Close()
+
+	services := newTestServices(t)
+	services.RecorderService.caDir = t.TempDir()
+	ctx := context.Background()
+	for _, address := range []string{"0.0.0.0:0", ":0", "example.com:8081"} {
+		if _, err := services.RecorderService.Start(ctx, &models.RecorderInput{Address: &address}); err == nil {
+			services.RecorderService.Stop(ctx)
+			t.Errorf("%s: expected a non-loopback address to be refused", address)
+		}
+	}
+	address := "127.0.0.1:0"
+	recorder, err := services.RecorderService.Start(ctx, &models.RecorderInput{Address: &address})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer services.RecorderService.Stop(ctx)
+
+	client := &http.Client{
+		Transport: &http.Transport{Proxy: http.ProxyURL(&url.URL{Scheme: "http", Host: recorder.Address})},
+		Timeout:   5 * time.Second,
+	}
+	resp, err := client.Get(target.URL + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	events := bufio.NewReader(resp.Body)
+	// the first event arrives while the target holds the second back
+	if line, err := events.ReadString('\n'); err != nil || line != "data: 1\n" {
+		t.Fatalf("first line = %q, %v", line, err)
+	}
+	close(next)
+	rest, _ := io.ReadAll(events)
+	if string(rest) != "\ndata: 2\n\n" {
+		t.Errorf("rest = %q", rest)
+	}
+
+	if status := waitForRecorder(t, services, 1); status.Recorded != 1 {
+		t.Fatalf("recorded %d", status.Recorded)
+	}
+	var request models.MyRequest
+	if err := services.MyRequestService.db.Take(&request).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := models.LoadResponseBodies(services.MyRequestService.db, &request); err != nil {
+		t.Fatal(err)
+	}
+	if request.ResponseBody != "data: 1\n\ndata: 2\n\n" {
+		t.Errorf("stored body = %q", request.ResponseBody)
+	}
+}
@@ -0,0 +1,160 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"sync"
+
+	"github.com/linn221/bane/models"
+	"gorm.io/gorm"
+)
+
+// ErrOutOfScope is returned when a request would go to a target outside its
+// project's scope
+var ErrOutOfScope = errors.New("out of scope")
+
+// scopeService keeps the scope rules of projects and checks the targets of
+// outgoing requests against them
+type scopeService struct {
+	db *gorm.DB
+}
+
+// SetScope replaces the project's rules
+func (s *scopeService) SetScope(ctx context.Context, projectId int, inScope models.ScopeRules, outOfScope models.ScopeRules) (*models.Project, error) {
+	project, err := firstById[models.Project](s.db.WithContext(ctx), projectId)
+	if err != nil {
+		return nil, fmt.Errorf("project %d not found: %v", projectId, err)
+	}
+	if _, err := models.NewScope(inScope, outOfScope); err != nil {
+		return nil, err
+	}
+	project.InScope, project.OutOfScope = inScope, outOfScope
+	err = s.db.WithContext(ctx).Model(project).Select("in_scope", "out_of_scope").Updates(project).Error
+	return project, err
+}
+
+// Check tells whether rawUrl is in the scope of the project, or of each
+// project with scope rules when projectId is nil
+func (s *scopeService) Check(ctx context.Context, rawUrl string, projectId *int) ([]*models.ScopeCheck, error) {
+	u, err := url.Parse(rawUrl)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid url %q", rawUrl)
+	}
+	var projects []*models.Project
+	query := s.db.WithContext(ctx).Order("id")
+	if projectId != nil {
+		query = query.Where("id = ?", *projectId)
+	}
+	if err := query.Find(&projects).Error; err != nil {
+		return nil, err
+	}
+	if projectId != nil && len(projects) == 0 {
+		return nil, fmt.Errorf("project %d not found", *projectId)
+	}
+
+	checks := []*models.ScopeCheck{}
+	for _, project := range projects {
+		scope, err := project.Scope()
+		if err != nil {
+			return nil, fmt.Errorf("project %s: %w", project.Name, err)
+		}
+		if projectId == nil && scope.Empty() {
+			continue
+		}
+		guard := &scopeGuard{scope: scope}
+		check := guard.check(ctx, u)
+		check.ProjectId = &project.Id
+		checks = append(checks, check)
+	}
+	return checks, nil
+}
+
+// guard loads the scope that requests of the project are held to. With
+// ignore set, as when the caller explicitly overrides the scope, nothing is
+// refused.
+func (s *scopeService) guard(ctx context.Context, projectId *int, ignore bool) (*scopeGuard, error) {
+	if projectId == nil || ignore {
+		return nil, nil
+	}
+	project, err := firstById[models.Project](s.db.WithContext(ctx), *projectId)
+	if err != nil {
+		return nil, err
+	}
+	scope, err := project.Scope()
+	if err != nil {
+		return nil, fmt.Errorf("project %s: %w", project.Name, err)
+	}
+	if scope.Empty() {
+		return nil, nil
+	}
+	return &scopeGuard{scope: scope}, nil
+}
+
+// scopeGuard checks the targets of one execution, such as a job, against a
+// scope. Hosts are resolved at most once, and only for CIDR rules; a host
+// that cannot be resolved matches no CIDR rule. A nil guard allows
+// everything.
+type scopeGuard struct {
+	scope *models.Scope
+
+	mu       sync.Mutex
+	resolved map[string][]net.IP
+}
+
+func (g *scopeGuard) check(ctx context.Context, u *url.URL) *models.ScopeCheck {
+	var addrs []net.IP
+	if g.scope.NeedsAddresses(u) {
+		addrs = g.addresses(ctx, u.Hostname())
+	}
+	return g.scope.Check(u, addrs)
+}
+
+func (g *scopeGuard) addresses(ctx context.Context, host string) []net.IP {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if addrs, ok := g.resolved[host]; ok {
+		return addrs
+	}
+	var addrs []net.IP
+	if resolved, err := net.DefaultResolver.LookupIPAddr(ctx, host); err == nil {
+		for _, addr := range resolved {
+			addrs = append(addrs, addr.IP)
+		}
+	}
+	if g.resolved == nil {
+		g.resolved = map[string][]net.IP{}
+	}
+	g.resolved[host] = addrs
+	return addrs
+}
+
+type scopeGuardKey struct{}
+
+// withScopeGuard makes the executor follow redirects only to URLs guard
+// allows
+func withScopeGuard(ctx context.Context, guard *scopeGuard) context.Context {
+	return context.WithValue(ctx, scopeGuardKey{}, guard)
+}
+
+// enforce refuses a request to an out-of-scope URL
+func (g *scopeGuard) enforce(ctx context.Context, rawUrl string) error {
+	if g == nil {
+		return nil
+	}
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return nil // the executor reports invalid URLs
+	}
+	if check := g.check(ctx, u); !check.InScope {
+		return fmt.Errorf("%w: %s %s; pass ignoreScope to send it anyway", ErrOutOfScope, rawUrl, check.Reason)
+	}
+	return nil
+}
+
+// allows reports whether the URL is in scope, for traffic bane only records
+func (g *scopeGuard) allows(ctx context.Context, u *url.URL) bool {
+	return g == nil || g.check(ctx, u).InScope
+}
@@ -0,0 +1,108 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/linn221/bane/models"
+	"github.com/linn221/bane/mystructs"
+)
+
+func TestScopeService_RefusesOutOfScopeTargets(t *testing.T) {
+	var mu sync.Mutex
+	var hits []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hits = append(hits, r.URL.Path)
+		mu.Unlock()
+		io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+	hitCount := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(hits)
+	}
+
+	services := newTestServices(t)
+	ctx := context.Background()
+	project, err := services.ProjectService.Create(ctx, &models.ProjectInput{
+		Name:       "program",
+		InScope:    models.ScopeRules{{Kind: "cidr", Value: "127.0.0.0/8"}},
+		OutOfScope: models.ScopeRules{{Kind: "prefix", Value: srv.URL + "/admin"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := services.ProjectService.Create(ctx, &models.ProjectInput{
+		Name:    "invalid",
+		InScope: models.ScopeRules{{Kind: "regex", Value: "("}},
+	}); err == nil {
+		t.Error("expected an invalid regex to be rejected")
+	}
+	if _, err := services.ProjectService.Create(ctx, &models.ProjectInput{Name: "unscoped"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := services.EndpointService.Create(ctx, &models.EndpointInput{
+		ProjectId: &project.Id,
+		Url:       mustVarString(t, srv.URL+"/{page=home}"),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := services.MyRequestService.ExecuteCurl(ctx, "endpoints1", mystructs.KVGroup{}, nil, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	admin := *mustKVGroup(t, "page:admin/users")
+	_, err = services.MyRequestService.ExecuteCurl(ctx, "endpoints1", admin, nil, false, nil)
+	if !errors.Is(err, ErrOutOfScope) || !strings.Contains(err.Error(), "prefix:"+srv.URL+"/admin") {
+		t.Errorf("expected an out-of-scope error naming the rule, got %v", err)
+	}
+	if hitCount() != 1 {
+		t.Fatalf("server was hit %d times, want 1", hitCount())
+	}
+	if _, err := services.MyRequestService.ExecuteCurl(ctx, "endpoints1", admin, nil, true, nil); err != nil {
+		t.Errorf("ignoreScope should send the request: %v", err)
+	}
+
+	wordList, err := services.WordService.CreateWordList(&models.WordListInput{Name: "pages"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := services.WordService.AddWordsToWordList(wordList.Id, []string{"a", "admin", "b"}); err != nil {
+		t.Fatal(err)
+	}
+	before := hitCount()
+	job, err := services.JobService.Fuzz(ctx, "endpoints1", "page", "wordlists1", 1, 0, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	services.JobService.running.Wait()
+	if job, err = services.JobService.Get(ctx, job.Id); err != nil {
+		t.Fatal(err)
+	}
+	if hitCount()-before != 2 || job.Failed != 1 {
+		t.Errorf("fuzz sent %d requests with %d failed, want 2 and 1", hitCount()-before, job.Failed)
+	}
+
+	checks, err := services.ScopeService.Check(ctx, srv.URL+"/admin", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(checks) != 1 || checks[0].InScope || *checks[0].ProjectId != project.Id {
+		t.Errorf("checks = %+v", checks)
+	}
+	checks, err = services.ScopeService.Check(ctx, "http://example.invalid/", &project.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(checks) != 1 || checks[0].InScope || checks[0].Reason != "matches no in-scope rule" {
+		t.Errorf("checks = %+v", checks)
+	}
+}
@@ -0,0 +1,321 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/linn221/bane/models"
+	"github.com/linn221/bane/mystructs"
+	"gorm.io/gorm"
+)
+
+type sequenceService struct {
+	db                 *gorm.DB
+	aliasService       *aliasService
+	myRequestService   *myRequestService
+	environmentService *environmentService
+	jobService         *jobService // runs sequences alongside the other jobs
+}
+
+func (s *sequenceService) Create(ctx context.Context, input *models.SequenceInput) (*models.Sequence, error) {
+	if len(input.Steps) == 0 {
+		return nil, fmt.Errorf("a sequence needs at least one step")
+	}
+	sequence := models.Sequence{
+		Name:        input.Name,
+		Description: input.Description,
+		ProjectId:   input.ProjectId,
+	}
+	for i, stepInput := range input.Steps {
+		number := i + 1
+		endpoint, err := first[models.Endpoint](ctx, s.db, s.aliasService, stepInput.EndpointAlias)
+		if err != nil {
+			return nil, fmt.Errorf("step %d: endpoint with alias '%s' not found: %v", number, stepInput.EndpointAlias, err)
+		}
+		step := models.SequenceStep{
+			SequenceNumber: number,
+			EndpointId:     endpoint.Id,
+			Variables:      derefKVGroup(stepInput.Variables),
+			Mappings:       derefKVGroup(stepInput.Mappings),
+		}
+		for _, mapping := range step.Mappings.KVPairs {
+			source, err := models.ParseValueSource(mapping.Value)
+			if err != nil {
+				return nil, fmt.Errorf("step %d: %v", number, err)
+			}
+			if source.Step >= number {
+				return nil, fmt.Errorf("step %d: mapping '%s' must read from an earlier step", number, mapping.Key)
+			}
+		}
+		sequence.Steps = append(sequence.Steps, step)
+	}
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&sequence).Error; err != nil {
+			return err
+		}
+		return s.aliasService.CreateAlias(tx, "sequences", sequence.Id, input.Alias)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &sequence, nil
+}
+
+func (s *sequenceService) Get(ctx context.Context, id *int, alias *string) (*models.Sequence, error) {
+	if id == nil && alias == nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	sequenceId := 0
+	if id != nil {
+		sequenceId = *id
+	} else {
+		var err error
+		if sequenceId, err = s.aliasService.GetReferenceId(ctx, *alias); err != nil {
+			return nil, err
+		}
+	}
+	var sequence models.Sequence
+	err := s.db.WithContext(ctx).Preload("Steps", func(db *gorm.DB) *gorm.DB {
+		return db.Order("sequence_number")
+	}).First(&sequence, sequenceId).Error
+	return &sequence, err
+}
+
+func (s *sequenceService) List(ctx context.Context) ([]*models.Sequence, error) {
+	var sequences []*models.Sequence
+	err := s.db.WithContext(ctx).Preload("Steps", func(db *gorm.DB) *gorm.DB {
+		return db.Order("sequence_number")
+	}).Order("id DESC").Find(&sequences).Error
+	return sequences, err
+}
+
+// Run starts a Job that sends every step in order, recording each as a
+// Request. It returns as soon as the job is started. The run stops at the
+// first step that gets no response or whose mappings cannot be resolved;
+// that step is still recorded with its error.
+// The environment's variables apply to every step unless overridden. A step
+// targeting a host outside its project's scope is refused, stopping the run,
+// unless ignoreScope is set.
+func (s *sequenceService) Run(ctx context.Context, alias string, variables mystructs.KVGroup, env *string, ignoreScope bool) (*models.Job, error) {
+	sequence, err := s.Get(ctx, nil, &alias)
+	if err != nil {
+		return nil, fmt.Errorf("sequence with alias '%s' not found: %v", alias, err)
+	}
+	vars, err := s.environmentService.Variables(ctx, env, sequence.ProjectId)
+	if err != nil {
+		return nil, err
+	}
+	maps.Copy(vars, variables.ToMap())
+
+	now := time.Now()
+	job := &models.Job{
+		Name:       "sequence " + sequence.Name,
+		Kind:       models.JobKindSequence,
+		Status:     models.JobStatusRunning,
+		SequenceId: &sequence.Id,
+		JobDate:    now,
+		StartedAt:  &now,
+		Total:      len(sequence.Steps),
+	}
+	if err := s.db.WithContext(ctx).Create(job).Error; err != nil {
+		return nil, err
+	}
+	s.jobService.spawn(context.Background(), job.Id, func(ctx context.Context) {
+		s.run(ctx, *job, sequence, vars, ignoreScope)
+	})
+	return job, nil
+}
+
+func (s *sequenceService) run(ctx context.Context, job models.Job, sequence *models.Sequence, vars map[string]string, ignoreScope bool) {
+	responses := map[int]*models.Request{}
+	for _, step := range sequence.Steps {
+		if ctx.Err() != nil {
+			job.Status = models.JobStatusCancelled
+			break
+		}
+		request, err := s.runStep(ctx, &job, step, vars, responses, ignoreScope)
+		job.Done++
+		if err != nil {
+			job.Failed++
+			job.Status = models.JobStatusFailed
+			job.Error = fmt.Sprintf("step %d: %v", step.SequenceNumber, err)
+			break
+		}
+		responses[step.SequenceNumber] = request
+	}
+	if job.Status == models.JobStatusRunning {
+		job.Status = models.JobStatusCompleted
+	}
+	finished := time.Now()
+	job.FinishedAt = &finished
+	s.db.Save(&job)
+}
+
+// runStep resolves the step's variables, sends it and stores the Request row,
+// along with the MyRequest of the exchange once it is sent.
+// vars carries mapped values forward to the later steps.
+func (s *sequenceService) runStep(ctx context.Context, job *models.Job, step models.SequenceStep, vars map[string]string, responses map[int]*models.Request, ignoreScope bool) (*models.Request, error) {
+	var endpoint models.Endpoint
+	if err := s.db.WithContext(ctx).First(&endpoint, step.EndpointId).Error; err != nil {
+		return nil, err
+	}
+
+	var mappingErr error
+	for _, mapping := range step.Mappings.KVPairs {
+		source, err := models.ParseValueSource(mapping.Value)
+		if err != nil {
+			mappingErr = err
+			break
+		}
+		value, err := source.Extract(responses[source.Step].Response())
+		if err != nil {
+			mappingErr = fmt.Errorf("mapping '%s': %v", mapping.Key, err)
+			break
+		}
+		vars[mapping.Key] = value
+	}
+	stepVars := make(map[string]string, len(vars))
+	for k, v := range vars {
+		stepVars[k] = v
+	}
+	for k, v := range step.Variables.ToMap() {
+		stepVars[k] = v
+	}
+
+	rendered := endpoint.Render(stepVars)
+	// a step cancelled in flight is still recorded
+	store := context.WithoutCancel(ctx)
+	cookies := s.myRequestService.cookieService
+	jar, err := cookies.Jar(ctx, endpoint.ProjectId)
+	if err != nil {
+		return nil, err
+	}
+	jar.Apply(rendered, time.Now())
+	request := newSequenceRequest(job.Id, step, rendered)
+	request.Variables = serializeVariables(stepVars)
+	guard, err := s.myRequestService.scopeService.guard(ctx, endpoint.ProjectId, ignoreScope)
+	if err != nil {
+		return nil, err
+	}
+	if mappingErr != nil {
+		request.Error = mappingErr.Error()
+	} else if err := guard.enforce(ctx, rendered.Url); err != nil {
+		request.Error = err.Error()
+	} else {
+		proxy, err := s.myRequestService.proxy(ctx, &endpoint)
+		if err != nil {
+			return nil, err
+		}
+		limit, err := s.myRequestService.rateLimit(ctx, &endpoint)
+		if err != nil {
+			return nil, err
+		}
+		sendCtx := withTransportOptions(withRateLimit(withProxy(ctx, proxy), limit), endpoint.Transport)
+		record := s.myRequestService.executor.Execute(withScopeGuard(sendCtx, guard), rendered)
+		record.EndpointId = endpoint.Id
+		record.JobId = &job.Id
+		record.Variables = request.Variables
+		if err := cookies.Capture(store, endpoint.ProjectId, record); err != nil {
+			return nil, err
+		}
+		// stored as any other request, so the step is searchable and scanned
+		if _, err := s.myRequestService.Create(store, record); err != nil {
+			return nil, err
+		}
+		request.MyRequestId = &record.Id
+		fillSequenceResponse(request, record)
+	}
+	if err := s.db.WithContext(store).Create(request).Error; err != nil {
+		return nil, err
+	}
+	if request.Error != "" {
+		return request, fmt.Errorf("%s", request.Error)
+	}
+	return request, nil
+}
+
+// newSequenceRequest fills the request half of a Request row
+func newSequenceRequest(jobId int, step models.SequenceStep, rendered *models.RenderedRequest) *models.Request {
+	request := &models.Request{
+		EndpointId:     step.EndpointId,
+		JobId:          jobId,
+		SequenceNumber: step.SequenceNumber,
+		HttpMethod:     models.HttpMethod(rendered.Method),
+		HttpBody:       rendered.Body,
+		HttpHeaders:    mystructs.KVGroup{KVPairs: rendered.Headers},
+	}
+	if u, err := url.Parse(rendered.Url); err == nil {
+		request.HttpSchema = models.HttpSchema(u.Scheme)
+		request.HttpDomain = u.Host
+		request.HttpPath = u.EscapedPath()
+		query := u.Query()
+		for _, key := range slices.Sorted(maps.Keys(query)) {
+			for _, value := range query[key] {
+				request.HttpQueries.KVPairs = append(request.HttpQueries.KVPairs, mystructs.KVPair{Key: key, Value: value})
+			}
+		}
+	}
+	header := http.Header{}
+	header.Set("Cookie", rendered.Header("Cookie"))
+	for _, cookie := range (&http.Request{Header: header}).Cookies() {
+		request.HttpCookies.KVPairs = append(request.HttpCookies.KVPairs, mystructs.KVPair{Key: cookie.Name, Value: cookie.Value})
+	}
+	return request
+}
+
+// fillSequenceResponse copies the response half of an executed record
+func fillSequenceResponse(request *models.Request, record *models.MyRequest) {
+	request.Error = record.Error
+	request.ResponseStatusCode = record.ResponseStatus
+	request.ResponseContentType = record.ContentType
+	request.ResponseLatency = time.Duration(record.Latency) * time.Millisecond
+	request.ResponseSize = int(record.Size)
+	request.ResponseBody = record.ResponseBody
+	request.ResponseBodyHash = record.ResponseBodyHash
+
+	var headers http.Header
+	json.Unmarshal([]byte(record.ResponseHeaders), &headers)
+	for _, key := range slices.Sorted(maps.Keys(headers)) {
+		for _, value := range headers[key] {
+			request.ResponseHeaders.KVPairs = append(request.ResponseHeaders.KVPairs, mystructs.KVPair{Key: key, Value: value})
+		}
+	}
+	for _, cookie := range (&http.Response{Header: headers}).Cookies() {
+		request.ResponseCookies.KVPairs = append(request.ResponseCookies.KVPairs, mystructs.KVPair{Key: cookie.Name, Value: cookie.Value})
+	}
+}
+
+func derefKVGroup(group *mystructs.KVGroup) mystructs.KVGroup {
+	if group == nil {
+		return mystructs.KVGroup{}
+	}
+	return *group
+}
+
+// Steps returns the Request rows recorded by a sequence run, in step order
+func (s *sequenceService) Steps(ctx context.Context, jobId int) ([]*models.Request, error) {
+	var requests []*models.Request
+	err := s.db.WithContext(ctx).Where("job_id = ?", jobId).Order("sequence_number").Find(&requests).Error
+	if err != nil {
+		return nil, err
+	}
+	hashes := make([]string, 0, len(requests))
+	for _, request := range requests {
+		hashes = append(hashes, request.ResponseBodyHash)
+	}
+	bodies, err := models.ResponseBodies(s.db.WithContext(ctx), hashes)
+	if err != nil {
+		return nil, err
+	}
+	for _, request := range requests {
+		request.ResponseBody = bodies[request.ResponseBodyHash]
+	}
+	return requests, nil
+}
@@ -0,0 +1,128 @@
+package services
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/linn221/bane/models"
+	"github.com/linn221/bane/mystructs"
+)
+
+func TestSequenceService_Run(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "s3cret"})
+			io.WriteString(w, `{"user":{"id":42}}`)
+		case "/form":
+			io.WriteString(w, `<input name="csrf" value="tok-1">`)
+		case "/delete":
+			if r.Header.Get("Cookie") != "session=s3cret" || r.FormValue("csrf") != "tok-1" || r.FormValue("id") != "42" {
+				http.Error(w, "forbidden", http.StatusForbidden)
+				return
+			}
+			io.WriteString(w, "deleted")
+		}
+	}))
+	defer srv.Close()
+
+	services := newTestServices(t)
+	ctx := context.Background()
+	for _, input := range []models.EndpointInput{
+		{Url: mustVarString(t, srv.URL+"/login")},
+		{Url: mustVarString(t, srv.URL+"/form")},
+		{
+			Url:     mustVarString(t, srv.URL+"/delete?id={id=0}&csrf={csrf=x}"),
+			Headers: mustVarKVGroup(t, "Cookie:session={session=x}"),
+		},
+	} {
+		if _, err := services.EndpointService.Create(ctx, &input); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err := services.SequenceService.Create(ctx, &models.SequenceInput{
+		Name: "bad",
+		Steps: []*models.SequenceStepInput{
+			{EndpointAlias: "endpoints1", Mappings: mustKVGroup(t, "id:1.json.$.user.id")},
+		},
+	})
+	if err == nil {
+		t.Error("expected an error for a mapping that reads from its own step")
+	}
+
+	_, err = services.SequenceService.Create(ctx, &models.SequenceInput{
+		Name:  "delete user",
+		Alias: "deleteuser",
+		Steps: []*models.SequenceStepInput{
+			{EndpointAlias: "endpoints1"},
+			{EndpointAlias: "endpoints2", Mappings: mustKVGroup(t, "id:1.json.$.user.id session:1.cookie.session")},
+			{EndpointAlias: "endpoints3", Mappings: mustKVGroup(t, `csrf:2.regex.value="([^"]+)"`)},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	job, err := services.SequenceService.Run(ctx, "deleteuser", mystructs.KVGroup{}, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	services.JobService.running.Wait()
+
+	job, err = services.JobService.Get(ctx, job.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Status != models.JobStatusCompleted || job.Done != 3 {
+		t.Fatalf("status=%s done=%d error=%q", job.Status, job.Done, job.Error)
+	}
+	steps, err := services.SequenceService.Steps(ctx, job.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(steps) != 3 {
+		t.Fatalf("recorded %d steps, want 3", len(steps))
+	}
+	last := steps[2]
+	if last.ResponseStatusCode != http.StatusOK || last.ResponseBody != "deleted" {
+		t.Errorf("last step status=%d body=%q", last.ResponseStatusCode, last.ResponseBody)
+	}
+	if len(steps[0].ResponseCookies.KVPairs) != 1 || steps[0].ResponseCookies.KVPairs[0].Value != "s3cret" {
+		t.Errorf("login cookies=%v", steps[0].ResponseCookies.KVPairs)
+	}
+	// every step is in the request history too
+	requests, err := services.MyRequestService.List(ctx, &models.MyRequestFilter{JobId: job.Id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(requests) != 3 || last.MyRequestId == nil || last.ResponseBodyHash != requests[0].ResponseBodyHash {
+		t.Errorf("stored %d requests, last step %v with body %q", len(requests), last.MyRequestId, last.ResponseBodyHash)
+	}
+
+	patched, err := PatchModel(ctx, services.SequenceService.db, services.AliasService, "deleteuser", models.PatchInput{
+		Values: []models.KVString{{Key: "name", Value: "remove user"}},
+	})
+	if err != nil || !patched {
+		t.Fatalf("patch: %v", err)
+	}
+	sequence, err := services.SequenceService.Get(ctx, nil, ptr("deleteuser"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sequence.Name != "remove user" {
+		t.Errorf("patched name=%q", sequence.Name)
+	}
+}
+
+func mustKVGroup(t *testing.T, s string) *mystructs.KVGroup {
+	t.Helper()
+	group, err := mystructs.NewKVGroupFromString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &group
+}
@@ -0,0 +1,66 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/linn221/bane/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type variableService struct {
+	db *gorm.DB
+}
+
+var variableName = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// Set stores value under name, replacing any previous value
+func (s *variableService) Set(ctx context.Context, name string, value string, endpointId *int) (*models.Variable, error) {
+	if !variableName.MatchString(name) {
+		return nil, fmt.Errorf("invalid variable name %q", name)
+	}
+	variable := models.Variable{Name: name, Value: value, EndpointId: endpointId}
+	err := s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"value", "endpoint_id", "updated_at"}),
+	}).Create(&variable).Error
+	if err != nil {
+		return nil, err
+	}
+	// the id is not reported back on conflict, so read the row again
+	var stored models.Variable
+	if err := s.db.WithContext(ctx).Where("name = ?", name).First(&stored).Error; err != nil {
+		return nil, err
+	}
+	return &stored, nil
+}
+
+func (s *variableService) List(ctx context.Context) ([]*models.Variable, error) {
+	var variables []*models.Variable
+	err := s.db.WithContext(ctx).Order("name").Find(&variables).Error
+	return variables, err
+}
+
+// Lookup returns the stored values of the given names; names that are not
+// in the store are left out
+func (s *variableService) Lookup(ctx context.Context, names []string) (map[string]string, error) {
+	values := make(map[string]string, len(names))
+	if len(names) == 0 {
+		return values, nil
+	}
+	var variables []models.Variable
+	if err := s.db.WithContext(ctx).Where("name IN ?", names).Find(&variables).Error; err != nil {
+		return nil, err
+	}
+	for _, v := range variables {
+		values[v.Name] = v.Value
+	}
+	return values, nil
+}
+
+func (s *variableService) Delete(ctx context.Context, name string) (bool, error) {
+	result := s.db.WithContext(ctx).Where("name = ?", name).Delete(&models.Variable{})
+	return result.RowsAffected > 0, result.Error
+}
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/linn221/bane/models"
+	"github.com/linn221/bane/mystructs"
+)
+
+func TestMyRequestService_ExtractorsFeedVariableStore(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			w.Header().Set("X-Request-Id", "req-7")
+			io.WriteString(w, `{"data":{"token":"t0k3n"}}`)
+		case "/me":
+			if r.Header.Get("Authorization") != "Bearer t0k3n" {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			io.WriteString(w, "ok")
+		}
+	}))
+	defer srv.Close()
+
+	services := newTestServices(t)
+	ctx := context.Background()
+	if _, err := services.EndpointService.Create(ctx, &models.EndpointInput{
+		Url:        mustVarString(t, srv.URL+"/login"),
+		Extractors: mustKVGroup(t, "x:regex.("),
+	}); err == nil {
+		t.Error("expected an error for an extractor without a valid kind")
+	}
+	if _, err := services.EndpointService.Create(ctx, &models.EndpointInput{
+		Url:        mustVarString(t, srv.URL+"/login"),
+		Extractors: mustKVGroup(t, "token:json.$.data.token requestId:header.X-Request-Id missing:cookie.none"),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := services.EndpointService.Create(ctx, &models.EndpointInput{
+		Url:     mustVarString(t, srv.URL+"/me"),
+		Headers: mustVarKVGroup(t, "Authorization:Bearer\\ {token}"),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	request, err := services.MyRequestService.ExecuteCurl(ctx, "endpoints2", mystructs.KVGroup{}, nil, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if request.ResponseStatus != http.StatusUnauthorized {
+		t.Errorf("status before login = %d, want 401", request.ResponseStatus)
+	}
+
+	request, err = services.MyRequestService.ExecuteCurl(ctx, "endpoints1", mystructs.KVGroup{}, nil, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if request.Extracted != `{"requestId":"req-7","token":"t0k3n"}` {
+		t.Errorf("Extracted = %s", request.Extracted)
+	}
+
+	request, err = services.MyRequestService.ExecuteCurl(ctx, "endpoints2", mystructs.KVGroup{}, nil, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if request.ResponseStatus != http.StatusOK {
+		t.Errorf("status with stored token = %d, want 200", request.ResponseStatus)
+	}
+	if request.Variables != `{"token":"t0k3n"}` {
+		t.Errorf("Variables = %s", request.Variables)
+	}
+
+	// explicit variables win over the store
+	request, err = services.MyRequestService.ExecuteCurl(ctx, "endpoints2", *mustKVGroup(t, "token:other"), nil, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if request.ResponseStatus != http.StatusUnauthorized {
+		t.Errorf("status with explicit token = %d, want 401", request.ResponseStatus)
+	}
+
+	if _, err := services.VariableService.Set(ctx, "token", "manual", nil); err != nil {
+		t.Fatal(err)
+	}
+	variables, err := services.VariableService.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(variables) != 2 || variables[1].Name != "token" || variables[1].Value != "manual" || variables[1].EndpointId != nil {
+		t.Errorf("variables = %+v", variables)
+	}
+	if deleted, err := services.VariableService.Delete(ctx, "token"); err != nil || !deleted {
+		t.Errorf("Delete = %v, %v", deleted, err)
+	}
+}
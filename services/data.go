@@ -8,13 +8,15 @@ import (
 
 func toModelStruct(tableName string) any {
 	var tableNameToStruct = map[string]any{
-		"endpoints":   models.Endpoint{},
-		"projects":    models.Project{},
-		"notes":       models.Note{},
-		"words":       models.Word{},
-		"wordlists":   models.WordList{},
-		"my_requests": models.MyRequest{},
-		"aliases":     models.Alias{},
+		"endpoints":    &models.Endpoint{},
+		"projects":     &models.Project{},
+		"notes":        &models.Note{},
+		"words":        &models.Word{},
+		"wordlists":    &models.WordList{},
+		"my_requests":  &models.MyRequest{},
+		"aliases":      &models.Alias{},
+		"environments": &models.Environment{},
+		"sequences":    &models.Sequence{},
 	}
 	emptyStruct, ok := tableNameToStruct[tableName]
 	if !ok {
@@ -3,6 +3,9 @@ package services
 import (
 	"context"
 
+	"github.com/linn221/bane/models"
+	"github.com/linn221/bane/mystructs"
+
 	"gorm.io/gorm"
 )
 
@@ -34,3 +37,32 @@ func first[T any](ctx context.Context, db *gorm.DB, aliasService *aliasService,
 func getIdByAlias[T any](ctx context.Context, db *gorm.DB, aliasService *aliasService, alias string) (int, error) {
 	return aliasService.GetReferenceId(ctx, alias)
 }
+
+// exportVariables merges the caller's variables with the variable chosen for
+// fuzzing, which always renders as the ffuf keyword
+func exportVariables(variables *mystructs.KVGroup, fuzz *string) map[string]string {
+	vars := map[string]string{}
+	if variables != nil {
+		vars = variables.ToMap()
+	}
+	if fuzz != nil && *fuzz != "" {
+		vars[*fuzz] = models.FuzzKeyword
+	}
+	return vars
+}
+
+// fillVariables sets each of the named placeholders that vars lacks, taking
+// the value from the first source that has one
+func fillVariables(vars map[string]string, names []string, sources ...map[string]string) {
+	for _, name := range names {
+		if _, ok := vars[name]; ok {
+			continue
+		}
+		for _, source := range sources {
+			if value, ok := source[name]; ok {
+				vars[name] = value
+				break
+			}
+		}
+	}
+}
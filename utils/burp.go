@@ -0,0 +1,111 @@
+package utils
+
+import (
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"time"
+)
+
+// BurpItems is the document written by Burp Suite's "Save items"
+type BurpItems struct {
+	XMLName     xml.Name   `xml:"items"`
+	BurpVersion string     `xml:"burpVersion,attr,omitempty"`
+	ExportTime  string     `xml:"exportTime,attr,omitempty"`
+	Items       []BurpItem `xml:"item"`
+}
+
+type BurpItem struct {
+	Time           string   `xml:"time"`
+	Url            BurpText `xml:"url"`
+	Host           BurpHost `xml:"host"`
+	Port           int      `xml:"port"`
+	Protocol       string   `xml:"protocol"`
+	Method         BurpText `xml:"method"`
+	Path           BurpText `xml:"path"`
+	Extension      string   `xml:"extension"`
+	Request        BurpData `xml:"request"`
+	Status         int      `xml:"status"`
+	ResponseLength int      `xml:"responselength"`
+	MimeType       string   `xml:"mimetype"`
+	Response       BurpData `xml:"response"`
+	Comment        string   `xml:"comment"`
+}
+
+// BurpText is character data Burp wraps in CDATA
+type BurpText struct {
+	Text string `xml:",cdata"`
+}
+
+type BurpHost struct {
+	Ip   string `xml:"ip,attr"`
+	Name string `xml:",chardata"`
+}
+
+// BurpData is a raw HTTP message, base64-encoded when Base64 is true
+type BurpData struct {
+	Base64 bool   `xml:"base64,attr"`
+	Data   string `xml:",cdata"`
+}
+
+// burpTimeLayouts covers the zero-padded day Burp writes and the usual Unix date
+var burpTimeLayouts = []string{"Mon Jan 02 15:04:05 MST 2006", time.UnixDate}
+
+// ParseBurp reads a Burp Suite items document
+func ParseBurp(r io.Reader) (*BurpItems, error) {
+	var items BurpItems
+	decoder := xml.NewDecoder(r)
+	decoder.Strict = false // Burp declares an inline DTD
+	if err := decoder.Decode(&items); err != nil {
+		return nil, fmt.Errorf("invalid Burp XML file: %w", err)
+	}
+	return &items, nil
+}
+
+// Write writes the items as a Burp Suite document
+func (items *BurpItems) Write(w io.Writer) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(items); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// Decoded returns the raw HTTP message
+func (d BurpData) Decoded() (string, error) {
+	if !d.Base64 {
+		return d.Data, nil
+	}
+	decoded, err := base64.StdEncoding.DecodeString(d.Data)
+	if err != nil {
+		return "", fmt.Errorf("invalid base64 data: %w", err)
+	}
+	return string(decoded), nil
+}
+
+// NewBurpData base64-encodes a raw HTTP message, as Burp does by default
+func NewBurpData(raw string) BurpData {
+	return BurpData{Base64: true, Data: base64.StdEncoding.EncodeToString([]byte(raw))}
+}
+
+// ParsedTime returns when the item was recorded, or the zero time if the
+// time cannot be read
+func (item *BurpItem) ParsedTime() time.Time {
+	for _, layout := range burpTimeLayouts {
+		if t, err := time.Parse(layout, item.Time); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// FormatBurpTime formats t the way Burp writes item times
+func FormatBurpTime(t time.Time) string {
+	return t.Format(burpTimeLayouts[0])
+}
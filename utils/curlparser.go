@@ -0,0 +1,350 @@
+package utils
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/linn221/bane/mystructs"
+)
+
+// CurlRequest holds everything extracted from a curl command line
+type CurlRequest struct {
+	Method          string
+	Url             string
+	Headers         []mystructs.KVPair
+	Body            string
+	Insecure        bool // -k
+	FollowRedirects bool // -L
+	Compressed      bool // --compressed
+}
+
+// multipartBoundary is fixed so imports of the same command are stable
+const multipartBoundary = "----BaneFormBoundary7MA4YWxkTrZu0gW"
+
+// curl options that take a value we have no use for; listed so the value
+// is not mistaken for the URL
+var curlIgnoredWithValue = map[string]bool{
+	"-o": true, "--output": true, "-m": true, "--max-time": true, "--connect-timeout": true,
+	"-x": true, "--proxy": true, "-U": true, "--proxy-user": true, "-w": true, "--write-out": true,
+	"-c": true, "--cookie-jar": true, "--cacert": true, "--capath": true, "-E": true, "--cert": true,
+	"--key": true, "--resolve": true, "--connect-to": true, "--interface": true, "--retry": true,
+	"--retry-delay": true, "--retry-max-time": true, "--max-redirs": true, "--limit-rate": true,
+	"-K": true, "--config": true, "-D": true, "--dump-header": true, "-y": true, "-Y": true,
+	"--speed-time": true, "--speed-limit": true, "-z": true, "--time-cond": true, "--trace": true,
+	"--trace-ascii": true, "--stderr": true, "-Q": true, "--quote": true, "--cert-type": true,
+	"--key-type": true, "--ciphers": true, "--proto": true, "--proto-redir": true,
+}
+
+// short options that take no value and may be grouped, as in -sSLk
+const curlShortFlags = "sSLkvIiGfgnNq0123456#:"
+
+// ParseCurl parses a curl command line, as copied from browser devtools, into a CurlRequest.
+// Only the parts that describe the request are kept; output and progress options are ignored.
+func ParseCurl(command string) (*CurlRequest, error) {
+	words, err := SplitShellWords(strings.TrimSpace(command))
+	if err != nil {
+		return nil, fmt.Errorf("failed to split curl command: %w", err)
+	}
+	if len(words) == 0 || path.Base(words[0]) != "curl" {
+		return nil, fmt.Errorf("not a curl command")
+	}
+
+	req := &CurlRequest{}
+	var data []string
+	var forms []curlFormPart
+	var user string
+	head, get := false, false
+
+	args := words[1:]
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		name, value, hasValue := arg, "", false
+		// --option=value
+		if strings.HasPrefix(arg, "--") {
+			if eq := strings.IndexByte(arg, '='); eq != -1 {
+				name, value, hasValue = arg[:eq], arg[eq+1:], true
+			}
+		} else if strings.HasPrefix(arg, "-") && len(arg) > 2 && !strings.ContainsRune(curlShortFlags, rune(arg[1])) {
+			// -XPOST, -H'Accept: */*'
+			name, value, hasValue = arg[:2], arg[2:], true
+		}
+		takeValue := func() (string, error) {
+			if hasValue {
+				return value, nil
+			}
+			if i+1 >= len(args) {
+				return "", fmt.Errorf("option %s needs a value", name)
+			}
+			i++
+			return args[i], nil
+		}
+
+		switch name {
+		case "-X", "--request":
+			if req.Method, err = takeValue(); err != nil {
+				return nil, err
+			}
+		case "-H", "--header":
+			v, err := takeValue()
+			if err != nil {
+				return nil, err
+			}
+			if header, ok := parseCurlHeader(v); ok {
+				req.Headers = append(req.Headers, header)
+			}
+		case "-A", "--user-agent":
+			v, err := takeValue()
+			if err != nil {
+				return nil, err
+			}
+			req.Headers = append(req.Headers, mystructs.KVPair{Key: "User-Agent", Value: v})
+		case "-e", "--referer":
+			v, err := takeValue()
+			if err != nil {
+				return nil, err
+			}
+			req.Headers = append(req.Headers, mystructs.KVPair{Key: "Referer", Value: v})
+		case "-d", "--data", "--data-ascii":
+			v, err := takeValue()
+			if err != nil {
+				return nil, err
+			}
+			// like curl, -d strips newlines
+			data = append(data, strings.NewReplacer("\r", "", "\n", "").Replace(v))
+		case "--data-raw", "--data-binary":
+			v, err := takeValue()
+			if err != nil {
+				return nil, err
+			}
+			data = append(data, v)
+		case "--data-urlencode":
+			v, err := takeValue()
+			if err != nil {
+				return nil, err
+			}
+			data = append(data, urlencodeCurlData(v))
+		case "-F", "--form", "--form-string":
+			v, err := takeValue()
+			if err != nil {
+				return nil, err
+			}
+			part, err := parseCurlForm(v, name == "--form-string")
+			if err != nil {
+				return nil, err
+			}
+			forms = append(forms, part)
+		case "-b", "--cookie":
+			v, err := takeValue()
+			if err != nil {
+				return nil, err
+			}
+			// without a '=' the value names a cookie file, which we cannot read
+			if strings.Contains(v, "=") {
+				req.Headers = append(req.Headers, mystructs.KVPair{Key: "Cookie", Value: v})
+			}
+		case "-u", "--user":
+			if user, err = takeValue(); err != nil {
+				return nil, err
+			}
+		case "--url":
+			if req.Url, err = takeValue(); err != nil {
+				return nil, err
+			}
+		case "-k", "--insecure":
+			req.Insecure = true
+		case "-L", "--location":
+			req.FollowRedirects = true
+		case "--compressed":
+			req.Compressed = true
+		case "-I", "--head":
+			head = true
+		case "-G", "--get":
+			get = true
+		default:
+			switch {
+			case curlIgnoredWithValue[name]:
+				if _, err := takeValue(); err != nil {
+					return nil, err
+				}
+			case strings.HasPrefix(arg, "-") && len(arg) > 1:
+				// grouped short flags such as -sSLk, or a flag we don't care about
+				if !strings.HasPrefix(arg, "--") {
+					for _, f := range arg[1:] {
+						switch f {
+						case 'k':
+							req.Insecure = true
+						case 'L':
+							req.FollowRedirects = true
+						case 'I':
+							head = true
+						case 'G':
+							get = true
+						}
+					}
+				}
+			default:
+				if req.Url == "" {
+					req.Url = arg
+				}
+			}
+		}
+	}
+
+	if req.Url == "" {
+		return nil, fmt.Errorf("no URL found in curl command")
+	}
+	if !strings.Contains(req.Url, "://") {
+		req.Url = "http://" + req.Url
+	}
+
+	if user != "" && !hasHeader(req.Headers, "Authorization") {
+		req.Headers = append(req.Headers, mystructs.KVPair{
+			Key:   "Authorization",
+			Value: "Basic " + base64.StdEncoding.EncodeToString([]byte(user)),
+		})
+	}
+
+	switch {
+	case len(forms) > 0:
+		req.Body = buildMultipartBody(forms)
+		if !hasHeader(req.Headers, "Content-Type") {
+			req.Headers = append(req.Headers, mystructs.KVPair{
+				Key:   "Content-Type",
+				Value: "multipart/form-data; boundary=" + multipartBoundary,
+			})
+		}
+	case len(data) > 0 && get:
+		separator := "?"
+		if strings.Contains(req.Url, "?") {
+			separator = "&"
+		}
+		req.Url += separator + strings.Join(data, "&")
+	case len(data) > 0:
+		req.Body = strings.Join(data, "&")
+		if !hasHeader(req.Headers, "Content-Type") {
+			req.Headers = append(req.Headers, mystructs.KVPair{Key: "Content-Type", Value: "application/x-www-form-urlencoded"})
+		}
+	}
+
+	if req.Method == "" {
+		switch {
+		case head:
+			req.Method = "HEAD"
+		case req.Body != "":
+			req.Method = "POST"
+		default:
+			req.Method = "GET"
+		}
+	}
+	req.Method = strings.ToUpper(req.Method)
+
+	return req, nil
+}
+
+// parseCurlHeader reads "Name: value". "Name;" means an empty header and
+// "Name:" with nothing after it means curl should drop the header.
+func parseCurlHeader(h string) (mystructs.KVPair, bool) {
+	if colon := strings.IndexByte(h, ':'); colon != -1 {
+		name := strings.TrimSpace(h[:colon])
+		value := strings.TrimSpace(h[colon+1:])
+		if name == "" || value == "" {
+			return mystructs.KVPair{}, false
+		}
+		return mystructs.KVPair{Key: name, Value: value}, true
+	}
+	if strings.HasSuffix(h, ";") {
+		return mystructs.KVPair{Key: strings.TrimSpace(strings.TrimSuffix(h, ";"))}, true
+	}
+	return mystructs.KVPair{}, false
+}
+
+// urlencodeCurlData implements the content forms of --data-urlencode:
+// "content", "=content" and "name=content". File forms are kept verbatim.
+func urlencodeCurlData(v string) string {
+	if strings.HasPrefix(v, "=") {
+		return url.QueryEscape(v[1:])
+	}
+	if eq := strings.IndexByte(v, '='); eq != -1 {
+		return v[:eq] + "=" + url.QueryEscape(v[eq+1:])
+	}
+	if strings.Contains(v, "@") {
+		return v
+	}
+	return url.QueryEscape(v)
+}
+
+type curlFormPart struct {
+	name        string
+	value       string
+	filename    string
+	contentType string
+}
+
+// parseCurlForm reads -F name=value, name=@file and name=<file, with optional
+// ;type= and ;filename= attributes. File contents cannot be read, so file parts
+// are sent empty with their filename kept.
+func parseCurlForm(v string, literal bool) (curlFormPart, error) {
+	eq := strings.IndexByte(v, '=')
+	if eq == -1 {
+		return curlFormPart{}, fmt.Errorf("invalid form field %q", v)
+	}
+	part := curlFormPart{name: v[:eq], value: v[eq+1:]}
+	if literal {
+		return part, nil
+	}
+	if strings.HasPrefix(part.value, "@") || strings.HasPrefix(part.value, "<") {
+		isUpload := part.value[0] == '@'
+		attrs := strings.Split(part.value[1:], ";")
+		if isUpload {
+			part.filename = path.Base(attrs[0])
+		}
+		part.value = ""
+		for _, attr := range attrs[1:] {
+			if k, val, ok := strings.Cut(attr, "="); ok {
+				switch strings.TrimSpace(k) {
+				case "type":
+					part.contentType = val
+				case "filename":
+					part.filename = strings.Trim(val, `"`)
+				}
+			}
+		}
+		return part, nil
+	}
+	if value, attrs, ok := strings.Cut(part.value, ";type="); ok {
+		part.value, part.contentType = value, attrs
+	}
+	return part, nil
+}
+
+func buildMultipartBody(parts []curlFormPart) string {
+	var b strings.Builder
+	for _, p := range parts {
+		b.WriteString("--" + multipartBoundary + "\r\n")
+		b.WriteString(`Content-Disposition: form-data; name="` + p.name + `"`)
+		if p.filename != "" {
+			b.WriteString(`; filename="` + p.filename + `"`)
+		}
+		b.WriteString("\r\n")
+		if p.contentType != "" {
+			b.WriteString("Content-Type: " + p.contentType + "\r\n")
+		} else if p.filename != "" {
+			b.WriteString("Content-Type: application/octet-stream\r\n")
+		}
+		b.WriteString("\r\n" + p.value + "\r\n")
+	}
+	b.WriteString("--" + multipartBoundary + "--\r\n")
+	return b.String()
+}
+
+func hasHeader(headers []mystructs.KVPair, name string) bool {
+	for _, h := range headers {
+		if strings.EqualFold(h.Key, name) {
+			return true
+		}
+	}
+	return false
+}
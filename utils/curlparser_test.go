@@ -0,0 +1,88 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseCurl_DevtoolsCopy(t *testing.T) {
+	cmd := `curl 'https://api.example.com/v1/items?page=2' \
+  -H 'accept: application/json' \
+  -H $'x-note: it\'s\ttabbed' \
+  -b 'session=abc; theme=dark' \
+  --data-raw '{"name":"widget"}' \
+  --compressed`
+	req, err := ParseCurl(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Method != "POST" || req.Url != "https://api.example.com/v1/items?page=2" {
+		t.Errorf("method=%q url=%q", req.Method, req.Url)
+	}
+	if req.Body != `{"name":"widget"}` || !req.Compressed {
+		t.Errorf("body=%q compressed=%v", req.Body, req.Compressed)
+	}
+	want := map[string]string{
+		"accept":       "application/json",
+		"x-note":       "it's\ttabbed",
+		"Cookie":       "session=abc; theme=dark",
+		"Content-Type": "application/x-www-form-urlencoded",
+	}
+	if len(req.Headers) != len(want) {
+		t.Fatalf("headers=%v", req.Headers)
+	}
+	for _, h := range req.Headers {
+		if want[h.Key] != h.Value {
+			t.Errorf("header %s=%q want %q", h.Key, h.Value, want[h.Key])
+		}
+	}
+}
+
+func TestParseCurl_Flags(t *testing.T) {
+	req, err := ParseCurl(`curl -sSLk -XPUT -u admin:secret --url example.com:8080/login --data-urlencode 'q=a b' -d x=1`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Method != "PUT" || req.Url != "http://example.com:8080/login" {
+		t.Errorf("method=%q url=%q", req.Method, req.Url)
+	}
+	if !req.Insecure || !req.FollowRedirects {
+		t.Errorf("insecure=%v followRedirects=%v", req.Insecure, req.FollowRedirects)
+	}
+	if req.Body != "q=a+b&x=1" {
+		t.Errorf("body=%q", req.Body)
+	}
+	if !hasHeader(req.Headers, "Authorization") {
+		t.Errorf("missing basic auth header: %v", req.Headers)
+	}
+}
+
+func TestParseCurl_Form(t *testing.T) {
+	req, err := ParseCurl(`curl https://example.com/upload -F 'title=hello world' -F 'file=@/tmp/a.png;type=image/png'`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Method != "POST" {
+		t.Errorf("method=%q", req.Method)
+	}
+	for _, want := range []string{
+		`name="title"` + "\r\n\r\nhello world",
+		`name="file"; filename="a.png"` + "\r\nContent-Type: image/png",
+	} {
+		if !strings.Contains(req.Body, want) {
+			t.Errorf("body missing %q:\n%s", want, req.Body)
+		}
+	}
+}
+
+func TestParseCurl_Errors(t *testing.T) {
+	for _, cmd := range []string{
+		`wget https://example.com`,
+		`curl -H 'accept: */*'`,
+		`curl 'https://example.com`,
+	} {
+		if _, err := ParseCurl(cmd); err == nil {
+			t.Errorf("expected an error for %q", cmd)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package utils
+
+type EditOp int
+
+const (
+	EditEqual EditOp = iota
+	EditDelete
+	EditInsert
+)
+
+// Edit is one step of a diff. Equal and Delete edits point into a,
+// Insert edits point into b.
+type Edit struct {
+	Op    EditOp
+	Index int
+}
+
+// maxEditDistance bounds the work done by DiffStrings; inputs that differ by
+// more than this many edits are reported as one big replacement
+const maxEditDistance = 2000
+
+// DiffStrings returns a shortest edit script turning a into b, using the
+// Myers O(ND) algorithm after stripping the common prefix and suffix
+func DiffStrings(a, b []string) []Edit {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	edits := make([]Edit, 0, len(a)+len(b))
+	for i := 0; i < prefix; i++ {
+		edits = append(edits, Edit{EditEqual, i})
+	}
+	for _, e := range myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]) {
+		e.Index += prefix
+		edits = append(edits, e)
+	}
+	for i := len(a) - suffix; i < len(a); i++ {
+		edits = append(edits, Edit{EditEqual, i})
+	}
+	return edits
+}
+
+func myers(a, b []string) []Edit {
+	n, m := len(a), len(b)
+	if n == 0 || m == 0 {
+		return replaceAll(n, m)
+	}
+
+	// v[k+offset] is the furthest x reached on diagonal k; trace keeps the
+	// part of v that round d read, for walking back the path afterwards
+	offset := n + m + 1
+	v := make([]int, 2*offset+1)
+	var trace [][]int
+	for d := 0; d <= n+m; d++ {
+		if d > maxEditDistance {
+			return replaceAll(n, m)
+		}
+		trace = append(trace, append([]int(nil), v[offset-d:offset+d+1]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[k-1+offset] < v[k+1+offset]) {
+				x = v[k+1+offset]
+			} else {
+				x = v[k-1+offset] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[k+offset] = x
+			if x >= n && y >= m {
+				return backtrack(trace, n, m)
+			}
+		}
+	}
+	return replaceAll(n, m)
+}
+
+func backtrack(trace [][]int, n, m int) []Edit {
+	var edits []Edit
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d] // index k+d
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[k-1+d] < v[k+1+d]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := 0
+		if d > 0 {
+			prevX = v[prevK+d]
+		}
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			edits = append(edits, Edit{EditEqual, x})
+		}
+		if d > 0 {
+			if x == prevX {
+				y--
+				edits = append(edits, Edit{EditInsert, y})
+			} else {
+				x--
+				edits = append(edits, Edit{EditDelete, x})
+			}
+		}
+	}
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+	return edits
+}
+
+func replaceAll(n, m int) []Edit {
+	edits := make([]Edit, 0, n+m)
+	for i := 0; i < n; i++ {
+		edits = append(edits, Edit{EditDelete, i})
+	}
+	for j := 0; j < m; j++ {
+		edits = append(edits, Edit{EditInsert, j})
+	}
+	return edits
+}
@@ -0,0 +1,59 @@
+package utils
+
+import (
+	"math/rand"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// applyEdits rebuilds b from a and the edit script
+func applyEdits(a, b []string, edits []Edit) []string {
+	var out []string
+	for _, e := range edits {
+		switch e.Op {
+		case EditEqual:
+			out = append(out, a[e.Index])
+		case EditInsert:
+			out = append(out, b[e.Index])
+		}
+	}
+	return out
+}
+
+func TestDiffStrings_Minimal(t *testing.T) {
+	a := strings.Split("a b c a b b a", " ")
+	b := strings.Split("c b a b a c", " ")
+	edits := DiffStrings(a, b)
+	changes := 0
+	for _, e := range edits {
+		if e.Op != EditEqual {
+			changes++
+		}
+	}
+	// the classic example from Myers' paper has an edit distance of 5
+	if changes != 5 {
+		t.Errorf("changes=%d want 5", changes)
+	}
+	if got := applyEdits(a, b, edits); !slices.Equal(got, b) {
+		t.Errorf("edits rebuild %v want %v", got, b)
+	}
+}
+
+func TestDiffStrings_Random(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	alphabet := []string{"x", "y", "z"}
+	for i := 0; i < 200; i++ {
+		a := make([]string, rng.Intn(12))
+		b := make([]string, rng.Intn(12))
+		for j := range a {
+			a[j] = alphabet[rng.Intn(len(alphabet))]
+		}
+		for j := range b {
+			b[j] = alphabet[rng.Intn(len(alphabet))]
+		}
+		if got := applyEdits(a, b, DiffStrings(a, b)); !slices.Equal(got, b) {
+			t.Fatalf("diff of %v and %v rebuilds %v", a, b, got)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	ExtractStatus = "status" // the status code
+	ExtractBody   = "body"   // the whole body
+	ExtractHeader = "header" // a response header, by name
+	ExtractCookie = "cookie" // a cookie set by the response, by name
+	ExtractJson   = "json"   // a value from a JSON body, by path
+	ExtractRegex  = "regex"  // the first capture group, or the whole match, of a pattern
+)
+
+// Response is the part of an HTTP response values can be extracted from
+type Response struct {
+	Status  int
+	Headers http.Header
+	Body    string
+}
+
+// Extract pulls a single value out of a response
+func Extract(kind string, arg string, resp Response) (string, error) {
+	switch kind {
+	case ExtractStatus:
+		return strconv.Itoa(resp.Status), nil
+	case ExtractBody:
+		return resp.Body, nil
+	case ExtractHeader:
+		values := resp.Headers.Values(arg)
+		if len(values) == 0 {
+			return "", fmt.Errorf("no %s header in response", arg)
+		}
+		return values[0], nil
+	case ExtractCookie:
+		for _, cookie := range (&http.Response{Header: resp.Headers}).Cookies() {
+			if cookie.Name == arg {
+				return cookie.Value, nil
+			}
+		}
+		return "", fmt.Errorf("response sets no %s cookie", arg)
+	case ExtractJson:
+		return JsonPath(resp.Body, arg)
+	case ExtractRegex:
+		re, err := regexp.Compile(arg)
+		if err != nil {
+			return "", fmt.Errorf("invalid regex: %w", err)
+		}
+		match := re.FindStringSubmatch(resp.Body)
+		if match == nil {
+			return "", fmt.Errorf("regex %s does not match the body", arg)
+		}
+		if len(match) > 1 {
+			return match[1], nil
+		}
+		return match[0], nil
+	default:
+		return "", fmt.Errorf("unknown extraction %q, expected status, body, header, cookie, json or regex", kind)
+	}
+}
+
+var jsonPathToken = regexp.MustCompile(`\[(\d+)\]|\["((?:[^"\\]|\\.)*)"\]|\.?([^.\[]+)`)
+
+// JsonPath reads a value out of a JSON document with a path such as
+// $.data.items[0].id, data.items.0.id or $["odd key"]. Strings are returned
+// as is, anything else as compact JSON.
+func JsonPath(body string, path string) (string, error) {
+	var current any
+	if err := json.Unmarshal([]byte(body), &current); err != nil {
+		return "", fmt.Errorf("body is not JSON: %w", err)
+	}
+
+	rest := strings.TrimPrefix(strings.TrimSpace(path), "$")
+	for rest != "" {
+		loc := jsonPathToken.FindStringSubmatchIndex(rest)
+		if loc == nil || loc[0] != 0 {
+			return "", fmt.Errorf("invalid JSON path %q", path)
+		}
+		var key string
+		index := -1
+		switch {
+		case loc[2] != -1:
+			index, _ = strconv.Atoi(rest[loc[2]:loc[3]])
+		case loc[4] != -1:
+			key, _ = strconv.Unquote(`"` + rest[loc[4]:loc[5]] + `"`)
+		default:
+			key = rest[loc[6]:loc[7]]
+		}
+		rest = rest[loc[1]:]
+
+		switch node := current.(type) {
+		case map[string]any:
+			value, ok := node[key]
+			if !ok || index != -1 {
+				return "", fmt.Errorf("JSON path %q not found", path)
+			}
+			current = value
+		case []any:
+			if index == -1 {
+				var err error
+				if index, err = strconv.Atoi(key); err != nil {
+					return "", fmt.Errorf("JSON path %q not found", path)
+				}
+			}
+			if index < 0 || index >= len(node) {
+				return "", fmt.Errorf("JSON path %q not found", path)
+			}
+			current = node[index]
+		default:
+			return "", fmt.Errorf("JSON path %q not found", path)
+		}
+	}
+
+	if s, ok := current.(string); ok {
+		return s, nil
+	}
+	b, _ := json.Marshal(current)
+	return string(b), nil
+}
@@ -0,0 +1,44 @@
+package utils
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestJsonPath(t *testing.T) {
+	body := `{"data":{"items":[{"id":7,"tags":["a"]}],"odd key":"x","token":"abc"}}`
+	tests := map[string]string{
+		"$.data.token":         "abc",
+		"data.items.0.id":      "7",
+		"$.data.items[0].tags": `["a"]`,
+		`$.data["odd key"]`:    "x",
+		"$.data.items[0]":      `{"id":7,"tags":["a"]}`,
+	}
+	for path, want := range tests {
+		got, err := JsonPath(body, path)
+		if err != nil || got != want {
+			t.Errorf("JsonPath(%q)=%q, %v want %q", path, got, err, want)
+		}
+	}
+	for _, path := range []string{"$.data.missing", "$.data.items[3]", "$.data.token.deeper"} {
+		if _, err := JsonPath(body, path); err == nil {
+			t.Errorf("JsonPath(%q) should fail", path)
+		}
+	}
+}
+
+func TestExtract_CookieAndHeader(t *testing.T) {
+	resp := Response{
+		Status:  302,
+		Headers: http.Header{"Set-Cookie": {"a=1; Path=/", "session=xyz; HttpOnly"}, "Location": {"/home"}},
+	}
+	if got, err := Extract(ExtractCookie, "session", resp); err != nil || got != "xyz" {
+		t.Errorf("cookie=%q, %v", got, err)
+	}
+	if got, err := Extract(ExtractHeader, "location", resp); err != nil || got != "/home" {
+		t.Errorf("header=%q, %v", got, err)
+	}
+	if got, _ := Extract(ExtractStatus, "", resp); got != "302" {
+		t.Errorf("status=%q", got)
+	}
+}
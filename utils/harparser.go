@@ -0,0 +1,141 @@
+package utils
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/linn221/bane/mystructs"
+)
+
+// Har is the part of an HTTP Archive (HAR 1.2) that describes requests
+type Har struct {
+	Log struct {
+		Entries []HarEntry `json:"entries"`
+	} `json:"log"`
+}
+
+type HarEntry struct {
+	StartedDateTime time.Time   `json:"startedDateTime"`
+	Time            float64     `json:"time"` // total milliseconds
+	Request         HarRequest  `json:"request"`
+	Response        HarResponse `json:"response"`
+	Timings         HarTimings  `json:"timings"`
+}
+
+type HarNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type HarRequest struct {
+	Method      string         `json:"method"`
+	Url         string         `json:"url"`
+	Headers     []HarNameValue `json:"headers"`
+	QueryString []HarNameValue `json:"queryString"`
+	Cookies     []HarNameValue `json:"cookies"`
+	PostData    *struct {
+		MimeType string         `json:"mimeType"`
+		Text     string         `json:"text"`
+		Params   []HarNameValue `json:"params"`
+	} `json:"postData"`
+}
+
+type HarResponse struct {
+	Status  int            `json:"status"`
+	Headers []HarNameValue `json:"headers"`
+	Content struct {
+		Size     int64  `json:"size"`
+		MimeType string `json:"mimeType"`
+		Text     string `json:"text"`
+		Encoding string `json:"encoding"`
+	} `json:"content"`
+}
+
+// HarTimings are in milliseconds; -1 means the phase did not apply
+type HarTimings struct {
+	Dns     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	Ssl     float64 `json:"ssl"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+}
+
+// ParseHar reads a HAR document
+func ParseHar(r io.Reader) (*Har, error) {
+	var har Har
+	if err := json.NewDecoder(r).Decode(&har); err != nil {
+		return nil, fmt.Errorf("invalid HAR file: %w", err)
+	}
+	return &har, nil
+}
+
+// RequestUrl returns the URL with the recorded query string, which some
+// exporters leave out of the url field
+func (r *HarRequest) RequestUrl() string {
+	if len(r.QueryString) == 0 || strings.Contains(r.Url, "?") {
+		return r.Url
+	}
+	queries := make([]string, 0, len(r.QueryString))
+	for _, q := range r.QueryString {
+		queries = append(queries, url.QueryEscape(q.Name)+"="+url.QueryEscape(q.Value))
+	}
+	return r.Url + "?" + strings.Join(queries, "&")
+}
+
+// RequestHeaders returns the headers to send again, adding a Cookie header
+// built from the recorded cookies when the headers carry none
+func (r *HarRequest) RequestHeaders() []mystructs.KVPair {
+	headers := make([]mystructs.KVPair, 0, len(r.Headers)+1)
+	for _, h := range r.Headers {
+		headers = append(headers, mystructs.KVPair{Key: h.Name, Value: h.Value})
+	}
+	headers = ReplayHeaders(headers)
+	if rawHeader(headers, "Cookie") == "" && len(r.Cookies) > 0 {
+		cookies := make([]string, 0, len(r.Cookies))
+		for _, c := range r.Cookies {
+			cookies = append(cookies, c.Name+"="+c.Value)
+		}
+		headers = append(headers, mystructs.KVPair{Key: "Cookie", Value: strings.Join(cookies, "; ")})
+	}
+	return headers
+}
+
+// Body returns the post data, encoding form params when no text was recorded
+func (r *HarRequest) Body() string {
+	if r.PostData == nil {
+		return ""
+	}
+	if r.PostData.Text != "" || len(r.PostData.Params) == 0 {
+		return r.PostData.Text
+	}
+	form := make([]string, 0, len(r.PostData.Params))
+	for _, p := range r.PostData.Params {
+		form = append(form, url.QueryEscape(p.Name)+"="+url.QueryEscape(p.Value))
+	}
+	return strings.Join(form, "&")
+}
+
+// Body returns the response content, decoding it when it was stored as base64
+func (r *HarResponse) Body() string {
+	if r.Content.Encoding == "base64" {
+		if decoded, err := base64.StdEncoding.DecodeString(r.Content.Text); err == nil {
+			return string(decoded)
+		}
+	}
+	return r.Content.Text
+}
+
+// Header returns the first response header matching name, case-insensitively
+func (r *HarResponse) Header(name string) string {
+	for _, h := range r.Headers {
+		if strings.EqualFold(h.Name, name) {
+			return h.Value
+		}
+	}
+	return ""
+}
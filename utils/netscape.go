@@ -0,0 +1,78 @@
+package utils
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NetscapeCookie is one line of a Netscape cookies.txt file, as written by
+// curl, wget and browser extensions
+type NetscapeCookie struct {
+	Domain            string // with a leading dot when IncludeSubdomains is set
+	IncludeSubdomains bool
+	Path              string
+	Secure            bool
+	Expires           *time.Time // nil for a session cookie
+	Name              string
+	Value             string
+	HttpOnly          bool
+}
+
+// httpOnlyPrefix marks HttpOnly cookies, which would otherwise be comments
+const httpOnlyPrefix = "#HttpOnly_"
+
+// ParseNetscapeCookies reads a Netscape cookies.txt file
+func ParseNetscapeCookies(r io.Reader) ([]NetscapeCookie, error) {
+	var cookies []NetscapeCookie
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		httpOnly := strings.HasPrefix(line, httpOnlyPrefix)
+		line = strings.TrimPrefix(line, httpOnlyPrefix)
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) == 6 {
+			// a cookie with an empty value, written without the last tab
+			fields = append(fields, "")
+		}
+		if len(fields) != 7 {
+			return nil, fmt.Errorf("line %d: expected 7 tab-separated fields, got %d", lineNumber, len(fields))
+		}
+		cookie := NetscapeCookie{
+			Domain:            fields[0],
+			IncludeSubdomains: strings.EqualFold(fields[1], "TRUE"),
+			Path:              fields[2],
+			Secure:            strings.EqualFold(fields[3], "TRUE"),
+			Name:              fields[5],
+			Value:             fields[6],
+			HttpOnly:          httpOnly,
+		}
+		expires, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid expiry %q", lineNumber, fields[4])
+		}
+		if expires > 0 {
+			t := time.Unix(expires, 0)
+			cookie.Expires = &t
+		}
+		if cookie.IncludeSubdomains && !strings.HasPrefix(cookie.Domain, ".") {
+			cookie.Domain = "." + cookie.Domain
+		}
+		if !cookie.IncludeSubdomains {
+			cookie.Domain = strings.TrimPrefix(cookie.Domain, ".")
+		}
+		cookies = append(cookies, cookie)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("invalid cookies.txt file: %w", err)
+	}
+	return cookies, nil
+}
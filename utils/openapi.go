@@ -0,0 +1,430 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/linn221/bane/mystructs"
+	"gopkg.in/yaml.v3"
+)
+
+// OpenApiOperation is one operation of an OpenAPI document, written as a
+// request template: parameters appear as {name=example} placeholders
+type OpenApiOperation struct {
+	Name        string
+	Description string
+	Method      string
+	Url         string
+	Headers     []mystructs.KVPair
+	Body        string
+}
+
+var openApiMethods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// maxSchemaDepth stops example generation on deeply nested or recursive schemas
+const maxSchemaDepth = 8
+
+type openApiDoc struct {
+	root    map[string]any
+	swagger bool // OpenAPI 2.0
+}
+
+// ParseOpenApi reads an OpenAPI 2.0 (Swagger) or 3.x document in JSON or YAML
+// and returns its operations in path order. baseUrl replaces the servers, or
+// the host and basePath, declared in the document.
+func ParseOpenApi(spec string, baseUrl string) ([]OpenApiOperation, error) {
+	var raw any
+	if err := yaml.Unmarshal([]byte(spec), &raw); err != nil {
+		return nil, fmt.Errorf("invalid OpenAPI document: %w", err)
+	}
+	root, ok := normalizeYaml(raw).(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("invalid OpenAPI document: not an object")
+	}
+	doc := &openApiDoc{root: root}
+	switch {
+	case root["swagger"] != nil:
+		doc.swagger = true
+	case root["openapi"] == nil:
+		return nil, fmt.Errorf("not an OpenAPI document: no openapi or swagger version")
+	}
+
+	if baseUrl == "" {
+		baseUrl = doc.baseUrl()
+	}
+	baseUrl = strings.TrimRight(baseUrl, "/")
+	if u, err := url.Parse(baseUrl); err != nil || u.Host == "" {
+		return nil, fmt.Errorf("the document declares no absolute server URL; pass a baseUrl")
+	}
+
+	paths := asMap(root["paths"])
+	var operations []OpenApiOperation
+	for _, path := range sortedKeys(paths) {
+		item := doc.resolve(paths[path])
+		for _, method := range openApiMethods {
+			operation, ok := item[method].(map[string]any)
+			if !ok {
+				continue
+			}
+			operations = append(operations, doc.operation(baseUrl, path, method, item, operation))
+		}
+	}
+	return operations, nil
+}
+
+// baseUrl is the first server URL with its variables at their defaults, or
+// the scheme, host and basePath of a 2.0 document
+func (doc *openApiDoc) baseUrl() string {
+	if doc.swagger {
+		host, _ := doc.root["host"].(string)
+		if host == "" {
+			return ""
+		}
+		scheme := "https"
+		if schemes, ok := doc.root["schemes"].([]any); ok && len(schemes) > 0 && !slices.Contains(schemes, any("https")) {
+			scheme, _ = schemes[0].(string)
+		}
+		basePath, _ := doc.root["basePath"].(string)
+		return scheme + "://" + host + basePath
+	}
+	servers, _ := doc.root["servers"].([]any)
+	if len(servers) == 0 {
+		return ""
+	}
+	server := asMap(servers[0])
+	serverUrl, _ := server["url"].(string)
+	variables := asMap(server["variables"])
+	for name, v := range variables {
+		serverUrl = strings.ReplaceAll(serverUrl, "{"+name+"}", fmt.Sprint(asMap(v)["default"]))
+	}
+	return serverUrl
+}
+
+func (doc *openApiDoc) operation(baseUrl, path, method string, item, operation map[string]any) OpenApiOperation {
+	op := OpenApiOperation{Method: strings.ToUpper(method)}
+	op.Name, _ = operation["operationId"].(string)
+	op.Description, _ = operation["summary"].(string)
+	if op.Description == "" {
+		op.Description, _ = operation["description"].(string)
+	}
+	if op.Name == "" {
+		op.Name = op.Method + " " + path
+	}
+
+	var queries, cookies []string
+	var formParams []map[string]any
+	for _, param := range doc.parameters(item, operation) {
+		name, _ := param["name"].(string)
+		placeholder := placeholderName(name)
+		switch param["in"] {
+		case "path":
+			value := strings.ReplaceAll(url.PathEscape(doc.paramExample(param)), "}", "%7D")
+			path = strings.ReplaceAll(path, "{"+name+"}", "{"+placeholder+"="+value+"}")
+		case "query":
+			value := strings.ReplaceAll(url.QueryEscape(doc.paramExample(param)), "}", "%7D")
+			queries = append(queries, url.QueryEscape(name)+"={"+placeholder+"="+value+"}")
+		case "header":
+			value := strings.ReplaceAll(doc.paramExample(param), "}", "")
+			op.Headers = append(op.Headers, mystructs.KVPair{Key: name, Value: "{" + placeholder + "=" + value + "}"})
+		case "cookie":
+			value := strings.ReplaceAll(doc.paramExample(param), "}", "")
+			cookies = append(cookies, name+"={"+placeholder+"="+value+"}")
+		case "body":
+			op.Body = doc.exampleJson(doc.resolve(param["schema"]), 0)
+			op.Headers = append(op.Headers, mystructs.KVPair{Key: "Content-Type", Value: doc.consumes(operation, "application/json")})
+		case "formData":
+			formParams = append(formParams, param)
+		}
+	}
+	if len(cookies) > 0 {
+		op.Headers = append(op.Headers, mystructs.KVPair{Key: "Cookie", Value: strings.Join(cookies, "; ")})
+	}
+	if len(formParams) > 0 {
+		form := make([]string, 0, len(formParams))
+		for _, param := range formParams {
+			name, _ := param["name"].(string)
+			form = append(form, url.QueryEscape(name)+"="+url.QueryEscape(doc.paramExample(param)))
+		}
+		op.Body = strings.Join(form, "&")
+		op.Headers = append(op.Headers, mystructs.KVPair{Key: "Content-Type", Value: "application/x-www-form-urlencoded"})
+	}
+	if body := doc.resolve(operation["requestBody"]); body != nil {
+		op.Body, op.Headers = doc.requestBody(body, op.Headers)
+	}
+
+	op.Url = baseUrl + path
+	if len(queries) > 0 {
+		op.Url += "?" + strings.Join(queries, "&")
+	}
+	return op
+}
+
+// parameters merges the path item's parameters with the operation's, the
+// operation winning for the same name and location
+func (doc *openApiDoc) parameters(item, operation map[string]any) []map[string]any {
+	var params []map[string]any
+	index := map[string]int{}
+	for _, list := range []any{item["parameters"], operation["parameters"]} {
+		entries, _ := list.([]any)
+		for _, entry := range entries {
+			param := doc.resolve(entry)
+			key := fmt.Sprint(param["in"], ":", param["name"])
+			if i, ok := index[key]; ok {
+				params[i] = param
+				continue
+			}
+			index[key] = len(params)
+			params = append(params, param)
+		}
+	}
+	return params
+}
+
+// requestBody picks the first media type we can write an example for, JSON first
+func (doc *openApiDoc) requestBody(body map[string]any, headers []mystructs.KVPair) (string, []mystructs.KVPair) {
+	content := asMap(body["content"])
+	types := sortedKeys(content)
+	sort.SliceStable(types, func(i, j int) bool {
+		return strings.Contains(types[i], "json") && !strings.Contains(types[j], "json")
+	})
+	for _, mediaType := range types {
+		media := asMap(content[mediaType])
+		schema := doc.resolve(media["schema"])
+		var text string
+		switch {
+		case media["example"] != nil:
+			text = exampleText(media["example"])
+		case len(asMap(media["examples"])) > 0:
+			examples := asMap(media["examples"])
+			first := doc.resolve(examples[sortedKeys(examples)[0]])
+			text = exampleText(first["value"])
+		case strings.Contains(mediaType, "json"):
+			text = doc.exampleJson(schema, 0)
+		case mediaType == "application/x-www-form-urlencoded":
+			properties := asMap(schema["properties"])
+			form := make([]string, 0, len(properties))
+			for _, name := range sortedKeys(properties) {
+				form = append(form, url.QueryEscape(name)+"="+url.QueryEscape(exampleText(doc.example(doc.resolve(properties[name]), 0))))
+			}
+			text = strings.Join(form, "&")
+		default:
+			continue
+		}
+		return text, append(headers, mystructs.KVPair{Key: "Content-Type", Value: mediaType})
+	}
+	return "", headers
+}
+
+func (doc *openApiDoc) consumes(operation map[string]any, fallback string) string {
+	for _, list := range []any{operation["consumes"], doc.root["consumes"]} {
+		if types, ok := list.([]any); ok && len(types) > 0 {
+			if t, ok := types[0].(string); ok {
+				return t
+			}
+		}
+	}
+	return fallback
+}
+
+// paramExample is the example, default or first enum value of a parameter,
+// falling back to a value of its type
+func (doc *openApiDoc) paramExample(param map[string]any) string {
+	if param["example"] != nil {
+		return exampleText(param["example"])
+	}
+	if examples := asMap(param["examples"]); len(examples) > 0 {
+		return exampleText(doc.resolve(examples[sortedKeys(examples)[0]])["value"])
+	}
+	schema := doc.resolve(param["schema"])
+	if schema == nil {
+		schema = param // 2.0 keeps type, default and enum on the parameter
+	}
+	return exampleText(doc.example(schema, 0))
+}
+
+func (doc *openApiDoc) exampleJson(schema map[string]any, depth int) string {
+	b, _ := json.Marshal(doc.example(schema, depth))
+	return string(b)
+}
+
+// example builds a sample value for a schema the way documentation tools do
+func (doc *openApiDoc) example(schema map[string]any, depth int) any {
+	if schema == nil || depth > maxSchemaDepth {
+		return nil
+	}
+	for _, key := range []string{"example", "default"} {
+		if schema[key] != nil {
+			return schema[key]
+		}
+	}
+	if enum, ok := schema["enum"].([]any); ok && len(enum) > 0 {
+		return enum[0]
+	}
+	for _, key := range []string{"allOf", "oneOf", "anyOf"} {
+		schemas, _ := schema[key].([]any)
+		if len(schemas) == 0 {
+			continue
+		}
+		if key != "allOf" {
+			return doc.example(doc.resolve(schemas[0]), depth+1)
+		}
+		merged := map[string]any{}
+		for _, s := range schemas {
+			if value, ok := doc.example(doc.resolve(s), depth+1).(map[string]any); ok {
+				for k, v := range value {
+					merged[k] = v
+				}
+			}
+		}
+		return merged
+	}
+
+	switch schemaType(schema) {
+	case "object":
+		properties := asMap(schema["properties"])
+		value := make(map[string]any, len(properties))
+		for _, name := range sortedKeys(properties) {
+			value[name] = doc.example(doc.resolve(properties[name]), depth+1)
+		}
+		return value
+	case "array":
+		item := doc.example(doc.resolve(schema["items"]), depth+1)
+		if item == nil {
+			return []any{}
+		}
+		return []any{item}
+	case "integer", "number":
+		return 1
+	case "boolean":
+		return true
+	case "string":
+		switch schema["format"] {
+		case "date":
+			return "2024-01-01"
+		case "date-time":
+			return "2024-01-01T00:00:00Z"
+		case "uuid":
+			return "00000000-0000-0000-0000-000000000000"
+		case "email":
+			return "user@example.com"
+		}
+		return "string"
+	}
+	return nil
+}
+
+func schemaType(schema map[string]any) string {
+	switch t := schema["type"].(type) {
+	case string:
+		return t
+	case []any: // 3.1 allows a list such as ["string", "null"]
+		for _, v := range t {
+			if s, ok := v.(string); ok && s != "null" {
+				return s
+			}
+		}
+	}
+	if schema["properties"] != nil {
+		return "object"
+	}
+	return ""
+}
+
+// resolve follows local $refs such as #/components/schemas/User
+func (doc *openApiDoc) resolve(node any) map[string]any {
+	for range maxSchemaDepth {
+		m := asMap(node)
+		ref, ok := m["$ref"].(string)
+		if !ok {
+			return m
+		}
+		node = doc.lookup(ref)
+	}
+	return nil
+}
+
+func (doc *openApiDoc) lookup(ref string) any {
+	if !strings.HasPrefix(ref, "#/") {
+		return nil
+	}
+	var node any = doc.root
+	for _, part := range strings.Split(ref[2:], "/") {
+		part = strings.ReplaceAll(strings.ReplaceAll(part, "~1", "/"), "~0", "~")
+		node = asMap(node)[part]
+	}
+	return node
+}
+
+var placeholderUnsafe = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// placeholderName turns a parameter name such as X-Api-Key into a valid
+// VarString placeholder name
+func placeholderName(name string) string {
+	name = placeholderUnsafe.ReplaceAllString(name, "_")
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		name = "_" + name
+	}
+	return name
+}
+
+// exampleText writes an example value as it would appear in a request
+func exampleText(v any) string {
+	switch value := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case int:
+		return strconv.Itoa(value)
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(value)
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+// normalizeYaml converts the map[any]any values yaml produces for non-string
+// keys, such as response codes, so documents can be walked and marshaled as JSON
+func normalizeYaml(v any) any {
+	switch value := v.(type) {
+	case map[string]any:
+		for k, child := range value {
+			value[k] = normalizeYaml(child)
+		}
+		return value
+	case map[any]any:
+		m := make(map[string]any, len(value))
+		for k, child := range value {
+			m[fmt.Sprint(k)] = normalizeYaml(child)
+		}
+		return m
+	case []any:
+		for i, child := range value {
+			value[i] = normalizeYaml(child)
+		}
+		return value
+	}
+	return v
+}
+
+func asMap(v any) map[string]any {
+	m, _ := v.(map[string]any)
+	return m
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
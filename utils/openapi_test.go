@@ -0,0 +1,144 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+const testOpenApi3 = `{
+  "openapi": "3.0.1",
+  "servers": [{"url": "https://{region}.api.example.com/v1", "variables": {"region": {"default": "eu"}}}],
+  "paths": {
+    "/users/{userId}": {
+      "parameters": [{"name": "userId", "in": "path", "required": true, "schema": {"type": "integer", "example": 42}}],
+      "get": {
+        "operationId": "getUser",
+        "summary": "Fetch a user",
+        "parameters": [
+          {"name": "fields", "in": "query", "schema": {"type": "string", "default": "id,name"}},
+          {"name": "X-Api-Key", "in": "header", "schema": {"type": "string"}},
+          {"name": "session", "in": "cookie", "example": "abc"}
+        ]
+      },
+      "put": {
+        "requestBody": {"$ref": "#/components/requestBodies/User"}
+      }
+    }
+  },
+  "components": {
+    "requestBodies": {
+      "User": {"content": {"application/json": {"schema": {"$ref": "#/components/schemas/User"}}}}
+    },
+    "schemas": {
+      "User": {
+        "type": "object",
+        "properties": {
+          "name": {"type": "string", "example": "Ada"},
+          "roles": {"type": "array", "items": {"type": "string", "enum": ["admin", "user"]}},
+          "manager": {"$ref": "#/components/schemas/User"}
+        }
+      }
+    }
+  }
+}`
+
+const testSwagger = `
+swagger: "2.0"
+host: petstore.example.com
+basePath: /api
+schemes: [http]
+paths:
+  /pets:
+    post:
+      parameters:
+        - in: body
+          name: pet
+          schema:
+            type: object
+            properties:
+              name: {type: string, example: Rex}
+              age: {type: integer}
+      responses:
+        200:
+          description: ok
+  /pets/{petId}/photo:
+    post:
+      consumes: [application/x-www-form-urlencoded]
+      parameters:
+        - {name: petId, in: path, type: string, required: true}
+        - {name: caption, in: formData, type: string, default: my pet}
+        - {name: limit, in: query, type: integer, enum: [5, 10]}
+`
+
+func TestParseOpenApi_V3(t *testing.T) {
+	ops, err := ParseOpenApi(testOpenApi3, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ops) != 2 {
+		t.Fatalf("got %d operations", len(ops))
+	}
+
+	get := ops[0]
+	if get.Name != "getUser" || get.Description != "Fetch a user" || get.Method != "GET" {
+		t.Errorf("get = %+v", get)
+	}
+	if get.Url != "https://eu.api.example.com/v1/users/{userId=42}?fields={fields=id%2Cname}" {
+		t.Errorf("url = %s", get.Url)
+	}
+	want := map[string]string{"X-Api-Key": "{X_Api_Key=string}", "Cookie": "session={session=abc}"}
+	if len(get.Headers) != len(want) {
+		t.Fatalf("headers = %v", get.Headers)
+	}
+	for _, h := range get.Headers {
+		if want[h.Key] != h.Value {
+			t.Errorf("header %s = %q, want %q", h.Key, h.Value, want[h.Key])
+		}
+	}
+
+	put := ops[1]
+	if put.Name != "PUT /users/{userId}" || put.Url != "https://eu.api.example.com/v1/users/{userId=42}" {
+		t.Errorf("put = %s %s", put.Name, put.Url)
+	}
+	if !strings.HasPrefix(put.Body, `{"manager":{"manager":`) || !strings.Contains(put.Body, `"name":"Ada","roles":["admin"]}`) {
+		t.Errorf("body = %s", put.Body)
+	}
+	if len(put.Headers) != 1 || put.Headers[0].Value != "application/json" {
+		t.Errorf("put headers = %v", put.Headers)
+	}
+
+	ops, err = ParseOpenApi(testOpenApi3, "http://localhost:8080/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(ops[0].Url, "http://localhost:8080/users/") {
+		t.Errorf("baseUrl override = %s", ops[0].Url)
+	}
+}
+
+func TestParseOpenApi_Swagger(t *testing.T) {
+	ops, err := ParseOpenApi(testSwagger, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ops) != 2 {
+		t.Fatalf("got %d operations", len(ops))
+	}
+	if ops[0].Url != "http://petstore.example.com/api/pets" || ops[0].Body != `{"age":1,"name":"Rex"}` {
+		t.Errorf("pets = %s %s", ops[0].Url, ops[0].Body)
+	}
+	photo := ops[1]
+	if photo.Url != "http://petstore.example.com/api/pets/{petId=string}/photo?limit={limit=5}" {
+		t.Errorf("photo url = %s", photo.Url)
+	}
+	if photo.Body != "caption=my+pet" || len(photo.Headers) != 1 || photo.Headers[0].Value != "application/x-www-form-urlencoded" {
+		t.Errorf("photo body = %q headers = %v", photo.Body, photo.Headers)
+	}
+
+	if _, err := ParseOpenApi(`{"openapi": "3.0.0", "paths": {}}`, ""); err == nil {
+		t.Error("expected an error without a server URL")
+	}
+	if _, err := ParseOpenApi(`{"info": {}}`, "https://x.test"); err == nil {
+		t.Error("expected an error for a document without a version")
+	}
+}
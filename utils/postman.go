@@ -0,0 +1,636 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/linn221/bane/mystructs"
+)
+
+// PostmanSchema identifies the Postman Collection v2.1 format
+const PostmanSchema = "https://schema.getpostman.com/json/collection/v2.1.0/collection.json"
+
+// maxVariableDepth stops the resolution of variables defined through each other
+const maxVariableDepth = 8
+
+// postmanRef matches a {{name}} variable reference
+var postmanRef = regexp.MustCompile(`\{\{([^{}]+)\}\}`)
+
+// varStringRef matches the {name} references of a parsed VarString template
+var varStringRef = regexp.MustCompile(`\{([a-zA-Z_][a-zA-Z0-9_]*)\}`)
+
+// postmanPathVariable matches a :name path segment
+var postmanPathVariable = regexp.MustCompile(`^:([a-zA-Z_][a-zA-Z0-9_-]*)$`)
+
+// PostmanCollection is a Postman Collection v2.1 document
+type PostmanCollection struct {
+	Info     PostmanInfo       `json:"info"`
+	Item     []PostmanItem     `json:"item"`
+	Auth     *PostmanAuth      `json:"auth,omitempty"`
+	Variable []PostmanKeyValue `json:"variable,omitempty"`
+}
+
+type PostmanInfo struct {
+	PostmanId   string      `json:"_postman_id,omitempty"`
+	Name        string      `json:"name"`
+	Description PostmanText `json:"description,omitempty"`
+	Schema      string      `json:"schema"`
+}
+
+// PostmanItem is a request, or a folder of items when Request is nil
+type PostmanItem struct {
+	Name        string            `json:"name"`
+	Description PostmanText       `json:"description,omitempty"`
+	Item        []PostmanItem     `json:"item,omitempty"`
+	Request     *PostmanRequest   `json:"request,omitempty"`
+	Auth        *PostmanAuth      `json:"auth,omitempty"`
+	Variable    []PostmanKeyValue `json:"variable,omitempty"`
+}
+
+type PostmanRequest struct {
+	Method      string            `json:"method"`
+	Header      []PostmanKeyValue `json:"header"`
+	Body        *PostmanBody      `json:"body,omitempty"`
+	Url         PostmanUrl        `json:"url"`
+	Auth        *PostmanAuth      `json:"auth,omitempty"`
+	Description PostmanText       `json:"description,omitempty"`
+}
+
+type PostmanUrl struct {
+	Raw      string            `json:"raw"`
+	Protocol string            `json:"protocol,omitempty"`
+	Host     PostmanSegments   `json:"host,omitempty"`
+	Port     string            `json:"port,omitempty"`
+	Path     PostmanSegments   `json:"path,omitempty"`
+	Query    []PostmanKeyValue `json:"query,omitempty"`
+	Variable []PostmanKeyValue `json:"variable,omitempty"`
+}
+
+type PostmanBody struct {
+	Mode       string            `json:"mode"`
+	Raw        string            `json:"raw,omitempty"`
+	Urlencoded []PostmanKeyValue `json:"urlencoded,omitempty"`
+	Formdata   []PostmanKeyValue `json:"formdata,omitempty"`
+	Graphql    *struct {
+		Query     string `json:"query"`
+		Variables string `json:"variables,omitempty"`
+	} `json:"graphql,omitempty"`
+	Options *struct {
+		Raw struct {
+			Language string `json:"language"`
+		} `json:"raw"`
+	} `json:"options,omitempty"`
+}
+
+type PostmanAuth struct {
+	Type   string            `json:"type"`
+	Bearer []PostmanKeyValue `json:"bearer,omitempty"`
+	Basic  []PostmanKeyValue `json:"basic,omitempty"`
+	Apikey []PostmanKeyValue `json:"apikey,omitempty"`
+}
+
+// PostmanKeyValue is a header, query parameter, form field, variable or auth
+// attribute
+type PostmanKeyValue struct {
+	Key      string       `json:"key"`
+	Value    PostmanValue `json:"value"`
+	Type     string       `json:"type,omitempty"`
+	Disabled bool         `json:"disabled,omitempty"`
+}
+
+// PostmanEnvironment is an environment exported from Postman
+type PostmanEnvironment struct {
+	Name   string `json:"name"`
+	Values []struct {
+		Key     string       `json:"key"`
+		Value   PostmanValue `json:"value"`
+		Enabled *bool        `json:"enabled"`
+	} `json:"values"`
+}
+
+// PostmanValue is a value Postman may write as a string, number or boolean
+type PostmanValue string
+
+func (v *PostmanValue) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*v = PostmanValue(s)
+		return nil
+	}
+	if string(data) == "null" {
+		*v = ""
+		return nil
+	}
+	*v = PostmanValue(data)
+	return nil
+}
+
+// PostmanText is a description, written either as a string or as an object
+// with the text in content
+type PostmanText string
+
+func (t *PostmanText) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*t = PostmanText(s)
+		return nil
+	}
+	var object struct {
+		Content string `json:"content"`
+	}
+	if err := json.Unmarshal(data, &object); err != nil {
+		return err
+	}
+	*t = PostmanText(object.Content)
+	return nil
+}
+
+// PostmanSegments are the parts of a host or path, written either as a list
+// or as a single string
+type PostmanSegments []string
+
+func (s *PostmanSegments) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*s = PostmanSegments{single}
+		return nil
+	}
+	var list []any
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*s = nil
+	for _, segment := range list {
+		switch value := segment.(type) {
+		case string:
+			*s = append(*s, value)
+		case map[string]any:
+			*s = append(*s, fmt.Sprint(value["value"]))
+		}
+	}
+	return nil
+}
+
+func (u *PostmanUrl) UnmarshalJSON(data []byte) error {
+	var raw string
+	if err := json.Unmarshal(data, &raw); err == nil {
+		*u = PostmanUrl{Raw: raw}
+		return nil
+	}
+	type plain PostmanUrl
+	return json.Unmarshal(data, (*plain)(u))
+}
+
+func (r *PostmanRequest) UnmarshalJSON(data []byte) error {
+	var raw string
+	if err := json.Unmarshal(data, &raw); err == nil {
+		*r = PostmanRequest{Method: "GET", Url: PostmanUrl{Raw: raw}}
+		return nil
+	}
+	type plain PostmanRequest
+	return json.Unmarshal(data, (*plain)(r))
+}
+
+// PostmanOperation is one request of a collection, written as a request
+// template: Postman {{name}} variables appear as {name=value} placeholders
+type PostmanOperation struct {
+	Name        string
+	Description string
+	Method      string
+	Url         string
+	Headers     []mystructs.KVPair
+	Body        string
+}
+
+// ParsePostmanCollection reads a Postman Collection v2.1 document
+func ParsePostmanCollection(r io.Reader) (*PostmanCollection, error) {
+	var collection PostmanCollection
+	if err := json.NewDecoder(r).Decode(&collection); err != nil {
+		return nil, fmt.Errorf("invalid Postman collection: %w", err)
+	}
+	if collection.Info.Schema != "" && !strings.Contains(collection.Info.Schema, "v2.") {
+		return nil, fmt.Errorf("unsupported Postman collection schema %s", collection.Info.Schema)
+	}
+	return &collection, nil
+}
+
+// ParsePostmanEnvironment reads an environment exported from Postman
+func ParsePostmanEnvironment(r io.Reader) (*PostmanEnvironment, error) {
+	var environment PostmanEnvironment
+	if err := json.NewDecoder(r).Decode(&environment); err != nil {
+		return nil, fmt.Errorf("invalid Postman environment: %w", err)
+	}
+	return &environment, nil
+}
+
+// Operations returns the requests of the collection, depth first. Variables
+// take their values from the environment, then from the enclosing folders
+// and the collection, as they would in Postman.
+func (c *PostmanCollection) Operations(environment *PostmanEnvironment) ([]PostmanOperation, error) {
+	env := map[string]string{}
+	if environment != nil {
+		for _, v := range environment.Values {
+			if v.Enabled == nil || *v.Enabled {
+				env[v.Key] = string(v.Value)
+			}
+		}
+	}
+	vars := scopePostmanVariables(map[string]string{}, c.Variable, env)
+	var operations []PostmanOperation
+	err := collectPostmanOperations(&operations, c.Item, "", vars, env, c.Auth)
+	return operations, err
+}
+
+func collectPostmanOperations(operations *[]PostmanOperation, items []PostmanItem, folder string, vars map[string]string, env map[string]string, auth *PostmanAuth) error {
+	for _, item := range items {
+		name := item.Name
+		if folder != "" {
+			name = folder + " / " + item.Name
+		}
+		itemAuth := auth
+		if item.Auth != nil {
+			itemAuth = item.Auth
+		}
+		if item.Request == nil {
+			scoped := scopePostmanVariables(vars, item.Variable, env)
+			if err := collectPostmanOperations(operations, item.Item, name, scoped, env, itemAuth); err != nil {
+				return err
+			}
+			continue
+		}
+		if item.Request.Auth != nil {
+			itemAuth = item.Request.Auth
+		}
+		op, err := item.Request.operation(vars, itemAuth)
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		op.Name = name
+		op.Description = string(item.Description)
+		if op.Description == "" {
+			op.Description = string(item.Request.Description)
+		}
+		*operations = append(*operations, *op)
+	}
+	return nil
+}
+
+// scopePostmanVariables returns the variables of an enclosing scope overridden
+// by the scope's own variables, which the environment overrides in turn
+func scopePostmanVariables(outer map[string]string, variables []PostmanKeyValue, env map[string]string) map[string]string {
+	if len(variables) == 0 && len(outer) > 0 {
+		return outer
+	}
+	scoped := make(map[string]string, len(outer)+len(variables)+len(env))
+	for k, v := range outer {
+		scoped[k] = v
+	}
+	for _, v := range variables {
+		if !v.Disabled {
+			scoped[v.Key] = string(v.Value)
+		}
+	}
+	for k, v := range env {
+		scoped[k] = v
+	}
+	return scoped
+}
+
+func (r *PostmanRequest) operation(vars map[string]string, auth *PostmanAuth) (*PostmanOperation, error) {
+	rawUrl, err := r.Url.template(vars)
+	if err != nil {
+		return nil, err
+	}
+	op := &PostmanOperation{Method: strings.ToUpper(r.Method), Url: rawUrl}
+	if op.Method == "" {
+		op.Method = "GET"
+	}
+	for _, h := range r.Header {
+		if !h.Disabled {
+			op.Headers = append(op.Headers, mystructs.KVPair{Key: PostmanTemplate(h.Key, vars), Value: PostmanTemplate(string(h.Value), vars)})
+		}
+	}
+
+	contentType := ""
+	if r.Body != nil {
+		op.Body, contentType = r.Body.template(vars)
+	}
+	if contentType != "" && rawHeader(op.Headers, "Content-Type") == "" {
+		op.Headers = append(op.Headers, mystructs.KVPair{Key: "Content-Type", Value: contentType})
+	}
+
+	if auth != nil {
+		key, value, query := auth.header(vars)
+		switch {
+		case query:
+			separator := "?"
+			if strings.Contains(op.Url, "?") {
+				separator = "&"
+			}
+			op.Url += separator + key + "=" + value
+		case key != "" && rawHeader(op.Headers, key) == "":
+			op.Headers = append(op.Headers, mystructs.KVPair{Key: key, Value: value})
+		}
+	}
+	return op, nil
+}
+
+// template returns the URL with variables in the scheme and host replaced by
+// their values, since an endpoint's domain cannot hold placeholders, and the
+// rest turned into placeholders
+func (u *PostmanUrl) template(vars map[string]string) (string, error) {
+	raw := u.Raw
+	if raw == "" {
+		raw = u.build()
+	}
+	for {
+		hostEnd := postmanHostEnd(raw)
+		loc := postmanRef.FindStringSubmatchIndex(raw[:hostEnd])
+		if loc == nil {
+			break
+		}
+		name := strings.TrimSpace(raw[loc[2]:loc[3]])
+		value, ok := vars[name]
+		if !ok {
+			return "", fmt.Errorf("variable %s in the host has no value", name)
+		}
+		raw = raw[:loc[0]] + resolvePostmanValue(value, vars, 0) + raw[loc[1]:]
+	}
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+
+	hostEnd := postmanHostEnd(raw)
+	pathEnd := len(raw)
+	if i := strings.IndexAny(raw[hostEnd:], "?#"); i >= 0 {
+		pathEnd = hostEnd + i
+	}
+	pathVars := map[string]string{}
+	for _, v := range u.Variable {
+		pathVars[v.Key] = string(v.Value)
+	}
+	segments := strings.Split(raw[hostEnd:pathEnd], "/")
+	for i, segment := range segments {
+		if match := postmanPathVariable.FindStringSubmatch(segment); match != nil {
+			segments[i] = placeholder(match[1], resolvePostmanValue(pathVars[match[1]], vars, 0))
+		} else {
+			segments[i] = PostmanTemplate(segment, vars)
+		}
+	}
+	return raw[:hostEnd] + strings.Join(segments, "/") + PostmanTemplate(raw[pathEnd:], vars), nil
+}
+
+// build writes the URL from its parts, for collections without a raw URL
+func (u *PostmanUrl) build() string {
+	var b strings.Builder
+	if u.Protocol != "" {
+		b.WriteString(u.Protocol + "://")
+	}
+	b.WriteString(strings.Join(u.Host, "."))
+	if u.Port != "" {
+		b.WriteString(":" + u.Port)
+	}
+	for _, segment := range u.Path {
+		b.WriteString("/" + strings.TrimPrefix(segment, "/"))
+	}
+	separator := "?"
+	for _, q := range u.Query {
+		if !q.Disabled {
+			b.WriteString(separator + q.Key + "=" + string(q.Value))
+			separator = "&"
+		}
+	}
+	return b.String()
+}
+
+// postmanHostEnd returns where the path, query or fragment of a URL begins
+func postmanHostEnd(raw string) int {
+	start := 0
+	if i := strings.Index(raw, "://"); i >= 0 {
+		start = i + 3
+	}
+	if i := strings.IndexAny(raw[start:], "/?#"); i >= 0 {
+		return start + i
+	}
+	return len(raw)
+}
+
+// template returns the body and the content type Postman would send with it
+func (b *PostmanBody) template(vars map[string]string) (string, string) {
+	switch b.Mode {
+	case "raw":
+		contentType := ""
+		if b.Options != nil {
+			contentType = map[string]string{
+				"json": "application/json",
+				"xml":  "application/xml",
+				"html": "text/html",
+				"text": "text/plain",
+			}[b.Options.Raw.Language]
+		}
+		return PostmanTemplate(b.Raw, vars), contentType
+	case "urlencoded":
+		fields := make([]string, 0, len(b.Urlencoded))
+		for _, field := range b.Urlencoded {
+			if !field.Disabled {
+				fields = append(fields, postmanEscapedTemplate(field.Key, vars)+"="+postmanEscapedTemplate(string(field.Value), vars))
+			}
+		}
+		return strings.Join(fields, "&"), "application/x-www-form-urlencoded"
+	case "formdata":
+		var body bytes.Buffer
+		writer := multipart.NewWriter(&body)
+		writer.SetBoundary(multipartBoundary)
+		for _, field := range b.Formdata {
+			if !field.Disabled && field.Type != "file" {
+				writer.WriteField(PostmanTemplate(field.Key, vars), PostmanTemplate(string(field.Value), vars))
+			}
+		}
+		writer.Close()
+		return body.String(), writer.FormDataContentType()
+	case "graphql":
+		if b.Graphql == nil {
+			return "", ""
+		}
+		payload := map[string]any{"query": b.Graphql.Query}
+		var variables any
+		if json.Unmarshal([]byte(b.Graphql.Variables), &variables) == nil {
+			payload["variables"] = variables
+		}
+		body, _ := json.Marshal(payload)
+		return PostmanTemplate(string(body), vars), "application/json"
+	}
+	return "", ""
+}
+
+// header returns the header an auth setting adds, or the query parameter
+// when query is true
+func (a *PostmanAuth) header(vars map[string]string) (key string, value string, query bool) {
+	attribute := func(attributes []PostmanKeyValue, name string) string {
+		for _, attr := range attributes {
+			if attr.Key == name {
+				return string(attr.Value)
+			}
+		}
+		return ""
+	}
+	switch a.Type {
+	case "bearer":
+		return "Authorization", "Bearer " + PostmanTemplate(attribute(a.Bearer, "token"), vars), false
+	case "basic":
+		// the credentials are encoded together, so they cannot stay placeholders
+		credentials := resolvePostmanValue(attribute(a.Basic, "username")+":"+attribute(a.Basic, "password"), vars, 0)
+		return "Authorization", "Basic " + base64.StdEncoding.EncodeToString([]byte(credentials)), false
+	case "apikey":
+		key := attribute(a.Apikey, "key")
+		if key == "" {
+			return "", "", false
+		}
+		if attribute(a.Apikey, "in") == "query" {
+			return postmanEscapedTemplate(key, vars), postmanEscapedTemplate(attribute(a.Apikey, "value"), vars), true
+		}
+		return PostmanTemplate(key, vars), PostmanTemplate(attribute(a.Apikey, "value"), vars), false
+	}
+	return "", "", false
+}
+
+// PostmanTemplate turns the {{name}} references of s into {name=value}
+// placeholders, or bare {name} ones for variables without a usable value
+func PostmanTemplate(s string, vars map[string]string) string {
+	return postmanRef.ReplaceAllStringFunc(s, func(ref string) string {
+		name := strings.TrimSpace(ref[2 : len(ref)-2])
+		value, ok := vars[name]
+		if !ok {
+			return "{" + placeholderName(name) + "}"
+		}
+		return placeholder(name, resolvePostmanValue(value, vars, 0))
+	})
+}
+
+// postmanEscapedTemplate is PostmanTemplate for form and query values: the
+// text around the references is URL-encoded
+func postmanEscapedTemplate(s string, vars map[string]string) string {
+	var b strings.Builder
+	last := 0
+	for _, loc := range postmanRef.FindAllStringIndex(s, -1) {
+		b.WriteString(url.QueryEscape(s[last:loc[0]]))
+		b.WriteString(PostmanTemplate(s[loc[0]:loc[1]], vars))
+		last = loc[1]
+	}
+	b.WriteString(url.QueryEscape(s[last:]))
+	return b.String()
+}
+
+// placeholder writes a {name=value} placeholder, leaving the value out when
+// it would end the placeholder early
+func placeholder(name string, value string) string {
+	name = placeholderName(name)
+	if value == "" || strings.ContainsAny(value, "{}") {
+		return "{" + name + "}"
+	}
+	return "{" + name + "=" + value + "}"
+}
+
+// resolvePostmanValue replaces the references in a variable's value with the
+// values of the variables they name
+func resolvePostmanValue(value string, vars map[string]string, depth int) string {
+	if depth >= maxVariableDepth {
+		return value
+	}
+	return postmanRef.ReplaceAllStringFunc(value, func(ref string) string {
+		if nested, ok := vars[strings.TrimSpace(ref[2:len(ref)-2])]; ok {
+			return resolvePostmanValue(nested, vars, depth+1)
+		}
+		return ref
+	})
+}
+
+// NewPostmanCollection starts an empty collection
+func NewPostmanCollection(name string, description string) *PostmanCollection {
+	return &PostmanCollection{
+		Info: PostmanInfo{Name: name, Description: PostmanText(description), Schema: PostmanSchema},
+		Item: []PostmanItem{},
+	}
+}
+
+// AddRequest adds a request built from VarString parts, turning placeholders
+// into {{name}} references. A placeholder's default becomes the value of a
+// collection variable unless an earlier request already defined it.
+func (c *PostmanCollection) AddRequest(name string, description string, method string, https bool, domain string,
+	path mystructs.VarString, queries mystructs.VarKVGroup, headers mystructs.VarKVGroup, body mystructs.VarString) {
+	protocol := "http"
+	if https {
+		protocol = "https"
+	}
+	u := PostmanUrl{
+		Protocol: protocol,
+		Host:     strings.Split(domain, "."),
+		Path:     PostmanSegments{},
+	}
+	if host, port, ok := strings.Cut(domain, ":"); ok {
+		u.Host, u.Port = strings.Split(host, "."), port
+	}
+	rawPath := c.reference(path)
+	for _, segment := range strings.Split(strings.TrimPrefix(rawPath, "/"), "/") {
+		u.Path = append(u.Path, segment)
+	}
+	u.Raw = protocol + "://" + domain + rawPath
+	for i, kv := range queries.VarKVs {
+		q := PostmanKeyValue{Key: c.reference(kv.Key), Value: PostmanValue(c.reference(kv.Value))}
+		u.Query = append(u.Query, q)
+		separator := "&"
+		if i == 0 {
+			separator = "?"
+		}
+		u.Raw += separator + q.Key + "=" + string(q.Value)
+	}
+
+	request := &PostmanRequest{Method: method, Header: []PostmanKeyValue{}, Url: u}
+	for _, kv := range headers.VarKVs {
+		request.Header = append(request.Header, PostmanKeyValue{Key: c.reference(kv.Key), Value: PostmanValue(c.reference(kv.Value))})
+	}
+	if raw := c.reference(body); raw != "" {
+		request.Body = &PostmanBody{Mode: "raw", Raw: raw}
+	}
+	c.Item = append(c.Item, PostmanItem{Name: name, Description: PostmanText(description), Request: request})
+}
+
+// reference writes a VarString with {{name}} references in place of its
+// placeholders, recording their defaults as collection variables
+func (c *PostmanCollection) reference(vs mystructs.VarString) string {
+	if vs.ParsedTemplate == "" && len(vs.Placeholders) == 0 {
+		return vs.OriginalString
+	}
+	for _, name := range vs.Placeholders {
+		value, ok := vs.Variables[name]
+		if !ok || c.hasVariable(name) {
+			continue
+		}
+		c.Variable = append(c.Variable, PostmanKeyValue{Key: name, Value: PostmanValue(value)})
+	}
+	return varStringRef.ReplaceAllString(vs.ParsedTemplate, "{{$1}}")
+}
+
+func (c *PostmanCollection) hasVariable(name string) bool {
+	for _, v := range c.Variable {
+		if v.Key == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Write writes the collection as indented JSON
+func (c *PostmanCollection) Write(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(c)
+}
@@ -0,0 +1,121 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+const testPostman = `{
+  "info": {"name": "Shop", "schema": "https://schema.getpostman.com/json/collection/v2.1.0/collection.json"},
+  "auth": {"type": "bearer", "bearer": [{"key": "token", "value": "{{token}}", "type": "string"}]},
+  "variable": [
+    {"key": "baseUrl", "value": "https://{{host}}/api"},
+    {"key": "host", "value": "shop.example.com"},
+    {"key": "page", "value": 1},
+    {"key": "token", "value": "collection-token"}
+  ],
+  "item": [
+    {
+      "name": "Items",
+      "variable": [{"key": "page", "value": "2"}],
+      "item": [
+        {
+          "name": "Get item",
+          "description": {"content": "One item", "type": "text/markdown"},
+          "request": {
+            "method": "GET",
+            "header": [
+              {"key": "Accept", "value": "application/json"},
+              {"key": "X-Debug", "value": "1", "disabled": true}
+            ],
+            "url": {
+              "raw": "{{baseUrl}}/items/:itemId?page={{page}}&sort={{sort}}",
+              "variable": [{"key": "itemId", "value": "42"}]
+            }
+          }
+        }
+      ]
+    },
+    {
+      "name": "Login",
+      "request": {
+        "auth": {"type": "noauth"},
+        "method": "post",
+        "header": [],
+        "body": {"mode": "urlencoded", "urlencoded": [
+          {"key": "user", "value": "a b"},
+          {"key": "pass", "value": "{{password}}"}
+        ]},
+        "url": {"protocol": "http", "host": ["{{host}}"], "path": ["login"]}
+      }
+    },
+    {
+      "name": "Create",
+      "request": {
+        "method": "POST",
+        "header": [],
+        "body": {"mode": "raw", "raw": "{\"name\": \"{{name}}\"}", "options": {"raw": {"language": "json"}}},
+        "url": "https://{{host}}/items"
+      }
+    }
+  ]
+}`
+
+const testPostmanEnvironment = `{"name": "staging", "values": [
+  {"key": "token", "value": "env-token", "enabled": true},
+  {"key": "password", "value": "hunter2", "enabled": true},
+  {"key": "name", "value": "unused", "enabled": false}
+]}`
+
+func TestPostmanCollection_Operations(t *testing.T) {
+	collection, err := ParsePostmanCollection(strings.NewReader(testPostman))
+	if err != nil {
+		t.Fatal(err)
+	}
+	env, err := ParsePostmanEnvironment(strings.NewReader(testPostmanEnvironment))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ops, err := collection.Operations(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ops) != 3 {
+		t.Fatalf("got %d operations", len(ops))
+	}
+
+	get := ops[0]
+	if get.Name != "Items / Get item" || get.Description != "One item" || get.Method != "GET" {
+		t.Errorf("get = %s %q %s", get.Name, get.Description, get.Method)
+	}
+	if get.Url != "https://shop.example.com/api/items/{itemId=42}?page={page=2}&sort={sort}" {
+		t.Errorf("url = %s", get.Url)
+	}
+	if len(get.Headers) != 2 || get.Headers[1].Key != "Authorization" || get.Headers[1].Value != "Bearer {token=env-token}" {
+		t.Errorf("headers = %v", get.Headers)
+	}
+
+	login := ops[1]
+	if login.Method != "POST" || login.Url != "http://shop.example.com/login" {
+		t.Errorf("login = %s %s", login.Method, login.Url)
+	}
+	if login.Body != "user=a+b&pass={password=hunter2}" || len(login.Headers) != 1 || login.Headers[0].Value != "application/x-www-form-urlencoded" {
+		t.Errorf("login body = %q headers = %v", login.Body, login.Headers)
+	}
+
+	create := ops[2]
+	if create.Body != `{"name": "{name}"}` || rawHeader(create.Headers, "Content-Type") != "application/json" {
+		t.Errorf("create body = %q headers = %v", create.Body, create.Headers)
+	}
+
+	if _, err := collection.Operations(nil); err != nil {
+		t.Fatal(err)
+	}
+	collection.Variable = nil
+	if _, err := collection.Operations(nil); err == nil {
+		t.Error("expected an error for a host variable without a value")
+	}
+	if _, err := ParsePostmanCollection(strings.NewReader(`{"info": {"schema": "https://schema.getpostman.com/json/collection/v1.0.0/collection.json"}}`)); err == nil {
+		t.Error("expected an error for a v1 collection")
+	}
+}
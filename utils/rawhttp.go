@@ -0,0 +1,188 @@
+package utils
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/linn221/bane/mystructs"
+)
+
+// RawRequest is an HTTP/1.x request as written on the wire. Headers keep
+// their order and case.
+type RawRequest struct {
+	Method  string
+	Target  string // the request target: a path, or an absolute URL for proxies
+	Proto   string
+	Headers []mystructs.KVPair
+	Body    string
+}
+
+// RawResponse is an HTTP/1.x response as written on the wire. The body is
+// de-chunked and decompressed.
+type RawResponse struct {
+	Proto   string
+	Status  int
+	Headers []mystructs.KVPair
+	Body    string
+}
+
+// splitRawMessage separates the start line, the header lines and the body.
+// Both CRLF and bare LF line endings are accepted.
+func splitRawMessage(raw string) (string, []mystructs.KVPair, string, error) {
+	head, body, found := strings.Cut(raw, "\r\n\r\n")
+	if !found {
+		head, body, _ = strings.Cut(raw, "\n\n")
+	}
+	lines := strings.Split(strings.ReplaceAll(head, "\r\n", "\n"), "\n")
+	startLine := strings.TrimSpace(lines[0])
+	if startLine == "" {
+		return "", nil, "", fmt.Errorf("empty HTTP message")
+	}
+	headers := make([]mystructs.KVPair, 0, len(lines)-1)
+	for _, line := range lines[1:] {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return "", nil, "", fmt.Errorf("invalid header line %q", line)
+		}
+		headers = append(headers, mystructs.KVPair{Key: strings.TrimSpace(name), Value: strings.TrimSpace(value)})
+	}
+	return startLine, headers, body, nil
+}
+
+// ParseRawRequest parses a request such as one copied from Burp or a proxy log
+func ParseRawRequest(raw string) (*RawRequest, error) {
+	startLine, headers, body, err := splitRawMessage(raw)
+	if err != nil {
+		return nil, err
+	}
+	parts := strings.Fields(startLine)
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("invalid request line %q", startLine)
+	}
+	req := &RawRequest{Method: strings.ToUpper(parts[0]), Target: parts[1], Proto: "HTTP/1.1", Headers: headers, Body: body}
+	if len(parts) > 2 {
+		req.Proto = parts[2]
+	}
+	return req, nil
+}
+
+// Header returns the first header value matching name, case-insensitively
+func (r *RawRequest) Header(name string) string {
+	return rawHeader(r.Headers, name)
+}
+
+// Url returns the absolute URL of the request. A relative target is joined
+// with the Host header, or with host when the request has none.
+func (r *RawRequest) Url(scheme string, host string) string {
+	if u, err := url.Parse(r.Target); err == nil && u.IsAbs() {
+		return r.Target
+	}
+	if h := r.Header("Host"); h != "" {
+		host = h
+	}
+	target := r.Target
+	if !strings.HasPrefix(target, "/") {
+		target = "/" + target
+	}
+	return scheme + "://" + host + target
+}
+
+// ReplayHeaders returns the headers to send the request to rawUrl again. On
+// top of what the package-level ReplayHeaders drops, the Host header is left
+// out when the URL already names the same host.
+func (r *RawRequest) ReplayHeaders(rawUrl string) []mystructs.KVPair {
+	headers := ReplayHeaders(r.Headers)
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return headers
+	}
+	kept := headers[:0]
+	for _, h := range headers {
+		if strings.EqualFold(h.Key, "Host") && strings.EqualFold(h.Value, u.Host) {
+			continue
+		}
+		kept = append(kept, h)
+	}
+	return kept
+}
+
+// ParseRawResponse parses a response as written on the wire
+func ParseRawResponse(raw string) (*RawResponse, error) {
+	startLine, headers, body, err := splitRawMessage(raw)
+	if err != nil {
+		return nil, err
+	}
+	parts := strings.Fields(startLine)
+	if len(parts) < 2 || !strings.HasPrefix(parts[0], "HTTP/") {
+		return nil, fmt.Errorf("invalid status line %q", startLine)
+	}
+	status, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid status code in %q", startLine)
+	}
+	resp := &RawResponse{Proto: parts[0], Status: status, Headers: headers, Body: body}
+
+	if strings.EqualFold(resp.Header("Transfer-Encoding"), "chunked") {
+		if decoded, err := io.ReadAll(httputil.NewChunkedReader(strings.NewReader(body))); err == nil {
+			resp.Body = string(decoded)
+		}
+	}
+	var reader io.ReadCloser
+	switch strings.ToLower(resp.Header("Content-Encoding")) {
+	case "gzip":
+		reader, err = gzip.NewReader(bytes.NewReader([]byte(resp.Body)))
+	case "deflate":
+		reader = flate.NewReader(bytes.NewReader([]byte(resp.Body)))
+	}
+	if reader != nil && err == nil {
+		if decoded, err := io.ReadAll(reader); err == nil {
+			resp.Body = string(decoded)
+		}
+		reader.Close()
+	}
+	return resp, nil
+}
+
+// Header returns the first header value matching name, case-insensitively
+func (r *RawResponse) Header(name string) string {
+	return rawHeader(r.Headers, name)
+}
+
+// replaySkippedHeaders are recomputed when a recorded request is sent again
+var replaySkippedHeaders = map[string]bool{
+	"content-length":    true,
+	"connection":        true,
+	"transfer-encoding": true,
+}
+
+// ReplayHeaders drops the headers of a recorded request that the client sets
+// itself when sending it again, including HTTP/2 pseudo-headers such as :authority
+func ReplayHeaders(headers []mystructs.KVPair) []mystructs.KVPair {
+	kept := make([]mystructs.KVPair, 0, len(headers))
+	for _, h := range headers {
+		if strings.HasPrefix(h.Key, ":") || replaySkippedHeaders[strings.ToLower(h.Key)] {
+			continue
+		}
+		kept = append(kept, h)
+	}
+	return kept
+}
+
+func rawHeader(headers []mystructs.KVPair, name string) string {
+	for _, h := range headers {
+		if strings.EqualFold(h.Key, name) {
+			return h.Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,65 @@
+package utils
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"testing"
+)
+
+func TestParseRawRequest(t *testing.T) {
+	req, err := ParseRawRequest("post /api/login?next=%2F HTTP/1.1\r\nHost: example.com:8443\r\nContent-Type: application/json\r\nContent-Length: 13\r\n\r\n{\"user\":\"a\"}\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Method != "POST" || req.Target != "/api/login?next=%2F" || req.Body != "{\"user\":\"a\"}\n" {
+		t.Errorf("request = %+v", req)
+	}
+	if got := req.Url("https", "ignored"); got != "https://example.com:8443/api/login?next=%2F" {
+		t.Errorf("Url = %q", got)
+	}
+	if got := req.ReplayHeaders("https://example.com:8443/api/login"); len(got) != 1 || got[0].Key != "Content-Type" {
+		t.Errorf("ReplayHeaders = %v", got)
+	}
+	if got := req.ReplayHeaders("https://10.0.0.1/api/login"); len(got) != 2 || got[0].Key != "Host" {
+		t.Errorf("ReplayHeaders for another host = %v", got)
+	}
+
+	req, err = ParseRawRequest("GET http://proxy.test/x HTTP/1.1\n\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := req.Url("https", "other"); got != "http://proxy.test/x" {
+		t.Errorf("absolute target Url = %q", got)
+	}
+	if _, err := ParseRawRequest("\r\n"); err == nil {
+		t.Error("expected an error for an empty request")
+	}
+}
+
+func TestParseRawResponse_ChunkedGzip(t *testing.T) {
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	w.Write([]byte("hello, world"))
+	w.Close()
+
+	var chunked bytes.Buffer
+	chunked.WriteString("5\r\n")
+	chunked.Write(gz.Bytes()[:5])
+	chunked.WriteString("\r\n")
+	rest := gz.Bytes()[5:]
+	fmt.Fprintf(&chunked, "%x\r\n", len(rest))
+	chunked.Write(rest)
+	chunked.WriteString("\r\n0\r\n\r\n")
+
+	resp, err := ParseRawResponse("HTTP/1.1 201 Created\r\nTransfer-Encoding: chunked\r\nContent-Encoding: gzip\r\n\r\n" + chunked.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Status != 201 || resp.Body != "hello, world" {
+		t.Errorf("status=%d body=%q", resp.Status, resp.Body)
+	}
+	if _, err := ParseRawResponse("GET / HTTP/1.1\r\n\r\n"); err == nil {
+		t.Error("expected an error for a request line")
+	}
+}
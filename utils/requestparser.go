@@ -0,0 +1,533 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/linn221/bane/mystructs"
+)
+
+// RequestFormat names the format a pasted request was written in
+type RequestFormat string
+
+const (
+	RequestFormatCurl       RequestFormat = "curl"
+	RequestFormatRaw        RequestFormat = "raw"
+	RequestFormatFetch      RequestFormat = "fetch"
+	RequestFormatPowerShell RequestFormat = "powershell"
+	RequestFormatUrl        RequestFormat = "url"
+)
+
+// PastedRequest is a request recovered from text copied out of a browser,
+// proxy or terminal, along with the format it was written in
+type PastedRequest struct {
+	*CurlRequest
+	Format RequestFormat
+}
+
+// rawRequestLine matches the request line of an HTTP/1.x request
+var rawRequestLine = regexp.MustCompile(`^[A-Z]+ \S+( HTTP/\d(\.\d)?)?$`)
+
+// powerShellInvoke matches the cmdlets "Copy as PowerShell" and people use
+var powerShellInvoke = regexp.MustCompile(`(?i)(^|[\s;(])(Invoke-WebRequest|Invoke-RestMethod|iwr|irm)\s`)
+
+// powerShellSkippedHeaders are the HTTP/2 pseudo-headers Chrome writes
+// without their colon when copying as PowerShell
+var powerShellSkippedHeaders = map[string]bool{"authority": true, "method": true, "path": true, "scheme": true}
+
+// ParseRequest detects the format of a pasted request and parses it: a curl
+// command, a raw HTTP/1.1 request, a browser "Copy as fetch" call, a "Copy as
+// PowerShell" Invoke-WebRequest command or a plain URL. scheme is used for raw
+// requests and URLs that do not name one.
+func ParseRequest(text string, scheme string) (*PastedRequest, error) {
+	text = strings.TrimSpace(text)
+	firstLine, _, _ := strings.Cut(text, "\n")
+	firstLine = strings.TrimSpace(firstLine)
+
+	var req *CurlRequest
+	var format RequestFormat
+	var err error
+	switch {
+	case text == "":
+		return nil, fmt.Errorf("nothing to import")
+	case strings.HasPrefix(text, "curl ") || strings.HasPrefix(text, "curl.exe "):
+		req, err = ParseCurl(strings.Replace(text, "curl.exe", "curl", 1))
+		format = RequestFormatCurl
+	case rawRequestLine.MatchString(firstLine):
+		req, err = parsePastedRaw(text, scheme)
+		format = RequestFormatRaw
+	case strings.Contains(text, "fetch("):
+		req, err = ParseFetch(text)
+		format = RequestFormatFetch
+	case powerShellInvoke.MatchString(text):
+		req, err = ParsePowerShell(text)
+		format = RequestFormatPowerShell
+	case !strings.ContainsAny(text, " \t\n"):
+		if !strings.Contains(text, "://") {
+			text = scheme + "://" + text
+		}
+		if u, err := url.Parse(text); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("not an http or https URL: %s", text)
+		}
+		req, format = &CurlRequest{Method: "GET", Url: text}, RequestFormatUrl
+	default:
+		return nil, fmt.Errorf("unrecognized request format: expected curl, raw HTTP, fetch, PowerShell or a URL")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &PastedRequest{CurlRequest: req, Format: format}, nil
+}
+
+// parsePastedRaw reads a raw HTTP request, taking the target from the Host
+// header unless the request line holds an absolute URL
+func parsePastedRaw(text string, scheme string) (*CurlRequest, error) {
+	raw, err := ParseRawRequest(text)
+	if err != nil {
+		return nil, err
+	}
+	if u, err := url.Parse(raw.Target); (err != nil || !u.IsAbs()) && raw.Header("Host") == "" {
+		return nil, fmt.Errorf("raw request has no Host header")
+	}
+	rawUrl := raw.Url(scheme, "")
+	return &CurlRequest{
+		Method:  raw.Method,
+		Url:     rawUrl,
+		Headers: raw.ReplayHeaders(rawUrl),
+		Body:    strings.TrimRight(raw.Body, "\r\n"),
+	}, nil
+}
+
+// ParseFetch parses a JavaScript fetch call as written by a browser's
+// "Copy as fetch" or "Copy as fetch (Node.js)"
+func ParseFetch(text string) (*CurlRequest, error) {
+	start := strings.Index(text, "fetch(")
+	rest := strings.TrimSpace(text[start+len("fetch("):])
+	rawUrl, n, err := readJsString(rest)
+	if err != nil {
+		return nil, fmt.Errorf("fetch url: %w", err)
+	}
+	req := &CurlRequest{Method: "GET", Url: rawUrl, FollowRedirects: true}
+
+	rest = strings.TrimSpace(rest[n:])
+	if !strings.HasPrefix(rest, ",") {
+		return req, nil
+	}
+	rest = strings.TrimSpace(rest[1:])
+	end := jsObjectEnd(rest)
+	if end < 0 {
+		return nil, fmt.Errorf("fetch options: unterminated object")
+	}
+	var options struct {
+		Headers  map[string]string `json:"headers"`
+		Body     *string           `json:"body"`
+		Method   string            `json:"method"`
+		Referrer string            `json:"referrer"`
+		Redirect string            `json:"redirect"`
+	}
+	if err := json.Unmarshal([]byte(jsObjectToJson(rest[:end])), &options); err != nil {
+		return nil, fmt.Errorf("fetch options: %w", err)
+	}
+
+	// JSON objects are unordered; keep the header order of the source
+	for _, name := range jsObjectKeys(rest[:end], "headers") {
+		if value, ok := options.Headers[name]; ok {
+			req.Headers = append(req.Headers, mystructs.KVPair{Key: name, Value: value})
+		}
+	}
+	if options.Referrer != "" && !hasHeader(req.Headers, "Referer") {
+		req.Headers = append(req.Headers, mystructs.KVPair{Key: "Referer", Value: options.Referrer})
+	}
+	if options.Method != "" {
+		req.Method = strings.ToUpper(options.Method)
+	}
+	if options.Body != nil {
+		req.Body = *options.Body
+	}
+	req.FollowRedirects = options.Redirect == "" || options.Redirect == "follow"
+	return req, nil
+}
+
+// ParsePowerShell parses an Invoke-WebRequest or Invoke-RestMethod command,
+// including the web session lines Chrome's "Copy as PowerShell" writes first
+func ParsePowerShell(text string) (*CurlRequest, error) {
+	text = strings.NewReplacer("`\r\n", " ", "`\n", " ").Replace(text)
+	req := &CurlRequest{FollowRedirects: true}
+	var userAgent string
+	var cookies []string
+
+	loc := powerShellInvoke.FindStringIndex(text)
+	for _, line := range strings.Split(text[:loc[0]], "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "$session.UserAgent"):
+			if _, value, ok := strings.Cut(line, "="); ok {
+				userAgent, _, _ = readPowerShellString(strings.TrimSpace(value))
+			}
+		case strings.HasPrefix(line, "$session.Cookies.Add("):
+			// New-Object System.Net.Cookie("name", "value", "/", "domain")
+			args := line[strings.Index(line, "Cookie(")+len("Cookie("):]
+			name, n, err := readPowerShellString(args)
+			if err != nil {
+				continue
+			}
+			args = strings.TrimLeft(args[n:], ", ")
+			value, _, err := readPowerShellString(args)
+			if err == nil {
+				cookies = append(cookies, name+"="+value)
+			}
+		}
+	}
+
+	tokens, err := powerShellTokens(text[loc[1]:])
+	if err != nil {
+		return nil, err
+	}
+	var contentType string
+	for i := 0; i < len(tokens); i++ {
+		name := strings.ToLower(tokens[i].text)
+		if tokens[i].quoted || !strings.HasPrefix(name, "-") {
+			if req.Url == "" {
+				req.Url = tokens[i].text
+			}
+			continue
+		}
+		value := func() powerShellToken {
+			if i+1 < len(tokens) {
+				i++
+				return tokens[i]
+			}
+			return powerShellToken{}
+		}
+		switch name {
+		case "-uri":
+			req.Url = value().text
+		case "-method":
+			req.Method = strings.ToUpper(value().text)
+		case "-headers":
+			for _, h := range value().table {
+				if !powerShellSkippedHeaders[strings.ToLower(h.Key)] {
+					req.Headers = append(req.Headers, h)
+				}
+			}
+		case "-contenttype":
+			contentType = value().text
+		case "-body":
+			req.Body = value().text
+		case "-useragent":
+			userAgent = value().text
+		case "-skipcertificatecheck":
+			req.Insecure = true
+		case "-maximumredirection":
+			req.FollowRedirects = value().text != "0"
+		case "-websession", "-outfile", "-timeoutsec", "-proxy", "-credential", "-sessionvariable":
+			value()
+		}
+	}
+	if req.Url == "" {
+		return nil, fmt.Errorf("no URL found in PowerShell command")
+	}
+
+	if userAgent != "" && !hasHeader(req.Headers, "User-Agent") {
+		req.Headers = append(req.Headers, mystructs.KVPair{Key: "User-Agent", Value: userAgent})
+	}
+	if len(cookies) > 0 && !hasHeader(req.Headers, "Cookie") {
+		req.Headers = append(req.Headers, mystructs.KVPair{Key: "Cookie", Value: strings.Join(cookies, "; ")})
+	}
+	if contentType != "" && !hasHeader(req.Headers, "Content-Type") {
+		req.Headers = append(req.Headers, mystructs.KVPair{Key: "Content-Type", Value: contentType})
+	}
+	req.Headers = ReplayHeaders(req.Headers)
+	if req.Method == "" {
+		req.Method = "GET"
+		if req.Body != "" {
+			req.Method = "POST"
+		}
+	}
+	return req, nil
+}
+
+// powerShellToken is a bare word, a string, or a @{} hashtable
+type powerShellToken struct {
+	text   string
+	quoted bool
+	table  []mystructs.KVPair
+}
+
+// powerShellTokens splits the arguments of a command, stopping at the end of
+// the statement
+func powerShellTokens(s string) ([]powerShellToken, error) {
+	var tokens []powerShellToken
+	for i := 0; i < len(s); {
+		switch c := s[i]; {
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case c == '\n' || c == ';':
+			return tokens, nil
+		case c == '"' || c == '\'':
+			text, n, err := readPowerShellString(s[i:])
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, powerShellToken{text: text, quoted: true})
+			i += n
+		case strings.HasPrefix(s[i:], "@{"):
+			table, n, err := readPowerShellTable(s[i:])
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, powerShellToken{table: table, quoted: true})
+			i += n
+		case c == '(':
+			// an expression such as ([System.Text.Encoding]::UTF8.GetBytes("...")):
+			// the first string inside it is the value
+			end := -1
+			for depth, j := 0, i; j < len(s) && end < 0; j++ {
+				switch s[j] {
+				case '"', '\'':
+					_, n, err := readPowerShellString(s[j:])
+					if err != nil {
+						return nil, err
+					}
+					j += n - 1
+				case '(':
+					depth++
+				case ')':
+					if depth--; depth == 0 {
+						end = j - i
+					}
+				}
+			}
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated expression in PowerShell command")
+			}
+			expr := s[i : i+end+1]
+			token := powerShellToken{quoted: true}
+			if q := strings.IndexAny(expr, `"'`); q >= 0 {
+				token.text, _, _ = readPowerShellString(expr[q:])
+			}
+			tokens = append(tokens, token)
+			i += end + 1
+		default:
+			end := strings.IndexAny(s[i:], " \t\r\n;")
+			if end < 0 {
+				end = len(s) - i
+			}
+			tokens = append(tokens, powerShellToken{text: s[i : i+end]})
+			i += end
+		}
+	}
+	return tokens, nil
+}
+
+// readPowerShellString reads a quoted string at the start of s, returning it
+// unescaped along with the number of bytes it took up
+func readPowerShellString(s string) (string, int, error) {
+	if s == "" || (s[0] != '"' && s[0] != '\'') {
+		return "", 0, fmt.Errorf("expected a quoted string")
+	}
+	quote := s[0]
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == quote && i+1 < len(s) && s[i+1] == quote:
+			// a doubled quote stands for itself
+			b.WriteByte(quote)
+			i++
+		case c == quote:
+			return b.String(), i + 1, nil
+		case c == '`' && quote == '"' && i+1 < len(s):
+			i++
+			switch s[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case '0':
+				b.WriteByte(0)
+			default:
+				b.WriteByte(s[i])
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated string in PowerShell command")
+}
+
+// readPowerShellTable reads a @{ "name"="value"; ... } hashtable of strings
+func readPowerShellTable(s string) ([]mystructs.KVPair, int, error) {
+	var table []mystructs.KVPair
+	i := len("@{")
+	for i < len(s) {
+		switch c := s[i]; {
+		case c == '}':
+			return table, i + 1, nil
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == ';':
+			i++
+		default:
+			var key string
+			if c == '"' || c == '\'' {
+				k, n, err := readPowerShellString(s[i:])
+				if err != nil {
+					return nil, 0, err
+				}
+				key, i = k, i+n
+			} else {
+				end := strings.IndexAny(s[i:], "= \t")
+				if end < 0 {
+					return nil, 0, fmt.Errorf("invalid hashtable entry in PowerShell command")
+				}
+				key, i = s[i:i+end], i+end
+			}
+			for i < len(s) && (s[i] == ' ' || s[i] == '\t') {
+				i++
+			}
+			if i >= len(s) || s[i] != '=' {
+				return nil, 0, fmt.Errorf("expected '=' after %q in PowerShell hashtable", key)
+			}
+			i++
+			for i < len(s) && (s[i] == ' ' || s[i] == '\t') {
+				i++
+			}
+			value, n, err := readPowerShellString(s[i:])
+			if err != nil {
+				return nil, 0, fmt.Errorf("hashtable value for %q: %w", key, err)
+			}
+			table = append(table, mystructs.KVPair{Key: key, Value: value})
+			i += n
+		}
+	}
+	return nil, 0, fmt.Errorf("unterminated hashtable in PowerShell command")
+}
+
+// readJsString reads a JavaScript string literal at the start of s, returning
+// it unescaped along with the number of bytes it took up
+func readJsString(s string) (string, int, error) {
+	if s == "" || !strings.ContainsRune("\"'`", rune(s[0])) {
+		return "", 0, fmt.Errorf("expected a string literal")
+	}
+	quote := s[0]
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case quote:
+			inner := s[1:i]
+			if quote != '"' {
+				inner = strings.ReplaceAll(strings.ReplaceAll(inner, `\`+string(quote), string(quote)), `"`, `\"`)
+			}
+			var value string
+			if err := json.Unmarshal([]byte(`"`+inner+`"`), &value); err != nil {
+				return "", 0, err
+			}
+			return value, i + 1, nil
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated string literal")
+}
+
+// jsObjectEnd returns the index just past the object literal at the start of
+// s, or -1 when it is not closed
+func jsObjectEnd(s string) int {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"', '\'', '`':
+			_, n, err := readJsString(s[i:])
+			if err != nil {
+				return -1
+			}
+			i += n - 1
+		case '{':
+			depth++
+		case '}':
+			if depth--; depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return -1
+}
+
+var (
+	jsUnquotedKey    = regexp.MustCompile(`([{,]\s*)([A-Za-z_$][\w$]*)\s*:`)
+	jsTrailingComma  = regexp.MustCompile(`,(\s*[}\]])`)
+	jsQuotedKeyValue = regexp.MustCompile(`"((?:[^"\\]|\\.)*)"\s*:`)
+)
+
+// jsObjectToJson makes the departures from JSON that hand-written fetch
+// calls have, such as unquoted keys, single-quoted strings and trailing commas,
+// acceptable to encoding/json
+func jsObjectToJson(object string) string {
+	if json.Valid([]byte(object)) {
+		return object
+	}
+	var b strings.Builder
+	last := 0
+	outside := func(end int) {
+		code := jsUnquotedKey.ReplaceAllString(object[last:end], `$1"$2":`)
+		b.WriteString(jsTrailingComma.ReplaceAllString(code, "$1"))
+	}
+	for i := 0; i < len(object); i++ {
+		if !strings.ContainsRune("\"'`", rune(object[i])) {
+			continue
+		}
+		value, n, err := readJsString(object[i:])
+		if err != nil {
+			break
+		}
+		outside(i)
+		quoted, _ := json.Marshal(value)
+		b.Write(quoted)
+		i += n - 1
+		last = i + 1
+	}
+	outside(len(object))
+	return b.String()
+}
+
+// jsObjectKeys returns the keys of the object held by field, in source order
+func jsObjectKeys(object string, field string) []string {
+	object = jsObjectToJson(object)
+	start := strings.Index(object, `"`+field+`"`)
+	if start < 0 {
+		return nil
+	}
+	open := strings.IndexByte(object[start:], '{')
+	if open < 0 {
+		return nil
+	}
+	inner := object[start+open:]
+	inner = inner[:max(jsObjectEnd(inner), 0)]
+
+	var keys []string
+	depth := 0
+	for i := 0; i < len(inner); i++ {
+		switch inner[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case '"':
+			loc := jsQuotedKeyValue.FindStringSubmatchIndex(inner[i:])
+			_, n, err := readJsString(inner[i:])
+			if err != nil {
+				return keys
+			}
+			if depth == 1 && loc != nil && loc[0] == 0 {
+				var key string
+				json.Unmarshal([]byte(`"`+inner[i+loc[2]:i+loc[3]]+`"`), &key)
+				keys = append(keys, key)
+			}
+			i += n - 1
+		}
+	}
+	return keys
+}
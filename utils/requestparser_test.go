@@ -0,0 +1,153 @@
+package utils
+
+import (
+	"testing"
+)
+
+func TestParseRequest_Formats(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		format  RequestFormat
+		method  string
+		url     string
+		headers map[string]string
+		body    string
+	}{
+		{
+			name:   "raw",
+			text:   "POST /api/login?next=%2F HTTP/1.1\r\nHost: shop.example.com\r\nContent-Type: application/json\r\nContent-Length: 13\r\n\r\n{\"user\":\"a\"}\r\n",
+			format: RequestFormatRaw,
+			method: "POST",
+			url:    "https://shop.example.com/api/login?next=%2F",
+			headers: map[string]string{
+				"Content-Type": "application/json",
+			},
+			body: `{"user":"a"}`,
+		},
+		{
+			name: "chrome fetch",
+			text: `fetch("https://shop.example.com/api/items", {
+  "headers": {
+    "accept": "application/json",
+    "x-csrf": "t\"k"
+  },
+  "referrer": "https://shop.example.com/",
+  "body": "{\"id\":1}",
+  "method": "POST",
+  "mode": "cors",
+  "credentials": "include"
+});`,
+			format: RequestFormatFetch,
+			method: "POST",
+			url:    "https://shop.example.com/api/items",
+			headers: map[string]string{
+				"accept":  "application/json",
+				"x-csrf":  `t"k`,
+				"Referer": "https://shop.example.com/",
+			},
+			body: `{"id":1}`,
+		},
+		{
+			name: "hand-written fetch",
+			text: `await fetch('https://shop.example.com/api/items?q=it\'s', {
+  method: 'DELETE',
+  headers: {Authorization: "Bearer abc",},
+});`,
+			format:  RequestFormatFetch,
+			method:  "DELETE",
+			url:     "https://shop.example.com/api/items?q=it's",
+			headers: map[string]string{"Authorization": "Bearer abc"},
+		},
+		{
+			name: "chrome powershell",
+			text: "$session = New-Object Microsoft.PowerShell.Commands.WebRequestSession\n" +
+				"$session.UserAgent = \"Mozilla/5.0\"\n" +
+				"$session.Cookies.Add((New-Object System.Net.Cookie(\"session\", \"abc\", \"/\", \"shop.example.com\")))\n" +
+				"Invoke-WebRequest -UseBasicParsing -Uri \"https://shop.example.com/api/items\" `\n" +
+				"-Method \"PUT\" `\n" +
+				"-WebSession $session `\n" +
+				"-Headers @{\n" +
+				"\"authority\"=\"shop.example.com\"\n" +
+				"  \"method\"=\"PUT\"\n" +
+				"  \"accept\"=\"*/*\"\n" +
+				"} `\n" +
+				"-ContentType \"application/json\" `\n" +
+				"-Body \"{`\"name`\":`\"it's`\"}\"",
+			format: RequestFormatPowerShell,
+			method: "PUT",
+			url:    "https://shop.example.com/api/items",
+			headers: map[string]string{
+				"accept":       "*/*",
+				"User-Agent":   "Mozilla/5.0",
+				"Cookie":       "session=abc",
+				"Content-Type": "application/json",
+			},
+			body: `{"name":"it's"}`,
+		},
+		{
+			name:   "powershell expression body",
+			text:   `iwr 'https://shop.example.com/x' -Method POST -Headers @{ 'X-A'='1'; Accept = "text/html" } -Body ([System.Text.Encoding]::UTF8.GetBytes("caf$([char]233)"))`,
+			format: RequestFormatPowerShell,
+			method: "POST",
+			url:    "https://shop.example.com/x",
+			headers: map[string]string{
+				"X-A":    "1",
+				"Accept": "text/html",
+			},
+			body: "caf$([char]233)",
+		},
+		{
+			name:   "curl",
+			text:   `curl 'https://shop.example.com/' -H 'X-A: 1'`,
+			format: RequestFormatCurl,
+			method: "GET",
+			url:    "https://shop.example.com/",
+			headers: map[string]string{
+				"X-A": "1",
+			},
+		},
+		{
+			name:   "url",
+			text:   "  shop.example.com/search?q=1\n",
+			format: RequestFormatUrl,
+			method: "GET",
+			url:    "https://shop.example.com/search?q=1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := ParseRequest(tt.text, "https")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if req.Format != tt.format || req.Method != tt.method || req.Url != tt.url || req.Body != tt.body {
+				t.Errorf("got %s %s %s body=%q", req.Format, req.Method, req.Url, req.Body)
+			}
+			if len(req.Headers) != len(tt.headers) {
+				t.Fatalf("headers = %v", req.Headers)
+			}
+			for _, h := range req.Headers {
+				if tt.headers[h.Key] != h.Value {
+					t.Errorf("header %s = %q, want %q", h.Key, h.Value, tt.headers[h.Key])
+				}
+			}
+		})
+	}
+}
+
+func TestParseRequest_Errors(t *testing.T) {
+	for _, text := range []string{
+		"",
+		"GET /no-host HTTP/1.1\r\nAccept: */*\r\n\r\n",
+		"ftp://example.com/file",
+		"this is not a request",
+		`fetch("https://example.com", {"headers": {`,
+		`Invoke-WebRequest -Uri "https://example.com`,
+	} {
+		if _, err := ParseRequest(text, "https"); err == nil {
+			t.Errorf("expected an error for %q", text)
+		}
+	}
+}
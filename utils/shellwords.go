@@ -0,0 +1,187 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// SplitShellWords splits a bash command line into words the way the shell would,
+// without expanding anything. It understands single and double quotes, ANSI-C
+// $'...' strings, backslash escapes and backslash-newline line continuations.
+func SplitShellWords(line string) ([]string, error) {
+	var words []string
+	var current strings.Builder
+	inWord := false
+
+	for i := 0; i < len(line); {
+		c := line[i]
+		switch {
+		case c == '\\':
+			if i+1 >= len(line) {
+				return nil, fmt.Errorf("unexpected trailing backslash")
+			}
+			next := line[i+1]
+			i += 2
+			if next == '\n' {
+				continue // line continuation
+			}
+			if next == '\r' && i < len(line) && line[i] == '\n' {
+				i++
+				continue
+			}
+			current.WriteByte(next)
+			inWord = true
+		case c == '\'':
+			end := strings.IndexByte(line[i+1:], '\'')
+			if end == -1 {
+				return nil, fmt.Errorf("unterminated single quote")
+			}
+			current.WriteString(line[i+1 : i+1+end])
+			i += end + 2
+			inWord = true
+		case c == '$' && i+1 < len(line) && line[i+1] == '\'':
+			s, n, err := readAnsiCString(line[i+2:])
+			if err != nil {
+				return nil, err
+			}
+			current.WriteString(s)
+			i += 2 + n
+			inWord = true
+		case c == '"':
+			s, n, err := readDoubleQuoted(line[i+1:])
+			if err != nil {
+				return nil, err
+			}
+			current.WriteString(s)
+			i += 1 + n
+			inWord = true
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			if inWord {
+				words = append(words, current.String())
+				current.Reset()
+				inWord = false
+			}
+			i++
+		default:
+			current.WriteByte(c)
+			inWord = true
+			i++
+		}
+	}
+	if inWord {
+		words = append(words, current.String())
+	}
+	return words, nil
+}
+
+// readDoubleQuoted reads up to the closing double quote. Inside double quotes a
+// backslash only escapes $ ` " \ and newline. Returns the value and the number
+// of bytes consumed including the closing quote.
+func readDoubleQuoted(s string) (string, int, error) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '"' {
+			return b.String(), i + 1, nil
+		}
+		if c == '\\' && i+1 < len(s) {
+			switch s[i+1] {
+			case '$', '`', '"', '\\':
+				b.WriteByte(s[i+1])
+				i++
+				continue
+			case '\n':
+				i++
+				continue
+			}
+		}
+		b.WriteByte(c)
+	}
+	return "", 0, fmt.Errorf("unterminated double quote")
+}
+
+// readAnsiCString reads the body of a $'...' string, decoding its escapes.
+// Returns the value and the number of bytes consumed including the closing quote.
+func readAnsiCString(s string) (string, int, error) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '\'' {
+			return b.String(), i + 1, nil
+		}
+		if c != '\\' || i+1 >= len(s) {
+			b.WriteByte(c)
+			continue
+		}
+		i++
+		switch e := s[i]; e {
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		case 'r':
+			b.WriteByte('\r')
+		case 'a':
+			b.WriteByte('\a')
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'v':
+			b.WriteByte('\v')
+		case 'e', 'E':
+			b.WriteByte(0x1b)
+		case '\\', '\'', '"', '?':
+			b.WriteByte(e)
+		case 'x':
+			n := countHexDigits(s[i+1:], 2)
+			if n == 0 {
+				b.WriteString(`\x`)
+				continue
+			}
+			v, _ := strconv.ParseUint(s[i+1:i+1+n], 16, 8)
+			b.WriteByte(byte(v))
+			i += n
+		case 'u', 'U':
+			max := 4
+			if e == 'U' {
+				max = 8
+			}
+			n := countHexDigits(s[i+1:], max)
+			if n == 0 {
+				b.WriteByte('\\')
+				b.WriteByte(e)
+				continue
+			}
+			v, _ := strconv.ParseUint(s[i+1:i+1+n], 16, 32)
+			r := rune(v)
+			if !utf8.ValidRune(r) {
+				r = utf8.RuneError
+			}
+			b.WriteRune(r)
+			i += n
+		case '0', '1', '2', '3', '4', '5', '6', '7':
+			n := 1
+			for n < 3 && i+n < len(s) && s[i+n] >= '0' && s[i+n] <= '7' {
+				n++
+			}
+			v, _ := strconv.ParseUint(s[i:i+n], 8, 8)
+			b.WriteByte(byte(v))
+			i += n - 1
+		default:
+			b.WriteByte('\\')
+			b.WriteByte(e)
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated $' quote")
+}
+
+func countHexDigits(s string, max int) int {
+	n := 0
+	for n < max && n < len(s) && strings.IndexByte("0123456789abcdefABCDEF", s[n]) != -1 {
+		n++
+	}
+	return n
+}
@@ -3,6 +3,7 @@ package utils
 import (
 	"fmt"
 	"net/url"
+	"regexp"
 	"strings"
 
 	"github.com/linn221/bane/mystructs"
@@ -59,9 +60,9 @@ func ParseHttpUrl(httpUrl mystructs.VarString) (*ParsedHttpUrl, error) {
 		return nil, fmt.Errorf("unsupported URL scheme '%s' (expected http or https)", scheme)
 	}
 
-	// Extract domain
-	httpDomain := parsedUrl.Hostname()
-	if httpDomain == "" {
+	// Extract domain, keeping a non-default port so the endpoint stays reachable
+	httpDomain := parsedUrl.Host
+	if parsedUrl.Hostname() == "" {
 		return nil, fmt.Errorf("missing domain in URL '%s'", executedUrl)
 	}
 
@@ -85,21 +86,18 @@ func ParseHttpUrl(httpUrl mystructs.VarString) (*ParsedHttpUrl, error) {
 	}
 
 	// Extract query parameters - convert to VarKVGroup
+	// The raw pairs are kept in order and as they were escaped, so the
+	// endpoint sends the query it was imported with
 	httpQueries := mystructs.VarKVGroup{VarKVs: []mystructs.VarKV{}}
-	queryValues := parsedUrl.Query()
-	
-	// Build VarKVGroup from query parameters
-	// We need to preserve the original VarString structure from the original URL
-	for key, values := range queryValues {
-		// Use the first value if multiple values exist
-		value := ""
-		if len(values) > 0 {
-			value = values[0]
+	for _, pair := range strings.Split(parsedUrl.RawQuery, "&") {
+		if pair == "" {
+			continue
 		}
-		
+		key, value, _ := strings.Cut(pair, "=")
+
 		// Try to extract the original VarString structure for this query param
-		keyVarString, valueVarString := extractQueryParamFromOriginalUrl(httpUrl, key, value)
-		
+		keyVarString, valueVarString := extractQueryParamFromOriginalUrl(httpUrl, EscapeQuery(key), EscapeQuery(value))
+
 		httpQueries.VarKVs = append(httpQueries.VarKVs, mystructs.VarKV{
 			Key:   keyVarString,
 			Value: valueVarString,
@@ -226,7 +224,7 @@ func extractQueryParamFromOriginalUrl(httpUrl mystructs.VarString, key, value st
 		
 		// Create VarStrings preserving structure
 		keyVar, _ := mystructs.NewVarString(key)
-		valueVar, err := mystructs.NewVarString(valueStr)
+		valueVar, err := mystructs.NewVarString(escapeQueryTemplate(valueStr))
 		if err != nil {
 			// Fallback to simple string
 			valueVar, _ = mystructs.NewVarString(value)
@@ -241,3 +239,39 @@ func extractQueryParamFromOriginalUrl(httpUrl mystructs.VarString, key, value st
 	return *keyVar, *valueVar
 }
 
+// queryPlaceholder matches a {name=default} or {name} placeholder
+var queryPlaceholder = regexp.MustCompile(`\{([a-zA-Z_][a-zA-Z0-9_]*)(=[^}]*)?\}`)
+
+// escapeQueryTemplate applies EscapeQuery to a query value with placeholders,
+// leaving the placeholders themselves intact
+func escapeQueryTemplate(s string) string {
+	var b strings.Builder
+	last := 0
+	for _, m := range queryPlaceholder.FindAllStringSubmatchIndex(s, -1) {
+		b.WriteString(EscapeQuery(s[last:m[0]]))
+		b.WriteString(s[m[0]:m[3]])
+		if m[4] >= 0 {
+			b.WriteString("=" + EscapeQuery(s[m[4]+1:m[5]]))
+		}
+		b.WriteString("}")
+		last = m[1]
+	}
+	b.WriteString(EscapeQuery(s[last:]))
+	return b.String()
+}
+
+// EscapeQuery percent-encodes the bytes a query key or value cannot carry as
+// they are: spaces, control characters, non-ASCII bytes and the #, & and =
+// that would end it early. Everything else, escapes included, is kept.
+func EscapeQuery(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c <= ' ' || c >= 0x7f || c == '#' || c == '&' || c == '=' {
+			fmt.Fprintf(&b, "%%%02X", c)
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
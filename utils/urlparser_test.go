@@ -0,0 +1,27 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/linn221/bane/mystructs"
+)
+
+func TestParseHttpUrl_KeepsRawQuery(t *testing.T) {
+	url, err := mystructs.NewVarString("https://example.com/a?z=1&next=%2F%2e%2e&a+b=c+d&token={token=abc}&raw=x y&d={d=a b}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseHttpUrl(*url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][2]string{{"z", "1"}, {"next", "%2F%2e%2e"}, {"a+b", "c+d"}, {"token", "{token=abc}"}, {"raw", "x%20y"}, {"d", "{d=a%20b}"}}
+	if len(parsed.HttpQueries.VarKVs) != len(want) {
+		t.Fatalf("got %d queries, want %d", len(parsed.HttpQueries.VarKVs), len(want))
+	}
+	for i, kv := range parsed.HttpQueries.VarKVs {
+		if got := [2]string{kv.Key.OriginalString, kv.Value.OriginalString}; got != want[i] {
+			t.Errorf("query %d = %q, want %q", i, got, want[i])
+		}
+	}
+}